@@ -0,0 +1,295 @@
+// Package batch runs a matrix of acct runs one after the other (batch
+// command), each one a generator process of its own named after the run
+// (--instance-name) so its log and final report land on the report dir,
+// with cool-down gaps between them, and compares their reports.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/instance"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// options set by the batch on every run
+var reserved = []string{"instance-name", "instance-dir", "daemon", "d", "pid-file", "log-file", "interactive", "resume"}
+
+// a run of the batch, its acct options by flag name over the batch ones
+type Run struct {
+	Name string `yaml:"name"`
+	// wait after the run, the batch one when empty
+	Cooldown string            `yaml:"cooldown"`
+	Options  map[string]string `yaml:"options"`
+}
+
+// batch file format: the options of every run, the runs and the cool-down
+// between them
+//
+//	cooldown: 60s
+//	options:
+//	  server: 10.0.0.1:1813
+//	  key: s3cr3t
+//	runs:
+//	  - name: 500pps
+//	    options:
+//	      pps: 500
+//	      max-req: 100000
+//	  - name: 1000pps
+//	    cooldown: 5m
+//	    options:
+//	      pps: 1000
+//	      max-req: 200000
+type File struct {
+	Cooldown string            `yaml:"cooldown"`
+	Options  map[string]string `yaml:"options"`
+	Runs     []Run             `yaml:"runs"`
+}
+
+// read and check a batch file
+func Load(name string) (*File, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(f.Runs) <= 0 {
+		return nil, fmt.Errorf("%s: no runs", name)
+	}
+	if _, err := cooldown(f.Cooldown); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if err := checkOptions(f.Options); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	names := make(map[string]bool)
+	for _, r := range f.Runs {
+		if !instance.Valid(r.Name) {
+			return nil, fmt.Errorf("%s: run name %q must have only letters, digits, _ . and -", name, r.Name)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("%s: run %s given twice", name, r.Name)
+		}
+		names[r.Name] = true
+		if _, err := cooldown(r.Cooldown); err != nil {
+			return nil, fmt.Errorf("%s: run %s: %v", name, r.Name, err)
+		}
+		if err := checkOptions(r.Options); err != nil {
+			return nil, fmt.Errorf("%s: run %s: %v", name, r.Name, err)
+		}
+	}
+	return &f, nil
+}
+
+func cooldown(s string) (time.Duration, error) {
+	if len(s) <= 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("cooldown %q must be a duration", s)
+	}
+	return d, nil
+}
+
+func checkOptions(options map[string]string) error {
+	for _, flag := range reserved {
+		if _, ok := options[flag]; ok {
+			return fmt.Errorf("%s is set by the batch", flag)
+		}
+	}
+	return nil
+}
+
+// wait after r, the batch cool-down when it has none
+func (f *File) cooldown(r Run) time.Duration {
+	if len(r.Cooldown) > 0 {
+		d, _ := cooldown(r.Cooldown)
+		return d
+	}
+	d, _ := cooldown(f.Cooldown)
+	return d
+}
+
+// acct arguments of r: the batch options with the run ones over them,
+// sorted by flag; true is a bool flag set and false one not set
+func (f *File) Args(r Run) []string {
+	options := make(map[string]string)
+	for k, v := range f.Options {
+		options[k] = v
+	}
+	for k, v := range r.Options {
+		options[k] = v
+	}
+	flags := make([]string, 0, len(options))
+	for k := range options {
+		flags = append(flags, k)
+	}
+	sort.Strings(flags)
+	var args []string
+	for _, k := range flags {
+		switch v := options[k]; v {
+		case "true":
+			args = append(args, "--"+k)
+		case "false":
+		default:
+			args = append(args, "--"+k+"="+v)
+		}
+	}
+	return args
+}
+
+// outcome of a run
+type Result struct {
+	Name    string
+	Started time.Time
+	// exit error of the run, nil when it exited 0
+	Err error
+	// final report, nil when the run wrote none
+	Stats *control.Stats
+}
+
+// run each run of f with the generator binary self, one after the other
+// with their cool-downs, the reports on dir; once ctx is done the run in
+// progress is stopped (SIGTERM) and no other starts. The runs write their
+// output to out
+func (f *File) Execute(ctx context.Context, self, dir string, out io.Writer) []Result {
+	var results []Result
+	for i, r := range f.Runs {
+		if ctx.Err() != nil {
+			break
+		}
+		if i > 0 {
+			if d := f.cooldown(f.Runs[i-1]); d > 0 {
+				fmt.Fprintf(out, "batch: cooling down %s before %s\n", d, r.Name)
+				select {
+				case <-time.After(d):
+				case <-ctx.Done():
+					return results
+				}
+			}
+		}
+		fmt.Fprintf(out, "batch: run %d of %d: %s\n", i+1, len(f.Runs), r.Name)
+		results = append(results, f.execute(ctx, r, self, dir, out))
+	}
+	return results
+}
+
+func (f *File) execute(ctx context.Context, r Run, self, dir string, out io.Writer) Result {
+	res := Result{Name: r.Name, Started: time.Now()}
+	path := instance.StatsFile(dir, r.Name)
+	// no report of a previous batch taken for this run's
+	os.Remove(path)
+	args := append([]string{"acct", "--instance-name=" + r.Name, "--instance-dir=" + dir}, f.Args(r)...)
+	cmd := exec.Command(self, args...)
+	cmd.Stdout, cmd.Stderr = out, out
+	if res.Err = cmd.Start(); res.Err != nil {
+		return res
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case res.Err = <-exited:
+	case <-ctx.Done():
+		// graceful stop, the run still writes its report
+		cmd.Process.Signal(syscall.SIGTERM)
+		res.Err = <-exited
+	}
+	if list, err := instance.List(dir); err == nil {
+		for _, i := range list {
+			if i.Name == r.Name {
+				s := i.Stats
+				res.Stats = &s
+			}
+		}
+	}
+	return res
+}
+
+// requests answered and their average latency in ms over the targets
+func answered(s *control.Stats) (uint64, float64) {
+	var acked uint64
+	var sum float64
+	for _, t := range s.Targets {
+		acked += t.Acked
+		sum += t.AvgLatencyMs * float64(t.Acked)
+	}
+	if acked <= 0 {
+		return 0, 0
+	}
+	return acked, sum / float64(acked)
+}
+
+// true when every run exited 0
+func OK(results []Result) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// comparison table of the runs
+func Fprint(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tRESULT\tELAPSED\tTOTAL\tANSWERED\tUNANSWERED\tPPS\tAVG LATENCY\tREJECTS")
+	for _, r := range results {
+		result := "ok"
+		if r.Err != nil {
+			result = r.Err.Error()
+		}
+		if r.Stats == nil {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\t-\t-\n", r.Name, result)
+			continue
+		}
+		s := r.Stats
+		acked, latency := answered(s)
+		unanswered, pps := 0.0, 0.0
+		if s.Total > 0 && acked <= s.Total {
+			unanswered = float64(s.Total-acked) / float64(s.Total) * 100
+		}
+		if s.Elapsed > 0 {
+			pps = float64(s.Total) / s.Elapsed
+		}
+		var rejects uint64
+		for _, n := range s.Rejects {
+			rejects += n
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.0fs\t%d\t%d\t%.2f%%\t%.1f\t%.2fms\t%d\n", r.Name, result, s.Elapsed, s.Total, acked, unanswered, pps, latency, rejects)
+	}
+	tw.Flush()
+}
+
+// the runs and their reports, for the batch report file
+type Report struct {
+	Name    string         `json:"name"`
+	Started time.Time      `json:"started"`
+	Error   string         `json:"error,omitempty"`
+	Stats   *control.Stats `json:"stats,omitempty"`
+}
+
+func Reports(results []Result) []Report {
+	reports := make([]Report, 0, len(results))
+	for _, r := range results {
+		rep := Report{Name: r.Name, Started: r.Started, Stats: r.Stats}
+		if r.Err != nil {
+			rep.Error = strings.TrimSpace(r.Err.Error())
+		}
+		reports = append(reports, rep)
+	}
+	return reports
+}
@@ -1,9 +1,15 @@
 #!/usr/bin/env bash
 
-pGOOS=(linux freebsd)
+pGOOS=(linux freebsd windows)
 GOARCH=amd64 
-GOBUILDVERSION="$(go run go-radius-gen-acct.go -v 2> /dev/null | grep -Eo '[0-9]+\.[0-9]+\.[0-9]+')"
+GOBUILDVERSION="$(go run go-radius-gen-acct.go -v 2> /dev/null | head -1 | grep -Eo '[0-9]+\.[0-9]+\.[0-9]+')"
+# commit and date of the build in --version and the reports, see ./buildinfo
+BUILDINFO="github.com/routecall/go-radius-gen-acct/buildinfo"
+LDFLAGS="-X $BUILDINFO.Commit=$(git rev-parse --short HEAD 2> /dev/null || echo unknown) -X $BUILDINFO.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
 
 for GOOS in "${pGOOS[@]}"; do
-  GOOS="$GOOS" GOARCH="$GOARCH" go build -o go-radius-gen-acct-"$GOBUILDVERSION"-"$GOOS"-"$GOARCH"
+  EXT=""
+  # no --daemon on windows, see ./daemonize
+  [ "$GOOS" == "windows" ] && EXT=".exe"
+  GOOS="$GOOS" GOARCH="$GOARCH" go build -ldflags "$LDFLAGS" -o go-radius-gen-acct-"$GOBUILDVERSION"-"$GOOS"-"$GOARCH""$EXT"
 done
@@ -0,0 +1,88 @@
+// Package buildinfo describes the binary, for --version and the reports:
+// its version, the commit and date it was built from (set by build.sh)
+// and the optional features built in, so the results of a run are
+// traceable to the binary which produced them.
+package buildinfo
+
+import (
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/routecall/go-radius-gen-acct/daemonize"
+)
+
+const Version = "0.12.3"
+
+// set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/routecall/go-radius-gen-acct/buildinfo.Commit=$(git rev-parse --short HEAD)"
+var (
+	Commit = "unknown"
+	Date   = "unknown"
+)
+
+// the binary and its features
+type Info struct {
+	Version  string   `json:"version"`
+	Commit   string   `json:"commit"`
+	Date     string   `json:"date"`
+	Go       string   `json:"go"`
+	Platform string   `json:"platform"`
+	Features []string `json:"features"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// the Info of the binary, the features as registered once the packages
+// are initialized
+func Get() *Info {
+	once.Do(func() {
+		info = Info{
+			Version:  Version,
+			Commit:   Commit,
+			Date:     Date,
+			Go:       runtime.Version(),
+			Platform: runtime.GOOS + "/" + runtime.GOARCH,
+			Features: features(),
+		}
+	})
+	return &info
+}
+
+// radsec, grpc and the lua scripts are always built in, the rest depend
+// on the platform and cgo
+func features() []string {
+	f := []string{"radsec-tls", "grpc", "lua"}
+	if daemonize.Supported {
+		f = append(f, "daemon")
+	}
+	if cgo {
+		f = append(f, "cgo")
+		switch runtime.GOOS {
+		case "linux", "freebsd", "darwin":
+			f = append(f, "plugins")
+		}
+	}
+	for _, d := range sql.Drivers() {
+		// registered without cgo too, failing to open
+		if d == "sqlite3" && !cgo {
+			continue
+		}
+		f = append(f, "db:"+d)
+	}
+	sort.Strings(f)
+	return f
+}
+
+// the version line and the build lines of --version
+func (i *Info) String() string {
+	return fmt.Sprintf("%s\ncommit:   %s\nbuilt:    %s\ngo:       %s %s\nfeatures: %s",
+		i.Version, i.Commit, i.Date, i.Go, i.Platform, strings.Join(i.Features, " "))
+}
@@ -0,0 +1,7 @@
+//go:build cgo
+// +build cgo
+
+package buildinfo
+
+// built with cgo, which the sqlite3 results db and the Go plugins need
+const cgo = true
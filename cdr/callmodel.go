@@ -0,0 +1,115 @@
+package cdr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// call phase distributions, around the phase mean as the mock server
+// --delay-dist
+const (
+	Fixed       = "fixed"
+	Uniform     = "uniform"
+	Exponential = "exponential"
+	Normal      = "normal"
+)
+
+// defaults of the phases not given to NewCallModel
+const (
+	DefaultSetup = "normal:2s"
+	DefaultRing  = "exponential:8s"
+	DefaultTalk  = "exponential:120s"
+)
+
+// duration of a call phase
+type Phase struct {
+	Dist string
+	Mean time.Duration
+}
+
+// parse "dist:mean" (e.g. "exponential:90s"), a bare duration is fixed
+func ParsePhase(s string) (Phase, error) {
+	p := Phase{Dist: Fixed}
+	mean := s
+	if i := strings.Index(s, ":"); i >= 0 {
+		p.Dist, mean = s[:i], s[i+1:]
+	}
+	switch p.Dist {
+	case Fixed, Uniform, Exponential, Normal:
+	default:
+		return p, fmt.Errorf("%q: distribution must be fixed, uniform, exponential or normal", s)
+	}
+	d, err := time.ParseDuration(mean)
+	if err != nil || d < 0 {
+		return p, fmt.Errorf("%q: invalid mean duration", s)
+	}
+	p.Mean = d
+	return p, nil
+}
+
+// draw a duration of the phase
+func (p Phase) Sample(r *rand.Rand) time.Duration {
+	mean := float64(p.Mean)
+	var d float64
+	switch p.Dist {
+	case Uniform:
+		d = r.Float64() * 2 * mean
+	case Exponential:
+		d = r.ExpFloat64() * mean
+	case Normal:
+		// standard deviation of a quarter of the mean
+		d = math.Max(0, r.NormFloat64()*mean/4+mean)
+	default:
+		d = mean
+	}
+	return time.Duration(d)
+}
+
+// phases of a synthetic call: the INVITE until ringing, ringing until the
+// answer and the talk until the BYE
+type CallModel struct {
+	Setup Phase
+	Ring  Phase
+	Talk  Phase
+}
+
+// model of the setup, ring and talk phases, empty ones get the defaults;
+// nil when all are empty, keeping the plain random timers
+func NewCallModel(setup, ring, talk string) (*CallModel, error) {
+	if len(setup) <= 0 && len(ring) <= 0 && len(talk) <= 0 {
+		return nil, nil
+	}
+	m := &CallModel{}
+	for _, p := range []struct {
+		spec, def string
+		phase     *Phase
+	}{
+		{setup, DefaultSetup, &m.Setup},
+		{ring, DefaultRing, &m.Ring},
+		{talk, DefaultTalk, &m.Talk},
+	} {
+		if len(p.spec) <= 0 {
+			p.spec = p.def
+		}
+		phase, err := ParsePhase(p.spec)
+		if err != nil {
+			return nil, err
+		}
+		*p.phase = phase
+	}
+	return m, nil
+}
+
+// ms_duration and setuptime (seconds until the answer, or until the
+// failure) of a call ending with sip code c
+func (m *CallModel) Timers(r *rand.Rand, c int) (int, int) {
+	setup := m.Setup.Sample(r) + m.Ring.Sample(r)
+	st := int((setup + time.Second/2) / time.Second)
+	if c != 200 {
+		return 0, st
+	}
+	return int(m.Talk.Sample(r) / time.Millisecond), st
+}
@@ -0,0 +1,105 @@
+package cdr
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// generated values with a cardinality limit (see ParseCardinality)
+const (
+	// caller number, the Calling-Station-Id and the From URI
+	Caller = "caller"
+	// destination number, the Called-Station-Id and the To URI
+	Callee = "callee"
+	// addresses of the caller side and the callee side (the B2BUA of the
+	// B-legs)
+	SrcIP = "src-ip"
+	DstIP = "dst-ip"
+)
+
+// draws to find a value not in the pool yet before taking a repeated one
+const maxDraws = 100
+
+// distinct values an attribute takes over a run, by attribute
+type Cardinality map[string]*ValuePool
+
+// parse "caller=10000,callee=500", empty for none
+func ParseCardinality(s string) (Cardinality, error) {
+	c := make(Cardinality)
+	for _, item := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(item)) <= 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		attr := strings.ToLower(strings.TrimSpace(kv[0]))
+		switch attr {
+		case Caller, Callee, SrcIP, DstIP:
+		default:
+			return nil, fmt.Errorf("unknown attribute %q, must be %s, %s, %s or %s", kv[0], Caller, Callee, SrcIP, DstIP)
+		}
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s: missing the number of values", attr)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s: number of values must be greater 0", attr)
+		}
+		c[attr] = &ValuePool{Size: n, total: n, seen: make(map[string]bool)}
+	}
+	return c, nil
+}
+
+// the Size distinct values of an attribute: the first Size draws make new
+// ones, the next draws pick one of them at random, so a run of n calls
+// sees min(n, Size) of them
+type ValuePool struct {
+	Size int
+	// Size over the workers of a shard
+	total  int
+	values []string
+	seen   map[string]bool
+}
+
+// value of attr, v when it has no limit; nth makes the i-th value of the
+// pool
+func (c Cardinality) value(r *rand.Rand, attr string, v string, nth func(i int) string) string {
+	p := c[attr]
+	if p == nil {
+		return v
+	}
+	return p.pick(r, nth)
+}
+
+func (p *ValuePool) pick(r *rand.Rand, nth func(i int) string) string {
+	if len(p.values) >= p.Size {
+		return p.values[r.Intn(len(p.values))]
+	}
+	var v string
+	for i := 0; i < maxDraws; i++ {
+		if v = nth(len(p.values)); !p.seen[v] {
+			break
+		}
+	}
+	p.seen[v] = true
+	p.values = append(p.values, v)
+	return v
+}
+
+// i-th address of a pool: the default ones first, then 10.0.0.0/8 ones
+func nthAddress(defaults []string) func(i int) string {
+	return func(i int) string {
+		if i < len(defaults) {
+			return defaults[i]
+		}
+		i -= len(defaults) - 1
+		return fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+}
+
+func randomNumber(r *rand.Rand) func(int) string {
+	return func(int) string {
+		return PhoneNumberBrazil(r)
+	}
+}
@@ -0,0 +1,86 @@
+package cdr
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// RFC 2866 Acct-Terminate-Cause values of the data sessions
+const (
+	TerminateUserRequest    = 1
+	TerminateLostCarrier    = 2
+	TerminateIdleTimeout    = 4
+	TerminateSessionTimeout = 5
+)
+
+// access points the data sessions spread over, and their SSID
+const (
+	accessPoints = 64
+	ssid         = "radgen"
+)
+
+// a subscriber data session (Options.Data) instead of a call: the talk
+// time is the session time, and the session is answered so its lifecycle
+// has the Start, Interims and Stop of a call
+func fillData(o *Options) *CdrValues {
+	rnd := o.rnd()
+	var ms int
+	if o.Model != nil {
+		ms, _ = o.Model.Timers(rnd, 200)
+	} else {
+		ms, _ = CdrTimers(rnd, 200)
+	}
+	id := fmt.Sprintf("%016X", o.Shard.Uint64(rnd.Uint64()))
+	c := &CdrValues{
+		AcctStatusType: StatusStop,
+		ResponseCode:   "200",
+		Method:         "INVITE",
+		EventTimestamp: o.now(),
+		AcctSessionId:  id,
+		CallId:         id,
+		MsDuration:     ms,
+		UserName:       o.Cardinality.value(rnd, Caller, o.caller(0), o.caller),
+		FramedIP:       o.Cardinality.value(rnd, SrcIP, cgnatAddress(rnd.Intn(1<<22)), cgnatAddress),
+		CallingStation: mac(rnd.Uint64()),
+		CalledStation:  mac(0x020000000000|uint64(rnd.Intn(accessPoints))) + ":" + ssid,
+		TerminateCause: terminateCause(rnd),
+	}
+	// the whole session on a single Stop, the lifecycle records count
+	// their own octets
+	c.OutputOctets = int(dataRate(rnd) * float64(ms) / 1000)
+	c.InputOctets = int(float64(c.OutputOctets) * uploadShare(rnd))
+	return c
+}
+
+// i-th address of the 100.64.0.0/10 shared address space
+func cgnatAddress(i int) string {
+	i &= 1<<22 - 1
+	return fmt.Sprintf("100.%d.%d.%d", 64+i>>16, i>>8&0xff, i&0xff)
+}
+
+// RFC 3580 format of the low 48 bits of v
+func mac(v uint64) string {
+	return fmt.Sprintf("%02X-%02X-%02X-%02X-%02X-%02X", byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// download bytes per second of a session, 10kB/s to 1MB/s
+func dataRate(r *rand.Rand) float64 {
+	return 10000 + r.Float64()*990000
+}
+
+// upload bytes per downloaded byte
+func uploadShare(r *rand.Rand) float64 {
+	return 0.05 + r.Float64()/5
+}
+
+func terminateCause(r *rand.Rand) int {
+	switch p := r.Float64(); {
+	case p < 0.5:
+		return TerminateUserRequest
+	case p < 0.8:
+		return TerminateIdleTimeout
+	case p < 0.9:
+		return TerminateSessionTimeout
+	}
+	return TerminateLostCarrier
+}
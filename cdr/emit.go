@@ -0,0 +1,81 @@
+package cdr
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// a record as written by JSONWriter, keyed by the attribute each field is
+// sent as so the json source maps it back without --map
+type emittedRecord struct {
+	AcctStatusType string `json:"Acct-Status-Type"`
+	AcctSessionId  string `json:"Acct-Session-Id"`
+	CallId         string `json:"Sip-Call-Id"`
+	Method         string `json:"Sip-Method"`
+	ResponseCode   string `json:"Sip-Response-Code"`
+	EventTimestamp string `json:"Event-Timestamp"`
+	FromTag        string `json:"Sip-From-Tag"`
+	ToTag          string `json:"Sip-To-Tag"`
+	MsDuration     int    `json:"Sip-Call-MSDuration"`
+	SetupTime      int    `json:"Sip-Call-Setuptime"`
+	CallerId       string `json:"Sip-Caller-Id"`
+	CalleeId       string `json:"Sip-Callee-Id"`
+	DstNumber      string `json:"Sip-Dst-Number"`
+	UserName       string `json:"User-Name,omitempty"`
+	InputOctets    int    `json:"Acct-Input-Octets,omitempty"`
+	OutputOctets   int    `json:"Acct-Output-Octets,omitempty"`
+	FramedIP       string `json:"Framed-IP-Address,omitempty"`
+	CallingStation string `json:"Calling-Station-Id,omitempty"`
+	CalledStation  string `json:"Called-Station-Id,omitempty"`
+	TerminateCause int    `json:"Acct-Terminate-Cause,omitempty"`
+}
+
+var statusTypes = map[int]string{
+	StatusStart:   "Start",
+	StatusStop:    "Stop",
+	StatusInterim: "Interim-Update",
+}
+
+// writes records as JSON, one object per line, the format the json source
+// reads: a run (--source json:-) or any transformer in between can take
+// them from a pipe. Flush after the last one
+type JSONWriter struct {
+	w *bufio.Writer
+	e *json.Encoder
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONWriter{w: bw, e: json.NewEncoder(bw)}
+}
+
+func (j *JSONWriter) Write(c *CdrValues) error {
+	return j.e.Encode(emittedRecord{
+		AcctStatusType: statusTypes[c.AcctStatusType],
+		AcctSessionId:  c.AcctSessionId,
+		CallId:         c.CallId,
+		Method:         c.Method,
+		ResponseCode:   c.ResponseCode,
+		EventTimestamp: c.EventTimestamp.Format(time.RFC3339Nano),
+		FromTag:        c.FromTag,
+		ToTag:          c.ToTag,
+		MsDuration:     c.MsDuration,
+		SetupTime:      c.SetupTime,
+		CallerId:       c.CallerId,
+		CalleeId:       c.CalleeId,
+		DstNumber:      c.DstNumber,
+		UserName:       c.UserName,
+		InputOctets:    c.InputOctets,
+		OutputOctets:   c.OutputOctets,
+		FramedIP:       c.FramedIP,
+		CallingStation: c.CallingStation,
+		CalledStation:  c.CalledStation,
+		TerminateCause: c.TerminateCause,
+	})
+}
+
+func (j *JSONWriter) Flush() error {
+	return j.w.Flush()
+}
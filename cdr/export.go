@@ -0,0 +1,286 @@
+package cdr
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sources of the calls of a CDR export, mapped to the attributes by
+// Options.Mapping: --source csv:file, json:file; the file - is stdin
+const (
+	CSVSource  = "csv"
+	JSONSource = "json"
+)
+
+// calls of a CSV or JSON export, one per record, with their columns
+// mapped to the cdr fields (see Mapping); the fields without a column are
+// generated as without the export
+type ExportReader struct {
+	name string
+	// next record by column, io.EOF after the last one
+	next   func() (map[string]string, error)
+	o      *Options
+	fields map[string]*mappedField
+	// columns of the first record, to tell the mapping
+	columns []string
+	first   map[string]string
+	record  int
+}
+
+func newExportReader(name string, next func() (map[string]string, error), o *Options) (*ExportReader, error) {
+	e := &ExportReader{name: name, next: next, o: o, fields: make(map[string]*mappedField)}
+	first, err := next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: no records", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	e.first = first
+	mapped := 0
+	for column := range first {
+		e.columns = append(e.columns, column)
+		if e.field(column) != nil {
+			mapped++
+		}
+	}
+	sort.Strings(e.columns)
+	if mapped <= 0 {
+		return nil, fmt.Errorf("%s: no column of %s mapped, use --map column=Attribute", name, strings.Join(e.columns, ", "))
+	}
+	return e, nil
+}
+
+func (e *ExportReader) field(column string) *mappedField {
+	f, ok := e.fields[column]
+	if !ok {
+		f = e.o.Mapping.field(column)
+		e.fields[column] = f
+	}
+	return f
+}
+
+// the columns of the first record with their attribute, and the ones not
+// mapped
+func (e *ExportReader) Columns() (map[string]string, []string) {
+	mapped := make(map[string]string)
+	var unmapped []string
+	for _, column := range e.columns {
+		if f := e.field(column); f != nil {
+			mapped[column] = f.attr
+		} else {
+			unmapped = append(unmapped, column)
+		}
+	}
+	return mapped, unmapped
+}
+
+func (e *ExportReader) read() (map[string]string, error) {
+	e.record++
+	if e.first != nil {
+		rec := e.first
+		e.first = nil
+		return rec, nil
+	}
+	return e.next()
+}
+
+// skip n calls, to resume a replay
+func (e *ExportReader) Skip(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := e.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cdr of the next call, io.EOF after the last one
+func (e *ExportReader) Next() (*CdrValues, error) {
+	rec, err := e.read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: record %d: %v", e.name, e.record, err)
+	}
+	c := FillCdrWith(e.o)
+	set := make(map[string]bool)
+	for _, column := range sortedColumns(rec) {
+		f := e.field(column)
+		v := strings.TrimSpace(rec[column])
+		if f == nil || len(v) <= 0 {
+			continue
+		}
+		if err := f.set(c, v); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %s: %v", e.name, e.record, column, err)
+		}
+		set[f.attr] = true
+	}
+	// a single id is both, as on the SIPp CSV
+	switch {
+	case set["Sip-Call-Id"] && !set["Acct-Session-Id"] && !set["Sip-Acct-Session-Id"]:
+		c.AcctSessionId = c.CallId
+	case !set["Sip-Call-Id"] && (set["Acct-Session-Id"] || set["Sip-Acct-Session-Id"]):
+		c.CallId = c.AcctSessionId
+	}
+	if set["Sip-Response-Code"] && c.ResponseCode != "200" {
+		// never answered, no dialog
+		c.ToTag = ""
+		c.MsDuration = 0
+	}
+	return c, nil
+}
+
+// the columns of rec in order, so a column mapped twice sets the same value
+// on every record
+func sortedColumns(rec map[string]string) []string {
+	columns := make([]string, 0, len(rec))
+	for column := range rec {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// records of a CSV export with a header line, the separator is ";" or ","
+func NewCSVReader(r io.Reader, o *Options) (*ExportReader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || len(header) <= 0) {
+		return nil, fmt.Errorf("csv: header: %v", err)
+	}
+	comma := ','
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		comma = ';'
+	}
+	hr := csv.NewReader(strings.NewReader(header))
+	hr.Comma = comma
+	names, err := hr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv: header: %v", err)
+	}
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return newExportReader("csv", func() (map[string]string, error) {
+		fields, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		rec := make(map[string]string, len(names))
+		for i, name := range names {
+			if i < len(fields) {
+				rec[name] = fields[i]
+			}
+		}
+		return rec, nil
+	}, o)
+}
+
+// records of a JSON export, an array of objects or one object per line;
+// the nested objects are flattened, "a.b" the b of the object a
+func NewJSONReader(r io.Reader, o *Options) (*ExportReader, error) {
+	br := bufio.NewReader(r)
+	d := json.NewDecoder(br)
+	array := false
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			break
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			array = true
+			if _, err := d.Token(); err != nil {
+				return nil, fmt.Errorf("json: %v", err)
+			}
+		}
+		break
+	}
+	return newExportReader("json", func() (map[string]string, error) {
+		if array && !d.More() {
+			return nil, io.EOF
+		}
+		var obj map[string]interface{}
+		if err := d.Decode(&obj); err != nil {
+			return nil, err
+		}
+		rec := make(map[string]string)
+		flatten(rec, "", obj)
+		return rec, nil
+	}, o)
+}
+
+func flatten(rec map[string]string, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flatten(rec, prefix+k+".", v)
+		case string:
+			rec[prefix+k] = v
+		case float64:
+			rec[prefix+k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			rec[prefix+k] = strconv.FormatBool(v)
+		case nil:
+			rec[prefix+k] = ""
+		default:
+			b, _ := json.Marshal(v)
+			rec[prefix+k] = string(b)
+		}
+	}
+}
+
+// file name of stdin, to read the records another run writes to a pipe
+// (--emit-json -)
+const Stdin = "-"
+
+// export of a file, closed by Close
+type exportFile struct {
+	*ExportReader
+	f *os.File
+}
+
+func (e *exportFile) Close() error {
+	return e.f.Close()
+}
+
+func openExport(arg string, o *Options, reader func(io.Reader, *Options) (*ExportReader, error)) (CdrSource, error) {
+	if arg == Stdin {
+		return reader(os.Stdin, o)
+	}
+	f, err := os.Open(arg)
+	if err != nil {
+		return nil, err
+	}
+	r, err := reader(f, o)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &exportFile{r, f}, nil
+}
+
+func init() {
+	RegisterSource(CSVSource, func(arg string, o *Options) (CdrSource, error) {
+		return openExport(arg, o, NewCSVReader)
+	})
+	RegisterSource(JSONSource, func(arg string, o *Options) (CdrSource, error) {
+		return openExport(arg, o, NewJSONReader)
+	})
+}
@@ -0,0 +1,55 @@
+package cdr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// failure codes drawn by default with Options.FailedRatio
+const DefaultFailedCodes = "404,480,486,487,503,603"
+
+// parse the comma-separated 4xx/5xx/6xx codes of the failed calls
+func ParseFailedCodes(s string) ([]string, error) {
+	var codes []string
+	for _, code := range strings.Split(s, ",") {
+		code = strings.TrimSpace(code)
+		if len(code) <= 0 {
+			continue
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 400 || n > 699 {
+			return nil, fmt.Errorf("%q is not a 4xx, 5xx or 6xx code", code)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) <= 0 {
+		return nil, fmt.Errorf("no failure codes")
+	}
+	return codes, nil
+}
+
+// 200, or a failure code with probability o.FailedRatio; the plain
+// ResponseCode mix when the ratio is negative
+func (o *Options) responseCode() string {
+	if o.FailedRatio < 0 {
+		return ResponseCode(o.rnd())
+	}
+	if o.rnd().Float64() >= o.FailedRatio || len(o.FailedCodes) <= 0 {
+		return "200"
+	}
+	return o.FailedCodes[o.rnd().Intn(len(o.FailedCodes))]
+}
+
+// share (0-1) of the generated calls answered, INVITEs with a 200
+func (o *Options) AnsweredShare() float64 {
+	// one 200 in the ResponseCode mix
+	answered := 1.0 / 3
+	if o.FailedRatio >= 0 {
+		answered = 1 - o.FailedRatio
+		if len(o.FailedCodes) <= 0 {
+			answered = 1
+		}
+	}
+	return o.Methods.Share("INVITE") * answered
+}
@@ -0,0 +1,92 @@
+package cdr
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// charsets of the format placeholders
+var charsets = map[string]string{
+	"digits": "0123456789",
+	"hex":    "0123456789abcdef",
+	"HEX":    "0123456789ABCDEF",
+	"alpha":  "abcdefghijklmnopqrstuvwxyz",
+	"alnum":  "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+// template of a generated tag or Call-ID, literal text with the
+// placeholders {CHARSET:N} (N random chars of digits, hex, HEX, alpha or
+// alnum), {src} and {dst} (the call source and destination IPs), e.g.
+// "as{hex:8}-{hex:8}@{src}"
+type Format struct {
+	parts []formatPart
+}
+
+type formatPart struct {
+	literal string
+	charset string
+	n       int
+	host    string
+}
+
+func ParseFormat(s string) (*Format, error) {
+	f := &Format{}
+	for len(s) > 0 {
+		i := strings.Index(s, "{")
+		if i < 0 {
+			f.parts = append(f.parts, formatPart{literal: s})
+			break
+		}
+		if i > 0 {
+			f.parts = append(f.parts, formatPart{literal: s[:i]})
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			return nil, fmt.Errorf("unclosed { in %q", s)
+		}
+		p, err := parsePlaceholder(s[i+1 : i+j])
+		if err != nil {
+			return nil, err
+		}
+		f.parts = append(f.parts, p)
+		s = s[i+j+1:]
+	}
+	return f, nil
+}
+
+func parsePlaceholder(s string) (formatPart, error) {
+	if s == "src" || s == "dst" {
+		return formatPart{host: s}, nil
+	}
+	kv := strings.SplitN(s, ":", 2)
+	if _, ok := charsets[kv[0]]; !ok || len(kv) != 2 {
+		return formatPart{}, fmt.Errorf("unknown placeholder {%s}", s)
+	}
+	n, err := strconv.Atoi(kv[1])
+	if err != nil || n <= 0 || n > 128 {
+		return formatPart{}, fmt.Errorf("length of {%s} must be between 1 and 128", s)
+	}
+	return formatPart{charset: charsets[kv[0]], n: n}, nil
+}
+
+// a value of the format for a call from src to dst
+func (f *Format) Render(r *rand.Rand, src, dst string) string {
+	var b strings.Builder
+	for _, p := range f.parts {
+		switch {
+		case p.n > 0:
+			for i := 0; i < p.n; i++ {
+				b.WriteByte(p.charset[r.Intn(len(p.charset))])
+			}
+		case p.host == "src":
+			b.WriteString(src)
+		case p.host == "dst":
+			b.WriteString(dst)
+		default:
+			b.WriteString(p.literal)
+		}
+	}
+	return b.String()
+}
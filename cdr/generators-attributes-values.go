@@ -6,9 +6,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/bxcodec/faker"
-	"github.com/bxcodec/faker/support/slice"
 )
 
 type CdrValues struct {
@@ -20,26 +17,37 @@ type CdrValues struct {
 	FromTag        string
 	ToTag          string
 	AcctSessionId  string
+	CallId         string
 	MsDuration     int
 	SetupTime      int
 	CallerId       string
 	CalleeId       string
 	DstNumber      string
+	// User-Name, not sent when empty
+	UserName string
+	// media counters of the lifecycle records (see Lifecycle)
+	InputOctets  int
+	OutputOctets int
+	// data sessions (Options.Data): address of the subscriber, MAC of its
+	// device, MAC:SSID of the access point and why the session ended
+	FramedIP       string
+	CallingStation string
+	CalledStation  string
+	TerminateCause int
 }
 
 // random ResponseCode in a collection
-func ResponseCode() string {
+func ResponseCode(r *rand.Rand) string {
 	codes := []string{
 		"200",
 		"480",
 		"503",
 	}
-	return codes[rand.Int()%len(codes)]
+	return codes[r.Int()%len(codes)]
 }
 
 // generate brazilian phone number on default E164
-func PhoneNumberBrazil() string {
-	out := ""
+func PhoneNumberBrazil(r *rand.Rand) string {
 	box_numbers := []string{
 		"11",
 		"21",
@@ -47,60 +55,161 @@ func PhoneNumberBrazil() string {
 		"51",
 		"66",
 	}
-	ints, _ := faker.RandomInt(1, 8)
-
-	for _, v := range slice.IntToString(ints) {
-		out += string(v)
+	// the digits 1 to 8 shuffled
+	var b strings.Builder
+	for _, v := range r.Perm(8) {
+		b.WriteString(strconv.Itoa(v + 1))
 	}
-	return fmt.Sprintf("55%s9%s", box_numbers[rand.Int()%len(box_numbers)], strings.Join(slice.IntToString(ints), ""))
+	return fmt.Sprintf("55%s9%s", box_numbers[r.Int()%len(box_numbers)], b.String())
 }
 
 // generate ms_duration, setuptime based on sip_code
-func CdrTimers(c int) (int, int) {
-	st := rand.Intn(30)
+func CdrTimers(r *rand.Rand, c int) (int, int) {
+	st := r.Intn(30)
 
 	if c != 200 {
 		return 0, st
 	}
 
-	rand.Seed(time.Now().UnixNano())
 	min := 100000
 	max := 900000
-	ms := min + rand.Intn(max-min+1)
+	ms := min + r.Intn(max-min+1)
 	return ms, st
 }
 
 // random Addresses IPV4 in a collection
-func Addresses() (string, string) {
-	s := []string{
-		"200.200.200.200",
-		"250.250.250.250",
+func Addresses(r *rand.Rand) (string, string) {
+	return srcAddresses[r.Int()%len(srcAddresses)], dstAddresses[r.Int()%len(dstAddresses)]
+}
+
+var srcAddresses = []string{
+	"200.200.200.200",
+	"250.250.250.250",
+}
+
+var dstAddresses = []string{
+	"100.100.100.100",
+	"130.130.130.130",
+	"150.150.150.150",
+}
+
+// how FillCdrWith generates the cdrs, the zero value is FillCdr
+type Options struct {
+	// timers drawn from this model, nil for CdrTimers
+	Model *CallModel
+	// weighted SIP methods, empty for INVITE only
+	Methods Methods
+	// a B-leg record (BLeg) follows each call
+	Legs bool
+	// share (0-1) of failed calls, with one of FailedCodes, no duration
+	// and no To-Tag; negative keeps the ResponseCode mix
+	FailedRatio float64
+	FailedCodes []string
+	// formats of the tags and Call-ID (also the A-leg session-id), nil for
+	// 24, 16 and 20 digits, the Call-ID @ the source IP
+	FromTag *Format
+	ToTag   *Format
+	CallId  *Format
+	// each answered call is a Start, Interims and a Stop (see Lifecycle)
+	Lifecycle       bool
+	InterimInterval time.Duration
+	ReinviteRatio   float64
+	// shares (0-1, adding up to 1 at most) of the lifecycle calls sent out
+	// of order (see disorder)
+	OrphanStops      float64
+	StopBeforeStart  float64
+	InterimAfterStop float64
+	// distinct callers, callees and addresses, nil for no limit
+	Cardinality Cardinality
+	// subscriber data sessions instead of calls (see fillData)
+	Data bool
+	// time of the generated records, nil for time.Now
+	Now func() time.Time
+	// columns of the csv and json sources (see ExportReader)
+	Mapping Mapping
+	// identifiers of the worker of a distributed run, nil for all
+	Shard *Shard
+	// source of the draws, not safe for concurrent use (one per generator,
+	// seeded for a repeatable run); nil for the package-level one
+	Rand *rand.Rand
+}
+
+// a random caller number of the shard
+func (o *Options) caller(int) string {
+	return o.Shard.Number(PhoneNumberBrazil(o.rnd()))
+}
+
+func (o *Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
 	}
-	d := []string{
-		"100.100.100.100",
-		"130.130.130.130",
-		"150.150.150.150",
+	return time.Now()
+}
+
+// value of the format f, or def digits (@ host when not empty) when f is
+// nil
+func render(r *rand.Rand, f *Format, def int, host, src, dst string) string {
+	if f != nil {
+		return f.Render(r, src, dst)
+	}
+	v := digits(r, def)
+	if len(host) > 0 {
+		v += "@" + host
 	}
-	return s[rand.Int()%len(s)], d[rand.Int()%len(d)]
+	return v
 }
 
 // create and set all struct CdrValues with generated data
 func FillCdr() *CdrValues {
-	src_ip, dst_ip := Addresses()
-	r := ResponseCode()
+	return FillCdrWith(&Options{FailedRatio: -1})
+}
+
+// FillCdr according o
+func FillCdrWith(o *Options) *CdrValues {
+	if o.Data {
+		return fillData(o)
+	}
+	rnd := o.rnd()
+	src_ip, dst_ip := Addresses(rnd)
+	src_ip = o.Cardinality.value(rnd, SrcIP, src_ip, nthAddress(srcAddresses))
+	dst_ip = o.Cardinality.value(rnd, DstIP, dst_ip, nthAddress(dstAddresses))
+	method := o.Methods.Pick(rnd)
+	r := o.responseCode()
 	ri, _ := strconv.Atoi(r)
-	ms, st := CdrTimers(ri)
-	dr := PhoneNumberBrazil()
-	de := PhoneNumberBrazil()
+	var ms, st int
+	if o.Model != nil {
+		ms, st = o.Model.Timers(rnd, ri)
+	} else {
+		ms, st = CdrTimers(rnd, ri)
+	}
+	switch method {
+	case "INVITE", "BYE":
+		// the call records, BYE ends the call with its duration
+	case "CANCEL":
+		// caller gave up before the answer
+		r, ms = "487", 0
+	default:
+		// in-dialog and out-of-dialog requests, no call timers
+		r, ms, st = "200", 0, 0
+	}
+	dr := o.Cardinality.value(rnd, Caller, o.caller(0), o.caller)
+	de := o.Cardinality.value(rnd, Callee, PhoneNumberBrazil(rnd), randomNumber(rnd))
+	sessionId := o.Shard.Id(render(rnd, o.CallId, 20, src_ip, src_ip, dst_ip))
+	toTag := render(rnd, o.ToTag, 16, "", src_ip, dst_ip)
+	if o.FailedRatio >= 0 && r[0] != '2' {
+		// no dialog established, no To-Tag
+		toTag = ""
+	}
 	return &CdrValues{
-		AcctStatusType: 4,
+		AcctStatusType: StatusStop,
 		ServiceType:    15,
 		ResponseCode:   r,
-		Method:         "INVITE",
-		EventTimestamp: time.Now(),
-		FromTag:        faker.GetIdentifier().Digit()[:24],
-		ToTag:          faker.GetIdentifier().Digit()[:16],
-		AcctSessionId:  faker.GetIdentifier().Digit()[:20] + "@" + src_ip,
+		Method:         method,
+		EventTimestamp: o.now(),
+		FromTag:        render(rnd, o.FromTag, 24, "", src_ip, dst_ip),
+		ToTag:          toTag,
+		AcctSessionId:  sessionId,
+		CallId:         sessionId,
 		MsDuration:     ms,
 		SetupTime:      st,
 		CallerId:       "sip:" + dr + "@" + src_ip + ":5077",
@@ -108,3 +217,27 @@ func FillCdr() *CdrValues {
 		DstNumber:      de,
 	}
 }
+
+// callee leg of the call a, as accounted by a B2BUA: same Call-ID, its own
+// session-id (the Call-ID format from the B2BUA side) and tags, answered a
+// bit later so a bit shorter
+func BLeg(a *CdrValues, o *Options) *CdrValues {
+	b := *a
+	rnd := o.rnd()
+	_, dst_ip := Addresses(rnd)
+	dst_ip = o.Cardinality.value(rnd, DstIP, dst_ip, nthAddress(dstAddresses))
+	b.AcctSessionId = o.Shard.Id(render(rnd, o.CallId, 20, dst_ip, dst_ip, dst_ip))
+	b.FromTag = render(rnd, o.FromTag, 24, "", dst_ip, dst_ip)
+	if len(a.ToTag) > 0 {
+		b.ToTag = render(rnd, o.ToTag, 16, "", dst_ip, dst_ip)
+	}
+	if b.MsDuration > 0 {
+		max := 500
+		if b.MsDuration < max {
+			max = b.MsDuration
+		}
+		b.MsDuration -= rnd.Intn(max + 1)
+	}
+	b.EventTimestamp = a.EventTimestamp.Add(time.Duration(rnd.Intn(50)) * time.Millisecond)
+	return &b
+}
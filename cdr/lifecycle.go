@@ -0,0 +1,122 @@
+package cdr
+
+import (
+	"sort"
+	"time"
+)
+
+// Sip-Acct-Status-Type of the records, Interim is the dictionary Alive
+const (
+	StatusStart   = 1
+	StatusStop    = 2
+	StatusInterim = 3
+)
+
+// media bytes per second each way of the codecs a call switches between,
+// RTP/UDP/IPv4 at 20ms packetization; calls start on G.711
+var codecRates = []int{
+	10000, // G.711
+	10000, // G.722
+	4000,  // Opus 16kbit/s
+	3000,  // G.729
+}
+
+// mid-call re-INVITE kinds
+const (
+	codecChange = iota
+	hold
+	resume
+)
+
+// media change at ms into the talk time
+type lifecycleEvent struct {
+	ms       int
+	reinvite int
+	// periodic interim, no media change
+	periodic bool
+}
+
+// records of the call c in lifecycle mode: a Start at the answer, the
+// Interims (every InterimInterval of talk time and on the re-INVITEs of
+// ReinviteRatio of the calls) and the Stop c ends with, all with the octet
+// counters so far; calls never answered only have their Stop. Some calls
+// get their records reordered or orphaned as o says (see disorder)
+func Lifecycle(c *CdrValues, o *Options) []*CdrValues {
+	if c.Method != "INVITE" || c.ResponseCode != "200" || c.MsDuration <= 0 {
+		return []*CdrValues{c}
+	}
+	var events []lifecycleEvent
+	if step := int(o.InterimInterval / time.Millisecond); step > 0 {
+		for ms := step; ms < c.MsDuration; ms += step {
+			events = append(events, lifecycleEvent{ms: ms, periodic: true})
+		}
+	}
+	rnd := o.rnd()
+	if o.ReinviteRatio > 0 && rnd.Float64() < o.ReinviteRatio && c.MsDuration > 2 {
+		if rnd.Intn(2) == 0 {
+			events = append(events, lifecycleEvent{ms: 1 + rnd.Intn(c.MsDuration-1), reinvite: codecChange})
+		} else {
+			at := 1 + rnd.Intn(c.MsDuration-2)
+			back := at + 1 + rnd.Intn(c.MsDuration-at-1)
+			events = append(events, lifecycleEvent{ms: at, reinvite: hold}, lifecycleEvent{ms: back, reinvite: resume})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].ms < events[j].ms })
+
+	answer := c.EventTimestamp.Add(-time.Duration(c.MsDuration) * time.Millisecond)
+	// the callee side loses a bit of the caller media, a data session
+	// uploads a share of what it downloads
+	in := 1 - rnd.Float64()/100
+	rate, held := codecRates[0], 0
+	if o.Data {
+		in, rate = uploadShare(rnd), int(dataRate(rnd))
+	}
+	record := func(status, ms int, out float64) *CdrValues {
+		r := *c
+		r.AcctStatusType = status
+		r.EventTimestamp = answer.Add(time.Duration(ms) * time.Millisecond)
+		r.MsDuration = ms
+		r.OutputOctets = int(out)
+		r.InputOctets = int(out * in)
+		return &r
+	}
+
+	records := []*CdrValues{record(StatusStart, 0, 0)}
+	var octets float64
+	last := 0
+	for _, e := range events {
+		octets += float64(rate) * float64(e.ms-last) / 1000
+		last = e.ms
+		if !e.periodic {
+			switch e.reinvite {
+			case codecChange:
+				rate = codecRates[1+rnd.Intn(len(codecRates)-1)]
+			case hold:
+				held, rate = rate, 0
+			case resume:
+				rate = held
+			}
+		}
+		records = append(records, record(StatusInterim, e.ms, octets))
+	}
+	octets += float64(rate) * float64(c.MsDuration-last) / 1000
+	return o.disorder(append(records, record(StatusStop, c.MsDuration, octets)))
+}
+
+// the reordering real UDP networks produce, at most one per call: a Stop
+// without its Start and Interims (OrphanStops), the Stop before the Start
+// (StopBeforeStart) or the last Interim after the Stop (InterimAfterStop,
+// calls with Interims only)
+func (o *Options) disorder(records []*CdrValues) []*CdrValues {
+	n := len(records)
+	p := o.rnd().Float64()
+	switch {
+	case p < o.OrphanStops:
+		return records[n-1:]
+	case p < o.OrphanStops+o.StopBeforeStart:
+		return append([]*CdrValues{records[n-1]}, records[:n-1]...)
+	case p < o.OrphanStops+o.StopBeforeStart+o.InterimAfterStop && n > 2:
+		records[n-2], records[n-1] = records[n-1], records[n-2]
+	}
+	return records
+}
@@ -0,0 +1,228 @@
+package cdr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Ignore maps a column to nothing, e.g. "internal_id=-"
+const Ignore = "-"
+
+// a cdr field settable from a column, by the attribute it is sent as
+type mappedField struct {
+	attr  string
+	field string
+	set   func(c *CdrValues, v string) error
+}
+
+func intField(f func(c *CdrValues) *int) func(c *CdrValues, v string) error {
+	return func(c *CdrValues, v string) error {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*f(c) = int(n)
+		return nil
+	}
+}
+
+func stringField(f func(c *CdrValues) *string) func(c *CdrValues, v string) error {
+	return func(c *CdrValues, v string) error {
+		*f(c) = v
+		return nil
+	}
+}
+
+// the attributes the columns can be mapped to; a column named as one of
+// them or as its cdr field (ignoring case and punctuation) maps to it
+// without a rule
+var mappedFields = []mappedField{
+	{"Sip-Call-Id", "CallId", stringField(func(c *CdrValues) *string { return &c.CallId })},
+	{"Acct-Session-Id", "AcctSessionId", stringField(func(c *CdrValues) *string { return &c.AcctSessionId })},
+	{"Sip-Acct-Session-Id", "", stringField(func(c *CdrValues) *string { return &c.AcctSessionId })},
+	{"Sip-Response-Code", "ResponseCode", stringField(func(c *CdrValues) *string { return &c.ResponseCode })},
+	{"Sip-Method", "Method", stringField(func(c *CdrValues) *string { return &c.Method })},
+	{"Sip-Event-Timestamp", "EventTimestamp", eventTimestamp},
+	{"Event-Timestamp", "", eventTimestamp},
+	{"Sip-From-Tag", "FromTag", stringField(func(c *CdrValues) *string { return &c.FromTag })},
+	{"Sip-To-Tag", "ToTag", stringField(func(c *CdrValues) *string { return &c.ToTag })},
+	{"Sip-Call-MSDuration", "MsDuration", intField(func(c *CdrValues) *int { return &c.MsDuration })},
+	// seconds, sent as the MSDuration
+	{"Sip-Call-Duration", "", func(c *CdrValues, v string) error {
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		c.MsDuration = int(s * 1000)
+		return nil
+	}},
+	{"Sip-Call-Setuptime", "SetupTime", intField(func(c *CdrValues) *int { return &c.SetupTime })},
+	{"Sip-Caller-Id", "CallerId", stringField(func(c *CdrValues) *string { return &c.CallerId })},
+	{"Sip-Callee-Id", "CalleeId", stringField(func(c *CdrValues) *string { return &c.CalleeId })},
+	{"Sip-Dst-Number", "DstNumber", stringField(func(c *CdrValues) *string { return &c.DstNumber })},
+	{"User-Name", "UserName", stringField(func(c *CdrValues) *string { return &c.UserName })},
+	{"Acct-Input-Octets", "InputOctets", intField(func(c *CdrValues) *int { return &c.InputOctets })},
+	{"Acct-Output-Octets", "OutputOctets", intField(func(c *CdrValues) *int { return &c.OutputOctets })},
+	{"Framed-IP-Address", "FramedIP", stringField(func(c *CdrValues) *string { return &c.FramedIP })},
+	{"Calling-Station-Id", "CallingStation", stringField(func(c *CdrValues) *string { return &c.CallingStation })},
+	{"Called-Station-Id", "CalledStation", stringField(func(c *CdrValues) *string { return &c.CalledStation })},
+	{"Acct-Terminate-Cause", "TerminateCause", intField(func(c *CdrValues) *int { return &c.TerminateCause })},
+	{"Acct-Status-Type", "AcctStatusType", statusType},
+	{"Sip-Acct-Status-Type", "", statusType},
+}
+
+// names of the status types, Alive is the dictionary Interim-Update
+var statusNames = map[string]int{
+	"start":          StatusStart,
+	"stop":           StatusStop,
+	"interim-update": StatusInterim,
+	"alive":          StatusInterim,
+}
+
+// Start, Stop, Interim-Update (or Alive) or their number
+func statusType(c *CdrValues, v string) error {
+	if n, ok := statusNames[strings.ToLower(v)]; ok {
+		c.AcctStatusType = n
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < StatusStart || n > StatusInterim {
+		return fmt.Errorf("%q is not Start, Stop or Interim-Update", v)
+	}
+	c.AcctStatusType = n
+	return nil
+}
+
+// unix seconds, "2006-01-02 15:04:05" local time or RFC 3339
+func eventTimestamp(c *CdrValues, v string) error {
+	t, err := ParseSIPpTime(v)
+	if err != nil {
+		return err
+	}
+	c.EventTimestamp = t
+	return nil
+}
+
+// the field of an attribute name, nil when it can't be mapped
+func lookupField(attr string) *mappedField {
+	for i, f := range mappedFields {
+		if strings.EqualFold(f.attr, attr) {
+			return &mappedFields[i]
+		}
+	}
+	return nil
+}
+
+// names of the attributes the columns can be mapped to
+func MappableAttributes() []string {
+	names := make([]string, 0, len(mappedFields))
+	for _, f := range mappedFields {
+		names = append(names, f.attr)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// attribute of each column of a CSV or JSON export (--map, --map-file), by
+// column name; Ignore skips a column
+type Mapping map[string]string
+
+// parse the "column=Attribute" rules, repeated or comma-separated
+func ParseMapping(rules []string) (Mapping, error) {
+	m := make(Mapping)
+	for _, s := range rules {
+		for _, rule := range strings.Split(s, ",") {
+			if len(strings.TrimSpace(rule)) <= 0 {
+				continue
+			}
+			kv := strings.SplitN(rule, "=", 2)
+			if len(kv) < 2 || len(strings.TrimSpace(kv[0])) <= 0 {
+				return nil, fmt.Errorf("%q is not column=Attribute", rule)
+			}
+			if err := m.set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Mapping) set(column, attr string) error {
+	if attr != Ignore {
+		f := lookupField(attr)
+		if f == nil {
+			return fmt.Errorf("%s: %q can't be mapped, must be one of %s or %s", column, attr, strings.Join(MappableAttributes(), ", "), Ignore)
+		}
+		attr = f.attr
+	}
+	m[column] = attr
+	return nil
+}
+
+// the mapping of the file, when given, with the rules over it; the file
+// may be missing when there are rules, it is written by Save
+func LoadMapping(file string, rules []string) (Mapping, error) {
+	m := make(Mapping)
+	if len(file) > 0 {
+		b, err := ioutil.ReadFile(file)
+		switch {
+		case os.IsNotExist(err) && len(rules) > 0:
+		case err != nil:
+			return nil, err
+		default:
+			var saved map[string]string
+			if err := yaml.UnmarshalStrict(b, &saved); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			for column, attr := range saved {
+				if err := m.set(column, attr); err != nil {
+					return nil, fmt.Errorf("%s: %v", file, err)
+				}
+			}
+		}
+	}
+	over, err := ParseMapping(rules)
+	if err != nil {
+		return nil, err
+	}
+	for column, attr := range over {
+		m[column] = attr
+	}
+	return m, nil
+}
+
+// write the mapping to file, column: Attribute by column, to replay the
+// same exports with --map-file alone
+func (m Mapping) Save(file string) error {
+	b, err := yaml.Marshal(map[string]string(m))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append([]byte("# column: attribute, written by --map\n"), b...), 0644)
+}
+
+// field of a column: its rule, else the attribute or cdr field of the same
+// name; nil for the columns not mapped or ignored
+func (m Mapping) field(column string) *mappedField {
+	if attr, ok := m[column]; ok {
+		return lookupField(attr)
+	}
+	name := normalizeColumn(column)
+	for c, attr := range m {
+		if normalizeColumn(c) == name {
+			return lookupField(attr)
+		}
+	}
+	for i, f := range mappedFields {
+		if name == normalizeColumn(f.attr) || (len(f.field) > 0 && name == normalizeColumn(f.field)) {
+			return &mappedFields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cdr
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/routecall/go-radius-gen-acct/rfc2866"
+)
+
+// a SIP method and its share of the generated records
+type MethodWeight struct {
+	Method string
+	Weight int
+}
+
+type Methods []MethodWeight
+
+// parse "INVITE=80,BYE=10,CANCEL=5", names of the Sip-Method dictionary
+// values
+func ParseMethods(s string) (Methods, error) {
+	var methods Methods
+	for _, item := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(item)) <= 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		name := strings.ToUpper(strings.TrimSpace(kv[0]))
+		if _, ok := SipMethod(name); !ok {
+			return nil, fmt.Errorf("unknown method %q", kv[0])
+		}
+		w := 1
+		if len(kv) == 2 {
+			var err error
+			if w, err = strconv.Atoi(strings.TrimSpace(kv[1])); err != nil || w <= 0 {
+				return nil, fmt.Errorf("weight of %s must be greater 0", name)
+			}
+		}
+		methods = append(methods, MethodWeight{name, w})
+	}
+	return methods, nil
+}
+
+// weighted random method, INVITE when there are none
+func (m Methods) Pick(r *rand.Rand) string {
+	total := 0
+	for _, mw := range m {
+		total += mw.Weight
+	}
+	if total <= 0 {
+		return "INVITE"
+	}
+	n := r.Intn(total)
+	for _, mw := range m {
+		if n < mw.Weight {
+			return mw.Method
+		}
+		n -= mw.Weight
+	}
+	return m[len(m)-1].Method
+}
+
+// Sip-Method value of name
+func SipMethod(name string) (rfc2866.SipMethod, bool) {
+	for v, s := range rfc2866.SipMethod_Strings {
+		if s == name {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// share (0-1) of the records picked with method name
+func (m Methods) Share(name string) float64 {
+	total, weight := 0, 0
+	for _, mw := range m {
+		total += mw.Weight
+		if mw.Method == name {
+			weight += mw.Weight
+		}
+	}
+	if total <= 0 {
+		if name == "INVITE" {
+			return 1
+		}
+		return 0
+	}
+	return float64(weight) / float64(total)
+}
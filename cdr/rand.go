@@ -0,0 +1,30 @@
+package cdr
+
+import "math/rand"
+
+// draws of the package-level source, safe for concurrent use but for
+// Read, for the Options without their own Rand
+var globalRand = rand.New(globalSource{})
+
+type globalSource struct{}
+
+func (globalSource) Int63() int64   { return rand.Int63() }
+func (globalSource) Uint64() uint64 { return rand.Uint64() }
+func (globalSource) Seed(int64)     {}
+
+// source of the draws of o
+func (o *Options) rnd() *rand.Rand {
+	if o.Rand != nil {
+		return o.Rand
+	}
+	return globalRand
+}
+
+// n random digits
+func digits(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + r.Intn(10))
+	}
+	return string(b)
+}
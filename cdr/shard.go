@@ -0,0 +1,115 @@
+package cdr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// most workers of a shard: the residue is kept in the last 6 digits
+const maxShards = 1000000
+
+// the share of the identifiers of worker Index of Count in a distributed
+// run (--shard): its session ids and caller numbers are Index modulo Count
+// in their last digits, so the workers never generate the same ones. A
+// nil Shard keeps the values as drawn
+type Shard struct {
+	Index int
+	Count int
+}
+
+// parse "i/n", worker i (from 0) of n, empty for none
+func ParseShard(s string) (*Shard, error) {
+	if len(s) <= 0 {
+		return nil, nil
+	}
+	kv := strings.SplitN(s, "/", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("shard %q must be i/n, worker i (from 0) of n", s)
+	}
+	i, err := strconv.Atoi(kv[0])
+	if err != nil {
+		return nil, fmt.Errorf("shard %q: bad worker %q", s, kv[0])
+	}
+	n, err := strconv.Atoi(kv[1])
+	if err != nil || n <= 0 || n > maxShards {
+		return nil, fmt.Errorf("shard %q: the workers must be 1 to %d", s, maxShards)
+	}
+	if i < 0 || i >= n {
+		return nil, fmt.Errorf("shard %q: the worker must be 0 to %d", s, n-1)
+	}
+	return &Shard{Index: i, Count: n}, nil
+}
+
+func (s *Shard) String() string {
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+}
+
+// session id or Call-ID of the shard, its part before @
+func (s *Shard) Id(id string) string {
+	if i := strings.Index(id, "@"); i >= 0 {
+		return s.apply(id[:i]) + id[i:]
+	}
+	return s.apply(id)
+}
+
+// phone number of the shard
+func (s *Shard) Number(n string) string {
+	return s.apply(n)
+}
+
+// random v of the shard
+func (s *Shard) Uint64(v uint64) uint64 {
+	if s == nil || s.Count <= 1 {
+		return v
+	}
+	n := uint64(s.Count)
+	return (v>>1)/n*n + uint64(s.Index)
+}
+
+// v with the value of its last digits (6 at most) Index modulo Count, or
+// ".Index" appended when it hasn't digits enough for Count
+func (s *Shard) apply(v string) string {
+	if s == nil || s.Count <= 1 {
+		return v
+	}
+	end := strings.LastIndexAny(v, "0123456789") + 1
+	start := end
+	for start > 0 && end-start < 6 && v[start-1] >= '0' && v[start-1] <= '9' {
+		start--
+	}
+	mod := 1
+	for i := start; i < end; i++ {
+		mod *= 10
+	}
+	if end <= 0 || mod < s.Count {
+		return v + "." + strconv.Itoa(s.Index)
+	}
+	x, _ := strconv.Atoi(v[start:end])
+	x = x - x%s.Count + s.Index
+	if x >= mod {
+		x -= s.Count
+	}
+	return fmt.Sprintf("%s%0*d%s", v[:start], end-start, x, v[end:])
+}
+
+// the caller pool of the shard: its share of the pool size, so the run
+// sees about the --cardinality callers over all the workers
+func (c Cardinality) Shard(s *Shard) {
+	p := c[Caller]
+	if p == nil {
+		return
+	}
+	if s == nil || s.Count <= 1 {
+		p.Size = p.total
+		return
+	}
+	size := p.total / s.Count
+	if s.Index < p.total%s.Count {
+		size++
+	}
+	if size < 1 {
+		size = 1
+	}
+	p.Size = size
+}
@@ -0,0 +1,219 @@
+package cdr
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// columns of a SIPp run CSV (e.g. written by the scenario <log> actions),
+// matched by header name ignoring case and punctuation; only the call id is
+// required
+var sippColumns = map[string][]string{
+	"callid":    {"callid", "call"},
+	"start":     {"start", "starttime", "invite", "invitetime"},
+	"answer":    {"answer", "answertime", "connect"},
+	"stop":      {"stop", "stoptime", "end", "endtime", "bye"},
+	"code":      {"responsecode", "code", "status"},
+	"caller":    {"caller", "callerid", "from"},
+	"callee":    {"callee", "calleeid", "to"},
+	"dstnumber": {"dstnumber", "dialed", "service"},
+}
+
+// records of a SIPp run CSV, one call per line, as accounting cdrs; the
+// columns not in the file are generated as without it
+type SIPpReader struct {
+	// calls from From and before To (zero for no bound) only, by their
+	// time (see CallTime)
+	From time.Time
+	To   time.Time
+
+	r    *csv.Reader
+	o    *Options
+	cols map[string]int
+	line int
+	time time.Time
+}
+
+// read the header of r, the separator is ";" (SIPp stat files) or ","
+func NewSIPpReader(r io.Reader, o *Options) (*SIPpReader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || len(header) <= 0) {
+		return nil, fmt.Errorf("sipp csv: header: %v", err)
+	}
+	comma := ','
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		comma = ';'
+	}
+	hr := csv.NewReader(strings.NewReader(header))
+	hr.Comma = comma
+	names, err := hr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("sipp csv: header: %v", err)
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	s := &SIPpReader{r: cr, o: o, cols: make(map[string]int), line: 1}
+	for i, name := range names {
+		name = normalizeColumn(name)
+		for col, aliases := range sippColumns {
+			for _, alias := range aliases {
+				if name == alias {
+					if _, ok := s.cols[col]; !ok {
+						s.cols[col] = i
+					}
+				}
+			}
+		}
+	}
+	if _, ok := s.cols["callid"]; !ok {
+		return nil, fmt.Errorf("sipp csv: no call id column on the header %q", strings.TrimSpace(header))
+	}
+	return s, nil
+}
+
+func normalizeColumn(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// skip n calls, to resume a replay
+func (s *SIPpReader) Skip(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := s.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SIPpReader) SetWindow(from, to time.Time) {
+	s.From, s.To = from, to
+}
+
+// time of the last call read: its start, answer or stop, zero without them
+func (s *SIPpReader) CallTime() time.Time {
+	return s.time
+}
+
+func (s *SIPpReader) field(rec []string, col string) string {
+	if i, ok := s.cols[col]; ok && i < len(rec) {
+		return strings.TrimSpace(rec[i])
+	}
+	return ""
+}
+
+// next record of a call between From and To
+func (s *SIPpReader) read() ([]string, error) {
+	for {
+		rec, err := s.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		s.line++
+		s.time = time.Time{}
+		for _, col := range []string{"start", "answer", "stop"} {
+			if v := s.field(rec, col); len(v) > 0 {
+				if s.time, err = ParseSIPpTime(v); err != nil {
+					return nil, fmt.Errorf("sipp csv: line %d: %s: %v", s.line, col, err)
+				}
+				break
+			}
+		}
+		if s.From.IsZero() && s.To.IsZero() {
+			return rec, nil
+		}
+		if s.time.IsZero() {
+			return nil, fmt.Errorf("sipp csv: line %d: no start, answer or stop time to select the call", s.line)
+		}
+		if !s.time.Before(s.From) && (s.To.IsZero() || s.time.Before(s.To)) {
+			return rec, nil
+		}
+	}
+}
+
+// cdr of the next call, io.EOF after the last one
+func (s *SIPpReader) Next() (*CdrValues, error) {
+	rec, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	field := func(col string) string {
+		return s.field(rec, col)
+	}
+	c := FillCdrWith(s.o)
+	c.CallId = field("callid")
+	if len(c.CallId) <= 0 {
+		return nil, fmt.Errorf("sipp csv: line %d: empty call id", s.line)
+	}
+	c.AcctSessionId = c.CallId
+	var start, answer, stop time.Time
+	for _, t := range []struct {
+		col string
+		v   *time.Time
+	}{{"start", &start}, {"answer", &answer}, {"stop", &stop}} {
+		if v := field(t.col); len(v) > 0 {
+			if *t.v, err = ParseSIPpTime(v); err != nil {
+				return nil, fmt.Errorf("sipp csv: line %d: %s: %v", s.line, t.col, err)
+			}
+		}
+	}
+	if code := field("code"); len(code) > 0 {
+		c.ResponseCode = code
+	} else if !stop.IsZero() {
+		c.ResponseCode = "200"
+	}
+	if !stop.IsZero() {
+		c.EventTimestamp = stop
+	} else if !s.time.IsZero() {
+		c.EventTimestamp = s.time
+	}
+	if answer.IsZero() {
+		answer = start
+	}
+	if !start.IsZero() {
+		c.SetupTime = int((answer.Sub(start) + time.Second/2) / time.Second)
+	}
+	c.MsDuration = 0
+	if c.ResponseCode != "200" {
+		// never answered, no dialog
+		c.ToTag = ""
+	} else if !answer.IsZero() && stop.After(answer) {
+		c.MsDuration = int(stop.Sub(answer) / time.Millisecond)
+	}
+	if v := field("caller"); len(v) > 0 {
+		c.CallerId = v
+	}
+	if v := field("callee"); len(v) > 0 {
+		c.CalleeId = v
+	}
+	if v := field("dstnumber"); len(v) > 0 {
+		c.DstNumber = v
+	}
+	return c, nil
+}
+
+// unix seconds (SIPp timestamps, with fraction), "2006-01-02 15:04:05.999999"
+// (SIPp date and time, also tab separated) or RFC 3339
+func ParseSIPpTime(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", strings.Replace(s, "\t", " ", 1), time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
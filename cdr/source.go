@@ -0,0 +1,132 @@
+package cdr
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// calls the generator sends, one cdr per call (the B-leg and lifecycle
+// records are made from it); only called from a single goroutine
+type CdrSource interface {
+	// cdr of the next call, io.EOF after the last one
+	Next() (*CdrValues, error)
+}
+
+// sources that can skip calls, to resume a run
+type SkipSource interface {
+	CdrSource
+	Skip(n int) error
+}
+
+// sources of calls with their original times, to replay them at their
+// times and select a window of them
+type TimedSource interface {
+	CdrSource
+	CallTime() time.Time
+	// only the calls from from and before to, zero for no bound
+	SetWindow(from, to time.Time)
+}
+
+// sources of a CDR export, with the attribute each column is sent as
+type ColumnSource interface {
+	CdrSource
+	// the columns mapped, and the ones not
+	Columns() (map[string]string, []string)
+}
+
+// makes a source from its argument (e.g. a file name), o generates the
+// values the source doesn't have
+type SourceFactory func(arg string, o *Options) (CdrSource, error)
+
+var (
+	sourcesMu sync.Mutex
+	sources   = make(map[string]SourceFactory)
+)
+
+// add the source name, selected with --source name[:arg]
+func RegisterSource(name string, f SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, dup := sources[name]; dup {
+		panic("cdr: source " + name + " registered twice")
+	}
+	sources[name] = f
+}
+
+// sorted names of the registered sources
+func Sources() []string {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// split "name[:arg]"
+func ParseSource(s string) (string, string) {
+	if i := strings.Index(s, ":"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// the registered source name with arg
+func NewSource(name, arg string, o *Options) (CdrSource, error) {
+	sourcesMu.Lock()
+	f, ok := sources[name]
+	sourcesMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q, must be one of %s", name, strings.Join(Sources(), ", "))
+	}
+	return f(arg, o)
+}
+
+// built-in sources
+const (
+	RandomSource = "random"
+	SIPpSource   = "sipp"
+)
+
+// generated calls, endless
+type randomSource struct {
+	o *Options
+}
+
+func (r randomSource) Next() (*CdrValues, error) {
+	return FillCdrWith(r.o), nil
+}
+
+// SIPp run CSV of a file, closed by Close
+type sippFile struct {
+	*SIPpReader
+	f *os.File
+}
+
+func (s *sippFile) Close() error {
+	return s.f.Close()
+}
+
+func init() {
+	RegisterSource(RandomSource, func(arg string, o *Options) (CdrSource, error) {
+		return randomSource{o}, nil
+	})
+	RegisterSource(SIPpSource, func(arg string, o *Options) (CdrSource, error) {
+		f, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewSIPpReader(f, o)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &sippFile{r, f}, nil
+	})
+}
@@ -0,0 +1,55 @@
+// Package checkpoint saves the progress of a run (--checkpoint) so an
+// interrupted one can continue with --resume.
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// progress of a run, Sent counts the requests handed to the network: the
+// ones in flight when the process died aren't sent again on resume
+type State struct {
+	Sent    uint64    `json:"sent"`
+	MaxReq  int       `json:"max_req"`
+	Done    bool      `json:"done"`
+	Updated time.Time `json:"updated"`
+	// --run-id of the run, kept on resume
+	RunID string `json:"run_id,omitempty"`
+	// --seed of the run and the draws made of it, the resumed run skips
+	// them to go on with the calls an uninterrupted one would send
+	Seed  int64  `json:"seed,omitempty"`
+	Draws uint64 `json:"draws,omitempty"`
+}
+
+// state saved on path, nil when there is no checkpoint yet
+func Load(path string) (*State, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// replace the checkpoint, a crash while saving keeps the previous one
+func Save(path string, s State) error {
+	s.Updated = time.Now()
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,96 @@
+// Package checksum computes the integrity checksum of the accounting
+// records (acct --checksum-attr) and finds it back on the stored ones
+// (verify --checksum-attr), to tell the records the server truncated or
+// changed on the way from the ones missing.
+package checksum
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/routecall/go-radius-gen-acct/dump"
+)
+
+// hex digits of a checksum, the first 16 bytes of the digest
+const Size = 32
+
+// attributes summed without --checksum-fields: the call and session ids
+// and the values a mediation truncates or rewrites, all kept as text on
+// the detail file
+var DefaultAttrs = []string{
+	"Acct-Session-Id",
+	"Sip-Acct-Session-Id",
+	"Sip-Call-Id",
+	"Sip-From-Tag",
+	"Sip-To-Tag",
+	"Sip-Response-Code",
+	"Sip-Caller-Id",
+	"Sip-Callee-Id",
+	"Sip-Dst-Number",
+	"Sip-Call-MSDuration",
+	"User-Name",
+	"Calling-Station-Id",
+	"Called-Station-Id",
+}
+
+// the dictionary names of the comma-separated attributes, DefaultAttrs
+// when empty; only strings and integers, the detail files print the dates
+// as they please
+func ParseAttrs(s string) ([]string, error) {
+	var attrs []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) <= 0 {
+			continue
+		}
+		t, ok := dump.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("checksum-fields: unknown attribute %q", name)
+		}
+		if k := dump.Dictionary[t].Kind; k != dump.String && k != dump.Integer {
+			return nil, fmt.Errorf("checksum-fields: %s is not a string or an integer", name)
+		}
+		attrs = append(attrs, dump.Name(t))
+	}
+	if len(attrs) <= 0 {
+		return DefaultAttrs, nil
+	}
+	return attrs, nil
+}
+
+// checksum of the values of attrs by name (the text of the strings, the
+// decimal of the integers), "Name=value" lines in the attrs order, a
+// missing attribute with an empty value: HMAC-SHA256 with key, SHA-256
+// without
+func Sum(key []byte, attrs []string, values map[string]string) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	for _, name := range attrs {
+		fmt.Fprintf(h, "%s=%s\n", name, values[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:Size]
+}
+
+// the checksum of a stored attribute value: the text itself, or the 0x hex
+// of the value, with the Vendor-Specific header before it or not, as the
+// detail files print the attributes out of their dictionary
+func Stored(v string) string {
+	if strings.HasPrefix(v, "0x") {
+		b, err := hex.DecodeString(v[2:])
+		if err != nil {
+			return v
+		}
+		v = string(b)
+	}
+	if len(v) > Size {
+		v = v[len(v)-Size:]
+	}
+	return v
+}
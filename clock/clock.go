@@ -0,0 +1,122 @@
+// Package clock is the time source of the pacers and the schedulers of
+// the generator: the real one, or a simulated one (Sim) the tests drive to
+// check the pacing, the retries and the call lifecycles deterministically
+// and faster than real time.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and sleeps
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	// channel receiving the time once d elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// the system clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// c, the real clock when nil
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// time elapsed on c since t
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// simulated clock, only moving when told: Advance moves it and wakes the
+// sleepers it passed, and with Auto a Sleep moves it itself to its end
+// right away, so a single goroutine runs through hours of schedule in no
+// time. Safe for concurrent use
+type Sim struct {
+	mu      sync.Mutex
+	now     time.Time
+	Auto    bool
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// simulated clock at start
+func NewSim(start time.Time) *Sim {
+	return &Sim{now: start}
+}
+
+func (s *Sim) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+func (s *Sim) After(d time.Duration) <-chan time.Time {
+	s.mu.Lock()
+	c := make(chan time.Time, 1)
+	at := s.now.Add(d)
+	if d <= 0 {
+		c <- s.now
+		s.mu.Unlock()
+		return c
+	}
+	s.waiters = append(s.waiters, waiter{at: at, c: c})
+	auto := s.Auto
+	s.mu.Unlock()
+	if auto {
+		s.AdvanceTo(at)
+	}
+	return c
+}
+
+func (s *Sim) Sleep(d time.Duration) {
+	<-s.After(d)
+}
+
+// move the clock d forward
+func (s *Sim) Advance(d time.Duration) {
+	s.AdvanceTo(s.Now().Add(d))
+}
+
+// move the clock to t, waking the sleepers due by then in order; a clock
+// already past t stays
+func (s *Sim) AdvanceTo(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.now) {
+		s.now = t
+	}
+	sort.SliceStable(s.waiters, func(i, j int) bool { return s.waiters[i].at.Before(s.waiters[j].at) })
+	n := 0
+	for _, w := range s.waiters {
+		if w.at.After(s.now) {
+			break
+		}
+		w.c <- w.at
+		n++
+	}
+	s.waiters = s.waiters[n:]
+}
+
+// sleepers waiting for the clock
+func (s *Sim) Waiters() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waiters)
+}
@@ -0,0 +1,78 @@
+// Package completion holds the bash and zsh completion scripts of the
+// command (completion subcommand); they ask the command itself for the
+// subcommands and flags (cli --generate-bash-completion), so they don't go
+// stale as the flags grow.
+package completion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shells with a completion script, fish is generated from the flags
+const (
+	Bash = "bash"
+	Zsh  = "zsh"
+	Fish = "fish"
+)
+
+const bashScript = `# bash completion of PROG, e.g. in ~/.bashrc:
+#   source <(PROG completion bash)
+_PROG_complete() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == -* ]]; then
+    opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null | sort -u)
+  else
+    opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+  return 0
+}
+complete -o bashdefault -o default -F _PROG_complete PROG
+`
+
+const zshScript = `#compdef PROG
+# zsh completion of PROG, e.g. in ~/.zshrc:
+#   source <(PROG completion zsh)
+_PROG_complete() {
+  local -a opts
+  local cur=${words[-1]}
+  if [[ "$cur" == -* ]]; then
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} $cur --generate-bash-completion 2>/dev/null | sort -u)}")
+  else
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+compdef _PROG_complete PROG
+`
+
+// the completion script of shell (Bash or Zsh) for the command prog; the
+// flags of the default command come twice, as the app flags and the acct
+// ones
+func Script(shell, prog string) (string, error) {
+	var script string
+	switch shell {
+	case Bash:
+		script = bashScript
+	case Zsh:
+		script = zshScript
+	default:
+		return "", fmt.Errorf("no completion script for %q, only %s, %s or %s", shell, Bash, Zsh, Fish)
+	}
+	// a shell function name of the program name
+	fn := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, prog)
+	script = strings.Replace(script, "_PROG_", "_"+fn+"_", -1)
+	return strings.Replace(script, "PROG", prog, -1), nil
+}
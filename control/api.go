@@ -0,0 +1,206 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/pacer"
+)
+
+// HTTP control API
+//
+//	POST /start /stop /pause /resume   change the generator state
+//	POST /rate?pps=N                   change the packets per second
+//	POST /plan?pps=N&max_req=M         set the load plan before /start,
+//	     [&shard=i/n&seed=S]           and the identifiers of the worker
+//	POST /snapshot                     write the stats (and profiles) to disk
+//	GET  /stats                        live stats
+//	GET  /stats/stream                 live stats every second (server-sent events)
+//	GET  /log                          last log lines
+//	GET  /                             web UI
+//	GET  /report                       final report (409 while running)
+//	GET  /healthz /readyz              probes, see HealthHandler
+type API struct {
+	Control *Control
+	// snapshot of the live stats
+	Stats func() interface{}
+	// final report, nil while the run isn't finished
+	Report func() interface{}
+	// log tail shown on the web UI, optional
+	Log *LogTail
+}
+
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", a.action(a.Control.Start))
+	mux.HandleFunc("/stop", a.action(a.Control.Stop))
+	mux.HandleFunc("/pause", a.action(a.Control.Pause))
+	mux.HandleFunc("/resume", a.action(a.Control.Resume))
+	mux.HandleFunc("/rate", a.rate)
+	mux.HandleFunc("/plan", a.plan)
+	mux.HandleFunc("/snapshot", a.snapshot)
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.Stats())
+	})
+	mux.HandleFunc("/stats/stream", a.statsStream)
+	registerHealth(mux, a.Control)
+	mux.HandleFunc("/log", func(w http.ResponseWriter, r *http.Request) {
+		lines := []string{}
+		if a.Log != nil {
+			lines = a.Log.Lines()
+		}
+		writeJSON(w, http.StatusOK, lines)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, uiHTML)
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		report := a.Report()
+		if report == nil {
+			writeError(w, http.StatusConflict, "run not finished")
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	return mux
+}
+
+// serve the API on addr, blocks like http.ListenAndServe
+func (a *API) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, a.Handler())
+}
+
+// serve the API on an already open listener (e.g. systemd socket)
+func (a *API) Serve(l net.Listener) error {
+	return http.Serve(l, a.Handler())
+}
+
+func (a *API) action(f func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		if err := f(); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"state": a.Control.State()})
+	}
+}
+
+func (a *API) rate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	pps, err := strconv.ParseFloat(r.FormValue("pps"), 64)
+	if err != nil || pps <= 0 {
+		writeError(w, http.StatusBadRequest, "pps must be greater 0")
+		return
+	}
+	if err := a.Control.SetRate(pps); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]float64{"pps": pps})
+}
+
+// server-sent events with the stats every second, until the client goes away
+func (a *API) statsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		b, _ := json.Marshal(a.Stats())
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (a *API) snapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	files, err := a.Control.Snapshot()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"files": files})
+}
+
+func (a *API) plan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	pps, err := strconv.ParseFloat(r.FormValue("pps"), 64)
+	if err != nil || pps < pacer.MinRate {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("pps must be at least %g", pacer.MinRate))
+		return
+	}
+	maxReq, err := strconv.Atoi(r.FormValue("max_req"))
+	if err != nil || maxReq < 0 {
+		writeError(w, http.StatusBadRequest, "max_req must be greater or equal 0")
+		return
+	}
+	var seed int64
+	if v := r.FormValue("seed"); len(v) > 0 {
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "seed must be an integer")
+			return
+		}
+	}
+	// all of it is checked before any is set, a bad shard leaves the plan
+	shard := r.FormValue("shard")
+	if _, err := cdr.ParseShard(shard); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := a.Control.SetPlan(pps, maxReq); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	plan := map[string]interface{}{"pps": pps, "max_req": maxReq}
+	if len(shard) > 0 {
+		if err := a.Control.SetShard(shard, seed); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		plan["shard"], plan["seed"] = shard, seed
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,137 @@
+package control
+
+import (
+	"fmt"
+	"sync"
+)
+
+// generator states
+const (
+	Waiting = "waiting"
+	Running = "running"
+	Paused  = "paused"
+	Stopped = "stopped"
+)
+
+// run control shared by the send loop and the control interfaces (API, signals)
+type Control struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	state string
+	// change the packets per second of the running generator
+	SetRateFunc func(pps float64) error
+	// set the load plan (packets per second and max requests) before start
+	SetPlanFunc func(pps float64, maxReq int) error
+	// set the identifiers shard (i/n) of the worker and the seed before
+	// start
+	SetShardFunc func(shard string, seed int64) error
+	// add, replace or remove (value nil) a custom field
+	SetFieldFunc func(id int, value *string) error
+	// write a snapshot of the stats to disk, returning its files
+	SnapshotFunc func() ([]string, error)
+}
+
+// start false waits for Start before the first packet
+func New(start bool) *Control {
+	c := &Control{state: Waiting}
+	if start {
+		c.state = Running
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// block while waiting or paused, false when the generator must stop
+func (c *Control) Wait() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.state == Waiting || c.state == Paused {
+		c.cond.Wait()
+	}
+	return c.state == Running
+}
+
+func (c *Control) set(from []string, to string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range from {
+		if c.state == f {
+			c.state = to
+			c.cond.Broadcast()
+			return nil
+		}
+	}
+	return fmt.Errorf("control: can't go from %s to %s", c.state, to)
+}
+
+func (c *Control) Start() error {
+	return c.set([]string{Waiting}, Running)
+}
+
+func (c *Control) Pause() error {
+	return c.set([]string{Running}, Paused)
+}
+
+func (c *Control) Resume() error {
+	return c.set([]string{Paused}, Running)
+}
+
+func (c *Control) Stop() error {
+	return c.set([]string{Waiting, Running, Paused}, Stopped)
+}
+
+func (c *Control) SetRate(pps float64) error {
+	if c.SetRateFunc == nil {
+		return fmt.Errorf("control: rate change not supported")
+	}
+	return c.SetRateFunc(pps)
+}
+
+func (c *Control) SetField(id int, value string) error {
+	if c.SetFieldFunc == nil {
+		return fmt.Errorf("control: custom fields change not supported")
+	}
+	return c.SetFieldFunc(id, &value)
+}
+
+func (c *Control) UnsetField(id int) error {
+	if c.SetFieldFunc == nil {
+		return fmt.Errorf("control: custom fields change not supported")
+	}
+	return c.SetFieldFunc(id, nil)
+}
+
+// snapshot the stats without stopping the run
+func (c *Control) Snapshot() ([]string, error) {
+	if c.SnapshotFunc == nil {
+		return nil, fmt.Errorf("control: snapshot not supported")
+	}
+	return c.SnapshotFunc()
+}
+
+// set the load plan, only before Start (coordinator mode)
+func (c *Control) SetPlan(pps float64, maxReq int) error {
+	if c.SetPlanFunc == nil {
+		return fmt.Errorf("control: plan not supported")
+	}
+	if c.State() != Waiting {
+		return fmt.Errorf("control: plan must be set before start")
+	}
+	return c.SetPlanFunc(pps, maxReq)
+}
+
+func (c *Control) SetShard(shard string, seed int64) error {
+	if c.SetShardFunc == nil {
+		return fmt.Errorf("control: shard not supported")
+	}
+	if c.State() != Waiting {
+		return fmt.Errorf("control: shard must be set before start")
+	}
+	return c.SetShardFunc(shard, seed)
+}
+
+func (c *Control) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
@@ -0,0 +1,490 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Empty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	mi := &file_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{0}
+}
+
+type State struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         string                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *State) Reset() {
+	*x = State{}
+	mi := &file_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *State) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*State) ProtoMessage() {}
+
+func (x *State) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use State.ProtoReflect.Descriptor instead.
+func (*State) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *State) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type Rate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// whole packets per second, use rate
+	//
+	// Deprecated: Marked as deprecated in control.proto.
+	Pps int64 `protobuf:"varint,1,opt,name=pps,proto3" json:"pps,omitempty"`
+	// packets per second, fractional for slow runs (0.2 is a packet every 5s)
+	Rate          float64 `protobuf:"fixed64,2,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rate) Reset() {
+	*x = Rate{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rate) ProtoMessage() {}
+
+func (x *Rate) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rate.ProtoReflect.Descriptor instead.
+func (*Rate) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+// Deprecated: Marked as deprecated in control.proto.
+func (x *Rate) GetPps() int64 {
+	if x != nil {
+		return x.Pps
+	}
+	return 0
+}
+
+func (x *Rate) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IntervalMs    int64                  `protobuf:"varint,1,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StatsRequest) GetIntervalMs() int64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+type TargetStats struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Addr               string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Sent               uint64                 `protobuf:"varint,2,opt,name=sent,proto3" json:"sent,omitempty"`
+	Acked              uint64                 `protobuf:"varint,3,opt,name=acked,proto3" json:"acked,omitempty"`
+	AvgLatencyMs       float64                `protobuf:"fixed64,4,opt,name=avg_latency_ms,json=avgLatencyMs,proto3" json:"avg_latency_ms,omitempty"`
+	ProxyStateMismatch uint64                 `protobuf:"varint,5,opt,name=proxy_state_mismatch,json=proxyStateMismatch,proto3" json:"proxy_state_mismatch,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *TargetStats) Reset() {
+	*x = TargetStats{}
+	mi := &file_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TargetStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TargetStats) ProtoMessage() {}
+
+func (x *TargetStats) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TargetStats.ProtoReflect.Descriptor instead.
+func (*TargetStats) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TargetStats) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *TargetStats) GetSent() uint64 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *TargetStats) GetAcked() uint64 {
+	if x != nil {
+		return x.Acked
+	}
+	return 0
+}
+
+func (x *TargetStats) GetAvgLatencyMs() float64 {
+	if x != nil {
+		return x.AvgLatencyMs
+	}
+	return 0
+}
+
+func (x *TargetStats) GetProxyStateMismatch() uint64 {
+	if x != nil {
+		return x.ProxyStateMismatch
+	}
+	return 0
+}
+
+type RunStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	State string                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	// rounded to whole packets per second, use rate
+	//
+	// Deprecated: Marked as deprecated in control.proto.
+	Pps            int64          `protobuf:"varint,2,opt,name=pps,proto3" json:"pps,omitempty"`
+	ElapsedSeconds float64        `protobuf:"fixed64,3,opt,name=elapsed_seconds,json=elapsedSeconds,proto3" json:"elapsed_seconds,omitempty"`
+	Total          uint64         `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Shed           uint64         `protobuf:"varint,5,opt,name=shed,proto3" json:"shed,omitempty"`
+	InFlightBytes  uint64         `protobuf:"varint,6,opt,name=in_flight_bytes,json=inFlightBytes,proto3" json:"in_flight_bytes,omitempty"`
+	Targets        []*TargetStats `protobuf:"bytes,7,rep,name=targets,proto3" json:"targets,omitempty"`
+	// packets per second, fractional
+	Rate          float64 `protobuf:"fixed64,8,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunStats) Reset() {
+	*x = RunStats{}
+	mi := &file_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunStats) ProtoMessage() {}
+
+func (x *RunStats) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunStats.ProtoReflect.Descriptor instead.
+func (*RunStats) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RunStats) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+// Deprecated: Marked as deprecated in control.proto.
+func (x *RunStats) GetPps() int64 {
+	if x != nil {
+		return x.Pps
+	}
+	return 0
+}
+
+func (x *RunStats) GetElapsedSeconds() float64 {
+	if x != nil {
+		return x.ElapsedSeconds
+	}
+	return 0
+}
+
+func (x *RunStats) GetTotal() uint64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *RunStats) GetShed() uint64 {
+	if x != nil {
+		return x.Shed
+	}
+	return 0
+}
+
+func (x *RunStats) GetInFlightBytes() uint64 {
+	if x != nil {
+		return x.InFlightBytes
+	}
+	return 0
+}
+
+func (x *RunStats) GetTargets() []*TargetStats {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+func (x *RunStats) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+var File_control_proto protoreflect.FileDescriptor
+
+const file_control_proto_rawDesc = "" +
+	"\n" +
+	"\rcontrol.proto\x12\tcontrolpb\"\a\n" +
+	"\x05Empty\"\x1d\n" +
+	"\x05State\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\"0\n" +
+	"\x04Rate\x12\x14\n" +
+	"\x03pps\x18\x01 \x01(\x03B\x02\x18\x01R\x03pps\x12\x12\n" +
+	"\x04rate\x18\x02 \x01(\x01R\x04rate\"/\n" +
+	"\fStatsRequest\x12\x1f\n" +
+	"\vinterval_ms\x18\x01 \x01(\x03R\n" +
+	"intervalMs\"\xa3\x01\n" +
+	"\vTargetStats\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x12\n" +
+	"\x04sent\x18\x02 \x01(\x04R\x04sent\x12\x14\n" +
+	"\x05acked\x18\x03 \x01(\x04R\x05acked\x12$\n" +
+	"\x0eavg_latency_ms\x18\x04 \x01(\x01R\favgLatencyMs\x120\n" +
+	"\x14proxy_state_mismatch\x18\x05 \x01(\x04R\x12proxyStateMismatch\"\xf7\x01\n" +
+	"\bRunStats\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\x12\x14\n" +
+	"\x03pps\x18\x02 \x01(\x03B\x02\x18\x01R\x03pps\x12'\n" +
+	"\x0felapsed_seconds\x18\x03 \x01(\x01R\x0eelapsedSeconds\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x04R\x05total\x12\x12\n" +
+	"\x04shed\x18\x05 \x01(\x04R\x04shed\x12&\n" +
+	"\x0fin_flight_bytes\x18\x06 \x01(\x04R\rinFlightBytes\x120\n" +
+	"\atargets\x18\a \x03(\v2\x16.controlpb.TargetStatsR\atargets\x12\x12\n" +
+	"\x04rate\x18\b \x01(\x01R\x04rate2\xd4\x02\n" +
+	"\aControl\x12+\n" +
+	"\x05Start\x12\x10.controlpb.Empty\x1a\x10.controlpb.State\x12*\n" +
+	"\x04Stop\x12\x10.controlpb.Empty\x1a\x10.controlpb.State\x12+\n" +
+	"\x05Pause\x12\x10.controlpb.Empty\x1a\x10.controlpb.State\x12,\n" +
+	"\x06Resume\x12\x10.controlpb.Empty\x1a\x10.controlpb.State\x12+\n" +
+	"\aSetRate\x12\x0f.controlpb.Rate\x1a\x0f.controlpb.Rate\x127\n" +
+	"\x05Stats\x12\x17.controlpb.StatsRequest\x1a\x13.controlpb.RunStats0\x01\x12/\n" +
+	"\x06Report\x12\x10.controlpb.Empty\x1a\x13.controlpb.RunStatsB;Z9github.com/routecall/go-radius-gen-acct/control/controlpbb\x06proto3"
+
+var (
+	file_control_proto_rawDescOnce sync.Once
+	file_control_proto_rawDescData []byte
+)
+
+func file_control_proto_rawDescGZIP() []byte {
+	file_control_proto_rawDescOnce.Do(func() {
+		file_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)))
+	})
+	return file_control_proto_rawDescData
+}
+
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_control_proto_goTypes = []any{
+	(*Empty)(nil),        // 0: controlpb.Empty
+	(*State)(nil),        // 1: controlpb.State
+	(*Rate)(nil),         // 2: controlpb.Rate
+	(*StatsRequest)(nil), // 3: controlpb.StatsRequest
+	(*TargetStats)(nil),  // 4: controlpb.TargetStats
+	(*RunStats)(nil),     // 5: controlpb.RunStats
+}
+var file_control_proto_depIdxs = []int32{
+	4, // 0: controlpb.RunStats.targets:type_name -> controlpb.TargetStats
+	0, // 1: controlpb.Control.Start:input_type -> controlpb.Empty
+	0, // 2: controlpb.Control.Stop:input_type -> controlpb.Empty
+	0, // 3: controlpb.Control.Pause:input_type -> controlpb.Empty
+	0, // 4: controlpb.Control.Resume:input_type -> controlpb.Empty
+	2, // 5: controlpb.Control.SetRate:input_type -> controlpb.Rate
+	3, // 6: controlpb.Control.Stats:input_type -> controlpb.StatsRequest
+	0, // 7: controlpb.Control.Report:input_type -> controlpb.Empty
+	1, // 8: controlpb.Control.Start:output_type -> controlpb.State
+	1, // 9: controlpb.Control.Stop:output_type -> controlpb.State
+	1, // 10: controlpb.Control.Pause:output_type -> controlpb.State
+	1, // 11: controlpb.Control.Resume:output_type -> controlpb.State
+	2, // 12: controlpb.Control.SetRate:output_type -> controlpb.Rate
+	5, // 13: controlpb.Control.Stats:output_type -> controlpb.RunStats
+	5, // 14: controlpb.Control.Report:output_type -> controlpb.RunStats
+	8, // [8:15] is the sub-list for method output_type
+	1, // [1:8] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_control_proto_init() }
+func file_control_proto_init() {
+	if File_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_control_proto_goTypes,
+		DependencyIndexes: file_control_proto_depIdxs,
+		MessageInfos:      file_control_proto_msgTypes,
+	}.Build()
+	File_control_proto = out.File
+	file_control_proto_goTypes = nil
+	file_control_proto_depIdxs = nil
+}
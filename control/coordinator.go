@@ -0,0 +1,130 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// drives worker generators (started with --api --wait-start) through their
+// control API and aggregates their stats
+type Coordinator struct {
+	// base URLs of the workers control API (e.g. http://10.0.0.1:8080)
+	Workers []string
+	Client  *http.Client
+}
+
+func NewCoordinator(workers []string) *Coordinator {
+	c := &Coordinator{Client: &http.Client{Timeout: 10 * time.Second}}
+	for _, w := range workers {
+		if !strings.Contains(w, "://") {
+			w = "http://" + w
+		}
+		c.Workers = append(c.Workers, strings.TrimSuffix(w, "/"))
+	}
+	return c
+}
+
+// share of total for the worker i, the remainder goes to the first ones
+func share(total int, i int, n int) int {
+	s := total / n
+	if i < total%n {
+		s++
+	}
+	return s
+}
+
+// split the load plan across the workers, each one its shard of the
+// identifiers of the seed, and start them
+func (c *Coordinator) Start(pps float64, maxReq int, unlimited bool, seed int64) error {
+	n := len(c.Workers)
+	for i, w := range c.Workers {
+		v := url.Values{}
+		v.Set("pps", strconv.FormatFloat(pps/float64(n), 'g', -1, 64))
+		v.Set("shard", strconv.Itoa(i)+"/"+strconv.Itoa(n))
+		v.Set("seed", strconv.FormatInt(seed, 10))
+		if unlimited {
+			v.Set("max_req", strconv.Itoa(maxReq))
+		} else {
+			v.Set("max_req", strconv.Itoa(share(maxReq, i, n)))
+		}
+		if err := c.post(w+"/plan", v); err != nil {
+			return err
+		}
+	}
+	for _, w := range c.Workers {
+		if err := c.post(w+"/start", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop all the workers
+func (c *Coordinator) Stop() error {
+	var last error
+	for _, w := range c.Workers {
+		if err := c.post(w+"/stop", nil); err != nil {
+			last = err
+		}
+	}
+	return last
+}
+
+// aggregated live stats of all the workers
+func (c *Coordinator) Stats() (Stats, error) {
+	return c.collect("/stats")
+}
+
+// aggregated final report, all workers must have finished
+func (c *Coordinator) Report() (Stats, error) {
+	return c.collect("/report")
+}
+
+func (c *Coordinator) collect(path string) (Stats, error) {
+	all := make([]Stats, 0, len(c.Workers))
+	for _, w := range c.Workers {
+		var s Stats
+		if err := c.get(w+path, &s); err != nil {
+			return Stats{}, err
+		}
+		all = append(all, s)
+	}
+	return Aggregate(all), nil
+}
+
+func (c *Coordinator) post(u string, v url.Values) error {
+	resp, err := c.Client.PostForm(u, v)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(u, resp)
+}
+
+func (c *Coordinator) get(u string, v interface{}) error {
+	resp, err := c.Client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(u, resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func checkResponse(u string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&e)
+	return fmt.Errorf("%s: %s %s", u, resp.Status, e.Error)
+}
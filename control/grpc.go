@@ -0,0 +1,108 @@
+package control
+
+import (
+	"context"
+	"math"
+	"net"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC control service (controlpb.Control), same operations as the HTTP API
+// plus a server-streaming Stats
+type GRPC struct {
+	controlpb.UnimplementedControlServer
+	Control *Control
+	// snapshot of the live stats
+	StatsFunc func() *controlpb.RunStats
+	// final report, nil while the run isn't finished
+	ReportFunc func() *controlpb.RunStats
+}
+
+// serve the service on addr, blocks until the listener fails
+func (g *GRPC) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return g.Serve(l)
+}
+
+// serve the service on an already open listener (e.g. systemd socket)
+func (g *GRPC) Serve(l net.Listener) error {
+	s := grpc.NewServer()
+	controlpb.RegisterControlServer(s, g)
+	return s.Serve(l)
+}
+
+func (g *GRPC) state(err error) (*controlpb.State, error) {
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &controlpb.State{State: g.Control.State()}, nil
+}
+
+func (g *GRPC) Start(context.Context, *controlpb.Empty) (*controlpb.State, error) {
+	return g.state(g.Control.Start())
+}
+
+func (g *GRPC) Stop(context.Context, *controlpb.Empty) (*controlpb.State, error) {
+	return g.state(g.Control.Stop())
+}
+
+func (g *GRPC) Pause(context.Context, *controlpb.Empty) (*controlpb.State, error) {
+	return g.state(g.Control.Pause())
+}
+
+func (g *GRPC) Resume(context.Context, *controlpb.Empty) (*controlpb.State, error) {
+	return g.state(g.Control.Resume())
+}
+
+func (g *GRPC) SetRate(ctx context.Context, r *controlpb.Rate) (*controlpb.Rate, error) {
+	// the clients sending the whole pps only
+	pps := r.GetRate()
+	if pps == 0 {
+		pps = float64(r.GetPps())
+	}
+	if pps <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "rate must be greater 0")
+	}
+	if err := g.Control.SetRate(pps); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &controlpb.Rate{Pps: int64(math.Round(pps)), Rate: pps}, nil
+}
+
+func (g *GRPC) Stats(r *controlpb.StatsRequest, stream controlpb.Control_StatsServer) error {
+	interval := time.Duration(r.GetIntervalMs()) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := stream.Send(g.StatsFunc()); err != nil {
+			return err
+		}
+		if g.Control.State() == Stopped {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-tick.C:
+		}
+	}
+}
+
+func (g *GRPC) Report(context.Context, *controlpb.Empty) (*controlpb.RunStats, error) {
+	report := g.ReportFunc()
+	if report == nil {
+		return nil, status.Error(codes.FailedPrecondition, "run not finished")
+	}
+	return report, nil
+}
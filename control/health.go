@@ -0,0 +1,36 @@
+package control
+
+import (
+	"net"
+	"net/http"
+)
+
+// liveness and readiness probes
+//
+//	GET /healthz   200 while the process is up
+//	GET /readyz    200 until the run is stopped (waiting, running or
+//	               paused), 503 after
+func HealthHandler(c *Control) http.Handler {
+	mux := http.NewServeMux()
+	registerHealth(mux, c)
+	return mux
+}
+
+func registerHealth(mux *http.ServeMux, c *Control) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		state := c.State()
+		code := http.StatusOK
+		if state == Stopped {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]string{"state": state})
+	})
+}
+
+// serve only the probes on l, for --health without the control API
+func ServeHealth(l net.Listener, c *Control) error {
+	return http.Serve(l, HealthHandler(c))
+}
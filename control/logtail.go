@@ -0,0 +1,36 @@
+package control
+
+import (
+	"strings"
+	"sync"
+)
+
+// io.Writer keeping the last lines written, used as log output so the web
+// UI can show the log tail
+type LogTail struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+}
+
+func NewLogTail(max int) *LogTail {
+	return &LogTail{max: max}
+}
+
+func (t *LogTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		t.lines = append(t.lines, line)
+	}
+	if len(t.lines) > t.max {
+		t.lines = append([]string(nil), t.lines[len(t.lines)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *LogTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.lines...)
+}
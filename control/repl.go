@@ -0,0 +1,92 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `commands:
+  start | stop | pause | resume
+  set pps N               change the packets per second
+  set field ID VALUE      add or replace a custom field (like --custom-fields)
+  unset field ID          remove a custom field
+  stats                   show the live stats
+  snapshot                write the stats (and profiles) to disk
+  help
+  quit                    stop the generator and exit`
+
+// interactive prompt (--interactive), returns on quit or end of input
+// after stopping the generator
+func REPL(in io.Reader, out io.Writer, c *Control, stats func() Stats) {
+	defer c.Stop()
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintln(out, "type help for the commands")
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) <= 0 {
+			continue
+		}
+		var err error
+		switch args[0] {
+		case "start":
+			err = c.Start()
+		case "stop":
+			err = c.Stop()
+		case "pause":
+			err = c.Pause()
+		case "resume":
+			err = c.Resume()
+		case "set", "unset":
+			err = replSet(c, args)
+		case "stats":
+			b, _ := json.MarshalIndent(stats(), "", "  ")
+			fmt.Fprintln(out, string(b))
+		case "snapshot":
+			var files []string
+			if files, err = c.Snapshot(); err == nil {
+				fmt.Fprintln(out, strings.Join(files, "\n"))
+			}
+		case "help":
+			fmt.Fprintln(out, replHelp)
+		case "quit", "exit":
+			return
+		default:
+			err = fmt.Errorf("unknown command %q, type help", args[0])
+		}
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+func replSet(c *Control, args []string) error {
+	switch {
+	case args[0] == "set" && len(args) == 3 && args[1] == "pps":
+		pps, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || pps <= 0 {
+			return fmt.Errorf("pps must be greater 0")
+		}
+		return c.SetRate(pps)
+	case args[0] == "set" && len(args) >= 4 && args[1] == "field":
+		id, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		return c.SetField(id, strings.Join(args[3:], " "))
+	case args[0] == "unset" && len(args) == 3 && args[1] == "field":
+		id, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		return c.UnsetField(id)
+	}
+	return fmt.Errorf("usage: set pps N | set field ID VALUE | unset field ID")
+}
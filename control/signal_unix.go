@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package control
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// pause on SIGTSTP (ctrl-z) and resume on SIGCONT, keeping the state and
+// counters instead of suspending the whole process
+func HandlePauseSignals(c *Control) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTSTP, syscall.SIGCONT)
+	go func() {
+		for sig := range sigs {
+			var err error
+			if sig == syscall.SIGTSTP {
+				if err = c.Pause(); err == nil {
+					log.Print("paused, send SIGCONT to resume")
+				}
+			} else {
+				if err = c.Resume(); err == nil {
+					log.Print("resumed")
+				}
+			}
+			if err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,4 @@
+package control
+
+// no job control signals on windows, pause with the API or --interactive
+func HandlePauseSignals(c *Control) {}
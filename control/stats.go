@@ -0,0 +1,346 @@
+package control
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/buildinfo"
+	"github.com/routecall/go-radius-gen-acct/control/controlpb"
+)
+
+// per target stats
+type TargetStats struct {
+	Addr               string  `json:"addr"`
+	Transport          string  `json:"transport,omitempty"`
+	Sent               uint64  `json:"sent"`
+	Acked              uint64  `json:"acked"`
+	AvgLatencyMs       float64 `json:"avg_latency_ms"`
+	ProxyStateMismatch uint64  `json:"proxy_state_mismatch,omitempty"`
+	Lost               uint64  `json:"lost,omitempty"`
+	PortUnreachable    uint64  `json:"port_unreachable,omitempty"`
+	Unreachable        uint64  `json:"unreachable,omitempty"`
+	// responses after the timeout of their request, and of no request
+	Late  uint64 `json:"late,omitempty"`
+	Stray uint64 `json:"stray,omitempty"`
+}
+
+// per scenario stats (--scenario)
+type ScenarioStats struct {
+	Name         string  `json:"name"`
+	Calls        uint64  `json:"calls"`
+	Sent         uint64  `json:"sent"`
+	Acked        uint64  `json:"acked"`
+	Failed       uint64  `json:"failed"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// average round trip time of the answered requests by component: the
+// client queueing, the retransmissions and the server
+type BudgetStats struct {
+	Answered uint64  `json:"answered"`
+	QueueMs  float64 `json:"queue_ms"`
+	RetryMs  float64 `json:"retry_ms"`
+	WaitMs   float64 `json:"wait_ms"`
+}
+
+// an interval of the --adaptive rate: its rate, answers and the overload
+// sign the rate was lowered on, empty when it was raised
+type AdaptiveStep struct {
+	Elapsed float64 `json:"elapsed_seconds"`
+	PPS     float64 `json:"pps"`
+	Sent    uint64  `json:"sent"`
+	Failed  uint64  `json:"failed"`
+	P99Ms   float64 `json:"p99_ms"`
+	Signal  string  `json:"signal,omitempty"`
+}
+
+// a --slo response time objective over the run: the requests meeting it
+// and not, the burn rate of its error budget and the warnings logged
+type SLOStats struct {
+	SLO       string  `json:"slo"`
+	Target    float64 `json:"target"`
+	LatencyMs float64 `json:"latency_ms"`
+	Good      uint64  `json:"good"`
+	Bad       uint64  `json:"bad"`
+	BurnRate  float64 `json:"burn_rate"`
+	Alerts    uint64  `json:"alerts"`
+}
+
+// a distinct error of the run: how often and when it happened and the
+// session of a request which hit it, empty for the errors of no request
+type ErrorStats struct {
+	Error     string    `json:"error"`
+	Count     uint64    `json:"count"`
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	SessionId string    `json:"session_id,omitempty"`
+}
+
+// sort the errors the most frequent first, then by text
+func SortErrors(errors []ErrorStats) {
+	sort.Slice(errors, func(i, j int) bool {
+		if errors[i].Count != errors[j].Count {
+			return errors[i].Count > errors[j].Count
+		}
+		return errors[i].Error < errors[j].Error
+	})
+}
+
+// snapshot of the run stats, served by the control interfaces
+type Stats struct {
+	State         string          `json:"state"`
+	PPS           float64         `json:"pps"`
+	Elapsed       float64         `json:"elapsed_seconds"`
+	Total         uint64          `json:"total"`
+	Shed          uint64          `json:"shed"`
+	InFlightBytes uint64          `json:"in_flight_bytes"`
+	IDExhausted   uint64          `json:"id_exhausted,omitempty"`
+	ExpectFailed  uint64          `json:"expect_failed,omitempty"`
+	Targets       []TargetStats   `json:"targets"`
+	Scenarios     []ScenarioStats `json:"scenarios,omitempty"`
+	// answers rejecting the requests by code and Error-Cause
+	Rejects map[string]uint64 `json:"rejects,omitempty"`
+	// --label of the run
+	Labels map[string]string `json:"labels,omitempty"`
+	// latency budget, nil before the first answer
+	Budget *BudgetStats `json:"latency_budget,omitempty"`
+	// accounting-responses missing each --expect-attr
+	ExpectMisses map[string]uint64 `json:"expect_misses,omitempty"`
+	// --adaptive rate trajectory and the mean rate the servers pushed back
+	// at
+	Adaptive []AdaptiveStep `json:"adaptive,omitempty"`
+	KneePPS  float64        `json:"knee_pps,omitempty"`
+	// --slo objectives
+	SLOs []SLOStats `json:"slos,omitempty"`
+	// requests in flight (a goroutine each) and their --max-in-flight,
+	// the requests which waited for room and the ones due meanwhile
+	InFlight      int    `json:"in_flight"`
+	MaxInFlight   int    `json:"max_in_flight,omitempty"`
+	InFlightWaits uint64 `json:"in_flight_waits,omitempty"`
+	QueueDepth    uint64 `json:"queue_depth"`
+	Goroutines    int    `json:"goroutines"`
+	// requests sent with a bad Request Authenticator (--bad-auth), and
+	// the ones answered anyway
+	BadAuth         uint64 `json:"bad_auth,omitempty"`
+	BadAuthAnswered uint64 `json:"bad_auth_answered,omitempty"`
+	// distinct errors of the run, the most frequent first
+	Errors []ErrorStats `json:"errors,omitempty"`
+	// binary of the run
+	Build *buildinfo.Info `json:"build,omitempty"`
+	// requests held by the --outage blackout, and the ones sent since
+	OutageHeld uint64 `json:"outage_held,omitempty"`
+	OutageSent uint64 `json:"outage_sent,omitempty"`
+	// generated values rejected by --value-check, by attribute and reason
+	ValueViolations map[string]uint64 `json:"value_violations,omitempty"`
+	// NAS-Ports of the --nas-port-range taken by open sessions, and the
+	// sessions given one already taken (every port taken)
+	NASPortsInUse     uint64 `json:"nas_ports_in_use,omitempty"`
+	NASPortsExhausted uint64 `json:"nas_ports_exhausted,omitempty"`
+	// seed of the random draws and the --shard of the worker
+	Seed  int64  `json:"seed,omitempty"`
+	Shard string `json:"shard,omitempty"`
+}
+
+// stats on the gRPC message
+func (s Stats) Proto() *controlpb.RunStats {
+	pb := &controlpb.RunStats{
+		State:          s.State,
+		Pps:            int64(math.Round(s.PPS)),
+		Rate:           s.PPS,
+		ElapsedSeconds: s.Elapsed,
+		Total:          s.Total,
+		Shed:           s.Shed,
+		InFlightBytes:  s.InFlightBytes,
+	}
+	for _, t := range s.Targets {
+		pb.Targets = append(pb.Targets, &controlpb.TargetStats{
+			Addr:               t.Addr,
+			Sent:               t.Sent,
+			Acked:              t.Acked,
+			AvgLatencyMs:       t.AvgLatencyMs,
+			ProxyStateMismatch: t.ProxyStateMismatch,
+		})
+	}
+	return pb
+}
+
+// sum the stats of several generators (coordinator mode), targets with
+// the same address are merged
+func Aggregate(all []Stats) Stats {
+	agg := Stats{State: Stopped}
+	index := make(map[string]int)
+	scenarios := make(map[string]int)
+	slos := make(map[string]int)
+	errs := make(map[string]int)
+	for i, s := range all {
+		if s.State != Stopped {
+			agg.State = s.State
+		}
+		agg.PPS += s.PPS
+		if s.Elapsed > agg.Elapsed {
+			agg.Elapsed = s.Elapsed
+		}
+		agg.Total += s.Total
+		agg.Shed += s.Shed
+		agg.InFlightBytes += s.InFlightBytes
+		agg.InFlight += s.InFlight
+		agg.MaxInFlight += s.MaxInFlight
+		agg.InFlightWaits += s.InFlightWaits
+		agg.QueueDepth += s.QueueDepth
+		agg.Goroutines += s.Goroutines
+		agg.BadAuth += s.BadAuth
+		agg.BadAuthAnswered += s.BadAuthAnswered
+		agg.OutageHeld += s.OutageHeld
+		agg.OutageSent += s.OutageSent
+		agg.NASPortsInUse += s.NASPortsInUse
+		agg.NASPortsExhausted += s.NASPortsExhausted
+		agg.IDExhausted += s.IDExhausted
+		agg.ExpectFailed += s.ExpectFailed
+		agg.Labels = commonLabels(agg.Labels, s.Labels, i == 0)
+		// the seed of the run, the workers have their own shard
+		if i == 0 || agg.Seed == s.Seed {
+			agg.Seed = s.Seed
+		} else {
+			agg.Seed = 0
+		}
+		if i == 0 || agg.Build != nil && s.Build != nil && agg.Build.String() == s.Build.String() {
+			agg.Build = s.Build
+		} else {
+			// the generators run different binaries
+			agg.Build = nil
+		}
+		agg.Budget = addBudget(agg.Budget, s.Budget)
+		for k, n := range s.ValueViolations {
+			if agg.ValueViolations == nil {
+				agg.ValueViolations = make(map[string]uint64)
+			}
+			agg.ValueViolations[k] += n
+		}
+		for k, n := range s.ExpectMisses {
+			if agg.ExpectMisses == nil {
+				agg.ExpectMisses = make(map[string]uint64)
+			}
+			agg.ExpectMisses[k] += n
+		}
+		for k, n := range s.Rejects {
+			if agg.Rejects == nil {
+				agg.Rejects = make(map[string]uint64)
+			}
+			agg.Rejects[k] += n
+		}
+		for _, t := range s.Targets {
+			// the same server over another transport is another target
+			key := t.Transport + "://" + t.Addr
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(agg.Targets)
+				agg.Targets = append(agg.Targets, t)
+				continue
+			}
+			a := &agg.Targets[i]
+			if acked := a.Acked + t.Acked; acked > 0 {
+				a.AvgLatencyMs = (a.AvgLatencyMs*float64(a.Acked) + t.AvgLatencyMs*float64(t.Acked)) / float64(acked)
+			}
+			a.Sent += t.Sent
+			a.Acked += t.Acked
+			a.ProxyStateMismatch += t.ProxyStateMismatch
+			a.Lost += t.Lost
+			a.PortUnreachable += t.PortUnreachable
+			a.Unreachable += t.Unreachable
+			a.Late += t.Late
+			a.Stray += t.Stray
+		}
+		for _, sc := range s.Scenarios {
+			i, ok := scenarios[sc.Name]
+			if !ok {
+				scenarios[sc.Name] = len(agg.Scenarios)
+				agg.Scenarios = append(agg.Scenarios, sc)
+				continue
+			}
+			a := &agg.Scenarios[i]
+			if acked := a.Acked + sc.Acked; acked > 0 {
+				a.AvgLatencyMs = (a.AvgLatencyMs*float64(a.Acked) + sc.AvgLatencyMs*float64(sc.Acked)) / float64(acked)
+			}
+			a.Calls += sc.Calls
+			a.Sent += sc.Sent
+			a.Acked += sc.Acked
+			a.Failed += sc.Failed
+		}
+		for _, o := range s.SLOs {
+			i, ok := slos[o.SLO]
+			if !ok {
+				slos[o.SLO] = len(agg.SLOs)
+				agg.SLOs = append(agg.SLOs, o)
+				continue
+			}
+			a := &agg.SLOs[i]
+			a.Good += o.Good
+			a.Bad += o.Bad
+			a.Alerts += o.Alerts
+			if a.Good+a.Bad > 0 {
+				a.BurnRate = float64(a.Bad) / float64(a.Good+a.Bad) / (1 - a.Target)
+			}
+		}
+		for _, e := range s.Errors {
+			i, ok := errs[e.Error]
+			if !ok {
+				errs[e.Error] = len(agg.Errors)
+				agg.Errors = append(agg.Errors, e)
+				continue
+			}
+			a := &agg.Errors[i]
+			a.Count += e.Count
+			if e.First.Before(a.First) {
+				a.First = e.First
+			}
+			if e.Last.After(a.Last) {
+				a.Last = e.Last
+			}
+			if len(a.SessionId) <= 0 {
+				a.SessionId = e.SessionId
+			}
+		}
+	}
+	SortErrors(agg.Errors)
+	return agg
+}
+
+// the labels of agg also on labels, all of them for the first generator;
+// the ones the generators differ on don't describe the aggregate
+func commonLabels(agg, labels map[string]string, first bool) map[string]string {
+	if first {
+		agg = make(map[string]string, len(labels))
+		for k, v := range labels {
+			agg[k] = v
+		}
+		return agg
+	}
+	for k, v := range agg {
+		if w, ok := labels[k]; !ok || w != v {
+			delete(agg, k)
+		}
+	}
+	return agg
+}
+
+// the budget of a and b weighted by their answers
+func addBudget(a, b *BudgetStats) *BudgetStats {
+	switch {
+	case b == nil:
+		return a
+	case a == nil:
+		sum := *b
+		return &sum
+	}
+	n := a.Answered + b.Answered
+	weigh := func(x, y float64) float64 {
+		return (x*float64(a.Answered) + y*float64(b.Answered)) / float64(n)
+	}
+	return &BudgetStats{
+		Answered: n,
+		QueueMs:  weigh(a.QueueMs, b.QueueMs),
+		RetryMs:  weigh(a.RetryMs, b.RetryMs),
+		WaitMs:   weigh(a.WaitMs, b.WaitMs),
+	}
+}
@@ -0,0 +1,103 @@
+package control
+
+// single page web UI served on / of the control API
+const uiHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-radius-gen-acct</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+button { margin-right: 4px; }
+#state { font-weight: bold; }
+canvas { border: 1px solid #ccc; margin-top: 10px; }
+table { border-collapse: collapse; margin-top: 10px; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+pre { background: #f4f4f4; height: 200px; overflow: auto; font-size: 12px; }
+</style>
+</head>
+<body>
+<h2>go-radius-gen-acct</h2>
+<div>
+  state: <span id="state">-</span>
+  <button onclick="post('/start')">start</button>
+  <button onclick="post('/pause')">pause</button>
+  <button onclick="post('/resume')">resume</button>
+  <button onclick="post('/stop')">stop</button>
+</div>
+<div>
+  pps: <input id="rate" type="range" min="1" max="10000" oninput="rateLabel.textContent = this.value" onchange="post('/rate?pps=' + this.value)">
+  <span id="rateLabel">-</span>
+</div>
+<canvas id="chart" width="800" height="200"></canvas>
+<div>accounting-request per second (blue), avg latency ms (red)</div>
+<table id="targets"></table>
+<h3>log</h3>
+<pre id="log"></pre>
+<script>
+var points = [], last = null, rateSet = false;
+function post(path) {
+  fetch(path, {method: 'POST'}).then(function(r) { return r.json(); }).then(function(j) {
+    if (j.error) { alert(j.error); }
+  });
+}
+function draw() {
+  var c = document.getElementById('chart'), ctx = c.getContext('2d');
+  ctx.clearRect(0, 0, c.width, c.height);
+  [['pps', 'blue'], ['lat', 'red']].forEach(function(serie) {
+    var max = 1;
+    points.forEach(function(p) { max = Math.max(max, p[serie[0]]); });
+    ctx.strokeStyle = serie[1];
+    ctx.beginPath();
+    points.forEach(function(p, i) {
+      var x = i * c.width / 120, y = c.height - p[serie[0]] * (c.height - 10) / max;
+      if (i == 0) { ctx.moveTo(x, y); } else { ctx.lineTo(x, y); }
+    });
+    ctx.stroke();
+    ctx.fillStyle = serie[1];
+    ctx.fillText(serie[0] + ' max ' + max.toFixed(1), 5, serie[0] == 'pps' ? 12 : 24);
+  });
+}
+function row(tag, cells) {
+  var tr = document.createElement('tr');
+  cells.forEach(function(v) {
+    var cell = document.createElement(tag);
+    cell.textContent = v;
+    tr.appendChild(cell);
+  });
+  return tr;
+}
+function update(s) {
+  document.getElementById('state').textContent = s.state;
+  if (!rateSet) {
+    document.getElementById('rate').value = s.pps;
+    document.getElementById('rateLabel').textContent = s.pps;
+    rateSet = true;
+  }
+  var lat = 0, acked = 0, table = document.getElementById('targets');
+  // cells as text, the addresses come from the flags and /plan
+  table.replaceChildren(row('th', ['server', 'sent', 'acked', 'avg latency ms']));
+  (s.targets || []).forEach(function(t) {
+    lat += t.avg_latency_ms * t.acked;
+    acked += t.acked;
+    table.appendChild(row('td', [t.addr, t.sent, t.acked, t.avg_latency_ms.toFixed(2)]));
+  });
+  if (last) {
+    points.push({pps: (s.total - last.total) / Math.max(s.elapsed_seconds - last.elapsed_seconds, 0.001), lat: acked ? lat / acked : 0});
+    if (points.length > 120) { points.shift(); }
+    draw();
+  }
+  last = s;
+}
+new EventSource('/stats/stream').onmessage = function(e) { update(JSON.parse(e.data)); };
+setInterval(function() {
+  fetch('/log').then(function(r) { return r.json(); }).then(function(lines) {
+    var log = document.getElementById('log');
+    log.textContent = lines.join('\n');
+    log.scrollTop = log.scrollHeight;
+  });
+}, 2000);
+</script>
+</body>
+</html>
+`
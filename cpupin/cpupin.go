@@ -0,0 +1,95 @@
+// Package cpupin keeps the generator on a set of CPUs (--cpus): the
+// process and GOMAXPROCS are limited to them and the readers of the shared
+// sockets are pinned one CPU each, their sockets steered to it with
+// SO_INCOMING_CPU, so the scheduler and the interrupts of the other CPUs
+// (another NUMA node) don't add to the measured tail latency.
+package cpupin
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var ErrNotSupported = errors.New("cpupin: not supported on this platform")
+
+// highest CPU number of a list plus one
+const maxCPUs = 1024
+
+// parse a CPU list as in taskset -c and cpuset, "0-3,8"
+func Parse(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var cpus []int
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) <= 0 {
+			continue
+		}
+		lo, hi := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			lo, hi = item[:i], item[i+1:]
+		}
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("cpus: bad cpu %q", item)
+		}
+		last, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil || last < first {
+			return nil, fmt.Errorf("cpus: bad cpu range %q", item)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if cpu >= maxCPUs {
+				return nil, fmt.Errorf("cpus: cpu %d over %d", cpu, maxCPUs-1)
+			}
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	if len(cpus) <= 0 {
+		return nil, fmt.Errorf("cpus: no cpu")
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// run the process on cpus only, with a P each
+func Process(cpus []int) error {
+	if err := setProcess(cpus); err != nil {
+		return err
+	}
+	runtime.GOMAXPROCS(len(cpus))
+	return nil
+}
+
+// lock the calling goroutine to its thread and run the thread on cpu only;
+// the goroutine keeps the thread until it returns
+func Thread(cpu int) error {
+	runtime.LockOSThread()
+	return setThread(cpu)
+}
+
+// net.Dialer Control steering the packets of the socket to cpu
+// (SO_INCOMING_CPU), doing nothing where there is no such option; control
+// is run first when not nil
+func IncomingCPU(cpu int, control func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if control != nil {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+		var err error
+		cerr := c.Control(func(fd uintptr) {
+			err = setIncomingCPU(fd, cpu)
+		})
+		if cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
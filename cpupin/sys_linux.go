@@ -0,0 +1,58 @@
+package cpupin
+
+import (
+	"io/ioutil"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// not in syscall
+const soIncomingCPU = 49
+
+// sched_setaffinity mask
+type cpuSet [maxCPUs / 64]uint64
+
+func mask(cpus ...int) *cpuSet {
+	var set cpuSet
+	for _, cpu := range cpus {
+		set[cpu/64] |= 1 << uint(cpu%64)
+	}
+	return &set
+}
+
+func setAffinity(tid int, set *cpuSet) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), unsafe.Sizeof(*set), uintptr(unsafe.Pointer(set)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// every thread of the process, the threads started later inherit it
+func setProcess(cpus []int) error {
+	tasks, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	set := mask(cpus...)
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		if err := setAffinity(tid, set); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// tid 0 is the calling thread
+func setThread(cpu int) error {
+	return setAffinity(0, mask(cpu))
+}
+
+func setIncomingCPU(fd uintptr, cpu int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soIncomingCPU, cpu)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package cpupin
+
+func setProcess(cpus []int) error {
+	return ErrNotSupported
+}
+
+func setThread(cpu int) error {
+	return ErrNotSupported
+}
+
+// no SO_INCOMING_CPU, the packets go to any CPU
+func setIncomingCPU(fd uintptr, cpu int) error {
+	return nil
+}
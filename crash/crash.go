@@ -0,0 +1,157 @@
+// Package crash writes a diagnostics bundle (config, log tail, stats and
+// a goroutine dump) when the generator panics or dies on a fatal error,
+// to attach to bug reports, and snapshots of the running generator on
+// demand.
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+type Reporter struct {
+	// directory of the bundles, empty disables them
+	Dir string
+	// name prefix of the bundle files
+	Name string
+	// options of the run, with the secrets already removed
+	Config interface{}
+	// partial stats, optional
+	Stats func() interface{}
+	// last log lines, optional
+	Log func() []string
+}
+
+// write the bundle, returning its path
+func (r *Reporter) Write(reason string, stack []byte) (string, error) {
+	if len(r.Dir) <= 0 {
+		return "", nil
+	}
+	now := time.Now()
+	path := filepath.Join(r.Dir, fmt.Sprintf("%s-crash-%s.txt", r.Name, now.Format("20060102-150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "# reason")
+	fmt.Fprintln(f, reason)
+	fmt.Fprintf(f, "time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(f, "go: %s %s/%s, pid %d\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, os.Getpid())
+	if len(stack) > 0 {
+		fmt.Fprintln(f, "\n# stack")
+		f.Write(stack)
+	}
+	fmt.Fprintln(f, "\n# config")
+	writeJSON(f, r.Config)
+	if r.Stats != nil {
+		fmt.Fprintln(f, "\n# stats")
+		writeJSON(f, r.Stats())
+	}
+	if r.Log != nil {
+		fmt.Fprintln(f, "\n# log")
+		for _, line := range r.Log() {
+			fmt.Fprintln(f, line)
+		}
+	}
+	fmt.Fprintln(f, "\n# goroutines")
+	pprof.Lookup("goroutine").WriteTo(f, 2)
+	return path, nil
+}
+
+func writeJSON(f *os.File, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(f, "error:", err)
+		return
+	}
+	f.Write(append(b, '\n'))
+}
+
+// stats of the running generator at a moment (see Snapshot)
+type snapshot struct {
+	Time   time.Time   `json:"time"`
+	Config interface{} `json:"config"`
+	Stats  interface{} `json:"stats,omitempty"`
+}
+
+// write the stats and config of the running generator to dir, with
+// profiles its goroutines and heap profile too (go tool pprof), returning
+// the files written; the run goes on
+func (r *Reporter) Snapshot(dir string, profiles bool) ([]string, error) {
+	now := time.Now()
+	base := filepath.Join(dir, fmt.Sprintf("%s-snapshot-%s", r.Name, now.Format("20060102-150405.000")))
+	s := snapshot{Time: now, Config: r.Config}
+	if r.Stats != nil {
+		s.Stats = r.Stats()
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{base + ".json"}
+	if err := ioutil.WriteFile(files[0], append(b, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	if !profiles {
+		return files, nil
+	}
+	for _, p := range []struct {
+		name, file string
+		debug      int
+	}{
+		{"goroutine", base + ".goroutine.txt", 2},
+		{"heap", base + ".heap.pprof", 0},
+	} {
+		f, err := os.Create(p.file)
+		if err != nil {
+			return files, err
+		}
+		err = pprof.Lookup(p.name).WriteTo(f, p.debug)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, p.file)
+	}
+	return files, nil
+}
+
+// write the bundle of a panic, the caller panics again after
+func (r *Reporter) Panic(v interface{}, stack []byte) {
+	r.report(fmt.Sprint("panic: ", v), stack)
+}
+
+// deferred on main, writes the bundle of a panic and keeps panicking
+func (r *Reporter) Recover() {
+	if v := recover(); v != nil {
+		buf := make([]byte, 64<<10)
+		r.Panic(v, buf[:runtime.Stack(buf, false)])
+		panic(v)
+	}
+}
+
+// log.Fatal writing the bundle first
+func (r *Reporter) Fatal(v ...interface{}) {
+	r.report(fmt.Sprint(v...), nil)
+	log.Fatal(v...)
+}
+
+func (r *Reporter) report(reason string, stack []byte) {
+	path, err := r.Write(reason, stack)
+	if err != nil {
+		log.Print("crash bundle: ", err)
+	} else if len(path) > 0 {
+		log.Print("crash bundle written to ", path)
+	}
+}
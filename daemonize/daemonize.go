@@ -0,0 +1,18 @@
+// Package daemonize runs the generator in background (--daemon), on the
+// platforms go-daemon supports.
+package daemonize
+
+import "errors"
+
+var (
+	// another daemon holds the pid file
+	ErrRunning = errors.New("already running, the pid file is locked")
+	// no daemon mode on this platform
+	ErrNotSupported = errors.New("daemon mode is not supported on this platform, run in foreground (e.g. under a service wrapper)")
+)
+
+// files of the daemon, the log file gets its stdout/stderr
+type Context struct {
+	PidFileName string
+	LogFileName string
+}
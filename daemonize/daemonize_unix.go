@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package daemonize
+
+import (
+	daemon "github.com/sevlyar/go-daemon"
+)
+
+// true when Reborn can run in background
+const Supported = true
+
+// fork the daemon, on the parent, parent is true and the caller must
+// return, on the daemon release must be called before exiting
+func (c *Context) Reborn() (parent bool, release func(), err error) {
+	cntxt := &daemon.Context{
+		PidFileName: c.PidFileName,
+		PidFilePerm: 0644,
+		LogFileName: c.LogFileName,
+		LogFilePerm: 0640,
+		WorkDir:     "./",
+		Umask:       027,
+	}
+	d, err := cntxt.Reborn()
+	if err == daemon.ErrWouldBlock {
+		return false, nil, ErrRunning
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if d != nil {
+		return true, nil, nil
+	}
+	return false, func() { cntxt.Release() }, nil
+}
@@ -0,0 +1,8 @@
+package daemonize
+
+// true when Reborn can run in background
+const Supported = false
+
+func (c *Context) Reborn() (parent bool, release func(), err error) {
+	return false, nil, ErrNotSupported
+}
@@ -0,0 +1,73 @@
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"layeh.com/radius"
+)
+
+// FreeRADIUS detail file writer (--detail-file), safe for concurrent use
+type DetailWriter struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+	r  Redaction
+}
+
+// create or append to the detail file on path, like the detail module,
+// with the attributes of r masked
+func CreateDetail(path string, r Redaction) (*DetailWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &DetailWriter{f: f, w: bufio.NewWriter(f), r: r}, nil
+}
+
+// write the packet as a detail record sent at t
+func (d *DetailWriter) Write(p *radius.Packet, t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return FprintDetail(d.w, p, t, d.r)
+}
+
+func (d *DetailWriter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.w.Flush(); err != nil {
+		d.f.Close()
+		return err
+	}
+	return d.f.Close()
+}
+
+// print the packet as a FreeRADIUS detail record: the ctime header, the
+// attributes indented by a tab (the ones of r masked) and the Timestamp,
+// ending on a blank line
+func FprintDetail(w io.Writer, p *radius.Packet, t time.Time, r Redaction) error {
+	fmt.Fprintf(w, "%s\n", t.Format("Mon Jan _2 15:04:05 2006"))
+	fmt.Fprintf(w, "\tPacket-Type = %s\n", p.Code)
+	for _, typ := range Types(p) {
+		for _, a := range p.Attributes[typ] {
+			fmt.Fprintf(w, "\t%s = %s\n", Name(typ), detailValue(typ, a, r))
+		}
+	}
+	_, err := fmt.Fprintf(w, "\tTimestamp = %d\n\n", t.Unix())
+	return err
+}
+
+// dates as the detail module prints them, the rest as Value
+func detailValue(t radius.Type, a radius.Attribute, r Redaction) string {
+	if Dictionary[t].Kind == Date && !r[t] {
+		if d, err := radius.Date(a); err == nil {
+			return strconv.Quote(d.Format("Jan _2 2006 15:04:05 MST"))
+		}
+	}
+	return r.Value(t, a)
+}
@@ -0,0 +1,140 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"layeh.com/radius"
+)
+
+// attribute value kinds
+const (
+	Octets  = "octets"
+	String  = "string"
+	Integer = "integer"
+	IPAddr  = "ipaddr"
+	Date    = "date"
+)
+
+// dictionary entry
+type Attr struct {
+	Name string
+	Kind string
+}
+
+// attributes the generator sends (RFC 2865/2866 and ./dictionary.routecall.opensips)
+var Dictionary = map[radius.Type]Attr{
+	1:   {"User-Name", String},
+	4:   {"NAS-IP-Address", IPAddr},
+	5:   {"NAS-Port", Integer},
+	6:   {"Service-Type", Integer},
+	7:   {"Framed-Protocol", Integer},
+	8:   {"Framed-IP-Address", IPAddr},
+	24:  {"State", Octets},
+	25:  {"Class", Octets},
+	30:  {"Called-Station-Id", String},
+	31:  {"Calling-Station-Id", String},
+	32:  {"NAS-Identifier", String},
+	33:  {"Proxy-State", Octets},
+	40:  {"Acct-Status-Type", Integer},
+	41:  {"Acct-Delay-Time", Integer},
+	42:  {"Acct-Input-Octets", Integer},
+	43:  {"Acct-Output-Octets", Integer},
+	44:  {"Acct-Session-Id", String},
+	46:  {"Acct-Session-Time", Integer},
+	47:  {"Acct-Input-Packets", Integer},
+	48:  {"Acct-Output-Packets", Integer},
+	49:  {"Acct-Terminate-Cause", Integer},
+	52:  {"Acct-Input-Gigawords", Integer},
+	53:  {"Acct-Output-Gigawords", Integer},
+	55:  {"Event-Timestamp", Date},
+	61:  {"NAS-Port-Type", Integer},
+	101: {"Sip-From-Tag", String},
+	102: {"Sip-Method", Integer},
+	103: {"Sip-Response-Code", String},
+	104: {"Sip-To-Tag", String},
+	105: {"Sip-Call-Id", String},
+	110: {"Sip-Caller-Id", String},
+	111: {"Sip-Callee-Id", String},
+	112: {"Sip-Dst-Number", String},
+	113: {"Sip-End-Reason", String},
+	114: {"Sip-Session", String},
+	115: {"Sip-Call-Reason", String},
+	116: {"Sip-Call-Duration", Integer},
+	117: {"Sip-Call-MSDuration", Integer},
+	118: {"Sip-Call-Setuptime", Integer},
+	119: {"Sip-Call-Created", String},
+	120: {"Sip-Acct-Status-Type", Integer},
+	122: {"Sip-Service-Type", Integer},
+	123: {"Sip-Event-Timestamp", Date},
+	124: {"Sip-Acct-Session-Id", String},
+}
+
+// dictionary name of the attribute type, or Attr-N when unknown
+func Name(t radius.Type) string {
+	if a, ok := Dictionary[t]; ok {
+		return a.Name
+	}
+	return "Attr-" + strconv.Itoa(int(t))
+}
+
+// attribute type of a dictionary name, Attr-N or a number
+func Lookup(name string) (radius.Type, bool) {
+	for t, a := range Dictionary {
+		if strings.EqualFold(a.Name, name) {
+			return t, true
+		}
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, "Attr-"))
+	if err != nil || n < 1 || n > 255 {
+		return 0, false
+	}
+	return radius.Type(n), true
+}
+
+// attribute value formatted according its dictionary kind
+func Value(t radius.Type, a radius.Attribute) string {
+	switch Dictionary[t].Kind {
+	case String:
+		return strconv.Quote(radius.String(a))
+	case Integer:
+		if i, err := radius.Integer(a); err == nil {
+			return strconv.FormatUint(uint64(i), 10)
+		}
+	case IPAddr:
+		if ip, err := radius.IPAddr(a); err == nil {
+			return net.IP(ip).String()
+		}
+	case Date:
+		if d, err := radius.Date(a); err == nil {
+			return d.UTC().Format(time.RFC3339)
+		}
+	}
+	return fmt.Sprintf("0x%x", []byte(a))
+}
+
+// attribute types of the packet in ascending order
+func Types(p *radius.Packet) []radius.Type {
+	types := make([]radius.Type, 0, len(p.Attributes))
+	for t := range p.Attributes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// print the decoded packet, one attribute per line, with the attributes of
+// r masked
+func Fprint(w io.Writer, p *radius.Packet, r Redaction) {
+	fmt.Fprintf(w, "%s Id %d\n", p.Code, p.Identifier)
+	for _, t := range Types(p) {
+		for _, a := range p.Attributes[t] {
+			fmt.Fprintf(w, "  %s = %s\n", Name(t), r.Value(t, a))
+		}
+	}
+}
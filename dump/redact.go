@@ -0,0 +1,44 @@
+package dump
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"layeh.com/radius"
+)
+
+// shown instead of the value of a redacted attribute
+const Mask = "<redacted>"
+
+// attributes masked on the dumps, the detail file and the logs (--redact),
+// nil for none
+type Redaction map[radius.Type]bool
+
+// parse "Calling-Station-Id,User-Name", dictionary names, Attr-N or
+// numbers; empty for none
+func ParseRedaction(s string) (Redaction, error) {
+	var r Redaction
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) <= 0 {
+			continue
+		}
+		t, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown attribute %q", name)
+		}
+		if r == nil {
+			r = make(Redaction)
+		}
+		r[t] = true
+	}
+	return r, nil
+}
+
+// Value of the attribute, Mask quoted when it is redacted
+func (r Redaction) Value(t radius.Type, a radius.Attribute) string {
+	if r[t] {
+		return strconv.Quote(Mask)
+	}
+	return Value(t, a)
+}
@@ -0,0 +1,103 @@
+// Package export records every emitted accounting-request (--export) as a
+// CSV, the reference of the post-run reconciliations (verify command).
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// results of a request
+const (
+	OK      = "ok"
+	Timeout = "timeout"
+	Error   = "error"
+)
+
+var header = []string{"sent", "acct_session_id", "call_id", "result", "acct_unique_session_id"}
+
+// an emitted accounting-request
+type Record struct {
+	Sent          time.Time
+	AcctSessionId string
+	CallId        string
+	Result        string
+	// FreeRADIUS Acct-Unique-Session-Id, empty without --acct-unique
+	AcctUniqueId string
+}
+
+// CSV writer safe for concurrent use by the sending goroutines
+type Writer struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *csv.Writer
+}
+
+// create (truncate) the export on path
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{f: f, w: csv.NewWriter(f)}
+	w.w.Write(header)
+	return w, nil
+}
+
+func (w *Writer) Write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write([]string{r.Sent.UTC().Format(time.RFC3339Nano), r.AcctSessionId, r.CallId, r.Result, r.AcctUniqueId})
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// records of the export on path
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	// exports without the unique session id have a column less
+	r.FieldsPerRecord = -1
+	var records []Record
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < len(header)-1 {
+			return nil, fmt.Errorf("%s: line %d: %d columns", path, line, len(rec))
+		}
+		if line == 1 && rec[0] == header[0] {
+			continue
+		}
+		sent, err := time.Parse(time.RFC3339Nano, rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", path, line, err)
+		}
+		record := Record{Sent: sent, AcctSessionId: rec[1], CallId: rec[2], Result: rec[3]}
+		if len(rec) > 4 {
+			record.AcctUniqueId = rec[4]
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,147 @@
+package gen
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/pacer"
+)
+
+// signs of server overload over an --adaptive interval
+const (
+	SignalRejects     = "rejects"
+	SignalTimeouts    = "timeouts"
+	SignalRetransmits = "retransmits"
+	SignalLatency     = "latency"
+)
+
+// --adaptive AIMD rate: raised by AdaptiveStep pps every interval the
+// servers keep up, multiplied by AdaptiveBackoff on a sign of overload;
+// the rates it backed off from are the knee of the curve
+type adaptive struct {
+	mu    sync.Mutex
+	steps []control.AdaptiveStep
+	peaks []float64
+}
+
+func (a *adaptive) add(s control.AdaptiveStep) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.steps = append(a.steps, s)
+	if len(s.Signal) > 0 {
+		a.peaks = append(a.peaks, s.PPS)
+	}
+}
+
+// rate trajectory so far and the knee, the mean rate the servers pushed
+// back at (zero before the first push back)
+func (a *adaptive) trajectory() ([]control.AdaptiveStep, float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var knee float64
+	for _, p := range a.peaks {
+		knee += p
+	}
+	if len(a.peaks) > 0 {
+		knee /= float64(len(a.peaks))
+	}
+	return append([]control.AdaptiveStep(nil), a.steps...), knee
+}
+
+// requests, retransmissions and rejects so far
+type pushback struct {
+	storm   stormSample
+	rejects uint64
+}
+
+func (g *Generator) pushback() pushback {
+	p := pushback{storm: g.stormSample()}
+	for _, n := range g.Rejects.Counts() {
+		p.rejects += n
+	}
+	return p
+}
+
+// overload sign of the interval, empty when the servers kept up: any
+// reject (Error-Cause), timeouts or retransmissions over
+// AdaptiveRetransmits of the requests, or the p99 over AdaptiveP99 ms
+// (twice the one of the first interval answered when zero)
+func (g *Generator) overloaded(l Level, from, to pushback, baseline *time.Duration) string {
+	cfg := g.Cfg
+	maxP99 := time.Duration(cfg.AdaptiveP99) * time.Millisecond
+	if maxP99 <= 0 {
+		if *baseline <= 0 && l.Sent > l.Failed {
+			*baseline = l.P99
+		}
+		maxP99 = 2 * *baseline
+	}
+	sent := to.storm.sent - from.storm.sent
+	switch {
+	case to.rejects > from.rejects:
+		return SignalRejects
+	case l.Sent > 0 && l.ErrorRate() > cfg.AdaptiveRetransmits:
+		return SignalTimeouts
+	case sent > 0 && float64(to.storm.retransmits-from.storm.retransmits)/float64(sent) > cfg.AdaptiveRetransmits:
+		return SignalRetransmits
+	case maxP99 > 0 && l.P99 > maxP99:
+		return SignalLatency
+	}
+	return ""
+}
+
+// run the AIMD loop every AdaptiveInterval seconds until ctx is done
+func (g *Generator) adapt(ctx context.Context) {
+	cfg := g.Cfg
+	interval := time.Duration(cfg.AdaptiveInterval) * time.Second
+	step := cfg.AdaptiveStep
+	if step <= 0 {
+		step = cfg.PPS / 10
+	}
+	var baseline time.Duration
+	last := g.pushback()
+	g.window.reset()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cfg.Clock.After(interval):
+		}
+		rate := g.Pacer.Rate()
+		l := g.window.level(int(rate))
+		g.window.reset()
+		now := g.pushback()
+		signal := g.overloaded(l, last, now, &baseline)
+		last = now
+		g.adaptive.add(control.AdaptiveStep{
+			Elapsed: clock.Since(cfg.Clock, g.Start).Seconds(),
+			PPS:     rate,
+			Sent:    l.Sent,
+			Failed:  l.Failed,
+			P99Ms:   l.P99.Seconds() * 1000,
+			Signal:  signal,
+		})
+		next := rate + step
+		if len(signal) > 0 {
+			next = rate * cfg.AdaptiveBackoff
+			if next < pacer.MinRate {
+				next = pacer.MinRate
+			}
+			g.event("adaptive: %s at %.1f pps (p99 %s, %d of %d failed), rate lowered to %.1f pps", signal, rate, l.P99, l.Failed, l.Sent, next)
+		}
+		if err := g.Pacer.SetRate(next); err != nil {
+			log.Print("adaptive: ", err)
+		}
+	}
+}
+
+// the --adaptive rate trajectory and knee, nil and zero without it
+func (g *Generator) Adaptive() ([]control.AdaptiveStep, float64) {
+	if g.adaptive == nil {
+		return nil, 0
+	}
+	return g.adaptive.trajectory()
+}
@@ -0,0 +1,98 @@
+package gen
+
+import (
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/sockbuf"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// how the Request Authenticator of a --bad-auth request is wrong: signed
+// with another secret (the server hashes it in full to find out), random
+// or all zeros
+const (
+	BadAuthSecret = "secret"
+	BadAuthRandom = "random"
+	BadAuthZero   = "zero"
+)
+
+func ParseBadAuthMode(s string) (string, error) {
+	switch s {
+	case BadAuthSecret, BadAuthRandom, BadAuthZero:
+		return s, nil
+	}
+	return "", fmt.Errorf("bad-auth-mode must be %s, %s or %s", BadAuthSecret, BadAuthRandom, BadAuthZero)
+}
+
+// true when the request goes with a bad authenticator (--bad-auth), only
+// to the UDP targets: a stream server may close the connection on it
+func (g *Generator) badAuth(t *target.Target) bool {
+	return g.Cfg.BadAuth > 0 && !t.Stream() && mrand.Float64() < g.Cfg.BadAuth
+}
+
+// the wire of packet with a bad Request Authenticator
+func badAuthWire(packet *radius.Packet, mode string) ([]byte, error) {
+	if mode == BadAuthSecret {
+		p := *packet
+		p.Secret = append([]byte("bad-auth-"), packet.Secret...)
+		return p.Encode()
+	}
+	b, err := packet.Encode()
+	if err != nil {
+		return nil, err
+	}
+	// the authenticator follows the code, identifier and length
+	auth := b[4:20]
+	if mode == BadAuthRandom {
+		rand.Read(auth)
+	} else {
+		for i := range auth {
+			auth[i] = 0
+		}
+	}
+	return b, nil
+}
+
+// send packet to t once with a bad Request Authenticator, on a socket of
+// its own; the server must drop it, an answer within Retry seconds is
+// counted and warned of as a server accepting it
+func (g *Generator) sendBadAuth(packet *radius.Packet, t *target.Target, nas *NAS) error {
+	cfg := g.Cfg
+	packet.Secret = t.Key([]byte(cfg.Key))
+	if nas != nil && nas.Secret != nil {
+		packet.Secret = nas.Secret
+	}
+	packet.Identifier = shardIdentifier(g.shard)
+	wire, err := badAuthWire(packet, cfg.BadAuthMode)
+	if err != nil {
+		return err
+	}
+	d := net.Dialer{Control: sockbuf.Control(cfg.RcvBuf, cfg.SndBuf)}
+	conn, err := d.Dial("udp", t.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write(wire); err != nil {
+		return err
+	}
+	atomic.AddUint64(&g.Counters.BadAuth, 1)
+	wait := time.Second * time.Duration(cfg.Retry)
+	if wait <= 0 {
+		wait = time.Second
+	}
+	conn.SetReadDeadline(time.Now().Add(wait))
+	b := make([]byte, 4096)
+	if n, err := conn.Read(b); err == nil && n >= 20 && b[1] == packet.Identifier {
+		if atomic.AddUint64(&g.Counters.BadAuthAnswered, 1) == 1 {
+			g.event("WARNING bad-auth: %s answered an accounting-request with a %s Request Authenticator", t.Addr, cfg.BadAuthMode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package gen
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// value of the extra attributes and custom fields of the bench cases, the
+// length of a typical Class or tag
+const benchValue = "0123456789abcdef"
+
+// bytes kept on top of a sample of the generated attributes, their
+// values vary in length from packet to packet
+const benchSlack = 256
+
+// first type of the bench custom fields, the implementation specific ones
+// (RFC 2865 5.26) not clashing with the generated attributes
+const benchCustomType = 224
+
+// a case of the bench command: the packets of a session type with extra
+// attributes (Class) and custom fields on top of the generated ones
+type BenchCase struct {
+	SessionType string
+	Extra       int
+	Custom      int
+}
+
+// throughput of a case over the workers
+type BenchResult struct {
+	BenchCase
+	Packets uint64
+	// attributes and wire bytes of a packet, on average
+	Attributes float64
+	Bytes      float64
+	// packets built and encoded per second, and the time each step took
+	// a packet on a worker
+	PerSecond float64
+	Build     time.Duration
+	Encode    time.Duration
+}
+
+// parse a comma separated list of counts, e.g. "0,10,50"
+func ParseCounts(spec string) ([]int, error) {
+	var counts []int
+	for _, s := range strings.Split(spec, ",") {
+		if len(strings.TrimSpace(s)) <= 0 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 0 || n > 255 {
+			return nil, fmt.Errorf("count %q must be 0 to 255", s)
+		}
+		counts = append(counts, n)
+	}
+	if len(counts) <= 0 {
+		return nil, fmt.Errorf("no counts in %q", spec)
+	}
+	return counts, nil
+}
+
+// every combination of the session types, extra attributes and custom
+// fields, "sip,data" "0,20" "0,10"; an error when the largest would make
+// packets over the RFC 2865 limit
+func BenchCases(cfg Config, types, extra, custom string) ([]BenchCase, error) {
+	extras, err := ParseCounts(extra)
+	if err != nil {
+		return nil, fmt.Errorf("extra-attributes: %v", err)
+	}
+	customs, err := ParseCounts(custom)
+	if err != nil {
+		return nil, fmt.Errorf("custom-fields: %v", err)
+	}
+	most := extras[0] + customs[0]
+	for _, e := range extras {
+		for _, c := range customs {
+			if e+c > most {
+				most = e + c
+			}
+		}
+	}
+	var cases []BenchCase
+	for _, t := range strings.Split(types, ",") {
+		t = strings.TrimSpace(t)
+		if t != SIPSession && t != DataSession {
+			return nil, fmt.Errorf("session-types: %q must be %s or %s", t, SIPSession, DataSession)
+		}
+		cfg.SessionType = t
+		opts, err := cdrOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		room := radius.MaxPacketLength - benchSlack - PacketSize(NewAcctPacket(cdr.FillCdrWith(&opts), nil, cfg))
+		if most*(2+len(benchValue)) > room {
+			return nil, fmt.Errorf("%d extra attributes and custom fields make %s packets over %d bytes, %d of them at most", most, t, radius.MaxPacketLength, room/(2+len(benchValue)))
+		}
+		for _, e := range extras {
+			for _, c := range customs {
+				cases = append(cases, BenchCase{SessionType: t, Extra: e, Custom: c})
+			}
+		}
+	}
+	return cases, nil
+}
+
+// build and encode the packets of each case for d on workers goroutines,
+// as the generator does before sending them; nothing is sent
+func Bench(cfg Config, cases []BenchCase, d time.Duration, workers int) ([]BenchResult, error) {
+	results := make([]BenchResult, 0, len(cases))
+	for _, bc := range cases {
+		cfg.SessionType = bc.SessionType
+		var mcf MapCustomFields
+		if bc.Custom > 0 {
+			mcf = NewMapCustomFields()
+			for i := 0; i < bc.Custom; i++ {
+				mcf[i] = CustomFields{ID: radius.Type(benchCustomType + i%(256-benchCustomType)), Value: benchValue}
+			}
+		}
+		r := BenchResult{BenchCase: bc}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var build, encode time.Duration
+		var attrs, bytes uint64
+		errs := make(chan error, workers)
+		start := time.Now()
+		for w := 0; w < workers; w++ {
+			// the cdr options aren't safe for concurrent use
+			opts, err := cdrOptions(cfg)
+			if err != nil {
+				return nil, err
+			}
+			opts.Rand = rand.New(rand.NewSource(start.UnixNano() + int64(w)))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var n, na, nb uint64
+				var b, e time.Duration
+				end := start.Add(d)
+				for {
+					t0 := time.Now()
+					if !t0.Before(end) {
+						break
+					}
+					p := NewAcctPacket(cdr.FillCdrWith(&opts), mcf, cfg)
+					for i := 0; i < bc.Extra; i++ {
+						rfc2865.Class_Add(p, []byte(benchValue))
+					}
+					t1 := time.Now()
+					wire, err := p.Encode()
+					if err != nil {
+						errs <- err
+						return
+					}
+					b += t1.Sub(t0)
+					e += time.Since(t1)
+					n++
+					nb += uint64(len(wire))
+					for _, values := range p.Attributes {
+						na += uint64(len(values))
+					}
+				}
+				mu.Lock()
+				r.Packets += n
+				build += b
+				encode += e
+				attrs += na
+				bytes += nb
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+		select {
+		case err := <-errs:
+			return nil, err
+		default:
+		}
+		if r.Packets > 0 {
+			r.Attributes = float64(attrs) / float64(r.Packets)
+			r.Bytes = float64(bytes) / float64(r.Packets)
+			r.PerSecond = float64(r.Packets) / elapsed.Seconds()
+			r.Build = build / time.Duration(r.Packets)
+			r.Encode = encode / time.Duration(r.Packets)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// table of the cases, with the range of their rates
+func FprintBench(w io.Writer, results []BenchResult, workers int) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tEXTRA\tCUSTOM\tATTRIBUTES\tBYTES\tPACKETS/S\tBUILD\tENCODE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%.0f\t%.0f\t%v\t%v\n", r.SessionType, r.Extra, r.Custom, r.Attributes, r.Bytes, r.PerSecond, r.Build, r.Encode)
+	}
+	tw.Flush()
+	var low, high float64
+	for i, r := range results {
+		if i == 0 || r.PerSecond < low {
+			low = r.PerSecond
+		}
+		if r.PerSecond > high {
+			high = r.PerSecond
+		}
+	}
+	fmt.Fprintf(w, "\n%d workers: %.0f to %.0f packets/s built and signed, an upper bound of --pps on this host before the sockets, the responses and the stats take their share\n", workers, low, high)
+}
@@ -0,0 +1,55 @@
+package gen
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// where the round trip time of a request went
+type Timing struct {
+	// on the client before the first transmission: building the packet,
+	// the hooks, the send jitter, a NAS source port or an Identifier of
+	// the shared sockets
+	Queue time.Duration
+	// waiting to retransmit the unanswered (or lost) transmissions, and
+	// on the servers timing out before the answering one
+	Retry time.Duration
+	// from the last transmission to the response, the server latency
+	Wait time.Duration
+}
+
+// sums of the timings of the answered requests, a server latency that
+// isn't the client queueing behind it
+type Budget struct {
+	answered uint64
+	// ns
+	queue int64
+	retry int64
+	wait  int64
+}
+
+func (b *Budget) add(t Timing) {
+	atomic.AddInt64(&b.queue, int64(t.Queue))
+	atomic.AddInt64(&b.retry, int64(t.Retry))
+	atomic.AddInt64(&b.wait, int64(t.Wait))
+	atomic.AddUint64(&b.answered, 1)
+}
+
+// averages of the answered requests, nil before the first one
+func (b *Budget) Stats() *control.BudgetStats {
+	n := atomic.LoadUint64(&b.answered)
+	if n <= 0 {
+		return nil
+	}
+	avg := func(sum *int64) float64 {
+		return time.Duration(atomic.LoadInt64(sum)/int64(n)).Seconds() * 1000
+	}
+	return &control.BudgetStats{
+		Answered: n,
+		QueueMs:  avg(&b.queue),
+		RetryMs:  avg(&b.retry),
+		WaitMs:   avg(&b.wait),
+	}
+}
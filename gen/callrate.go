@@ -0,0 +1,243 @@
+package gen
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// mean talk time of the plain random timers (see cdr.CdrTimers)
+const plainTalkTime = 500 * time.Second
+
+// the load of --cps and --erlangs: the calls arrive at CPS per second and,
+// by Little's law, Erlangs concurrent answered calls need a mean talk time
+// of Erlangs / answered calls per second; the records of each call go out
+// at their times from its arrival
+type CallPlan struct {
+	// mean talk time of the answered calls
+	HoldTime time.Duration
+	// records expected per call and the packets per second they make
+	Records float64
+	PPS     float64
+	// call model with the talk time mean of HoldTime, nil without Erlangs
+	Model *cdr.CallModel
+}
+
+// plan of the calls of cfg, CPS greater 0
+func PlanCalls(cfg Config) (*CallPlan, error) {
+	o, err := cdrOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	answered := o.AnsweredShare()
+	p := &CallPlan{}
+	switch {
+	case cfg.Erlangs > 0:
+		if answered <= 0 {
+			return nil, fmt.Errorf("erlangs: no answered calls to carry them, see failed-ratio and methods")
+		}
+		p.HoldTime = time.Duration(cfg.Erlangs / (cfg.CPS * answered) * float64(time.Second))
+		model := o.Model
+		if model == nil {
+			if model, err = cdr.NewCallModel("", "", cdr.DefaultTalk); err != nil {
+				return nil, err
+			}
+		}
+		m := *model
+		m.Talk.Mean = p.HoldTime
+		p.Model = &m
+	case o.Model != nil:
+		p.HoldTime = o.Model.Talk.Mean
+	default:
+		p.HoldTime = plainTalkTime
+	}
+	p.Records = 1
+	if o.Lifecycle {
+		// Start and Stop, the periodic Interims and the re-INVITE ones (a
+		// codec change or a hold and resume)
+		perAnswered := 2 + o.ReinviteRatio*1.5
+		if o.InterimInterval > 0 {
+			perAnswered += float64(p.HoldTime) / float64(o.InterimInterval)
+		}
+		p.Records = 1 - answered + answered*perAnswered
+	}
+	if o.Legs {
+		p.Records *= 2
+	}
+	p.PPS = cfg.CPS * p.Records
+	return p, nil
+}
+
+// record of a call due at a time
+type scheduled struct {
+	due time.Time
+	// keeps the order of the records due at the same time
+	seq uint64
+	c   *cdr.CdrValues
+	cl  call
+}
+
+// records by due time, a container/heap
+type schedule []scheduled
+
+func (s schedule) Len() int { return len(s) }
+func (s schedule) Less(i, j int) bool {
+	if s[i].due.Equal(s[j].due) {
+		return s[i].seq < s[j].seq
+	}
+	return s[i].due.Before(s[j].due)
+}
+func (s schedule) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *schedule) Push(x interface{}) { *s = append(*s, x.(scheduled)) }
+func (s *schedule) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return x
+}
+
+// schedule the records of a call arriving at start, each one at its time
+// from origin run speed times faster, without overtaking the record before
+// it so the reordered ones (see cdr.Lifecycle) stay so; the --cps calls
+// get the timestamps of their send times
+func (g *Generator) scheduleCall(records []*cdr.CdrValues, cl call, start, origin time.Time, speed float64) {
+	offset := nasOffset(cl)
+	last := start
+	for _, r := range records {
+		due := start.Add(time.Duration(float64(r.EventTimestamp.Add(-offset).Sub(origin)) / speed))
+		if due.Before(last) {
+			due = last
+		}
+		last = due
+		if g.Cfg.Speed <= 0 {
+			// the NAS clock offset stays on the timestamps
+			r.EventTimestamp = due.Add(offset)
+		}
+		g.dueSeq++
+		heap.Push(&g.due, scheduled{due: due, seq: g.dueSeq, c: r, cl: cl})
+	}
+}
+
+// sleep until t, false when the run was stopped meanwhile
+func (g *Generator) sleepUntil(t time.Time) bool {
+	for {
+		d := t.Sub(g.Cfg.Clock.Now())
+		if g.Control.State() == control.Stopped {
+			return false
+		}
+		if d <= 0 {
+			return true
+		}
+		if d > time.Second {
+			d = time.Second
+		}
+		g.Cfg.Clock.Sleep(d)
+	}
+}
+
+// call read ahead of its arrival
+type upcomingCall struct {
+	records []*cdr.CdrValues
+	cl      call
+	at      time.Time
+	// time the records are scheduled from
+	origin time.Time
+}
+
+// read the next call and when it arrives: the --cps ones as a Poisson
+// process, the --speed ones at their time
+func (g *Generator) readUpcoming() (*upcomingCall, error) {
+	records, cl, err := g.nextCall()
+	if err != nil {
+		return nil, err
+	}
+	u := &upcomingCall{records: records, cl: cl}
+	if g.Cfg.Speed > 0 {
+		if u.at, err = g.replayArrival(records[0]); err != nil {
+			return nil, err
+		}
+		u.origin = g.callTime()
+		return u, nil
+	}
+	u.origin = records[0].EventTimestamp
+	for _, r := range records[1:] {
+		if r.EventTimestamp.Before(u.origin) {
+			u.origin = r.EventTimestamp
+		}
+	}
+	u.origin = u.origin.Add(-nasOffset(cl))
+	if g.arrival.IsZero() {
+		g.arrival = g.Cfg.Clock.Now()
+	}
+	u.at = g.arrival
+	g.arrival = g.arrival.Add(time.Duration(g.rnd.ExpFloat64() / g.Cfg.CPS * float64(time.Second)))
+	return u, nil
+}
+
+// with --cps or --speed the next record at its time; io.EOF once the call
+// source ended and its calls are done. Only called from a single goroutine
+func (g *Generator) nextScheduled() (*cdr.CdrValues, call, error) {
+	for {
+		if g.upcoming == nil && !g.sourceDone {
+			u, err := g.readUpcoming()
+			if err == io.EOF {
+				g.sourceDone = true
+			} else if err != nil {
+				return nil, call{}, err
+			}
+			g.upcoming = u
+		}
+		if u := g.upcoming; u != nil {
+			// no catching up on the calls of a pause
+			if lag := clock.Since(g.Cfg.Clock, u.at); lag > time.Second {
+				u.at = u.at.Add(lag)
+				if g.Cfg.Speed > 0 {
+					g.replayStart = g.replayStart.Add(lag)
+				} else {
+					g.arrival = g.arrival.Add(lag)
+				}
+			}
+		}
+		if len(g.due) > 0 && (g.upcoming == nil || !g.due[0].due.After(g.upcoming.at)) {
+			s := heap.Pop(&g.due).(scheduled)
+			if !g.sleepUntil(s.due) {
+				return nil, call{}, io.EOF
+			}
+			return s.c, s.cl, nil
+		}
+		u := g.upcoming
+		if u == nil || !g.sleepUntil(u.at) {
+			return nil, call{}, io.EOF
+		}
+		speed := g.Cfg.Speed
+		if speed <= 0 {
+			speed = 1
+		}
+		g.scheduleCall(u.records, u.cl, u.at, u.origin, speed)
+		g.upcoming = nil
+	}
+}
+
+// the next record paced by the pacer (see nextThinking with --think-time)
+// or by its call, with --cps the pacer as a ceiling and with --speed at the
+// replayed times only
+func (g *Generator) next() (*cdr.CdrValues, call, error) {
+	if g.think != nil {
+		_ = g.Pacer.Take()
+		return g.nextThinking()
+	}
+	if g.callPlan == nil && g.Cfg.Speed <= 0 {
+		_ = g.Pacer.Take()
+		return g.nextCdr()
+	}
+	c, cl, err := g.nextScheduled()
+	if err == nil && g.callPlan != nil {
+		_ = g.Pacer.Take()
+	}
+	return c, cl, err
+}
@@ -0,0 +1,122 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"layeh.com/radius"
+)
+
+// attributes of the accounting-responses carried to the next records of
+// their session (--carry-attr), as a NAS echoes the State, Class or
+// session keys a server hands back: each record of a session gets the
+// last values answered to the previous ones. Safe for concurrent use
+type Carry struct {
+	Types []radius.Type
+	mu    sync.Mutex
+	// values answered by Acct-Session-Id and attribute, until the Stop
+	sessions map[string]map[radius.Type][]radius.Attribute
+	// requests sent with carried values
+	Carried uint64
+}
+
+// carry of the attributes, the names as on the dictionary or numbers; nil
+// without attributes
+func ParseCarry(attrs []string) (*Carry, error) {
+	var types []radius.Type
+	for _, s := range attrs {
+		for _, name := range strings.Split(s, ",") {
+			if len(strings.TrimSpace(name)) <= 0 {
+				continue
+			}
+			t, ok := dump.Lookup(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("carry-attr: unknown attribute %q", name)
+			}
+			types = append(types, t)
+		}
+	}
+	if len(types) <= 0 {
+		return nil, nil
+	}
+	return &Carry{Types: types, sessions: make(map[string]map[radius.Type][]radius.Attribute)}, nil
+}
+
+// true when t is carried
+func (cr *Carry) Carries(t radius.Type) bool {
+	for _, ct := range cr.Types {
+		if ct == t {
+			return true
+		}
+	}
+	return false
+}
+
+// add the values carried for the session of c to its packet, the session
+// is forgotten on its Stop
+func (cr *Carry) add(p *radius.Packet, c *cdr.CdrValues) {
+	cr.mu.Lock()
+	values := cr.sessions[c.AcctSessionId]
+	if c.AcctStatusType == cdr.StatusStop {
+		delete(cr.sessions, c.AcctSessionId)
+	}
+	cr.mu.Unlock()
+	if len(values) <= 0 {
+		return
+	}
+	for _, t := range cr.Types {
+		for _, v := range values[t] {
+			p.Add(t, v)
+		}
+	}
+	atomic.AddUint64(&cr.Carried, 1)
+}
+
+// keep the carried attributes of the response to a record of c for the
+// next records of its session, an attribute not answered again keeps its
+// previous values
+func (cr *Carry) answer(c *cdr.CdrValues, response *radius.Packet) {
+	if c.AcctStatusType == cdr.StatusStop {
+		return
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for _, t := range cr.Types {
+		values := response.Attributes[t]
+		if len(values) <= 0 {
+			continue
+		}
+		session := cr.sessions[c.AcctSessionId]
+		if session == nil {
+			session = make(map[radius.Type][]radius.Attribute)
+			cr.sessions[c.AcctSessionId] = session
+		}
+		session[t] = append([]radius.Attribute(nil), values...)
+	}
+}
+
+// carry of the calls of cl: the one of its scenario, else the run one
+func (g *Generator) carryOf(cl call) *Carry {
+	if cl.scenario != nil && cl.scenario.carry != nil {
+		return cl.scenario.carry
+	}
+	return g.carry
+}
+
+// requests sent with carried values, over the run and its scenarios
+func (g *Generator) Carried() uint64 {
+	var n uint64
+	if g.carry != nil {
+		n += atomic.LoadUint64(&g.carry.Carried)
+	}
+	for _, sc := range g.Scenarios {
+		if sc.carry != nil {
+			n += atomic.LoadUint64(&sc.carry.Carried)
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"strconv"
+
+	"github.com/routecall/go-radius-gen-acct/checksum"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"layeh.com/radius"
+)
+
+// integrity checksum of the requests (--checksum-attr): the key attributes
+// of each packet summed (see checksum.Sum) in an attribute of its own, so
+// verify tells the records stored truncated or changed
+type Checksum struct {
+	attr  *RunIDAttr
+	key   []byte
+	names []string
+	types []radius.Type
+}
+
+// checksum in attr (vendor:type or a number) of the comma-separated
+// attributes, checksum.DefaultAttrs when empty; nil without attr
+func ParseChecksum(attr, key, attrs string) (*Checksum, error) {
+	if len(attr) <= 0 {
+		return nil, nil
+	}
+	a, err := parseAttr("checksum-attr", attr)
+	if err != nil {
+		return nil, err
+	}
+	names, err := checksum.ParseAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+	cs := &Checksum{attr: a, key: []byte(key), names: names}
+	for _, name := range names {
+		t, _ := dump.Lookup(name)
+		cs.types = append(cs.types, t)
+	}
+	return cs, nil
+}
+
+// add the checksum of the packet, once its attributes are final
+func (cs *Checksum) Add(p *radius.Packet) {
+	values := make(map[string]string, len(cs.names))
+	for i, t := range cs.types {
+		a := p.Get(t)
+		if a == nil {
+			continue
+		}
+		if dump.Dictionary[t].Kind == dump.Integer {
+			n, err := radius.Integer(a)
+			if err != nil {
+				continue
+			}
+			values[cs.names[i]] = strconv.FormatUint(uint64(n), 10)
+		} else {
+			values[cs.names[i]] = radius.String(a)
+		}
+	}
+	cs.attr.Add(p, checksum.Sum(cs.key, cs.names, values))
+}
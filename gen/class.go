@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// Class of the sessions (--session-class), as a NAS gets it on the
+// Access-Accept and echoes it on every accounting record of the session
+// for the billing to correlate them; without an authentication step here,
+// each session gets one made from its Acct-Session-Id, replaced by the
+// Class of an accounting-response of the session when the server answers
+// one. Safe for concurrent use
+type SessionClasses struct {
+	mu sync.Mutex
+	// Class answered by the servers by Acct-Session-Id, until the Stop
+	answered map[string][][]byte
+}
+
+func NewSessionClasses() *SessionClasses {
+	return &SessionClasses{answered: make(map[string][][]byte)}
+}
+
+// Class made for a session
+func sessionClass(id string) []byte {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return []byte(fmt.Sprintf("radgen-%016x", h.Sum64()))
+}
+
+// add the Class of the session of c to its packet, the session is
+// forgotten on its Stop
+func (s *SessionClasses) add(p *radius.Packet, c *cdr.CdrValues) {
+	s.mu.Lock()
+	classes, ok := s.answered[c.AcctSessionId]
+	if c.AcctStatusType == cdr.StatusStop {
+		delete(s.answered, c.AcctSessionId)
+	}
+	s.mu.Unlock()
+	if !ok {
+		classes = [][]byte{sessionClass(c.AcctSessionId)}
+	}
+	for _, class := range classes {
+		rfc2865.Class_Add(p, class)
+	}
+}
+
+// keep the Class of the response to a record of c for the next records
+// of its session
+func (s *SessionClasses) answer(c *cdr.CdrValues, response *radius.Packet) {
+	if c.AcctStatusType == cdr.StatusStop {
+		return
+	}
+	classes, _ := rfc2865.Class_Gets(response)
+	if len(classes) <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answered[c.AcctSessionId] = classes
+}
@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"net"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// --session-type, what the records account
+const (
+	SIPSession  = "sip"
+	DataSession = "data"
+)
+
+// attribute values of the data sessions
+const (
+	serviceTypeFramed   = 2
+	framedProtocolPPP   = 1
+	nasPortTypeWireless = 19
+	// average wire size of the packets, to count them from the octets
+	avgPacketSize = 800
+)
+
+// RFC 2866/2869 attributes of a subscriber data session record, the
+// octet counters over 4GiB carried on the Gigawords
+func dataAttributes(p *radius.Packet, c *cdr.CdrValues, cfg Config) {
+	p.Add(AcctStatusType, radius.NewInteger(uint32(c.AcctStatusType)))
+	p.Add(AcctSessionId, radius.Attribute(c.AcctSessionId))
+	if len(c.UserName) > 0 {
+		p.Add(UserName, radius.Attribute(c.UserName))
+	}
+	p.Add(ServiceType, radius.NewInteger(serviceTypeFramed))
+	p.Add(FramedProtocol, radius.NewInteger(framedProtocolPPP))
+	if ip, err := radius.NewIPAddr(net.ParseIP(c.FramedIP)); err == nil {
+		p.Add(FramedIPAddress, ip)
+	}
+	p.Add(CallingStationId, radius.Attribute(c.CallingStation))
+	p.Add(CalledStationId, radius.Attribute(c.CalledStation))
+	p.Add(NASPortType, radius.NewInteger(nasPortTypeWireless))
+	if date, err := radius.NewDate(c.EventTimestamp); err == nil {
+		p.Add(EventTimestamp, date)
+	}
+	if c.AcctStatusType != cdr.StatusStart {
+		p.Add(AcctSessionTime, radius.NewInteger(uint32(c.MsDuration/1000)))
+		for _, n := range []struct {
+			octets                      int
+			counter, gigawords, packets radius.Type
+		}{
+			{c.InputOctets, AcctInputOctets, AcctInputGigawords, AcctInputPackets},
+			{c.OutputOctets, AcctOutputOctets, AcctOutputGigawords, AcctOutputPackets},
+		} {
+			p.Add(n.counter, radius.NewInteger(uint32(n.octets)))
+			if giga := uint64(n.octets) >> 32; giga > 0 {
+				p.Add(n.gigawords, radius.NewInteger(uint32(giga)))
+			}
+			p.Add(n.packets, radius.NewInteger(uint32((n.octets+avgPacketSize-1)/avgPacketSize)))
+		}
+	}
+	if c.AcctStatusType == cdr.StatusStop {
+		p.Add(AcctTerminateCause, radius.NewInteger(uint32(c.TerminateCause)))
+	}
+	rfc2865.NASPort_Add(p, rfc2865.NASPort(cfg.NASPort))
+	rfc2865.NASIPAddress_Add(p, net.ParseIP(cfg.NASIPAddress))
+}
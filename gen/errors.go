@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// distinct errors kept, the ones past it are counted together: an error
+// quoting its request would grow the table with every request
+const maxErrors = 100
+
+const otherErrors = "(other errors)"
+
+// distinct errors of the run by text, with their count, first and last
+// time and the session of a request which hit them, so a failed run leaves
+// more than the error which stopped it; safe for concurrent use
+type Errors struct {
+	mu     sync.Mutex
+	errors map[string]*control.ErrorStats
+}
+
+// count err at t, sessionId is the request's, empty for the errors of no
+// request
+func (e *Errors) add(err error, t time.Time, sessionId string) {
+	msg := err.Error()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.errors == nil {
+		e.errors = make(map[string]*control.ErrorStats)
+	}
+	s, ok := e.errors[msg]
+	if !ok && len(e.errors) >= maxErrors {
+		msg = otherErrors
+		s, ok = e.errors[msg]
+	}
+	if !ok {
+		s = &control.ErrorStats{Error: msg, First: t}
+		e.errors[msg] = s
+	}
+	s.Count++
+	s.Last = t
+	if len(s.SessionId) <= 0 {
+		s.SessionId = sessionId
+	}
+}
+
+// the errors, the most frequent first, nil without any
+func (e *Errors) Stats() []control.ErrorStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errors) <= 0 {
+		return nil
+	}
+	stats := make([]control.ErrorStats, 0, len(e.errors))
+	for _, s := range e.errors {
+		stats = append(stats, *s)
+	}
+	control.SortErrors(stats)
+	return stats
+}
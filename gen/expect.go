@@ -0,0 +1,130 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"layeh.com/radius"
+)
+
+// attribute the accounting-responses must carry (--expect-attr)
+type ExpectAttr struct {
+	Type radius.Type
+	// empty for any value, * matches any run of characters
+	Value   string
+	pattern *regexp.Regexp
+}
+
+// parse "Name[=value]", the name as on the dictionary, Attr-N or a number;
+// a * on the value matches anything, "Class=*" is a Class of any value
+func ParseExpectAttr(s string) (ExpectAttr, error) {
+	name, value := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, value = s[:i], s[i+1:]
+	}
+	t, ok := dump.Lookup(strings.TrimSpace(name))
+	if !ok {
+		return ExpectAttr{}, fmt.Errorf("expect-attr: unknown attribute %q", name)
+	}
+	a := ExpectAttr{Type: t, Value: value}
+	if strings.Contains(value, "*") {
+		quoted := strings.Replace(regexp.QuoteMeta(value), `\*`, ".*", -1)
+		a.pattern = regexp.MustCompile("^" + quoted + "$")
+	}
+	return a, nil
+}
+
+// the expectation as given, Name[=value]
+func (a ExpectAttr) String() string {
+	if len(a.Value) <= 0 {
+		return dump.Name(a.Type)
+	}
+	return dump.Name(a.Type) + "=" + a.Value
+}
+
+func (a ExpectAttr) match(v radius.Attribute) bool {
+	switch {
+	case len(a.Value) <= 0:
+		return true
+	case a.pattern != nil:
+		return a.pattern.MatchString(radius.String(v)) || a.pattern.MatchString(dump.Value(a.Type, v))
+	}
+	return radius.String(v) == a.Value || dump.Value(a.Type, v) == a.Value
+}
+
+// failed requests logged, the others are only counted
+const maxExpectLogged = 100
+
+// expectations on the accounting-responses, every request must be
+// answered within Within (zero for the timeout) with the Attrs
+type Expect struct {
+	Within time.Duration
+	Attrs  []ExpectAttr
+	// accounting-responses without each of Attrs
+	misses []uint64
+}
+
+// expectations of cfg, nil without them
+func NewExpect(cfg Config) (*Expect, error) {
+	if cfg.ExpectWithin <= 0 && len(cfg.ExpectAttrs) <= 0 {
+		return nil, nil
+	}
+	e := &Expect{Within: time.Duration(cfg.ExpectWithin) * time.Millisecond}
+	for _, s := range cfg.ExpectAttrs {
+		a, err := ParseExpectAttr(s)
+		if err != nil {
+			return nil, err
+		}
+		e.Attrs = append(e.Attrs, a)
+	}
+	e.misses = make([]uint64, len(e.Attrs))
+	return e, nil
+}
+
+// accounting-responses missing each attribute expectation by the
+// expectation, nil without them
+func (e *Expect) Misses() map[string]uint64 {
+	if len(e.Attrs) <= 0 {
+		return nil
+	}
+	m := make(map[string]uint64, len(e.Attrs))
+	for i, a := range e.Attrs {
+		m[a.String()] += atomic.LoadUint64(&e.misses[i])
+	}
+	return m
+}
+
+// the expectations response (nil on err) failed, empty when it passed;
+// counts the missing attributes
+func (e *Expect) Check(response *radius.Packet, latency time.Duration, err error) []string {
+	if err != nil {
+		return []string{"no accounting-response: " + err.Error()}
+	}
+	var failed []string
+	if e.Within > 0 && latency > e.Within {
+		failed = append(failed, fmt.Sprintf("answered in %s, expected within %s", latency, e.Within))
+	}
+	for i, want := range e.Attrs {
+		attrs := response.Attributes[want.Type]
+		found := false
+		for _, a := range attrs {
+			if want.match(a) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			atomic.AddUint64(&e.misses[i], 1)
+			msg := "no " + dump.Name(want.Type)
+			if len(want.Value) > 0 {
+				msg += " = " + want.Value
+			}
+			failed = append(failed, msg+" on the accounting-response")
+		}
+	}
+	return failed
+}
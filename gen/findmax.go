@@ -0,0 +1,145 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --find-max stops once the failing level is this close to the passing one
+// (share of the passing one), and gives up above maxFindPPS
+const (
+	findMaxPrecision = 0.05
+	maxFindPPS       = 1000000
+)
+
+// a level of the --find-max search
+type Level struct {
+	PPS int
+	// requests answered or failed while measuring, and their rate
+	Sent   uint64
+	Failed uint64
+	Rate   float64
+	P99    time.Duration
+	// why the level failed, empty when it passed
+	Reason string
+}
+
+func (l Level) ErrorRate() float64 {
+	if l.Sent <= 0 {
+		return 0
+	}
+	return float64(l.Failed) / float64(l.Sent)
+}
+
+// answers measured since the last reset
+type window struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	sent      uint64
+	failed    uint64
+}
+
+func (w *window) add(latency time.Duration, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sent++
+	if err != nil {
+		w.failed++
+		return
+	}
+	w.latencies = append(w.latencies, latency)
+}
+
+func (w *window) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.latencies = w.latencies[:0]
+	w.sent, w.failed = 0, 0
+}
+
+// level of the answers so far at pps
+func (w *window) level(pps int) Level {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	l := Level{PPS: pps, Sent: w.sent, Failed: w.failed}
+	if n := len(w.latencies); n > 0 {
+		sort.Slice(w.latencies, func(i, j int) bool { return w.latencies[i] < w.latencies[j] })
+		l.P99 = w.latencies[(n*99+99)/100-1]
+	}
+	return l
+}
+
+// hold pps for the settling period, then measure it for FindMaxHold
+// seconds; false when the run was stopped meanwhile
+func (g *Generator) measure(pps int) (Level, bool) {
+	cfg := g.Cfg
+	if err := g.Pacer.SetRate(float64(pps)); err != nil {
+		return Level{}, false
+	}
+	if !g.sleepUntil(cfg.Clock.Now().Add(time.Duration(cfg.FindMaxSettle) * time.Second)) {
+		return Level{}, false
+	}
+	g.window.reset()
+	hold := time.Duration(cfg.FindMaxHold) * time.Second
+	if !g.sleepUntil(cfg.Clock.Now().Add(hold)) {
+		return Level{}, false
+	}
+	l := g.window.level(pps)
+	l.Rate = float64(l.Sent) / hold.Seconds()
+	maxP99 := time.Duration(cfg.FindMaxP99) * time.Millisecond
+	switch {
+	case l.Sent <= 0:
+		l.Reason = "no request answered or failed"
+	case l.ErrorRate() > cfg.FindMaxErrorRate:
+		l.Reason = fmt.Sprintf("error rate %.2f%% over %.2f%%", l.ErrorRate()*100, cfg.FindMaxErrorRate*100)
+	case maxP99 > 0 && l.P99 > maxP99:
+		l.Reason = fmt.Sprintf("p99 %s over %s", l.P99, maxP99)
+	case l.Rate < 0.9*float64(pps):
+		l.Reason = fmt.Sprintf("the generator only reached %.0f pps", l.Rate)
+	}
+	return l, true
+}
+
+// search the highest pps the servers sustain: from PPS doubling it until a
+// level fails, then bisecting between the passing and the failing levels;
+// each level is given to report once measured. Runs along Run, which
+// must have FindMax set
+func (g *Generator) FindMax(report func(Level)) (int, error) {
+	good, bad := 0, 0
+	pps := int(math.Ceil(g.Cfg.PPS))
+	for {
+		l, ok := g.measure(pps)
+		if !ok {
+			return good, fmt.Errorf("stopped before the end of the search")
+		}
+		report(l)
+		if len(l.Reason) <= 0 {
+			good = pps
+		} else {
+			bad = pps
+		}
+		switch {
+		case bad == 0 && pps*2 > maxFindPPS:
+			return good, fmt.Errorf("still sustainable at %d pps, the search limit", good)
+		case bad == 0:
+			pps *= 2
+			continue
+		case good == 0 && bad <= 1:
+			return 0, fmt.Errorf("no sustainable rate, 1 pps fails")
+		}
+		step := int(float64(good) * findMaxPrecision)
+		if step < 1 {
+			step = 1
+		}
+		if good > 0 && bad-good <= step {
+			return good, nil
+		}
+		pps = (good + bad) / 2
+		if pps <= 0 {
+			pps = 1
+		}
+	}
+}
@@ -0,0 +1,1471 @@
+// Package gen is the accounting-request generator core (packet building,
+// pacing, sending and stats) used by the go-radius-gen-acct command.
+//
+// Embedding it in a Go program:
+//
+//	cfg := gen.DefaultConfig()
+//	cfg.Servers = []string{"127.0.0.1:1813"}
+//	cfg.Key = "secret"
+//	cfg.MaxReq = 1000
+//	report, err := gen.Run(ctx, cfg, gen.Callbacks{})
+package gen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/buildinfo"
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"github.com/routecall/go-radius-gen-acct/export"
+	"github.com/routecall/go-radius-gen-acct/output"
+	"github.com/routecall/go-radius-gen-acct/pacer"
+	"github.com/routecall/go-radius-gen-acct/shadow"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// max int value, MaxReq without limit
+const MaxUint = ^uint(0)
+const MaxInt = int(MaxUint >> 1)
+
+// generator options, each one matches the acct flag of the same name
+type Config struct {
+	NASPort      int
+	NASIPAddress string
+	Servers      []string
+	Port         string
+	Key          string
+	// secret rotated to, for all the targets, and its switchover (see
+	// ParseRotation), empty without a rotation
+	NewKey       string
+	KeySwitch    string
+	PPS          float64
+	MaxReq       int
+	Retry        int
+	MaxRetry     int
+	CustomFields string
+	MaxMemory    int
+	// requests in flight, a goroutine each, zero for no limit
+	MaxInFlight  int
+	Shed         bool
+	Pacer        string
+	Burst        int
+	PacingJitter string
+	Policy       string
+	SRV          string
+	SRVRefresh   int
+	TargetsFile  string
+	StickyKey    string
+	ProxyState   bool
+	ProxyHops    int
+	WaitStart    bool
+	DryRun       int
+	// file the records are written to as JSON lines instead of sent, - for
+	// stdout (see Emit)
+	EmitJSON string
+	// call phases "dist:mean" (see cdr.ParsePhase), all empty keeps the
+	// plain random timers
+	SetupTime string
+	RingTime  string
+	TalkTime  string
+	// weighted SIP methods "INVITE=80,BYE=20", empty for INVITE only
+	Methods string
+	// A-leg and B-leg records per call
+	Legs bool
+	// distinct values of the generated attributes "caller=10000,callee=500"
+	// (see cdr.ParseCardinality), empty for no limit
+	Cardinality string
+	// share (0-1) of failed calls with one of FailedCodes (comma-separated,
+	// empty for cdr.DefaultFailedCodes), negative keeps the 200/480/503 mix
+	FailedRatio float64
+	FailedCodes string
+	// templates of the generated values (see cdr.ParseFormat), empty for
+	// the plain digits
+	FromTagFormat string
+	ToTagFormat   string
+	CallIdFormat  string
+	// replay the calls of a SIPp run CSV (see cdr.SIPpReader) instead of
+	// generating them, skipping the first SIPpSkip ones
+	SIPpCSV  string
+	SIPpSkip int
+	// calls source name[:arg] (see cdr.RegisterSource), empty for the
+	// random one; SIPpCSV is the sipp one
+	Source string
+	// column=Attribute rules of the csv and json sources over the ones of
+	// MapFile (see cdr.Mapping)
+	Maps    []string
+	MapFile string
+	// window of the replayed calls (see cdr.ParseSIPpTime), empty for no
+	// bound, and the speed they are replayed at from their times, zero to
+	// send them at PPS
+	From  string
+	To    string
+	Speed float64
+	// CSV of the emitted requests (see export.Record)
+	Export string
+	// FreeRADIUS detail file of the sent requests
+	DetailFile string
+	// SIPSession calls or DataSession subscriber data sessions
+	SessionType string
+	// Start, Interims (every InterimInterval seconds of talk time, zero
+	// for none, and on the re-INVITEs of ReinviteRatio of the calls) and
+	// Stop records per answered call
+	Lifecycle       bool
+	InterimInterval int
+	ReinviteRatio   float64
+	// shares (0-1) of the lifecycle calls with only their Stop, the Stop
+	// before the Start or an Interim after the Stop
+	OrphanStops      float64
+	StopBeforeStart  float64
+	InterimAfterStop float64
+	// random delay "dist:mean" (see cdr.ParsePhase) between the records of
+	// a call, empty to send them back to back
+	ThinkTime string
+	// on stop, send the Stops of the sessions left open (Start sent, Stop
+	// not) before returning
+	CloseSessions bool
+	// simulated NAS fleet (see NewFleet), zero sends NASIPAddress and
+	// NASPort only; NASSecrets comma-separated
+	NASCount      int
+	NASSecrets    string
+	NASSourcePort int
+	NASClockSkew  string
+	// rate limits of the NAS (see ParseNASRates), empty for none, and the
+	// requests each one may send back to back
+	NASRate  string
+	NASBurst int
+	// NAS-Ports the sessions of each NAS take, min-max (see NASPorts),
+	// empty for the fixed NASPort
+	NASPortRange string
+	// add Acct-Session-Id and export the FreeRADIUS Acct-Unique-Session-Id
+	AcctUnique bool
+	// share (0-1) of the calls reusing the session id of a recent one
+	SessionCollisions float64
+	// realms with their servers and secret (see target.LoadRealms), the
+	// calls get a User-Name caller@realm and go to the realm servers
+	RealmsFile string
+	// test run id added to every packet in the RunIDAttr attribute (see
+	// ParseRunIDAttr), to find and clean up the records of the run
+	RunID     string
+	RunIDAttr string
+	// attribute (see ParseChecksum) of the checksum of the
+	// ChecksumFields attributes of each request, an HMAC with ChecksumKey
+	ChecksumAttr   string
+	ChecksumKey    string
+	ChecksumFields string
+	// calls logged with their decoded requests and responses, see
+	// ParseTraceSessions
+	TraceSessions string
+	// interoperability check: every call is traced and every failed
+	// expectation logged
+	Functional bool
+	// no log lines of the events of the run (the warnings of the
+	// watchers, the rate changes), and none of the failed requests
+	Quiet           bool
+	NoRequestErrors bool
+	// calls keeping their traces, detail file records and results rows,
+	// see ParseSampleRate; empty for all of them
+	SampleRate string
+	// time source of the pacer and the schedulers, nil for the system
+	// clock; a clock.Sim runs them deterministically for the tests
+	Clock clock.Clock `json:"-"`
+	// run in virtual time against the built-in mock server (see
+	// Simulation), which drops SimulateLoss (0-1) of the transmissions
+	Simulate     bool
+	SimulateLoss float64
+	simulation   *Simulation
+	// attributes masked on the traces, the dry-run dump and the detail
+	// file, see dump.ParseRedaction
+	Redact string
+	// client side impairment: share (0-1) of the transmissions lost before
+	// the wire and random delay in ms added to the send times
+	SendLoss   float64
+	SendJitter int
+	// share (0-1) of the requests sent once with a Request Authenticator
+	// wrong as BadAuthMode says (see sendBadAuth)
+	BadAuth     float64
+	BadAuthMode string
+	// identifiers of the worker of a distributed run, i/n (see
+	// cdr.Shard), and the seed of the random draws, zero for a random one;
+	// SeedSkip draws of the seed are skipped, the ones the interrupted run
+	// made before a --resume
+	Shard    string
+	Seed     int64
+	SeedSkip uint64
+	// what to do with the generated values their dictionary type or the
+	// Bounds (attr=min-max) reject, see ValueCheck
+	ValueCheck string
+	Bounds     []string
+	// blackout of the sending, after:duration of the run (see Outage)
+	Outage string
+	// SQLite file of the request results and the run metadata
+	ResultsDB string
+	// CSV of the response counts by second and latency bin (see heatmap)
+	Heatmap string
+	// sinks of the run, name[:arg] (see output.Open) over the ones of
+	// Export, DetailFile, ResultsDB and Heatmap
+	Outputs []string
+	// capacity search (see FindMax): the failed requests are counted
+	// instead of stopping the run, each level settles FindMaxSettle
+	// seconds and is measured FindMaxHold seconds against the error rate
+	// (0-1) and the p99 in ms (zero for none)
+	FindMax          bool
+	FindMaxSettle    int
+	FindMaxHold      int
+	FindMaxErrorRate float64
+	FindMaxP99       int
+	// warn when the retransmissions reach StormRatio (zero for never) of
+	// the requests over the last StormWindow seconds, halving the rate
+	// with StormBackoff
+	StormRatio   float64
+	StormWindow  int
+	StormBackoff bool
+	// AIMD rate on the servers push back (see adaptive): AdaptiveStep pps
+	// more every AdaptiveInterval seconds (zero for a tenth of PPS), times
+	// AdaptiveBackoff on rejects, AdaptiveRetransmits (0-1) of the
+	// requests retransmitted or timed out, or the p99 over AdaptiveP99 ms
+	// (zero for twice the first one)
+	Adaptive            bool
+	AdaptiveInterval    int
+	AdaptiveStep        float64
+	AdaptiveBackoff     float64
+	AdaptiveRetransmits float64
+	AdaptiveP99         int
+	// rate of the hours of the day on ScheduleTZ (see RateWindow, the
+	// local time when empty), PPS out of the windows
+	Schedule   []string
+	ScheduleTZ string
+	// response time objectives (see SLO), warned of when their burn rate
+	// over the last SLOWindow seconds reaches SLOBurn
+	SLOs      []string
+	SLOWindow int
+	SLOBurn   float64
+	// server (host[:port[:secret]]) also sent every request, its answers
+	// compared with the ones of the servers; the diverging records go to
+	// ShadowReport, latencies further apart than ShadowLatencyDiff ms
+	// diverge too
+	Shadow            string
+	ShadowReport      string
+	ShadowLatencyDiff int
+	// UDP sockets per target shared by the requests, their Identifiers
+	// allocated by the generator (see mux), zero for a socket per request;
+	// IDExhausted is mux.Block or mux.Open
+	SharedSockets int
+	IDExhausted   string
+	// seconds the Identifier of a timed-out request is held for its late
+	// response (see mux.Client)
+	LateWindow int
+	// certificates of the tls targets (see TLSConfig): the CA verifying the
+	// servers, empty for the system ones, and the client certificate and
+	// its key, empty for none
+	RadSecCA       string
+	RadSecCert     string
+	RadSecKey      string
+	RadSecInsecure bool
+	// receive and send buffers in bytes of the sockets, zero for the
+	// system default
+	RcvBuf int
+	SndBuf int
+	// CPUs of the run (see cpupin.Parse), empty for all of them
+	CPUs string
+	// Accounting-On of every NAS before the first request and
+	// Accounting-Off after the last one
+	AcctOnOff bool
+	// expectations on the accounting-responses (see Expect), ExpectWithin
+	// in ms
+	ExpectWithin int
+	ExpectAttrs  []string
+	// "name=value" labels of the run, on its reports and results
+	Labels []string
+	// call shapes "path=weight" mixed in the run (see LoadScenarios),
+	// empty for the call options of the run only
+	Scenarios []string
+	// destination (host[:port[:secret]]) every request is copied to,
+	// without waiting or counting its responses
+	Mirror string
+	// a Class per session on all its records (see SessionClasses)
+	SessionClass bool
+	// attributes of the responses added to the next records of their
+	// session (see Carry)
+	CarryAttrs []string
+	// new calls per second, their records sent at their times (see
+	// CallPlan), zero to send them back to back at PPS; Erlangs sets the
+	// talk time mean for that many concurrent answered calls
+	CPS     float64
+	Erlangs float64
+}
+
+// the acct flags defaults
+func DefaultConfig() Config {
+	return Config{
+		NASPort:      5666,
+		NASIPAddress: "127.0.0.1",
+		Port:         "1813",
+		PPS:          10,
+		MaxReq:       MaxInt,
+		Retry:        3,
+		MaxRetry:     20,
+		Pacer:        pacer.Leaky,
+		Burst:        10,
+		Policy:       target.RoundRobin,
+		SRVRefresh:   60,
+		StickyKey:    "session",
+		ProxyHops:    1,
+		FailedRatio:  -1,
+	}
+}
+
+// optional callbacks of a run
+type Callbacks struct {
+	// after each accounting-request, response and t are the answer and the
+	// target which gave it, err is not nil when no target answered
+	OnResponse func(packet *radius.Packet, response *radius.Packet, t *target.Target, err error)
+	// run in order on each built packet before it's sent, from the
+	// generator loop
+	BeforeSend []Hook
+	// run in order on each accounting-response, concurrently from the
+	// senders
+	AfterResponse []Hook
+	// a sender goroutine panicked (e.g. on a hook), called with the
+	// panicking stack before the panic goes on
+	OnPanic func(v interface{}, stack []byte)
+}
+
+// counters shared between the senders and the stats
+type Counters struct {
+	Total uint64
+	Shed  uint64
+	// calls given a reused session id (--session-collisions)
+	Collisions uint64
+	// requests failing the expectations (--expect-within, --expect-attr)
+	ExpectFailed uint64
+	// retry storms detected (--storm-ratio)
+	Storms uint64
+	// requests sent with a bad authenticator (--bad-auth), and the ones
+	// the servers answered anyway
+	BadAuth         uint64
+	BadAuthAnswered uint64
+	// requests without the expected answer: no response, a rejecting
+	// one or failing the expectations
+	Failed uint64
+}
+
+// state of a generator run
+type Generator struct {
+	Cfg       Config
+	Callbacks Callbacks
+	Counters  Counters
+	InFlight  *InFlight
+	Pool      *target.Pool
+	Pacer     *pacer.Adjustable
+	Control   *control.Control
+	Start     time.Time
+	// --shared-sockets and the connections of the tcp and tls targets
+	Sockets *Sockets
+	// --simulate run, nil otherwise
+	Simulation *Simulation
+	// --mirror destination, nil without it
+	Mirror *Mirror
+	// --new-key, nil without it
+	Rotation *Rotation
+	// --scenario mix, nil without it
+	Scenarios []*Scenario
+	// --shadow server and the comparison with it, nil without it
+	ShadowTarget *target.Target
+	Shadow       *shadow.Comparer
+	// answers rejecting the requests
+	Rejects Rejects
+	// distinct errors of the run
+	Errors Errors
+	// where the time of the answered requests went
+	Budget Budget
+
+	maxReq  int64
+	cdrOpts cdr.Options
+	// records of the last call still to send, the B-leg and lifecycle ones
+	pending []*cdr.CdrValues
+	// --nas-count devices, the calls go round them
+	fleet   []*NAS
+	nasNext int
+	// --nas-port-range of the single NAS, the fleet has its own
+	nasPorts *NASPorts
+	// --realms-file realms, nil without them
+	realms []*target.Realm
+	// attribute of the run id, nil when not added
+	runIDAttr *RunIDAttr
+	// --checksum-attr, nil without it
+	checksum *Checksum
+	// --trace-session calls, nil when not tracing
+	trace *TraceSet
+	// calls drawn so far
+	calls uint64
+	// one call of every sample keeps its evidence (--sample-rate)
+	sample uint64
+	// --expect-within and --expect-attr, nil without them
+	expect *Expect
+	// --label, nil without them
+	labels map[string]string
+	// --session-class, nil without it
+	classes *SessionClasses
+	// --carry-attr, nil without it
+	carry *Carry
+	// sender of the last call
+	call call
+	// --think-time, nil without it
+	think *cdr.Phase
+	// sessions of the sent Starts and Interims not stopped yet, nil
+	// without --close-sessions
+	open map[string]bool
+	// stopped by StopForUpgrade, and started from the Handoff of the
+	// binary replaced (see TakeOver)
+	upgrading int32
+	tookOver  bool
+	// --cps plan and the records of the calls not sent yet, nil without it
+	callPlan *CallPlan
+	due      schedule
+	dueSeq   uint64
+	// next call and the next --cps arrival, the call source ended
+	upcoming   *upcomingCall
+	arrival    time.Time
+	sourceDone bool
+	// --speed time and call time of the first replayed call
+	replayStart time.Time
+	replayFirst time.Time
+	// session ids of recent calls, see collide
+	recentIds []string
+	// calls of --source or --sipp-csv
+	source cdr.CdrSource
+	// --redact attributes, nil for none
+	redact dump.Redaction
+	// nil with --value-check off
+	values *ValueCheck
+	// nil without --outage
+	outage *Outage
+	// --shard of the worker, nil for all the identifiers, and --seed
+	shard *cdr.Shard
+	seed  int64
+	// draws of the generated calls, seeded by SetShard; only used by the
+	// goroutine generating them, which sets drawing from its first draw
+	// on so the shard and the seed can't change under it
+	rnd     *rand.Rand
+	src     *drawSource
+	drawing bool
+	// --output sinks and the feed of their stats
+	outputs     []output.Writer
+	outputsDone chan struct{}
+	outputsWg   sync.WaitGroup
+	// answers of the --find-max level or the --adaptive interval, nil
+	// without them
+	window *window
+	// nil without --adaptive
+	adaptive *adaptive
+	// --slo objectives
+	slos []*SLO
+	// last --max-in-flight saturation warning, from the generator loop
+	saturatedAt time.Time
+	// --schedule windows and their time zone
+	schedule    []RateWindow
+	scheduleLoc *time.Location
+	// MapCustomFields, replaced as a whole when changed during the run
+	customFields atomic.Value
+	mu           sync.Mutex
+	final        *control.Stats
+	err          error
+}
+
+// the cdr options of cfg
+func cdrOptions(cfg Config) (cdr.Options, error) {
+	if len(cfg.FailedCodes) <= 0 {
+		cfg.FailedCodes = cdr.DefaultFailedCodes
+	}
+	model, err := cdr.NewCallModel(cfg.SetupTime, cfg.RingTime, cfg.TalkTime)
+	if err != nil {
+		return cdr.Options{}, err
+	}
+	methods, err := cdr.ParseMethods(cfg.Methods)
+	if err != nil {
+		return cdr.Options{}, fmt.Errorf("methods: %v", err)
+	}
+	failed, err := cdr.ParseFailedCodes(cfg.FailedCodes)
+	if err != nil {
+		return cdr.Options{}, fmt.Errorf("failed-codes: %v", err)
+	}
+	cardinality, err := cdr.ParseCardinality(cfg.Cardinality)
+	if err != nil {
+		return cdr.Options{}, fmt.Errorf("cardinality: %v", err)
+	}
+	mapping, err := cdr.LoadMapping(cfg.MapFile, cfg.Maps)
+	if err != nil {
+		return cdr.Options{}, fmt.Errorf("map: %v", err)
+	}
+	opts := cdr.Options{
+		Model:            model,
+		Methods:          methods,
+		Legs:             cfg.Legs,
+		FailedRatio:      cfg.FailedRatio,
+		FailedCodes:      failed,
+		Lifecycle:        cfg.Lifecycle,
+		InterimInterval:  time.Second * time.Duration(cfg.InterimInterval),
+		ReinviteRatio:    cfg.ReinviteRatio,
+		OrphanStops:      cfg.OrphanStops,
+		StopBeforeStart:  cfg.StopBeforeStart,
+		InterimAfterStop: cfg.InterimAfterStop,
+		Cardinality:      cardinality,
+		Data:             cfg.SessionType == DataSession,
+		Now:              clock.Or(cfg.Clock).Now,
+		Mapping:          mapping,
+	}
+	for _, f := range []struct {
+		name, spec string
+		format     **cdr.Format
+	}{
+		{"from-tag-format", cfg.FromTagFormat, &opts.FromTag},
+		{"to-tag-format", cfg.ToTagFormat, &opts.ToTag},
+		{"call-id-format", cfg.CallIdFormat, &opts.CallId},
+	} {
+		if len(f.spec) <= 0 {
+			continue
+		}
+		if *f.format, err = cdr.ParseFormat(f.spec); err != nil {
+			return cdr.Options{}, fmt.Errorf("%s: %v", f.name, err)
+		}
+	}
+	return opts, nil
+}
+
+func New(cfg Config, cb Callbacks) (*Generator, error) {
+	if cfg.Simulate && cfg.Clock == nil {
+		sim := clock.NewSim(time.Now())
+		sim.Auto = true
+		cfg.Clock = sim
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	if cfg.Simulate {
+		cfg.simulation = newSimulation(cfg)
+	}
+	mcf, err := GetMapCustomFields(cfg.CustomFields)
+	if err != nil {
+		return nil, fmt.Errorf("custom-fields: %v", err)
+	}
+	jitter, err := pacer.ParseJitter(cfg.PacingJitter)
+	if err != nil {
+		return nil, err
+	}
+	rl, err := pacer.NewAdjustableClock(cfg.Clock, cfg.Pacer, cfg.PPS, cfg.Burst, jitter)
+	if err != nil {
+		return nil, err
+	}
+	var pool *target.Pool
+	var realms []*target.Realm
+	if len(cfg.RealmsFile) > 0 {
+		realms, err = target.LoadRealms(cfg.RealmsFile, cfg.Port, cfg.Policy)
+		if err == nil {
+			// every realm target, for the stats
+			pool, err = target.NewPool(target.RealmTargets(realms), cfg.Policy)
+		}
+	} else {
+		pool, err = NewTargetPool(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.FailedCodes) <= 0 {
+		cfg.FailedCodes = cdr.DefaultFailedCodes
+	}
+	opts, err := cdrOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var plan *CallPlan
+	if cfg.CPS > 0 {
+		if plan, err = PlanCalls(cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Erlangs > 0 {
+			opts.Model = plan.Model
+		}
+	}
+	g := &Generator{
+		Cfg:       cfg,
+		Callbacks: cb,
+		InFlight:  NewInFlight(uint64(cfg.MaxMemory)<<20, cfg.MaxInFlight, cfg.Clock),
+		Pool:      pool,
+		Pacer:     rl,
+		Control:   control.New(!cfg.WaitStart),
+		Start:     cfg.Clock.Now(),
+		maxReq:    int64(cfg.MaxReq),
+		cdrOpts:   opts,
+		realms:    realms,
+		Sockets:   NewSockets(cfg),
+		callPlan:  plan,
+	}
+	g.Simulation = cfg.simulation
+	if g.fleet, err = NewFleet(cfg); err != nil {
+		return nil, err
+	}
+	if len(g.fleet) <= 0 && len(cfg.NASPortRange) > 0 {
+		min, max, err := ParseNASPortRange(cfg.NASPortRange)
+		if err != nil {
+			return nil, err
+		}
+		g.nasPorts = NewNASPorts(min, max)
+	}
+	if cfg.FindMax || cfg.Adaptive {
+		g.window = &window{}
+	}
+	if cfg.Adaptive {
+		g.adaptive = &adaptive{}
+	}
+	if g.slos, err = ParseSLOs(cfg.SLOs); err != nil {
+		return nil, err
+	}
+	if g.schedule, err = ParseSchedule(cfg.Schedule); err != nil {
+		return nil, err
+	}
+	g.scheduleLoc = time.Local
+	if len(cfg.ScheduleTZ) > 0 {
+		if g.scheduleLoc, err = time.LoadLocation(cfg.ScheduleTZ); err != nil {
+			return nil, fmt.Errorf("schedule-tz: %v", err)
+		}
+	}
+	if len(g.schedule) > 0 {
+		// the run starts at the rate of the hour
+		rate, _ := g.scheduledRate(g.Start)
+		if err := g.Pacer.SetRate(rate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CloseSessions {
+		g.open = make(map[string]bool)
+	}
+	if len(cfg.ThinkTime) > 0 {
+		think, err := cdr.ParsePhase(cfg.ThinkTime)
+		if err != nil {
+			return nil, fmt.Errorf("think-time: %v", err)
+		}
+		g.think = &think
+	}
+	if len(cfg.Scenarios) > 0 {
+		if g.Scenarios, err = LoadScenarios(cfg.Scenarios, cfg); err != nil {
+			return nil, err
+		}
+		// the cardinality is the run one
+		for _, sc := range g.Scenarios {
+			sc.opts.Cardinality = opts.Cardinality
+		}
+	}
+	if len(cfg.RunID) > 0 {
+		if g.runIDAttr, err = ParseRunIDAttr(cfg.RunIDAttr); err != nil {
+			return nil, err
+		}
+	}
+	if g.checksum, err = ParseChecksum(cfg.ChecksumAttr, cfg.ChecksumKey, cfg.ChecksumFields); err != nil {
+		return nil, err
+	}
+	if len(cfg.Shadow) > 0 {
+		if g.ShadowTarget, err = target.Parse(cfg.Shadow, cfg.Port); err != nil {
+			return nil, fmt.Errorf("shadow: %v", err)
+		}
+	}
+	if g.expect, err = NewExpect(cfg); err != nil {
+		return nil, err
+	}
+	if g.labels, err = ParseLabels(cfg.Labels); err != nil {
+		return nil, err
+	}
+	if cfg.SessionClass {
+		g.classes = NewSessionClasses()
+	}
+	if g.carry, err = ParseCarry(cfg.CarryAttrs); err != nil {
+		return nil, err
+	}
+	if g.values, err = ParseValueCheck(cfg.ValueCheck, cfg.Bounds); err != nil {
+		return nil, err
+	}
+	if len(cfg.Outage) > 0 {
+		if g.outage, err = ParseOutage(cfg.Outage); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.NewKey) > 0 {
+		if g.Rotation, err = ParseRotation(cfg.NewKey, cfg.KeySwitch); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Mirror) > 0 {
+		if g.Mirror, err = NewMirror(cfg.Mirror, cfg); err != nil {
+			return nil, fmt.Errorf("mirror: %v", err)
+		}
+	}
+	if len(cfg.TraceSessions) > 0 {
+		if g.trace, err = ParseTraceSessions(cfg.TraceSessions); err != nil {
+			return nil, err
+		}
+	}
+	if g.sample, err = ParseSampleRate(cfg.SampleRate); err != nil {
+		return nil, err
+	}
+	if g.redact, err = dump.ParseRedaction(cfg.Redact); err != nil {
+		return nil, err
+	}
+	if g.source, err = NewSource(cfg, &g.cdrOpts); err != nil {
+		return nil, err
+	}
+	if err := g.SetShard(cfg.Shard, cfg.Seed); err != nil {
+		return nil, err
+	}
+	g.Control.SetRateFunc = rl.SetRate
+	g.Control.SetPlanFunc = g.SetPlan
+	g.Control.SetShardFunc = g.SetShard
+	g.Control.SetFieldFunc = g.SetCustomField
+	g.customFields.Store(mcf)
+	return g, nil
+}
+
+// create the generator and run it until MaxReq, stop or ctx is done,
+// returning the final stats
+func Run(ctx context.Context, cfg Config, cb Callbacks) (control.Stats, error) {
+	g, err := New(cfg, cb)
+	if err != nil {
+		return control.Stats{}, err
+	}
+	err = g.Run(ctx)
+	return *g.Report(), err
+}
+
+func (g *Generator) CustomFields() MapCustomFields {
+	return g.customFields.Load().(MapCustomFields)
+}
+
+// add or replace the custom field id, remove it when value is nil
+func (g *Generator) SetCustomField(id int, value *string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	mcf := NewMapCustomFields()
+	k := 0
+	for _, c := range g.CustomFields() {
+		if c.ID != radius.Type(id) {
+			mcf[k] = c
+			k++
+		}
+	}
+	if value != nil {
+		mcf[k] = CustomFields{radius.Type(id), *value}
+	}
+	g.customFields.Store(mcf)
+	return nil
+}
+
+// load plan received from a coordinator
+func (g *Generator) SetPlan(pps float64, maxReq int) error {
+	if err := g.Pacer.SetRate(pps); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&g.maxReq, int64(maxReq))
+	return nil
+}
+
+// accounting-responses missing each --expect-attr, nil without them
+func (g *Generator) ExpectMisses() map[string]uint64 {
+	if g.expect == nil {
+		return nil
+	}
+	return g.expect.Misses()
+}
+
+func (g *Generator) Stats() control.Stats {
+	s := control.Stats{
+		State:         g.Control.State(),
+		PPS:           g.Pacer.Rate(),
+		Elapsed:       clock.Since(g.Cfg.Clock, g.Start).Seconds(),
+		Total:         atomic.LoadUint64(&g.Counters.Total),
+		Shed:          atomic.LoadUint64(&g.Counters.Shed),
+		InFlightBytes: g.InFlight.Bytes(),
+		MaxInFlight:   g.Cfg.MaxInFlight,
+		Goroutines:    runtime.NumGoroutine(),
+		ExpectFailed:  atomic.LoadUint64(&g.Counters.ExpectFailed),
+		Rejects:       g.Rejects.Counts(),
+		Labels:        g.labels,
+		Budget:        g.Budget.Stats(),
+	}
+	if g.Cfg.SharedSockets > 0 {
+		s.IDExhausted, _ = g.Sockets.Exhausted()
+	}
+	s.ExpectMisses = g.ExpectMisses()
+	s.Adaptive, s.KneePPS = g.Adaptive()
+	s.SLOs = g.SLOs()
+	s.InFlight, s.InFlightWaits, s.QueueDepth = g.InFlight.Requests(s.PPS)
+	s.BadAuth = atomic.LoadUint64(&g.Counters.BadAuth)
+	s.BadAuthAnswered = atomic.LoadUint64(&g.Counters.BadAuthAnswered)
+	g.mu.Lock()
+	if g.shard != nil {
+		s.Shard = g.shard.String()
+	}
+	s.Seed = g.seed
+	g.mu.Unlock()
+	if g.values != nil {
+		s.ValueViolations = g.values.Violations()
+	}
+	s.NASPortsInUse, s.NASPortsExhausted = g.NASPortStats()
+	if g.outage != nil {
+		s.OutageHeld = atomic.LoadUint64(&g.outage.Held)
+		s.OutageSent = atomic.LoadUint64(&g.outage.Sent)
+	}
+	s.Errors = g.Errors.Stats()
+	s.Build = buildinfo.Get()
+	for _, t := range g.Pool.Targets() {
+		late, stray := g.Sockets.Replies(t)
+		s.Targets = append(s.Targets, control.TargetStats{
+			Addr:               t.Addr,
+			Transport:          t.TransportName(),
+			Sent:               atomic.LoadUint64(&t.Sent),
+			Acked:              atomic.LoadUint64(&t.Acked),
+			AvgLatencyMs:       t.AvgLatency().Seconds() * 1000,
+			ProxyStateMismatch: atomic.LoadUint64(&t.ProxyStateMismatch),
+			Lost:               atomic.LoadUint64(&t.Lost),
+			Late:               late,
+			Stray:              stray,
+			PortUnreachable:    atomic.LoadUint64(&t.ICMP.PortUnreachable),
+			Unreachable:        atomic.LoadUint64(&t.ICMP.Unreachable),
+		})
+	}
+	for _, sc := range g.Scenarios {
+		s.Scenarios = append(s.Scenarios, sc.Stats())
+	}
+	return s
+}
+
+// final stats, nil while the run isn't finished
+func (g *Generator) Report() *control.Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.final
+}
+
+// log an event of the run, unless quiet
+func (g *Generator) event(format string, v ...interface{}) {
+	if !g.Cfg.Quiet {
+		log.Printf(format, v...)
+	}
+}
+
+// first send error, it stops the run
+func (g *Generator) fail(err error) {
+	g.failSession(err, "")
+}
+
+// fail on a request of the session, kept in the errors of the run
+func (g *Generator) failSession(err error, sessionId string) {
+	g.Errors.add(err, g.Cfg.Clock.Now(), sessionId)
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mu.Unlock()
+	g.Control.Stop()
+}
+
+// NAS and realm sending the records of a call
+type call struct {
+	// nil without a fleet
+	nas *NAS
+	// nil without realms
+	realm *target.Realm
+	// --trace-session call
+	trace bool
+	// --sample-rate call, its requests go to the detail file and the
+	// results file
+	sampled bool
+	// nil without --scenario
+	scenario *Scenario
+}
+
+// targets of the call requests
+func (g *Generator) pool(cl call) *target.Pool {
+	if cl.realm != nil {
+		return cl.realm.Pool
+	}
+	return g.Pool
+}
+
+// cdr of the next request and the sender of its call, with --legs the
+// B-leg after each call and with --lifecycle the records of each leg; only
+// called from a single goroutine
+func (g *Generator) nextCdr() (*cdr.CdrValues, call, error) {
+	if len(g.pending) > 0 {
+		c := g.pending[0]
+		g.pending = g.pending[1:]
+		return c, g.call, nil
+	}
+	records, cl, err := g.nextCall()
+	if err != nil {
+		return nil, call{}, err
+	}
+	g.call = cl
+	g.pending = records[1:]
+	return records[0], cl, nil
+}
+
+// records of the next call in sending order and its sender
+func (g *Generator) nextCall() ([]*cdr.CdrValues, call, error) {
+	var c *cdr.CdrValues
+	opts := &g.cdrOpts
+	var scenario *Scenario
+	if len(g.Scenarios) > 0 {
+		// the scenarios generate their calls
+		scenario = pickScenario(g.rnd, g.Scenarios)
+		atomic.AddUint64(&scenario.Calls, 1)
+		opts = &scenario.opts
+		c = cdr.FillCdrWith(opts)
+	} else {
+		var err error
+		// io.EOF after the last call
+		if c, err = g.source.Next(); err != nil {
+			return nil, call{}, err
+		}
+	}
+	g.collide(c)
+	g.calls++
+	records := []*cdr.CdrValues{c}
+	if opts.Legs {
+		records = append(records, cdr.BLeg(c, opts))
+	}
+	if opts.Lifecycle {
+		var all []*cdr.CdrValues
+		for _, r := range records {
+			all = append(all, cdr.Lifecycle(r, opts)...)
+		}
+		records = all
+	}
+	cl := call{sampled: sampled(g.calls, g.sample), scenario: scenario}
+	cl.trace = g.Cfg.Functional && cl.sampled || g.trace != nil && g.trace.Match(g.calls, c)
+	if len(g.fleet) > 0 {
+		nas := g.nextNAS()
+		if nas.ClockOffset != 0 {
+			for _, r := range records {
+				r.EventTimestamp = r.EventTimestamp.Add(nas.ClockOffset)
+			}
+		}
+		cl.nas = nas
+	}
+	if len(g.realms) > 0 {
+		realm := target.PickRealm(g.realms)
+		user := userPart(c.CallerId)
+		if len(c.CallerId) <= 0 {
+			// data sessions, the subscriber is the User-Name
+			user = c.UserName
+		}
+		user += "@" + realm.Name
+		for _, r := range records {
+			r.UserName = user
+		}
+		cl.realm = realm
+	}
+	return records, cl, nil
+}
+
+// user of a SIP URI, "sip:5511999990000@10.0.0.1:5060" is 5511999990000
+func userPart(uri string) string {
+	if i := strings.Index(uri, ":"); i >= 0 {
+		uri = uri[i+1:]
+	}
+	if i := strings.Index(uri, "@"); i >= 0 {
+		uri = uri[:i]
+	}
+	return uri
+}
+
+// recent session ids --session-collisions reuses
+const collisionWindow = 1024
+
+// with SessionCollisions, reuse the session id of a recent call on c
+func (g *Generator) collide(c *cdr.CdrValues) {
+	if g.Cfg.SessionCollisions <= 0 {
+		return
+	}
+	if len(g.recentIds) > 0 && g.rnd.Float64() < g.Cfg.SessionCollisions {
+		c.AcctSessionId = g.recentIds[g.rnd.Intn(len(g.recentIds))]
+		atomic.AddUint64(&g.Counters.Collisions, 1)
+		return
+	}
+	if len(g.recentIds) < collisionWindow {
+		g.recentIds = append(g.recentIds, c.AcctSessionId)
+	} else {
+		g.recentIds[g.rnd.Intn(collisionWindow)] = c.AcctSessionId
+	}
+}
+
+// deferred on the senders, see Callbacks.OnPanic
+func (g *Generator) panicked() {
+	if v := recover(); v != nil {
+		if g.Callbacks.OnPanic != nil {
+			g.Callbacks.OnPanic(v, debug.Stack())
+		}
+		panic(v)
+	}
+}
+
+// send one accounting-request and account the result, ready is when
+// emit started building it
+func (g *Generator) send(packet *radius.Packet, c *cdr.CdrValues, cl call, t *target.Target, ready time.Time) {
+	if g.badAuth(t) {
+		if err := g.sendBadAuth(packet, t, cl.nas); err != nil {
+			g.fail(err)
+		}
+		return
+	}
+	sent := g.Cfg.Clock.Now()
+	if g.Mirror != nil {
+		g.Mirror.Send(packet)
+	}
+	var shadowed shadow.Result
+	var swg sync.WaitGroup
+	if g.Shadow != nil {
+		// Exchange sets the secret of the target on the packet, and the
+		// primary one goes on changing under the shadow
+		sp := copyPacket(packet)
+		swg.Add(1)
+		go func() {
+			defer swg.Done()
+			shadowed = g.exchangeShadow(sp, cl)
+		}()
+	}
+	var secret []byte
+	rotated := g.Rotation != nil && g.Rotation.use(sent.Sub(g.Start))
+	if rotated {
+		secret = g.Rotation.Key
+	}
+	response, t, timing, err := sendAcct(packet, t, cl.nas, g.Sockets, g.pool(cl), secret, g.Cfg)
+	latency := clock.Since(g.Cfg.Clock, sent)
+	if g.Rotation != nil {
+		g.Rotation.count(rotated, response != nil)
+	}
+	if cl.trace {
+		traceExchange(packet, response, t, c, g.redact, err)
+	}
+	failed := err != nil
+	if g.expect != nil {
+		if misses := g.expect.Check(response, latency, err); len(misses) > 0 {
+			failed = true
+			if n := atomic.AddUint64(&g.Counters.ExpectFailed, 1); n <= maxExpectLogged && !g.Cfg.NoRequestErrors || g.Cfg.Functional {
+				log.Print("expect: ", redactSessionId(g.redact, c.AcctSessionId), ": ", strings.Join(misses, ", "))
+			}
+		}
+	}
+	if response != nil {
+		if g.classes != nil {
+			g.classes.answer(c, response)
+		}
+		if carry := g.carryOf(cl); carry != nil {
+			carry.answer(c, response)
+		}
+		if g.Rejects.add(response) {
+			failed = true
+		}
+		timing.Queue += sent.Sub(ready)
+		g.Budget.add(timing)
+	}
+	if failed {
+		atomic.AddUint64(&g.Counters.Failed, 1)
+	}
+	if cl.scenario != nil {
+		cl.scenario.count(response, latency)
+	}
+	if g.window != nil {
+		g.window.add(latency, err)
+	}
+	for _, slo := range g.slos {
+		slo.count(latency, err)
+	}
+	result := resultOf(err)
+	if len(g.outputs) > 0 {
+		r := &output.Request{Packet: packet, Response: response, Sent: sent, Server: t.Addr, Result: result,
+			AcctSessionId: c.AcctSessionId, CallId: c.CallId, StatusType: c.AcctStatusType, Sampled: cl.sampled}
+		if response != nil {
+			r.Latency = latency
+		}
+		if g.Cfg.AcctUnique {
+			r.AcctUniqueId = AcctUniqueSessionId(packet)
+		}
+		g.writeOutputs(r)
+	}
+	if g.Shadow != nil {
+		primary := shadow.Result{Result: result, Latency: latency}
+		if response != nil {
+			primary.Code = int(response.Code)
+		}
+		swg.Wait()
+		if serr := g.Shadow.Compare(sent, c.AcctSessionId, primary, shadowed); serr != nil {
+			g.fail(serr)
+		}
+	}
+	if g.Callbacks.OnResponse != nil {
+		g.Callbacks.OnResponse(packet, response, t, err)
+	}
+	if err != nil && g.window != nil {
+		// --find-max and --adaptive overload the servers on purpose
+		g.Errors.add(err, g.Cfg.Clock.Now(), redactSessionId(g.redact, c.AcctSessionId))
+		return
+	}
+	if err == nil {
+		err = runHooks(g.Callbacks.AfterResponse, response, c)
+	}
+	if err != nil {
+		g.failSession(err, redactSessionId(g.redact, c.AcctSessionId))
+	}
+}
+
+// send the request to the --shadow server too
+func (g *Generator) exchangeShadow(packet *radius.Packet, cl call) shadow.Result {
+	start := g.Cfg.Clock.Now()
+	response, err := Exchange(packet, g.ShadowTarget, cl.nas, g.Sockets, g.Cfg)
+	r := shadow.Result{Result: resultOf(err), Latency: clock.Since(g.Cfg.Clock, start)}
+	if err == nil {
+		r.Code = int(response.Code)
+	}
+	return r
+}
+
+// export result of a request sent with err
+func resultOf(err error) string {
+	if IsTimeout(err) {
+		return export.Timeout
+	} else if err != nil {
+		return export.Error
+	}
+	return export.OK
+}
+
+// warn, once a minute at most, when the generator waited on
+// --max-in-flight: it saturates before the servers do
+func (g *Generator) saturated() {
+	clk := clock.Or(g.Cfg.Clock)
+	if clock.Since(clk, g.saturatedAt) < time.Minute {
+		return
+	}
+	g.saturatedAt = clk.Now()
+	n, waits, _ := g.InFlight.Requests(0)
+	g.event("WARNING generator saturated: %d requests in flight reached --max-in-flight %d (%d waits so far), the rate sent is below the one asked", n, g.Cfg.MaxInFlight, waits)
+}
+
+// the request of c, as sent and as --dry-run prints it: its attributes
+// through the hooks and the --value-check; ErrSkip when a hook skips it
+func (g *Generator) build(c *cdr.CdrValues, cl call) (*radius.Packet, error) {
+	cfg := g.Cfg
+	packet := NewAcctPacket(c, g.CustomFields(), cfg)
+	if g.shard != nil {
+		packet.Identifier = shardIdentifier(g.shard)
+	}
+	if cl.nas != nil {
+		cl.nas.Apply(packet)
+	}
+	g.nasPort(packet, c, cl)
+	if g.runIDAttr != nil {
+		g.runIDAttr.Add(packet, cfg.RunID)
+	}
+	if g.classes != nil {
+		g.classes.add(packet, c)
+	}
+	if carry := g.carryOf(cl); carry != nil {
+		carry.add(packet, c)
+	}
+	if cfg.AcctUnique {
+		AddAcctSessionId(packet, c)
+	}
+	if err := runHooks(g.Callbacks.BeforeSend, packet, c); err != nil {
+		return nil, err
+	}
+	if g.values != nil {
+		if err := g.values.Check(packet); err != nil {
+			return nil, err
+		}
+	}
+	return packet, nil
+}
+
+// build the packet of c and send it from a goroutine of its own added to
+// wg, unless a hook skips it or --shed drops it; the error of a hook
+func (g *Generator) emit(wg *sync.WaitGroup, c *cdr.CdrValues, cl call) error {
+	ready := g.Cfg.Clock.Now()
+	packet, err := g.build(c, cl)
+	if err == ErrSkip {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if g.outage != nil {
+		if err := g.flushOutage(wg, false); err != nil {
+			return err
+		}
+		if g.holdOutage(packet, c, cl, ready) {
+			return nil
+		}
+	}
+	return g.dispatch(wg, packet, c, cl, ready)
+}
+
+// send the built request once there is room for it
+func (g *Generator) dispatch(wg *sync.WaitGroup, packet *radius.Packet, c *cdr.CdrValues, cl call, ready time.Time) error {
+	cfg := g.Cfg
+	if g.checksum != nil {
+		g.checksum.Add(packet)
+	}
+	size := uint64(PacketSize(packet) + InFlightOverhead)
+	// --max-memory and --max-in-flight backpressure, wait for pending
+	// requests or shed this one
+	if cfg.Shed {
+		if !g.InFlight.TryAcquire(size) {
+			atomic.AddUint64(&g.Counters.Shed, 1)
+			return nil
+		}
+	} else if g.InFlight.Acquire(size) && cfg.MaxInFlight > 0 {
+		g.saturated()
+	}
+	t := g.pool(cl).Next(StickyKey(c, cfg))
+	wg.Add(1)
+	send := func() {
+		defer wg.Done()
+		defer g.InFlight.Release(size)
+		defer g.panicked()
+		if cl.nas != nil {
+			cl.nas.take(g.Cfg.Clock)
+		}
+		atomic.AddUint64(&g.Counters.Total, 1)
+		g.send(packet, c, cl, t, ready)
+	}
+	if g.Simulation != nil {
+		// nothing waits on the mock server, and the virtual clock only
+		// stays put for the senders of its goroutine
+		send()
+	} else {
+		go send()
+	}
+	if g.open != nil {
+		g.track(c)
+	}
+	return nil
+}
+
+// generate and send the accounting-requests until MaxReq, stop or ctx is
+// done, returns the first send error
+func (g *Generator) Run(ctx context.Context) error {
+	cfg := g.Cfg
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		g.Control.Stop()
+	}()
+	if len(cfg.SRV) > 0 || len(cfg.TargetsFile) > 0 {
+		go WatchTargets(ctx, g.Pool, cfg)
+	}
+	if cfg.StormRatio > 0 {
+		go g.watchStorm(ctx)
+	}
+	if g.adaptive != nil {
+		go g.adapt(ctx)
+	}
+	if len(g.slos) > 0 {
+		go g.watchSLOs(ctx)
+	}
+	if len(g.schedule) > 0 {
+		go g.followSchedule(ctx)
+	}
+	if c, ok := g.source.(io.Closer); ok {
+		defer c.Close()
+	}
+	if cfg.AcctOnOff && !g.tookOver {
+		if err := g.acctOnOff(AccountingOn); err != nil {
+			return err
+		}
+	}
+	if err := g.openOutputs(); err != nil {
+		return err
+	}
+	if g.ShadowTarget != nil {
+		c, err := shadow.Create(cfg.ShadowReport, time.Duration(cfg.ShadowLatencyDiff)*time.Millisecond)
+		if err != nil {
+			return err
+		}
+		g.Shadow = c
+	}
+	g.feedOutputs()
+
+	var wg sync.WaitGroup
+	for i := int64(0); ; i++ {
+		if !g.Control.Wait() || i >= atomic.LoadInt64(&g.maxReq) {
+			break
+		}
+		if i == 0 {
+			g.mu.Lock()
+			g.drawing = true
+			g.mu.Unlock()
+		}
+		c, cl, err := g.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			g.fail(err)
+			break
+		}
+		if err := g.emit(&wg, c, cl); err != nil {
+			g.fail(err)
+			break
+		}
+	}
+	if g.outage != nil {
+		if err := g.flushOutage(&wg, true); err != nil {
+			g.fail(err)
+		}
+	}
+	if cfg.CloseSessions && !g.Upgrading() {
+		stops := g.openStops()
+		if len(stops) > 0 {
+			log.Print("closing ", len(stops), " open sessions")
+		}
+		for _, s := range stops {
+			_ = g.Pacer.Take()
+			if err := g.emit(&wg, s.c, s.cl); err != nil {
+				g.fail(err)
+				break
+			}
+		}
+	}
+	wg.Wait()
+	if cfg.AcctOnOff && !g.Upgrading() {
+		if err := g.acctOnOff(AccountingOff); err != nil {
+			g.fail(err)
+		}
+	}
+	if g.Sockets != nil {
+		g.Sockets.Close()
+	}
+	if g.Mirror != nil {
+		g.Mirror.Close()
+	}
+	if g.Shadow != nil {
+		if err := g.Shadow.Close(); err != nil {
+			g.fail(err)
+		}
+	}
+
+	// the report must be ready once the state is stopped
+	final := g.Stats()
+	final.State = control.Stopped
+	g.closeOutputs(final)
+	g.mu.Lock()
+	g.final = &final
+	err := g.err
+	g.mu.Unlock()
+	g.Control.Stop()
+	return err
+}
+
+// --emit-json, write the records of up to MaxReq requests to w as JSON
+// lines (see cdr.JSONWriter) instead of sending them, for another run to
+// send (--source json:-) after any transformation in between. Unpaced, a
+// pipe holds it back to the pace of its reader
+func (g *Generator) Emit(w io.Writer) error {
+	jw := cdr.NewJSONWriter(w)
+	for i := 0; i < g.Cfg.MaxReq; i++ {
+		c, _, err := g.nextCdr()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := jw.Write(c); err != nil {
+			return err
+		}
+		atomic.AddUint64(&g.Counters.Total, 1)
+	}
+	return jw.Flush()
+}
+
+// --dry-run, build and encode packets printing them to w instead of sending
+func (g *Generator) DryRun(w io.Writer) error {
+	cfg := g.Cfg
+	n := cfg.DryRun
+	if cfg.MaxReq < n {
+		n = cfg.MaxReq
+	}
+	// packets built, the ones the hooks skip aren't
+	var built, skipped, size, attrs int
+	perTarget := make(map[*target.Target]int)
+	for i := 0; i < n; i++ {
+		c, cl, err := g.nextCdr()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		packet, err := g.build(c, cl)
+		if err == ErrSkip {
+			fmt.Fprintf(w, "# packet %d skipped by hook\n", i+1)
+			skipped++
+			continue
+		} else if err != nil {
+			return fmt.Errorf("packet %d: %v", i+1, err)
+		}
+		if g.checksum != nil {
+			g.checksum.Add(packet)
+		}
+		t := g.pool(cl).Next(StickyKey(c, cfg))
+		packet.Secret = t.Key([]byte(cfg.Key))
+		if cl.nas != nil && cl.nas.Secret != nil {
+			packet.Secret = cl.nas.Secret
+		}
+		b, err := packet.Encode()
+		if err != nil {
+			return fmt.Errorf("packet %d: %v", i+1, err)
+		}
+		built++
+		size += len(b)
+		for _, values := range packet.Attributes {
+			attrs += len(values)
+		}
+		perTarget[t]++
+		fmt.Fprintf(w, "# packet %d to %s, %d bytes\n", i+1, t.Addr, len(b))
+		dump.Fprint(w, packet, g.redact)
+	}
+
+	fmt.Fprintln(w, "# summary")
+	fmt.Fprintf(w, "packets built:        %d\n", built)
+	if skipped > 0 {
+		fmt.Fprintf(w, "packets skipped:      %d by hook\n", skipped)
+	}
+	if built > 0 {
+		fmt.Fprintf(w, "avg packet size:      %d bytes\n", size/built)
+		fmt.Fprintf(w, "avg attributes:       %.1f\n", float64(attrs)/float64(built))
+	}
+	fmt.Fprintf(w, "rate:                 %g pps (%s pacer, burst %d)\n", cfg.PPS, cfg.Pacer, cfg.Burst)
+	if p := g.callPlan; p != nil {
+		fmt.Fprintf(w, "calls:                %g cps, mean talk time %s, %.1f records per call (%.1f pps)\n", cfg.CPS, p.HoldTime, p.Records, p.PPS)
+	}
+	if cfg.MaxReq == MaxInt {
+		fmt.Fprintln(w, "max requests:         unlimited")
+	} else {
+		fmt.Fprintf(w, "max requests:         %d\n", cfg.MaxReq)
+		fmt.Fprintf(w, "estimated duration:   %s\n", time.Duration(float64(cfg.MaxReq)/cfg.PPS*float64(time.Second)))
+	}
+	for _, sc := range g.Scenarios {
+		fmt.Fprintf(w, "  scenario %s weight %d: %d of the built calls\n", sc.Name, sc.Weight, atomic.LoadUint64(&sc.Calls))
+	}
+	fmt.Fprintf(w, "policy:               %s\n", cfg.Policy)
+	for _, t := range g.Pool.Targets() {
+		fmt.Fprintf(w, "  %s weight %d priority %d: %d of the built packets\n", t.Addr, t.Weight, t.Priority, perTarget[t])
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package gen
+
+import (
+	"container/heap"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+)
+
+// state a run hands to the binary replacing it (see StopForUpgrade): the
+// counters and the records not sent yet of the calls begun, so the
+// sessions open on the servers go on
+type Handoff struct {
+	Counters Counters                  `json:"counters"`
+	Targets  map[string]TargetCounters `json:"targets,omitempty"`
+	Records  []HandoffRecord           `json:"records,omitempty"`
+	// sessions open on the servers (--close-sessions)
+	Open []string `json:"open,omitempty"`
+}
+
+// counters of a target, by address on the Handoff
+type TargetCounters struct {
+	Sent               uint64 `json:"sent"`
+	Acked              uint64 `json:"acked"`
+	Latency            uint64 `json:"latency_ns"`
+	ProxyStateMismatch uint64 `json:"proxy_state_mismatch,omitempty"`
+	Lost               uint64 `json:"lost,omitempty"`
+	Retransmits        uint64 `json:"retransmits,omitempty"`
+}
+
+// record to send at Due, its call sender by the NAS index (-1 for none)
+// and the realm and scenario names
+type HandoffRecord struct {
+	Due      time.Time      `json:"due"`
+	Cdr      *cdr.CdrValues `json:"cdr"`
+	NAS      int            `json:"nas"`
+	Realm    string         `json:"realm,omitempty"`
+	Scenario string         `json:"scenario,omitempty"`
+	Trace    bool           `json:"trace,omitempty"`
+	// not sampled, so the records of older binaries keep their evidence
+	Unsampled bool `json:"unsampled,omitempty"`
+}
+
+// stop the run for a binary upgrade: unlike a stop, no --close-sessions
+// Stops nor Accounting-Off, the records of the calls begun are left for
+// Handoff
+func (g *Generator) StopForUpgrade() {
+	atomic.StoreInt32(&g.upgrading, 1)
+	g.Control.Stop()
+}
+
+// true once StopForUpgrade was called
+func (g *Generator) Upgrading() bool {
+	return atomic.LoadInt32(&g.upgrading) != 0
+}
+
+// state for the replacing binary, once Run returned
+func (g *Generator) Handoff() Handoff {
+	h := Handoff{Targets: make(map[string]TargetCounters)}
+	h.Counters = Counters{
+		Total:        atomic.LoadUint64(&g.Counters.Total),
+		Shed:         atomic.LoadUint64(&g.Counters.Shed),
+		Collisions:   atomic.LoadUint64(&g.Counters.Collisions),
+		ExpectFailed: atomic.LoadUint64(&g.Counters.ExpectFailed),
+		Storms:       atomic.LoadUint64(&g.Counters.Storms),
+	}
+	for _, t := range g.Pool.Targets() {
+		h.Targets[t.Addr] = TargetCounters{
+			Sent:               atomic.LoadUint64(&t.Sent),
+			Acked:              atomic.LoadUint64(&t.Acked),
+			Latency:            atomic.LoadUint64(&t.Latency),
+			ProxyStateMismatch: atomic.LoadUint64(&t.ProxyStateMismatch),
+			Lost:               atomic.LoadUint64(&t.Lost),
+			Retransmits:        atomic.LoadUint64(&t.Retransmits),
+		}
+	}
+	now := g.Cfg.Clock.Now()
+	for _, c := range g.pending {
+		h.Records = append(h.Records, g.handoffRecord(scheduled{due: now, c: c, cl: g.call}))
+	}
+	for _, s := range g.due {
+		h.Records = append(h.Records, g.handoffRecord(s))
+	}
+	for id := range g.open {
+		h.Open = append(h.Open, id)
+	}
+	return h
+}
+
+func (g *Generator) handoffRecord(s scheduled) HandoffRecord {
+	r := HandoffRecord{Due: s.due, Cdr: s.c, NAS: -1, Trace: s.cl.trace, Unsampled: !s.cl.sampled}
+	for i, n := range g.fleet {
+		if n == s.cl.nas {
+			r.NAS = i
+		}
+	}
+	if s.cl.realm != nil {
+		r.Realm = s.cl.realm.Name
+	}
+	if s.cl.scenario != nil {
+		r.Scenario = s.cl.scenario.Name
+	}
+	return r
+}
+
+// go on from the state of the replaced binary, before Run; the run must
+// have the same options
+func (g *Generator) TakeOver(h Handoff) {
+	g.tookOver = true
+	g.Counters = h.Counters
+	for _, t := range g.Pool.Targets() {
+		if tc, ok := h.Targets[t.Addr]; ok {
+			t.Sent, t.Acked, t.Latency = tc.Sent, tc.Acked, tc.Latency
+			t.ProxyStateMismatch, t.Lost, t.Retransmits = tc.ProxyStateMismatch, tc.Lost, tc.Retransmits
+		}
+	}
+	for _, r := range h.Records {
+		cl := call{trace: r.Trace, sampled: !r.Unsampled}
+		if r.NAS >= 0 && r.NAS < len(g.fleet) {
+			cl.nas = g.fleet[r.NAS]
+		}
+		for _, realm := range g.realms {
+			if realm.Name == r.Realm {
+				cl.realm = realm
+			}
+		}
+		for _, sc := range g.Scenarios {
+			if sc.Name == r.Scenario {
+				cl.scenario = sc
+			}
+		}
+		if g.callPlan == nil && g.think == nil && g.Cfg.Speed <= 0 {
+			// back to back, the rest of the last call
+			g.pending = append(g.pending, r.Cdr)
+			g.call = cl
+			continue
+		}
+		g.dueSeq++
+		heap.Push(&g.due, scheduled{due: r.Due, seq: g.dueSeq, c: r.Cdr, cl: cl})
+	}
+	if g.open != nil {
+		for _, id := range h.Open {
+			g.open[id] = true
+		}
+	}
+}
+
+// handoff of the binary replaced, next to the checkpoint on path
+func HandoffPath(checkpoint string) string {
+	return checkpoint + ".handoff"
+}
+
+func SaveHandoff(path string, h Handoff) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// handoff on path, removed once read; nil when there is none
+func LoadHandoff(path string) (*Handoff, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	var h Handoff
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
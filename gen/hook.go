@@ -0,0 +1,66 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+)
+
+// per-packet hook, c is the generated cdr the packet was built from.
+// returning ErrSkip from a BeforeSend hook drops the packet, any other
+// error stops the run
+type Hook func(packet *radius.Packet, c *cdr.CdrValues) error
+
+// returned by a BeforeSend hook to not send the packet
+var ErrSkip = errors.New("gen: skip packet")
+
+// symbols looked up on a --plugin, both are optional:
+//
+//	func BeforeSend(packet *radius.Packet, c *cdr.CdrValues) error
+//	func AfterResponse(response *radius.Packet, c *cdr.CdrValues) error
+const (
+	PluginBeforeSend    = "BeforeSend"
+	PluginAfterResponse = "AfterResponse"
+)
+
+// open a Go plugin (go build -buildmode=plugin) and add its hooks to cb
+func LoadPlugin(path string, cb *Callbacks) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range []string{PluginBeforeSend, PluginAfterResponse} {
+		sym, err := p.Lookup(name)
+		if err != nil {
+			continue
+		}
+		h, ok := sym.(func(*radius.Packet, *cdr.CdrValues) error)
+		if !ok {
+			return fmt.Errorf("plugin %s: %s must be a func(*radius.Packet, *cdr.CdrValues) error", path, name)
+		}
+		if name == PluginBeforeSend {
+			cb.BeforeSend = append(cb.BeforeSend, h)
+		} else {
+			cb.AfterResponse = append(cb.AfterResponse, h)
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("plugin %s: no %s or %s hook", path, PluginBeforeSend, PluginAfterResponse)
+	}
+	return nil
+}
+
+// run the hooks in order, stopping on the first error
+func runHooks(hooks []Hook, packet *radius.Packet, c *cdr.CdrValues) error {
+	for _, h := range hooks {
+		if err := h(packet, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package gen
+
+import (
+	"math/rand"
+	"time"
+)
+
+// random part of the send time of a request (--send-jitter)
+func sendJitter(cfg Config) time.Duration {
+	if cfg.SendJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(cfg.SendJitter)*int64(time.Millisecond) + 1))
+}
+
+// transmissions of a request the timeout allows, the first one and the
+// retransmissions every Retry seconds
+func transmissions(cfg Config) int {
+	if cfg.Retry <= 0 || cfg.MaxRetry <= 1 {
+		return 1
+	}
+	return cfg.MaxRetry
+}
+
+// leading transmissions of a request lost before the wire (--send-loss),
+// each with the SendLoss probability; the client retransmits the same
+// packet so a lost one only delays the request to the next retry, all of
+// them lost is a timeout without anything sent
+func lostTransmissions(cfg Config) int {
+	if cfg.SendLoss <= 0 {
+		return 0
+	}
+	n, max := 0, transmissions(cfg)
+	for n < max && rand.Float64() < cfg.SendLoss {
+		n++
+	}
+	return n
+}
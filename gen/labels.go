@@ -0,0 +1,47 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// label names, the metric label ones so they carry over to any exporter
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parse the "name=value" labels of --label, nil without them
+func ParseLabels(specs []string) (map[string]string, error) {
+	var labels map[string]string
+	for _, s := range specs {
+		kv := strings.SplitN(s, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("label %s: must be name=value", s)
+		}
+		if !labelName.MatchString(name) {
+			return nil, fmt.Errorf("label %s: name must be letters, digits and _, not starting with a digit", s)
+		}
+		if _, dup := labels[name]; dup {
+			return nil, fmt.Errorf("label %s given twice", name)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[name] = strings.TrimSpace(kv[1])
+	}
+	return labels, nil
+}
+
+// "name=value,..." sorted by name
+func FormatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + labels[name]
+	}
+	return strings.Join(names, ",")
+}
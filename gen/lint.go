@@ -0,0 +1,90 @@
+package gen
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/dump"
+)
+
+// SIPp CSV lines reported at most by Lint
+const maxLintLines = 20
+
+// problem found by Lint, a run still goes with a warning
+type Problem struct {
+	Warning bool
+	Msg     string
+}
+
+func (p Problem) String() string {
+	if p.Warning {
+		return "warning: " + p.Msg
+	}
+	return "error: " + p.Msg
+}
+
+// problems of the generator configuration found without sending (config
+// validate): the custom fields resolved against the dictionary and the
+// generated attributes, and every call of the SIPp CSV, which it reads to
+// the end
+func (g *Generator) Lint() []Problem {
+	var problems []Problem
+	generated := NewAcctPacket(cdr.FillCdrWith(&g.cdrOpts), nil, g.Cfg)
+	for _, f := range g.CustomFields() {
+		name := fmt.Sprintf("custom field %d", f.ID)
+		if f.ID < 1 || f.ID > 255 {
+			problems = append(problems, Problem{Msg: name + ": attribute type out of 1-255"})
+			continue
+		}
+		if len(f.Value) > 253 {
+			problems = append(problems, Problem{Msg: fmt.Sprintf("%s: value of %d bytes, 253 at most", name, len(f.Value))})
+		}
+		a, ok := dump.Dictionary[f.ID]
+		if !ok {
+			problems = append(problems, Problem{Warning: true, Msg: name + ": not on the dictionary, sent as text"})
+			continue
+		}
+		name += " (" + a.Name + ")"
+		if a.Kind != dump.String && a.Kind != dump.Octets {
+			problems = append(problems, Problem{Msg: fmt.Sprintf("%s: %s attribute, the value is sent as text", name, a.Kind)})
+		}
+		if _, ok := generated.Attributes[f.ID]; ok {
+			problems = append(problems, Problem{Warning: true, Msg: name + ": sent besides the generated one"})
+		}
+	}
+	if g.values != nil {
+		// the generated attributes, the custom fields included
+		p := NewAcctPacket(cdr.FillCdrWith(&g.cdrOpts), g.CustomFields(), g.Cfg)
+		for _, t := range dump.Types(p) {
+			for _, a := range p.Attributes[t] {
+				if _, reason := g.values.check(t, a); len(reason) > 0 {
+					problems = append(problems, Problem{Warning: g.values.Lenient, Msg: "value-check: " + dump.Name(t) + " " + reason})
+				}
+			}
+		}
+	}
+	if len(g.Cfg.SIPpCSV) > 0 {
+		calls, bad := 0, 0
+		for {
+			_, err := g.source.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if bad++; bad <= maxLintLines {
+					problems = append(problems, Problem{Msg: err.Error()})
+				}
+				continue
+			}
+			calls++
+		}
+		if bad > maxLintLines {
+			problems = append(problems, Problem{Msg: fmt.Sprintf("sipp csv: %d more bad lines", bad-maxLintLines)})
+		}
+		if calls <= 0 {
+			problems = append(problems, Problem{Msg: "sipp csv: no calls"})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,53 @@
+package gen
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// --mirror destination, each request is copied to it once over a single
+// socket, its responses are never read
+type Mirror struct {
+	Target *target.Target
+	// requests copied and the ones that failed to be written
+	Sent   uint64
+	Errors uint64
+
+	conn net.Conn
+	key  []byte
+}
+
+// mirror to spec (host[:port[:secret]]), cfg.Key when it has no secret
+func NewMirror(spec string, cfg Config) (*Mirror, error) {
+	t, err := target.Parse(spec, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("udp", t.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Mirror{Target: t, conn: conn, key: t.Key([]byte(cfg.Key))}, nil
+}
+
+// copy the packet to the mirror, signed with its secret
+func (m *Mirror) Send(packet *radius.Packet) {
+	p := *packet
+	p.Secret = m.key
+	b, err := p.Encode()
+	if err == nil {
+		_, err = m.conn.Write(b)
+	}
+	if err != nil {
+		atomic.AddUint64(&m.Errors, 1)
+		return
+	}
+	atomic.AddUint64(&m.Sent, 1)
+}
+
+func (m *Mirror) Close() error {
+	return m.conn.Close()
+}
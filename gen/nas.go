@@ -0,0 +1,251 @@
+package gen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/pacer"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// a simulated NAS of the fleet (--nas-count)
+type NAS struct {
+	IP         net.IP
+	Port       int
+	Identifier string
+	// own shared secret, nil for the target one
+	Secret []byte
+	// local UDP port of its requests, zero for an ephemeral one
+	SourcePort int
+	// drift of its clock, added to the Event-Timestamps
+	ClockOffset time.Duration
+	// own rate limit in requests per second and its pacer, zero and nil
+	// for none (see ParseNASRates)
+	Rate  float64
+	pacer pacer.Pacer
+	// requests sent and the time they waited its rate limit
+	Sent   uint64
+	Waited int64
+	// with a source port, its requests go one at a time
+	mu sync.Mutex
+	// NAS-Ports of its sessions, nil for Port
+	ports *NASPorts
+}
+
+// the cfg.NASCount devices: NAS-IP-Address consecutive from
+// cfg.NASIPAddress, NAS-Port from cfg.NASPort, NAS-Identifier nas-1 to
+// nas-N, the cfg.NASSecrets cycled and source ports from
+// cfg.NASSourcePort, clock offsets from cfg.NASClockSkew (see
+// ParseClockSkew), rate limits from cfg.NASRate with cfg.NASBurst (see
+// ParseNASRates) and the NAS-Ports of cfg.NASPortRange each; nil without a
+// fleet. The offsets and rates drawn are the same for the same cfg.Seed
+func NewFleet(cfg Config) ([]*NAS, error) {
+	if cfg.NASCount <= 0 {
+		return nil, nil
+	}
+	ip := net.ParseIP(cfg.NASIPAddress).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("nas-ip %s: not an IPv4 address", cfg.NASIPAddress)
+	}
+	var secrets []string
+	if len(cfg.NASSecrets) > 0 {
+		secrets = strings.Split(cfg.NASSecrets, ",")
+	}
+	if cfg.NASSourcePort < 0 || cfg.NASSourcePort+cfg.NASCount-1 > 65535 {
+		return nil, fmt.Errorf("nas-source-port %d: no room for %d ports", cfg.NASSourcePort, cfg.NASCount)
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+	skew, err := ParseClockSkew(r, cfg.NASClockSkew, cfg.NASCount)
+	if err != nil {
+		return nil, fmt.Errorf("nas-clock-skew: %v", err)
+	}
+	rates, err := ParseNASRates(r, cfg.NASRate, cfg.NASCount)
+	if err != nil {
+		return nil, fmt.Errorf("nas-rate: %v", err)
+	}
+	minPort, maxPort, err := ParseNASPortRange(cfg.NASPortRange)
+	if err != nil {
+		return nil, err
+	}
+	base := binary.BigEndian.Uint32(ip)
+	fleet := make([]*NAS, cfg.NASCount)
+	for i := range fleet {
+		n := &NAS{
+			IP:         make(net.IP, 4),
+			Port:       cfg.NASPort + i,
+			Identifier: "nas-" + strconv.Itoa(i+1),
+		}
+		binary.BigEndian.PutUint32(n.IP, base+uint32(i))
+		if len(secrets) > 0 {
+			n.Secret = []byte(strings.TrimSpace(secrets[i%len(secrets)]))
+		}
+		if cfg.NASSourcePort > 0 {
+			n.SourcePort = cfg.NASSourcePort + i
+		}
+		if len(skew) > 0 {
+			n.ClockOffset = skew[i%len(skew)]
+		}
+		if len(rates) > 0 {
+			n.Rate = rates[i%len(rates)]
+			if n.pacer, err = pacer.NewClock(clock.Or(cfg.Clock), pacer.Token, n.Rate, cfg.NASBurst, 0); err != nil {
+				return nil, fmt.Errorf("nas-rate: %v", err)
+			}
+		}
+		if len(cfg.NASPortRange) > 0 {
+			n.ports = NewNASPorts(minPort, maxPort)
+		}
+		fleet[i] = n
+	}
+	return fleet, nil
+}
+
+// clock offsets of n devices: an unsigned duration D draws each one from
+// -D to D with r, a comma-separated list of signed durations ("-5s,0,+2m")
+// is cycled across the fleet; empty for none
+func ParseClockSkew(r *rand.Rand, spec string, n int) ([]time.Duration, error) {
+	spec = strings.TrimSpace(spec)
+	if len(spec) <= 0 {
+		return nil, nil
+	}
+	if !strings.ContainsAny(spec, ",+-") {
+		max, err := time.ParseDuration(spec)
+		if err != nil {
+			return nil, err
+		}
+		skew := make([]time.Duration, n)
+		if max > 0 {
+			for i := range skew {
+				skew[i] = time.Duration(r.Int63n(int64(2*max)+1)) - max
+			}
+		}
+		return skew, nil
+	}
+	var skew []time.Duration
+	for _, v := range strings.Split(spec, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		skew = append(skew, d)
+	}
+	return skew, nil
+}
+
+// rate limits of n devices in requests per second: "dist:mean" draws each
+// one with r around the mean (fixed, uniform, exponential or normal as the
+// call phases, exponential:2 makes a few chatty NAS and many quiet ones), a
+// comma-separated list of rates ("50,5,5,1") is cycled across the fleet;
+// empty for none
+func ParseNASRates(r *rand.Rand, spec string, n int) ([]float64, error) {
+	spec = strings.TrimSpace(spec)
+	if len(spec) <= 0 {
+		return nil, nil
+	}
+	if i := strings.Index(spec, ":"); i >= 0 {
+		dist := spec[:i]
+		mean, err := strconv.ParseFloat(strings.TrimSpace(spec[i+1:]), 64)
+		if err != nil || mean < pacer.MinRate {
+			return nil, fmt.Errorf("%q: mean rate must be at least %g", spec, pacer.MinRate)
+		}
+		rates := make([]float64, n)
+		for i := range rates {
+			switch dist {
+			case cdr.Fixed:
+				rates[i] = mean
+			case cdr.Uniform:
+				rates[i] = r.Float64() * 2 * mean
+			case cdr.Exponential:
+				rates[i] = r.ExpFloat64() * mean
+			case cdr.Normal:
+				// standard deviation of a quarter of the mean
+				rates[i] = r.NormFloat64()*mean/4 + mean
+			default:
+				return nil, fmt.Errorf("%q: distribution must be fixed, uniform, exponential or normal", spec)
+			}
+			if rates[i] < pacer.MinRate {
+				rates[i] = pacer.MinRate
+			}
+		}
+		return rates, nil
+	}
+	var rates []float64
+	for _, v := range strings.Split(spec, ",") {
+		r, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || r < pacer.MinRate {
+			return nil, fmt.Errorf("%q: rate must be at least %g", v, pacer.MinRate)
+		}
+		rates = append(rates, r)
+	}
+	return rates, nil
+}
+
+// wait the rate limit of n, if any, before one of its requests
+func (n *NAS) take(c clock.Clock) {
+	atomic.AddUint64(&n.Sent, 1)
+	if n.pacer == nil {
+		return
+	}
+	start := c.Now()
+	n.pacer.Take()
+	atomic.AddInt64(&n.Waited, int64(clock.Since(c, start)))
+}
+
+// NAS of the next call: round the fleet, or drawn by rate when the NAS
+// have their own so each one gets its share of the calls
+func (g *Generator) nextNAS() *NAS {
+	if g.fleet[0].Rate <= 0 {
+		nas := g.fleet[g.nasNext%len(g.fleet)]
+		g.nasNext++
+		return nas
+	}
+	var sum float64
+	for _, n := range g.fleet {
+		sum += n.Rate
+	}
+	x := g.rnd.Float64() * sum
+	for _, n := range g.fleet {
+		if x -= n.Rate; x < 0 {
+			return n
+		}
+	}
+	return g.fleet[len(g.fleet)-1]
+}
+
+// the NAS of the fleet by requests sent, the chattiest first
+func (g *Generator) Fleet() []*NAS {
+	fleet := append([]*NAS(nil), g.fleet...)
+	sort.SliceStable(fleet, func(i, j int) bool {
+		return atomic.LoadUint64(&fleet[i].Sent) > atomic.LoadUint64(&fleet[j].Sent)
+	})
+	return fleet
+}
+
+// replace the NAS attributes of the packet with the ones of n
+func (n *NAS) Apply(p *radius.Packet) {
+	rfc2865.NASIPAddress_Set(p, n.IP)
+	rfc2865.NASPort_Set(p, rfc2865.NASPort(n.Port))
+	rfc2865.NASIdentifier_SetString(p, n.Identifier)
+}
+
+// clock offset of the NAS of cl, zero without a fleet
+func nasOffset(cl call) time.Duration {
+	if cl.nas == nil {
+		return 0
+	}
+	return cl.nas.ClockOffset
+}
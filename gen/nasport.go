@@ -0,0 +1,178 @@
+package gen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// most ports of a --nas-port-range
+const maxNASPorts = 1 << 20
+
+// the NAS-Ports of a NAS (--nas-port-range): a session takes the free port
+// least recently used with its first record and gives it back with its
+// Stop, so the servers keying the sessions on (NAS-IP, NAS-Port) see the
+// ports of a real NAS. With every port taken (sessions never stopped, or
+// more open sessions than ports) the port of the oldest open session is
+// reused, counted as exhausted
+type NASPorts struct {
+	mu sync.Mutex
+	// free ports, the least recently used first
+	free []int
+	// port of each open session, and the sessions in the order they took
+	// theirs
+	open  map[string]int
+	order []string
+	// sessions given a port already taken
+	Exhausted uint64
+}
+
+// parse "min-max" of the NAS-Ports, empty for none
+func ParseNASPortRange(spec string) (int, int, error) {
+	if len(spec) <= 0 {
+		return 0, 0, nil
+	}
+	r := strings.SplitN(spec, "-", 2)
+	if len(r) != 2 {
+		return 0, 0, fmt.Errorf("nas-port-range %q must be min-max", spec)
+	}
+	min, err := strconv.ParseUint(strings.TrimSpace(r[0]), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nas-port-range %q: bad min", spec)
+	}
+	max, err := strconv.ParseUint(strings.TrimSpace(r[1]), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nas-port-range %q: bad max", spec)
+	}
+	if min > max || max-min+1 > maxNASPorts {
+		return 0, 0, fmt.Errorf("nas-port-range %q: min must be at most max, %d ports at most", spec, maxNASPorts)
+	}
+	return int(min), int(max), nil
+}
+
+func NewNASPorts(min, max int) *NASPorts {
+	p := &NASPorts{open: make(map[string]int)}
+	for port := min; port <= max; port++ {
+		p.free = append(p.free, port)
+	}
+	return p
+}
+
+// port of the session of the record c, taken with its first record and
+// given back with its Stop; true the first time every port was taken
+func (p *NASPorts) take(c *cdr.CdrValues) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	exhausted := p.Exhausted
+	port, ok := p.open[c.AcctSessionId]
+	if c.AcctStatusType == cdr.StatusStop {
+		if !ok {
+			// a Stop of no open session (replayed, collided) leaves the
+			// pool as it is: a free port or the one of the oldest session,
+			// neither taken
+			return p.peek(), false
+		}
+		delete(p.open, c.AcctSessionId)
+		p.free = append(p.free, port)
+		return port, false
+	}
+	if !ok {
+		port = p.next()
+		p.open[c.AcctSessionId] = port
+		p.order = append(p.order, c.AcctSessionId)
+		if len(p.order) > 2*len(p.open)+1024 {
+			// drop the stopped sessions
+			order := p.order[:0]
+			for _, id := range p.order {
+				if _, ok := p.open[id]; ok {
+					order = append(order, id)
+				}
+			}
+			p.order = order
+		}
+	}
+	return port, exhausted == 0 && p.Exhausted > 0
+}
+
+// the least recently used free port, or the one of the oldest open
+// session
+func (p *NASPorts) next() int {
+	if len(p.free) > 0 {
+		port := p.free[0]
+		p.free = p.free[1:]
+		return port
+	}
+	for len(p.order) > 0 {
+		id := p.order[0]
+		p.order = p.order[1:]
+		if port, ok := p.open[id]; ok {
+			// the oldest session loses its port
+			delete(p.open, id)
+			p.Exhausted++
+			return port
+		}
+	}
+	// not reached, a port is either free or open
+	return 0
+}
+
+// the port next would give, without taking it
+func (p *NASPorts) peek() int {
+	if len(p.free) > 0 {
+		return p.free[0]
+	}
+	for _, id := range p.order {
+		if port, ok := p.open[id]; ok {
+			return port
+		}
+	}
+	return 0
+}
+
+// ports taken by open sessions and the sessions given a taken port
+func (p *NASPorts) Stats() (int, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.open), p.Exhausted
+}
+
+// NAS-Port of the session of c from the ports of its NAS, the fixed one
+// without --nas-port-range
+func (g *Generator) nasPort(p *radius.Packet, c *cdr.CdrValues, cl call) {
+	ports := g.nasPorts
+	if cl.nas != nil {
+		ports = cl.nas.ports
+	}
+	if ports == nil {
+		return
+	}
+	port, exhausted := ports.take(c)
+	if exhausted {
+		g.event("nas-port-range: every NAS-Port of a NAS taken, the oldest open sessions lose theirs")
+	}
+	rfc2865.NASPort_Set(p, rfc2865.NASPort(port))
+}
+
+// NAS-Ports taken by the open sessions over the fleet, and the sessions
+// given a taken one
+func (g *Generator) NASPortStats() (uint64, uint64) {
+	pools := []*NASPorts{g.nasPorts}
+	for _, n := range g.fleet {
+		pools = append(pools, n.ports)
+	}
+	var inUse, exhausted uint64
+	for _, p := range pools {
+		if p == nil {
+			continue
+		}
+		n, e := p.Stats()
+		inUse += uint64(n)
+		exhausted += e
+	}
+	return inUse, exhausted
+}
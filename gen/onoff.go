@@ -0,0 +1,93 @@
+package gen
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// RFC 2866 attributes of the Accounting-On/Off requests
+const (
+	AcctStatusType radius.Type = 40
+	EventTimestamp radius.Type = 55
+)
+
+// Acct-Status-Type of a NAS (re)starting or shutting down
+const (
+	AccountingOn  = 7
+	AccountingOff = 8
+)
+
+// Accounting-On or Off request (status) of the NAS, nil nas is the
+// --nas-ip-address one
+func NewAcctOnOffPacket(status uint32, nas *NAS, cfg Config) *radius.Packet {
+	packet := radius.New(radius.CodeAccountingRequest, []byte(cfg.Key))
+	packet.Add(AcctStatusType, radius.NewInteger(status))
+	now := clock.Or(cfg.Clock).Now()
+	if nas != nil {
+		now = now.Add(nas.ClockOffset)
+		nas.Apply(packet)
+	} else {
+		rfc2865.NASIPAddress_Add(packet, net.ParseIP(cfg.NASIPAddress))
+	}
+	ts, _ := radius.NewDate(now)
+	packet.Add(EventTimestamp, ts)
+	return packet
+}
+
+// send the Accounting-On or Off (status) of every NAS to the servers of
+// each realm (or the servers), returning the first error
+func (g *Generator) acctOnOff(status uint32) error {
+	nases := g.fleet
+	if len(nases) <= 0 {
+		nases = []*NAS{nil}
+	}
+	pools := []*target.Pool{g.Pool}
+	if len(g.realms) > 0 {
+		pools = pools[:0]
+		for _, r := range g.realms {
+			pools = append(pools, r.Pool)
+		}
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+	for _, pool := range pools {
+		for _, nas := range nases {
+			wg.Add(1)
+			go func(pool *target.Pool, nas *NAS) {
+				defer wg.Done()
+				packet := NewAcctOnOffPacket(status, nas, g.Cfg)
+				if g.runIDAttr != nil {
+					g.runIDAttr.Add(packet, g.Cfg.RunID)
+				}
+				key := g.Cfg.NASIPAddress
+				if nas != nil {
+					key = nas.IP.String()
+				}
+				_, t, err := SendAcct(packet, pool.Next(key), nas, g.Sockets, pool, g.Cfg)
+				if err != nil {
+					mu.Lock()
+					if first == nil {
+						first = fmt.Errorf("%s to %s: %v", onOffName(status), t.Addr, err)
+					}
+					mu.Unlock()
+				}
+			}(pool, nas)
+		}
+	}
+	wg.Wait()
+	return first
+}
+
+func onOffName(status uint32) string {
+	if status == AccountingOn {
+		return "Accounting-On"
+	}
+	return "Accounting-Off"
+}
@@ -0,0 +1,103 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"layeh.com/radius"
+)
+
+// NAS reconnecting after an outage (--outage): the requests due during
+// the blackout are held, as a NAS keeps the records it can't send, and
+// are all sent at once when it is over, their Acct-Delay-Time the seconds
+// they were held, the storm the accounting servers have to absorb
+type Outage struct {
+	// run time of the blackout and its length
+	After time.Duration
+	For   time.Duration
+	// requests held and the ones sent since
+	Held uint64
+	Sent uint64
+
+	mu   sync.Mutex
+	held []heldRequest
+}
+
+// a request of the blackout and the time it was due
+type heldRequest struct {
+	packet *radius.Packet
+	c      *cdr.CdrValues
+	cl     call
+	due    time.Time
+}
+
+// parse the blackout after:duration of the run, e.g. "2m:30s"
+func ParseOutage(spec string) (*Outage, error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("outage: %q must be after:duration of the run, e.g. 2m:30s", spec)
+	}
+	after, err := time.ParseDuration(spec[:i])
+	if err != nil || after < 0 {
+		return nil, fmt.Errorf("outage: bad start %q, a duration of the run (2m)", spec[:i])
+	}
+	d, err := time.ParseDuration(spec[i+1:])
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("outage: bad length %q, a duration greater 0 (30s)", spec[i+1:])
+	}
+	return &Outage{After: after, For: d}, nil
+}
+
+// hold the request due during the blackout, false out of it
+func (g *Generator) holdOutage(packet *radius.Packet, c *cdr.CdrValues, cl call, due time.Time) bool {
+	o := g.outage
+	elapsed := due.Sub(g.Start)
+	if elapsed < o.After || elapsed >= o.After+o.For {
+		return false
+	}
+	o.mu.Lock()
+	if len(o.held) <= 0 {
+		g.event("outage: holding the requests for %v", o.For)
+	}
+	o.held = append(o.held, heldRequest{packet: packet, c: c, cl: cl, due: due})
+	o.mu.Unlock()
+	atomic.AddUint64(&o.Held, 1)
+	return true
+}
+
+// send the held requests once the blackout is over, or now when the run
+// ends before
+func (g *Generator) flushOutage(wg *sync.WaitGroup, now bool) error {
+	o := g.outage
+	t := g.Cfg.Clock.Now()
+	if !now && t.Sub(g.Start) < o.After+o.For {
+		return nil
+	}
+	o.mu.Lock()
+	held := o.held
+	o.held = nil
+	o.mu.Unlock()
+	if len(held) <= 0 {
+		return nil
+	}
+	g.event("outage over: sending the %d held requests, up to %v late", len(held), t.Sub(held[0].due).Round(time.Second))
+	for _, h := range held {
+		// on top of the delay the request already had
+		delay := uint32(t.Sub(h.due) / time.Second)
+		if a := h.packet.Get(AcctDelayTime); a != nil {
+			if d, err := radius.Integer(a); err == nil {
+				delay += d
+			}
+		}
+		h.packet.Set(AcctDelayTime, radius.NewInteger(delay))
+		atomic.AddUint64(&o.Sent, 1)
+		if err := g.dispatch(wg, h.packet, h.c, h.cl, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package gen
+
+import (
+	"log"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/output"
+)
+
+// --output specs of the run, the ones of --export, --detail-file,
+// --results-db and --heatmap first
+func (cfg Config) outputSpecs() []string {
+	var specs []string
+	if len(cfg.Export) > 0 {
+		specs = append(specs, "csv:"+cfg.Export)
+	}
+	if len(cfg.DetailFile) > 0 {
+		specs = append(specs, "detail:"+cfg.DetailFile)
+	}
+	if len(cfg.ResultsDB) > 0 {
+		specs = append(specs, "sqlite:"+cfg.ResultsDB)
+	}
+	if len(cfg.Heatmap) > 0 {
+		specs = append(specs, "heatmap:"+cfg.Heatmap)
+	}
+	return append(specs, cfg.Outputs...)
+}
+
+// open the sinks of the run, closing the ones already open on an error
+func (g *Generator) openOutputs() error {
+	cfg := g.Cfg
+	run := output.Run{RunID: cfg.RunID, Start: g.Start, PPS: cfg.PPS, MaxReq: cfg.MaxReq,
+		Policy: cfg.Policy, Labels: g.labels, Redact: g.redact}
+	for _, t := range g.Pool.Targets() {
+		run.Servers = append(run.Servers, t.Addr)
+	}
+	for _, spec := range cfg.outputSpecs() {
+		w, err := output.Open(spec, run)
+		if err != nil {
+			for _, w := range g.outputs {
+				w.Close(g.Stats())
+			}
+			g.outputs = nil
+			return err
+		}
+		g.outputs = append(g.outputs, w)
+	}
+	return nil
+}
+
+// the stats to the output.StatsWriter sinks every second until
+// closeOutputs, the first error of each logged
+func (g *Generator) feedOutputs() {
+	var writers []output.StatsWriter
+	for _, w := range g.outputs {
+		if sw, ok := w.(output.StatsWriter); ok {
+			writers = append(writers, sw)
+		}
+	}
+	if len(writers) <= 0 {
+		return
+	}
+	g.outputsDone = make(chan struct{})
+	g.outputsWg.Add(1)
+	go func() {
+		defer g.outputsWg.Done()
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		failed := make([]bool, len(writers))
+		for {
+			select {
+			case <-g.outputsDone:
+				return
+			case <-tick.C:
+			}
+			s := g.Stats()
+			for i, w := range writers {
+				if err := w.Stats(s); err != nil && !failed[i] {
+					failed[i] = true
+					log.Print("output: ", err)
+				}
+			}
+		}
+	}()
+}
+
+// a request to every sink, an error stops the run
+func (g *Generator) writeOutputs(r *output.Request) {
+	for _, w := range g.outputs {
+		if err := w.Request(r); err != nil {
+			g.failSession(err, redactSessionId(g.redact, r.AcctSessionId))
+		}
+	}
+}
+
+// close the sinks with the final stats of the run
+func (g *Generator) closeOutputs(final control.Stats) {
+	if g.outputsDone != nil {
+		close(g.outputsDone)
+		g.outputsWg.Wait()
+	}
+	for _, w := range g.outputs {
+		if err := w.Close(final); err != nil {
+			g.fail(err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package gen
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/rfc2866"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// used for --custom-fields
+type CustomFields struct {
+	ID    radius.Type
+	Value string
+}
+
+type MapCustomFields map[int]CustomFields
+
+func NewMapCustomFields() MapCustomFields {
+	return make(MapCustomFields)
+}
+
+func ParseCustomFields(c string) (MapCustomFields, error) {
+	mapCustomFields := NewMapCustomFields()
+	attrs := strings.Split(c, ",")
+	for k, att := range attrs {
+		s := strings.SplitN(att, "=", 2)
+		if len(s) < 2 {
+			return nil, fmt.Errorf("%q is not ID=Value", att)
+		}
+		id, err := strconv.Atoi(s[0])
+		if err != nil {
+			return nil, err
+		}
+		mapCustomFields[k] = CustomFields{radius.Type(id), s[1]}
+	}
+	return mapCustomFields, nil
+}
+
+func AddCustomField(p *radius.Packet, mcf MapCustomFields) {
+	for _, c := range mcf {
+		p.Add(c.ID, []byte(c.Value))
+	}
+}
+
+func GetMapCustomFields(c string) (MapCustomFields, error) {
+	if len(c) > 0 {
+		mapCustomFields, err := ParseCustomFields(c)
+		if err != nil {
+			return nil, err
+		}
+		return mapCustomFields, nil
+	}
+	return nil, nil
+}
+
+// RFC 2865/2866/2869/3162 attributes sent without the dictionary helpers
+const (
+	UserName            radius.Type = 1
+	NASIPAddress        radius.Type = 4
+	NASPort             radius.Type = 5
+	ServiceType         radius.Type = 6
+	FramedProtocol      radius.Type = 7
+	FramedIPAddress     radius.Type = 8
+	CalledStationId     radius.Type = 30
+	CallingStationId    radius.Type = 31
+	NASIdentifier       radius.Type = 32
+	AcctDelayTime       radius.Type = 41
+	AcctInputOctets     radius.Type = 42
+	AcctOutputOctets    radius.Type = 43
+	AcctSessionId       radius.Type = 44
+	AcctSessionTime     radius.Type = 46
+	AcctInputPackets    radius.Type = 47
+	AcctOutputPackets   radius.Type = 48
+	AcctTerminateCause  radius.Type = 49
+	AcctInputGigawords  radius.Type = 52
+	AcctOutputGigawords radius.Type = 53
+	NASPortType         radius.Type = 61
+	NASPortId           radius.Type = 87
+	NASIPv6Address      radius.Type = 95
+)
+
+// sequence of the Proxy-State values tagged on each request (--proxy-state)
+var proxyStateSeq uint64
+
+// parse struct CdrValues to radius packet
+func ParseCdrAttributes(p *radius.Packet, c *cdr.CdrValues, cfg Config) {
+	if cfg.SessionType == DataSession {
+		dataAttributes(p, c, cfg)
+		return
+	}
+	rfc2866.SipAcctStatusType_Add(p, rfc2866.SipAcctStatusType(c.AcctStatusType))
+	rfc2866.SipServiceType_Add(p, rfc2866.SipServiceType_Value_SipSession)
+	rfc2866.SipResponseCode_AddString(p, c.ResponseCode)
+	method, ok := cdr.SipMethod(c.Method)
+	if !ok {
+		method = rfc2866.SipMethod_Value_OTHER
+	}
+	rfc2866.SipMethod_Add(p, method)
+	rfc2866.SipEventTimestamp_Add(p, c.EventTimestamp)
+	rfc2866.SipFromTag_AddString(p, c.FromTag)
+	if len(c.ToTag) > 0 {
+		rfc2866.SipToTag_AddString(p, c.ToTag)
+	}
+	if len(c.UserName) > 0 {
+		rfc2865.UserName_AddString(p, c.UserName)
+	}
+	rfc2866.SipCallerID_AddString(p, c.CallerId)
+	rfc2866.SipCalleeID_AddString(p, c.CalleeId)
+	rfc2866.SipDstNumber_AddString(p, c.DstNumber)
+	rfc2866.SipAcctSessionID_AddString(p, c.AcctSessionId)
+	if len(c.CallId) > 0 {
+		rfc2866.SipCallID_AddString(p, c.CallId)
+	}
+	rfc2866.SipCallMSDuration_Add(p, rfc2866.SipCallMSDuration(c.MsDuration))
+	rfc2866.SipCallSetuptime_Add(p, rfc2866.SipCallSetuptime(c.SetupTime))
+	if c.InputOctets > 0 || c.OutputOctets > 0 {
+		p.Add(AcctInputOctets, radius.NewInteger(uint32(c.InputOctets)))
+		p.Add(AcctOutputOctets, radius.NewInteger(uint32(c.OutputOctets)))
+	}
+	rfc2865.NASPort_Add(p, rfc2865.NASPort(cfg.NASPort))
+	rfc2865.NASIPAddress_Add(p, net.ParseIP(cfg.NASIPAddress))
+	return
+}
+
+// wire size of the packet (header + attributes)
+func PacketSize(p *radius.Packet) int {
+	size := 20
+	for _, attrs := range p.Attributes {
+		for _, a := range attrs {
+			size += 2 + len(a)
+		}
+	}
+	return size
+}
+
+// create the radius Accounting-Request package
+func NewAcctPacket(c *cdr.CdrValues, mcf MapCustomFields, cfg Config) *radius.Packet {
+	packet := radius.New(radius.CodeAccountingRequest, []byte(cfg.Key))
+	ParseCdrAttributes(packet, c, cfg)
+	if mcf != nil {
+		AddCustomField(packet, mcf)
+	}
+	if cfg.ProxyState {
+		state := make([]byte, 8)
+		binary.BigEndian.PutUint64(state, atomic.AddUint64(&proxyStateSeq, 1))
+		rfc2865.ProxyState_Add(packet, state)
+	}
+	return packet
+}
+
+// true when the response echoes the Proxy-State of the request unchanged
+func HasProxyState(request, response *radius.Packet) bool {
+	sent := rfc2865.ProxyState_Get(request)
+	states, _ := rfc2865.ProxyState_Gets(response)
+	for _, s := range states {
+		if bytes.Equal(s, sent) {
+			return true
+		}
+	}
+	return false
+}
+
+// the Acct-Unique-Session-Id FreeRADIUS derives for the packet (acct_unique
+// policy): md5 of User-Name, Acct-Session-Id, NAS-IPv6-Address or
+// NAS-IP-Address, NAS-Identifier, NAS-Port-Id and NAS-Port, comma-separated
+func AcctUniqueSessionId(p *radius.Packet) string {
+	str := func(t radius.Type) string {
+		return string(p.Get(t))
+	}
+	nasIP := ""
+	if a, ok := p.Lookup(NASIPv6Address); ok {
+		nasIP = net.IP(a).String()
+	} else if a, ok := p.Lookup(NASIPAddress); ok {
+		nasIP = net.IP(a).String()
+	}
+	nasPort := ""
+	if a, ok := p.Lookup(NASPort); ok {
+		if n, err := radius.Integer(a); err == nil {
+			nasPort = strconv.FormatUint(uint64(n), 10)
+		}
+	}
+	sum := md5.Sum([]byte(strings.Join([]string{
+		str(UserName), str(AcctSessionId), nasIP, str(NASIdentifier), str(NASPortId), nasPort,
+	}, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// add the Acct-Session-Id FreeRADIUS keys the sessions on, the
+// Sip-Acct-Session-Id of c, unless a custom field or hook set one
+func AddAcctSessionId(p *radius.Packet, c *cdr.CdrValues) {
+	if _, ok := p.Lookup(AcctSessionId); ok {
+		return
+	}
+	if a, err := radius.NewString(c.AcctSessionId); err == nil {
+		p.Add(AcctSessionId, a)
+	}
+}
+
+// copy of packet not sharing its attributes, to send it concurrently to
+// another target
+func copyPacket(packet *radius.Packet) *radius.Packet {
+	p := radius.New(packet.Code, append([]byte(nil), packet.Secret...))
+	p.Identifier = packet.Identifier
+	p.Authenticator = packet.Authenticator
+	for typ, values := range packet.Attributes {
+		for _, v := range values {
+			p.Add(typ, append(radius.Attribute(nil), v...))
+		}
+	}
+	return p
+}
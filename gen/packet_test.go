@@ -0,0 +1,35 @@
+package gen
+
+import (
+	"testing"
+
+	"layeh.com/radius"
+)
+
+func TestCopyPacketSharesNothing(t *testing.T) {
+	p := radius.New(radius.CodeAccountingRequest, []byte("secret"))
+	p.Add(AcctSessionId, radius.Attribute("a"))
+	c := copyPacket(p)
+	p.Add(AcctSessionId, radius.Attribute("b"))
+	c.Set(UserName, radius.Attribute("c"))
+	c.Attributes[AcctSessionId][0][0] = 'x'
+	c.Secret[0] = 'x'
+	if got := len(p.Attributes[AcctSessionId]); got != 2 {
+		t.Errorf("%d Acct-Session-Id of the packet, want 2", got)
+	}
+	if got := len(c.Attributes[AcctSessionId]); got != 1 {
+		t.Errorf("%d Acct-Session-Id of the copy, want 1", got)
+	}
+	if _, ok := p.Attributes[UserName]; ok {
+		t.Error("User-Name of the copy set on the packet")
+	}
+	if v := string(p.Attributes[AcctSessionId][0]); v != "a" {
+		t.Errorf("Acct-Session-Id of the packet %q, want a", v)
+	}
+	if string(p.Secret) != "secret" {
+		t.Errorf("secret of the packet %q, want secret", p.Secret)
+	}
+	if c.Identifier != p.Identifier || c.Authenticator != p.Authenticator {
+		t.Error("copy of another Identifier or Authenticator")
+	}
+}
@@ -0,0 +1,35 @@
+package gen
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLS config of the connections to the tls (RadSec) targets
+func TLSConfig(cfg Config) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: cfg.RadSecInsecure}
+	if len(cfg.RadSecCA) > 0 {
+		b, err := ioutil.ReadFile(cfg.RadSecCA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no PEM certificate", cfg.RadSecCA)
+		}
+	}
+	if (len(cfg.RadSecCert) > 0) != (len(cfg.RadSecKey) > 0) {
+		return nil, errors.New("the client certificate and its key go together")
+	}
+	if len(cfg.RadSecCert) > 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.RadSecCert, cfg.RadSecKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,104 @@
+package gen
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"layeh.com/radius"
+)
+
+// Error-Cause (RFC 5176) of the Access-Reject, CoA-NAK and Disconnect-NAK
+// answers; the Sip-From-Tag of the opensips dictionary has the same number
+// on the Accounting-Requests
+const ErrorCause radius.Type = 101
+
+// Error-Cause values
+var errorCauses = map[uint32]string{
+	201: "Residual Session Context Removed",
+	202: "Invalid EAP Packet",
+	401: "Unsupported Attribute",
+	402: "Missing Attribute",
+	403: "NAS Identification Mismatch",
+	404: "Invalid Request",
+	405: "Unsupported Service",
+	406: "Unsupported Extension",
+	407: "Invalid Attribute Value",
+	501: "Administratively Prohibited",
+	502: "Request Not Routable",
+	503: "Session Context Not Found",
+	504: "Session Context Not Removable",
+	505: "Other Proxy Processing Error",
+	506: "Resources Unavailable",
+	507: "Request Initiated",
+	508: "Multiple Session Selection Unsupported",
+}
+
+// answers of another code than the expected one, by code and Error-Cause,
+// counted apart from the transport failures: a policy reject is an answer
+// of the server, not a loss; safe for concurrent use
+type Rejects struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// kind of the response rejecting the request, empty when it is the
+// expected answer
+func rejectKind(response *radius.Packet) string {
+	switch response.Code {
+	case radius.CodeAccountingResponse:
+		return ""
+	case radius.CodeAccessReject, radius.CodeCoANAK, radius.CodeDisconnectNAK:
+	default:
+		return response.Code.String()
+	}
+	kind := response.Code.String()
+	if a, ok := response.Lookup(ErrorCause); ok {
+		if v, err := radius.Integer(a); err == nil {
+			kind += " Error-Cause " + strconv.FormatUint(uint64(v), 10)
+			if name, ok := errorCauses[v]; ok {
+				kind += " (" + name + ")"
+			}
+		}
+	}
+	return kind
+}
+
+// count the response when it rejects the request, true when it does
+func (r *Rejects) add(response *radius.Packet) bool {
+	kind := rejectKind(response)
+	if len(kind) <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[string]uint64)
+	}
+	r.counts[kind]++
+	return true
+}
+
+// counts by kind, nil when nothing was rejected
+func (r *Rejects) Counts() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.counts) <= 0 {
+		return nil
+	}
+	counts := make(map[string]uint64, len(r.counts))
+	for k, n := range r.counts {
+		counts[k] = n
+	}
+	return counts
+}
+
+// sorted kinds of counts
+func RejectKinds(counts map[string]uint64) []string {
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
@@ -0,0 +1,41 @@
+package gen
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+)
+
+// --from and --to of cfg, zero when not given
+func ReplayWindow(cfg Config) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if len(cfg.From) > 0 {
+		if from, err = cdr.ParseSIPpTime(cfg.From); err != nil {
+			return from, to, fmt.Errorf("from: %v", err)
+		}
+	}
+	if len(cfg.To) > 0 {
+		if to, err = cdr.ParseSIPpTime(cfg.To); err != nil {
+			return from, to, fmt.Errorf("to: %v", err)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
+
+// send time of the last replayed call c with --speed, its time from the
+// first one Speed times faster
+func (g *Generator) replayArrival(c *cdr.CdrValues) (time.Time, error) {
+	t := g.callTime()
+	if t.IsZero() {
+		return t, fmt.Errorf("sipp csv: call %s has no start, answer or stop time to replay it at", c.CallId)
+	}
+	if g.replayStart.IsZero() {
+		g.replayStart, g.replayFirst = g.Cfg.Clock.Now(), t
+	}
+	return g.replayStart.Add(time.Duration(float64(t.Sub(g.replayFirst)) / g.Cfg.Speed)), nil
+}
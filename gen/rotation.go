@@ -0,0 +1,68 @@
+package gen
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// shared secret rotation under load (--new-key): the requests switch from
+// the secrets of the targets to the new one after a time of the run, or a
+// share of them uses it from the start
+type Rotation struct {
+	Key []byte
+	// run time of the switchover, zero with Share
+	After time.Duration
+	// share (0-1) of the requests with the new secret
+	Share float64
+	// requests sent and answered with the old and the new secret
+	OldSent, OldAcked uint64
+	NewSent, NewAcked uint64
+}
+
+// parse the switchover of key, a duration of the run ("5m") or a share of
+// the requests ("25%")
+func ParseRotation(key, at string) (*Rotation, error) {
+	if len(key) <= 0 {
+		return nil, fmt.Errorf("new-key: empty secret")
+	}
+	r := &Rotation{Key: []byte(key)}
+	at = strings.TrimSpace(at)
+	if strings.HasSuffix(at, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(at, "%"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("key-switch: share %q must be greater 0%% and at most 100%%", at)
+		}
+		r.Share = p / 100
+		return r, nil
+	}
+	d, err := time.ParseDuration(at)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("key-switch: %q must be a duration of the run (5m) or a share of the requests (25%%)", at)
+	}
+	r.After = d
+	return r, nil
+}
+
+// true when the request sent elapsed into the run uses the new secret
+func (r *Rotation) use(elapsed time.Duration) bool {
+	if r.Share > 0 {
+		return rand.Float64() < r.Share
+	}
+	return elapsed >= r.After
+}
+
+// count a request with the new secret or the old one
+func (r *Rotation) count(rotated, answered bool) {
+	sent, acked := &r.OldSent, &r.OldAcked
+	if rotated {
+		sent, acked = &r.NewSent, &r.NewAcked
+	}
+	atomic.AddUint64(sent, 1)
+	if answered {
+		atomic.AddUint64(acked, 1)
+	}
+}
@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"layeh.com/radius"
+)
+
+// Vendor-Specific attribute type
+const VendorSpecific radius.Type = 26
+
+// run id of a run without --run-id, its start time and a random suffix
+func NewRunID() string {
+	return fmt.Sprintf("radgen-%s-%04x", time.Now().UTC().Format("20060102T150405"), rand.Intn(1<<16))
+}
+
+// attribute carrying a text on every packet, the run id (--run-id-attr)
+// or the checksum (--checksum-attr)
+type RunIDAttr struct {
+	Type radius.Type
+	// Vendor-Specific when Vendor isn't zero
+	Vendor     uint32
+	VendorType byte
+}
+
+// parse "class", an attribute number or vendor:type for a Vendor-Specific
+// one, e.g. "9:1" for a Cisco-AVPair; "none" (or empty) disables it
+func ParseRunIDAttr(s string) (*RunIDAttr, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "none":
+		return nil, nil
+	case "class":
+		return &RunIDAttr{Type: 25}, nil
+	}
+	return parseAttr("run-id-attr", s)
+}
+
+// an attribute number or vendor:type, flag names it on the errors
+func parseAttr(flag, s string) (*RunIDAttr, error) {
+	if i := strings.Index(s, ":"); i >= 0 {
+		vendor, err := strconv.ParseUint(s[:i], 10, 32)
+		if err != nil || vendor == 0 {
+			return nil, fmt.Errorf("%s: invalid vendor %q", flag, s[:i])
+		}
+		typ, err := strconv.ParseUint(s[i+1:], 10, 8)
+		if err != nil || typ == 0 {
+			return nil, fmt.Errorf("%s: invalid vendor type %q", flag, s[i+1:])
+		}
+		return &RunIDAttr{Type: VendorSpecific, Vendor: uint32(vendor), VendorType: byte(typ)}, nil
+	}
+	typ, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || typ == 0 || radius.Type(typ) == VendorSpecific {
+		return nil, fmt.Errorf("%s: invalid attribute %q", flag, s)
+	}
+	return &RunIDAttr{Type: radius.Type(typ)}, nil
+}
+
+// add the run id (or any text) to the packet
+func (a *RunIDAttr) Add(p *radius.Packet, id string) {
+	if a.Vendor == 0 {
+		p.Add(a.Type, radius.Attribute(id))
+		return
+	}
+	// vendor id, vendor type, vendor length and the value
+	b := make([]byte, 6, 6+len(id))
+	binary.BigEndian.PutUint32(b, a.Vendor)
+	b[4] = a.VendorType
+	b[5] = byte(2 + len(id))
+	p.Add(VendorSpecific, radius.Attribute(append(b, id...)))
+}
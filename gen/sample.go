@@ -0,0 +1,39 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parse the --sample-rate "1/N" or share (0-1] of the calls keeping their
+// evidence, returning N: one call of every N is sampled
+func ParseSampleRate(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) <= 0 {
+		return 1, nil
+	}
+	if i := strings.Index(s, "/"); i >= 0 {
+		num, err := strconv.ParseUint(strings.TrimSpace(s[:i]), 10, 64)
+		if err != nil || num != 1 {
+			return 0, fmt.Errorf("sample-rate %s: must be 1/N", s)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(s[i+1:]), 10, 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("sample-rate %s: N must be greater 0", s)
+		}
+		return n, nil
+	}
+	share, err := strconv.ParseFloat(s, 64)
+	if err != nil || share <= 0 || share > 1 {
+		return 0, fmt.Errorf("sample-rate %s: must be 1/N or a share between 0 and 1", s)
+	}
+	return uint64(math.Round(1 / share)), nil
+}
+
+// true when the call n (1 the first one) keeps its evidence: the first
+// call, then one of every sample
+func sampled(n, sample uint64) bool {
+	return sample <= 1 || (n-1)%sample == 0
+}
@@ -0,0 +1,186 @@
+package gen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/control"
+	yaml "gopkg.in/yaml.v2"
+	"layeh.com/radius"
+)
+
+// --scenario file, the call shape of a share of the calls; each option is
+// the acct flag of the same name, the ones not given are the run ones
+//
+//	name: short-calls
+//	ring-time: exponential:2s
+//	talk-time: exponential:10s
+//	failed-ratio: 0.1
+//	carry-attr: [State, Class]
+type ScenarioFile struct {
+	Name            string   `yaml:"name"`
+	SetupTime       string   `yaml:"setup-time"`
+	RingTime        string   `yaml:"ring-time"`
+	TalkTime        string   `yaml:"talk-time"`
+	Methods         string   `yaml:"methods"`
+	FailedRatio     *float64 `yaml:"failed-ratio"`
+	FailedCodes     string   `yaml:"failed-codes"`
+	Legs            *bool    `yaml:"legs"`
+	InterimInterval *int     `yaml:"interim-interval"`
+	ReinviteRatio   *float64 `yaml:"reinvite-ratio"`
+	CarryAttr       []string `yaml:"carry-attr"`
+}
+
+// a scenario of the run mix and the counters of its calls
+type Scenario struct {
+	Name string
+	// share of the calls relative to the other scenarios
+	Weight int
+	Calls  uint64
+	Sent   uint64
+	Acked  uint64
+	// requests without a response
+	Failed uint64
+	// sum of the response latencies in ns
+	latency int64
+	opts    cdr.Options
+	// response attributes carried by its calls, nil for the run ones
+	carry *Carry
+}
+
+// parse "path=weight" of --scenario, weight 1 when not given
+func parseScenarioSpec(s string) (string, int, error) {
+	path, weight := s, 1
+	if i := strings.LastIndex(s, "="); i >= 0 {
+		w, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		if err != nil || w <= 0 {
+			return "", 0, fmt.Errorf("scenario %s: weight must be greater 0", s)
+		}
+		path, weight = strings.TrimSpace(s[:i]), w
+	}
+	return path, weight, nil
+}
+
+// load the scenarios "path=weight" over the options of cfg, named after
+// their file when without a name
+func LoadScenarios(specs []string, cfg Config) ([]*Scenario, error) {
+	var scenarios []*Scenario
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		path, weight, err := parseScenarioSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f ScenarioFile
+		if err := yaml.UnmarshalStrict(b, &f); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(f.Name) <= 0 {
+			f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("%s: scenario %s given twice", path, f.Name)
+		}
+		names[f.Name] = true
+		opts, err := cdrOptions(f.apply(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if (f.InterimInterval != nil || f.ReinviteRatio != nil) && !cfg.Lifecycle {
+			return nil, fmt.Errorf("%s: interim-interval and reinvite-ratio need --lifecycle", path)
+		}
+		carry, err := ParseCarry(f.CarryAttr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		scenarios = append(scenarios, &Scenario{Name: f.Name, Weight: weight, opts: opts, carry: carry})
+	}
+	return scenarios, nil
+}
+
+// cfg with the options of f
+func (f *ScenarioFile) apply(cfg Config) Config {
+	for _, s := range []struct {
+		v   string
+		dst *string
+	}{
+		{f.SetupTime, &cfg.SetupTime},
+		{f.RingTime, &cfg.RingTime},
+		{f.TalkTime, &cfg.TalkTime},
+		{f.Methods, &cfg.Methods},
+		{f.FailedCodes, &cfg.FailedCodes},
+	} {
+		if len(s.v) > 0 {
+			*s.dst = s.v
+		}
+	}
+	if f.FailedRatio != nil {
+		cfg.FailedRatio = *f.FailedRatio
+	}
+	if f.Legs != nil {
+		cfg.Legs = *f.Legs
+	}
+	if f.InterimInterval != nil {
+		cfg.InterimInterval = *f.InterimInterval
+	}
+	if f.ReinviteRatio != nil {
+		cfg.ReinviteRatio = *f.ReinviteRatio
+	}
+	return cfg
+}
+
+// weighted random scenario
+func pickScenario(r *rand.Rand, scenarios []*Scenario) *Scenario {
+	total := 0
+	for _, s := range scenarios {
+		total += s.Weight
+	}
+	n := r.Intn(total)
+	for _, s := range scenarios {
+		if n -= s.Weight; n < 0 {
+			return s
+		}
+	}
+	return scenarios[len(scenarios)-1]
+}
+
+// count a request of the scenario and its answer
+func (s *Scenario) count(response *radius.Packet, latency time.Duration) {
+	atomic.AddUint64(&s.Sent, 1)
+	if response == nil {
+		atomic.AddUint64(&s.Failed, 1)
+		return
+	}
+	atomic.AddUint64(&s.Acked, 1)
+	atomic.AddInt64(&s.latency, int64(latency))
+}
+
+func (s *Scenario) AvgLatency() time.Duration {
+	acked := atomic.LoadUint64(&s.Acked)
+	if acked <= 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.latency) / int64(acked))
+}
+
+func (s *Scenario) Stats() control.ScenarioStats {
+	return control.ScenarioStats{
+		Name:         s.Name,
+		Calls:        atomic.LoadUint64(&s.Calls),
+		Sent:         atomic.LoadUint64(&s.Sent),
+		Acked:        atomic.LoadUint64(&s.Acked),
+		Failed:       atomic.LoadUint64(&s.Failed),
+		AvgLatencyMs: s.AvgLatency().Seconds() * 1000,
+	}
+}
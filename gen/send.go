@@ -0,0 +1,288 @@
+package gen
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/mux"
+	"github.com/routecall/go-radius-gen-acct/sockbuf"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// approximate memory held by each pending request besides the packet
+// itself (goroutine stack, client socket and buffers)
+const InFlightOverhead = 8 << 10
+
+// in-flight accounting-requests bytes accounting, used by --max-memory,
+// and requests (a goroutine each) capped by --max-in-flight
+type InFlight struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	max      uint64
+	bytes    uint64
+	maxReqs  int
+	requests int
+	// requests which waited for room, and since when the one waiting does
+	// on clock
+	waits   uint64
+	waiting time.Time
+	clock   clock.Clock
+}
+
+// max is the limit in bytes and maxReqs in requests, zero means no limit;
+// clk nil for the real clock
+func NewInFlight(max uint64, maxReqs int, clk clock.Clock) *InFlight {
+	f := &InFlight{max: max, maxReqs: maxReqs, clock: clock.Or(clk)}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *InFlight) fits(n uint64) bool {
+	if f.maxReqs > 0 && f.requests >= f.maxReqs {
+		return false
+	}
+	// a single request bigger than the limit still goes when nothing else is pending
+	return f.max == 0 || f.bytes == 0 || f.bytes+n <= f.max
+}
+
+// reserve n bytes and a request, blocking while a limit is exceeded; true
+// when it had to wait
+func (f *InFlight) Acquire(n uint64) bool {
+	f.mu.Lock()
+	waited := !f.fits(n)
+	if waited {
+		f.waits++
+		f.waiting = f.clock.Now()
+	}
+	for !f.fits(n) {
+		f.cond.Wait()
+	}
+	f.waiting = time.Time{}
+	f.bytes += n
+	f.requests++
+	f.mu.Unlock()
+	return waited
+}
+
+// reserve n bytes and a request without blocking, false when a limit is
+// exceeded
+func (f *InFlight) TryAcquire(n uint64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.fits(n) {
+		return false
+	}
+	f.bytes += n
+	f.requests++
+	return true
+}
+
+// give back n bytes and the request reserved by Acquire or TryAcquire
+func (f *InFlight) Release(n uint64) {
+	f.mu.Lock()
+	f.bytes -= n
+	f.requests--
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+func (f *InFlight) Bytes() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bytes
+}
+
+// requests in flight, the ones which waited for room so far and the
+// queue depth: the requests due at rate pps since the generator waits
+// for room, zero when it doesn't
+func (f *InFlight) Requests(rate float64) (int, uint64, uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var depth uint64
+	if !f.waiting.IsZero() {
+		depth = 1 + uint64(clock.Since(f.clock, f.waiting).Seconds()*rate)
+	}
+	return f.requests, f.waits, depth
+}
+
+// exchange the packet with a single target, returning the response; nas
+// is the simulated NAS sending it, nil without a fleet, and sockets the
+// shared ones, nil to dial a socket for the request
+func Exchange(packet *radius.Packet, t *target.Target, nas *NAS, sockets *Sockets, cfg Config) (*radius.Packet, error) {
+	response, _, err := exchange(packet, t, nas, sockets, nil, cfg)
+	return response, err
+}
+
+// Exchange, with where its time went; secret replaces the ones of the
+// target and the NAS, nil for them
+func exchange(packet *radius.Packet, t *target.Target, nas *NAS, sockets *Sockets, secret []byte, cfg Config) (response *radius.Packet, timing Timing, err error) {
+	clk := clock.Or(cfg.Clock)
+	entered := clk.Now()
+	client := radius.Client{
+		Retry:           time.Second * time.Duration(cfg.Retry),
+		MaxPacketErrors: cfg.MaxRetry,
+	}
+	client.Dialer.Control = sockbuf.Control(cfg.RcvBuf, cfg.SndBuf)
+	packet.Secret = t.Key([]byte(cfg.Key))
+	if nas != nil {
+		if nas.Secret != nil {
+			packet.Secret = nas.Secret
+		}
+		if nas.SourcePort > 0 {
+			// a single socket can be bound to the port
+			nas.mu.Lock()
+			defer nas.mu.Unlock()
+			client.Dialer.LocalAddr = &net.UDPAddr{Port: nas.SourcePort}
+		}
+	}
+	if secret != nil {
+		packet.Secret = secret
+	}
+	if cfg.simulation != nil {
+		response, err = cfg.simulation.exchange(packet, t, cfg)
+		return response, timing, err
+	}
+
+	clk.Sleep(sendJitter(cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the timeout, gone with the exchange so --max-in-flight bounds the
+	// goroutines too
+	go func() {
+		select {
+		case <-clk.After(time.Second * time.Duration(cfg.Retry*cfg.MaxRetry)):
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	atomic.AddUint64(&t.Sent, 1)
+	start := clk.Now()
+	// the least policies pick on the outstanding requests and the recent
+	// round-trip times, a failure costing the whole timeout
+	t.Begin()
+	defer func() {
+		rtt := clock.Since(clk, start)
+		if err != nil {
+			rtt = time.Second * time.Duration(cfg.Retry*cfg.MaxRetry)
+		}
+		t.Done(rtt)
+	}()
+	// the jitter and the NAS source port are queueing, and so is the wait
+	// for an Identifier of the shared sockets
+	timing.Queue = start.Sub(entered)
+	var idWait time.Duration
+	stream := t.Stream()
+	defer func() {
+		onWire := clock.Since(clk, start) - idWait
+		var n uint64
+		if !stream {
+			n = retransmissions(onWire, cfg)
+		}
+		atomic.AddUint64(&t.Retransmits, n)
+		timing.Queue += idWait
+		timing.Retry = time.Duration(n) * time.Second * time.Duration(cfg.Retry)
+		timing.Wait = onWire - timing.Retry
+	}()
+	// the connections of the streams deliver every transmission
+	if lost := lostTransmissions(cfg); lost > 0 && !stream {
+		atomic.AddUint64(&t.Lost, uint64(lost))
+		if lost >= transmissions(cfg) {
+			// nothing reaches the server, wait the timeout
+			<-ctx.Done()
+			return nil, timing, ctx.Err()
+		}
+		// the retransmission is the first on the wire
+		select {
+		case <-clk.After(time.Second * time.Duration(cfg.Retry*lost)):
+		case <-ctx.Done():
+			return nil, timing, ctx.Err()
+		}
+	}
+	if sockets.shared(t) {
+		var mc *mux.Client
+		if mc, err = sockets.client(t); err == nil {
+			called := clk.Now()
+			var written time.Time
+			if response, written, err = mc.Exchange(ctx, packet); !written.IsZero() {
+				idWait = written.Sub(called)
+			}
+		}
+	} else {
+		response, err = client.Exchange(ctx, packet, t.Addr)
+	}
+	if err != nil {
+		t.ICMP.Add(err)
+		return nil, timing, err
+	}
+	atomic.AddUint64(&t.Latency, uint64(clock.Since(clk, start)))
+	atomic.AddUint64(&t.Acked, 1)
+	if cfg.ProxyState && !HasProxyState(packet, response) {
+		atomic.AddUint64(&t.ProxyStateMismatch, 1)
+	}
+	return response, timing, nil
+}
+
+// UDP sockets open at once when every request waits its whole timeout,
+// each Exchange dials its own socket
+func MaxSockets(cfg Config) uint64 {
+	timeout := cfg.Retry * cfg.MaxRetry
+	if timeout < 1 {
+		timeout = 1
+	}
+	n := uint64(math.Ceil(peakPPS(cfg) * float64(timeout)))
+	if cfg.MaxReq < MaxInt && uint64(cfg.MaxReq) < n {
+		n = uint64(cfg.MaxReq)
+	}
+	if cfg.SharedSockets > 0 {
+		// an Identifier space each
+		if shared := n/256 + uint64(cfg.SharedSockets); shared < n {
+			n = shared
+		}
+	}
+	return n
+}
+
+// true when the server didn't answer in time (retries exhausted)
+func IsTimeout(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return true
+	}
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
+
+// send the radius Accounting-Request package to server, on failover
+// policy a timeout moves the packet to the next server; returns the
+// response and the target which answered it
+func SendAcct(packet *radius.Packet, t *target.Target, nas *NAS, sockets *Sockets, pool *target.Pool, cfg Config) (*radius.Packet, *target.Target, error) {
+	response, t, _, err := sendAcct(packet, t, nas, sockets, pool, nil, cfg)
+	return response, t, err
+}
+
+// SendAcct, with where its time went and secret replacing the ones of the
+// targets (see exchange); the servers timing out before the one answering
+// are time lost to retransmissions
+func sendAcct(packet *radius.Packet, t *target.Target, nas *NAS, sockets *Sockets, pool *target.Pool, secret []byte, cfg Config) (*radius.Packet, *target.Target, Timing, error) {
+	var err error
+	var response *radius.Packet
+	var timing, lost Timing
+	for _, tg := range pool.Tries(t) {
+		t = tg
+		response, timing, err = exchange(packet, tg, nas, sockets, secret, cfg)
+		timing.Queue += lost.Queue
+		timing.Retry += lost.Retry
+		if err == nil || !IsTimeout(err) {
+			break
+		}
+		lost = Timing{Queue: timing.Queue, Retry: timing.Retry + timing.Wait}
+	}
+	return response, t, timing, err
+}
@@ -0,0 +1,53 @@
+package gen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+)
+
+func TestInFlightQueueDepth(t *testing.T) {
+	sim := clock.NewSim(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	f := NewInFlight(0, 1, sim)
+	if f.Acquire(100) {
+		t.Fatal("first request waited")
+	}
+	waited := make(chan bool)
+	go func() { waited <- f.Acquire(100) }()
+	for _, waits, _ := f.Requests(0); waits == 0; _, waits, _ = f.Requests(0) {
+		time.Sleep(time.Millisecond)
+	}
+	// 2s waiting at 10 pps, the one waiting and 20 due since
+	sim.Advance(2 * time.Second)
+	if n, waits, depth := f.Requests(10); n != 1 || waits != 1 || depth != 21 {
+		t.Errorf("requests %d, waits %d, depth %d, want 1, 1 and 21", n, waits, depth)
+	}
+	f.Release(100)
+	if !<-waited {
+		t.Error("second request didn't wait")
+	}
+	if _, _, depth := f.Requests(10); depth != 0 {
+		t.Errorf("depth %d once room was made, want 0", depth)
+	}
+}
+
+func TestSaturatedWarnsOncePerMinute(t *testing.T) {
+	sim := clock.NewSim(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := &Generator{Cfg: Config{Clock: sim, MaxInFlight: 1, Quiet: true}, InFlight: NewInFlight(0, 1, sim)}
+	g.saturated()
+	first := g.saturatedAt
+	if !first.Equal(sim.Now()) {
+		t.Fatalf("warned at %v, want the simulated %v", first, sim.Now())
+	}
+	sim.Advance(30 * time.Second)
+	g.saturated()
+	if !g.saturatedAt.Equal(first) {
+		t.Error("warned again within the minute")
+	}
+	sim.Advance(30 * time.Second)
+	g.saturated()
+	if !g.saturatedAt.Equal(sim.Now()) {
+		t.Error("no warning once the minute elapsed")
+	}
+}
@@ -0,0 +1,37 @@
+package gen
+
+import "github.com/routecall/go-radius-gen-acct/cdr"
+
+// note the sessions c opens or closes
+func (g *Generator) track(c *cdr.CdrValues) {
+	switch c.AcctStatusType {
+	case cdr.StatusStart, cdr.StatusInterim:
+		g.open[c.AcctSessionId] = true
+	case cdr.StatusStop:
+		delete(g.open, c.AcctSessionId)
+	}
+}
+
+// the Stops not sent yet of the sessions open on the servers, their
+// timestamps not past now (--close-sessions)
+func (g *Generator) openStops() []scheduled {
+	records := make([]scheduled, 0, len(g.pending)+len(g.due))
+	for _, c := range g.pending {
+		records = append(records, scheduled{c: c, cl: g.call})
+	}
+	records = append(records, g.due...)
+	g.pending, g.due = nil, nil
+	now := g.Cfg.Clock.Now()
+	var stops []scheduled
+	for _, s := range records {
+		if s.c.AcctStatusType != cdr.StatusStop || !g.open[s.c.AcctSessionId] {
+			continue
+		}
+		if offset := nasOffset(s.cl); s.c.EventTimestamp.After(now.Add(offset)) {
+			s.c.EventTimestamp = now.Add(offset)
+		}
+		delete(g.open, s.c.AcctSessionId)
+		stops = append(stops, s)
+	}
+	return stops
+}
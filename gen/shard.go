@@ -0,0 +1,109 @@
+package gen
+
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+)
+
+// the identifiers of the worker of a distributed run (see cdr.Shard, ""
+// for all of them) and the seed of the random draws, zero for a random
+// one; the seed is mixed with the worker so the workers of a run draw
+// their own values. Set before the first draw (control API /plan)
+func (g *Generator) SetShard(shard string, seed int64) error {
+	s, err := cdr.ParseShard(shard)
+	if err != nil {
+		return err
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.drawing {
+		return errors.New("shard and seed must be set before the run draws its calls")
+	}
+	g.shard, g.seed = s, seed
+	if s != nil {
+		seed += int64(s.Index)
+	}
+	g.src = &drawSource{src: rand.NewSource(seed).(rand.Source64)}
+	if g.seed == g.Cfg.Seed {
+		// the draws the run resumed made already
+		g.src.skip(g.Cfg.SeedSkip)
+	}
+	g.rnd = rand.New(g.src)
+	g.cdrOpts.Shard = s
+	g.cdrOpts.Rand = g.rnd
+	g.cdrOpts.Cardinality.Shard(s)
+	g.Sockets.setShard(s)
+	for _, sc := range g.Scenarios {
+		sc.opts.Shard = s
+		sc.opts.Rand = g.rnd
+	}
+	if s != nil {
+		g.event("shard %s of the identifiers, seed %d", s, g.seed)
+	}
+	return nil
+}
+
+// the RADIUS Identifiers of the shard, first+k*stride: i modulo n for
+// the worker i of n, all of them without a shard; beyond 256 workers the
+// ones of the same i modulo 256 share theirs
+func identifiers(s *cdr.Shard) (int, int) {
+	if s == nil {
+		return 0, 1
+	}
+	n := s.Count
+	if n > 256 {
+		n = 256
+	}
+	return s.Index % n, n
+}
+
+// a random Identifier of the share of s, of the global source so the
+// seeded draws of the calls stay the same
+func shardIdentifier(s *cdr.Shard) byte {
+	first, stride := identifiers(s)
+	return byte(first + stride*rand.Intn((256-first+stride-1)/stride))
+}
+
+// seed of the draws of the generated calls and the number made, saved by
+// --checkpoint so a resumed run goes on with the draws that follow
+func (g *Generator) SeedState() (int64, uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.seed, atomic.LoadUint64(&g.src.draws)
+}
+
+// source of the generated calls counting its draws
+type drawSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func (s *drawSource) Int63() int64 {
+	atomic.AddUint64(&s.draws, 1)
+	return s.src.Int63()
+}
+
+func (s *drawSource) Uint64() uint64 {
+	atomic.AddUint64(&s.draws, 1)
+	return s.src.Uint64()
+}
+
+func (s *drawSource) Seed(seed int64) {
+	atomic.StoreUint64(&s.draws, 0)
+	s.src.Seed(seed)
+}
+
+// advance the source n draws
+func (s *drawSource) skip(n uint64) {
+	for i := uint64(0); i < n; i++ {
+		s.src.Uint64()
+	}
+	atomic.AddUint64(&s.draws, n)
+}
@@ -0,0 +1,168 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/mockserver"
+	"github.com/routecall/go-radius-gen-acct/rfc2866"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// --simulate run: the requests are answered by the built-in mock server
+// without the network, on a clock.Sim jumping over the waits of the
+// pacer and the schedulers, and counted by virtual second and status type
+type Simulation struct {
+	server *mockserver.Server
+	clock  clock.Clock
+	start  time.Time
+	mu     sync.Mutex
+	// requests by second of the run and status type
+	seconds map[int64]map[string]uint64
+	// sum of the virtual round trip time of the answered requests
+	latency int64
+}
+
+func newSimulation(cfg Config) *Simulation {
+	return &Simulation{
+		server:  mockserver.New(mockserver.Config{Secret: []byte(cfg.Key), Loss: cfg.SimulateLoss}),
+		clock:   cfg.Clock,
+		start:   cfg.Clock.Now(),
+		seconds: make(map[int64]map[string]uint64),
+	}
+}
+
+// status type of a request: the Acct-Status-Type of the On/Off and data
+// session ones, else the Sip-Acct-Status-Type
+func statusName(p *radius.Packet) string {
+	if a, ok := p.Lookup(AcctStatusType); ok {
+		v, _ := radius.Integer(a)
+		switch v {
+		case 1:
+			return "Start"
+		case 2:
+			return "Stop"
+		case 3:
+			return "Interim-Update"
+		case AccountingOn, AccountingOff:
+			return onOffName(v)
+		}
+	}
+	return rfc2866.SipAcctStatusType_Get(p).String()
+}
+
+// exchange of the packet with the mock server standing for t: each
+// transmission the client would make is answered or dropped, a request
+// without an authentic response times out
+func (s *Simulation) exchange(packet *radius.Packet, t *target.Target, cfg Config) (*radius.Packet, error) {
+	second := int64(s.clock.Now().Sub(s.start) / time.Second)
+	s.mu.Lock()
+	counts := s.seconds[second]
+	if counts == nil {
+		counts = make(map[string]uint64)
+		s.seconds[second] = counts
+	}
+	counts[statusName(packet)]++
+	s.mu.Unlock()
+
+	atomic.AddUint64(&t.Sent, 1)
+	wire, err := packet.Encode()
+	if err != nil {
+		return nil, err
+	}
+	retry := time.Second * time.Duration(cfg.Retry)
+	t.Begin()
+	for i := 0; i < transmissions(cfg); i++ {
+		if i > 0 {
+			atomic.AddUint64(&t.Retransmits, 1)
+		}
+		wires, delay := s.server.Respond(wire)
+		if len(wires) <= 0 || !radius.IsAuthenticResponse(wires[0], wire, packet.Secret) {
+			continue
+		}
+		response, err := radius.Parse(wires[0], packet.Secret)
+		if err != nil {
+			continue
+		}
+		latency := time.Duration(i)*retry + delay
+		atomic.AddInt64(&s.latency, int64(latency))
+		atomic.AddUint64(&t.Latency, uint64(latency))
+		atomic.AddUint64(&t.Acked, 1)
+		t.Done(latency)
+		return response, nil
+	}
+	t.Done(time.Duration(transmissions(cfg)) * retry)
+	return nil, context.DeadlineExceeded
+}
+
+// write the plan the run went through: its virtual length, the requests
+// by status type and their schedule, by second for the runs up to two
+// minutes and by minute for the longer ones
+func (s *Simulation) Fprint(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seconds []int64
+	totals := make(map[string]uint64)
+	var sent uint64
+	for sec, counts := range s.seconds {
+		seconds = append(seconds, sec)
+		for k, n := range counts {
+			totals[k] += n
+			sent += n
+		}
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+	var kinds []string
+	for k := range totals {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	elapsed := s.clock.Now().Sub(s.start)
+	fmt.Fprintln(w, "# simulation")
+	fmt.Fprintf(w, "virtual run time:     %s\n", elapsed.Truncate(time.Millisecond))
+	fmt.Fprintf(w, "requests:             %d\n", sent)
+	for _, k := range kinds {
+		fmt.Fprintf(w, "  %-19s %d\n", k+":", totals[k])
+	}
+	answered := atomic.LoadUint64(&s.server.Answered)
+	fmt.Fprintf(w, "transmissions:        %d (%d answered, %d dropped)\n", atomic.LoadUint64(&s.server.Received), answered, atomic.LoadUint64(&s.server.Dropped))
+	if answered > 0 {
+		fmt.Fprintf(w, "avg latency:          %s\n", time.Duration(atomic.LoadInt64(&s.latency)/int64(answered)))
+	}
+
+	step := int64(1)
+	unit := "second"
+	if elapsed > 2*time.Minute {
+		step, unit = 60, "minute"
+	}
+	fmt.Fprintf(w, "# schedule by %s\n", unit)
+	rows := make(map[int64]map[string]uint64)
+	var order []int64
+	for _, sec := range seconds {
+		row := sec / step
+		if rows[row] == nil {
+			rows[row] = make(map[string]uint64)
+			order = append(order, row)
+		}
+		for k, n := range s.seconds[sec] {
+			rows[row][k] += n
+		}
+	}
+	for _, row := range order {
+		fmt.Fprintf(w, "%s %d:", unit, row)
+		for _, k := range kinds {
+			if n := rows[row][k]; n > 0 {
+				fmt.Fprintf(w, " %s %d", k, n)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,147 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// response time objective of the run (--slo), e.g. 99% of the requests
+// answered under 20ms; a request not answered misses it too. Its error
+// budget is the 1-Target share of the requests allowed to miss it, the
+// burn rate how fast it is spent: 1 spends it exactly over the run, 10
+// ten times faster
+type SLO struct {
+	Target  float64
+	Latency time.Duration
+	good    uint64
+	bad     uint64
+	// burn rate warnings of the run
+	alerts uint64
+}
+
+// parse the "99%<20ms" objectives, repeated or comma-separated
+func ParseSLOs(specs []string) ([]*SLO, error) {
+	var slos []*SLO
+	for _, s := range specs {
+		for _, spec := range strings.Split(s, ",") {
+			spec = strings.TrimSpace(spec)
+			if len(spec) <= 0 {
+				continue
+			}
+			kv := strings.SplitN(spec, "<", 2)
+			if len(kv) < 2 || !strings.HasSuffix(kv[0], "%") {
+				return nil, fmt.Errorf("slo: %q is not percent%%<latency, e.g. 99%%<20ms", spec)
+			}
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(kv[0], "%"), 64)
+			if err != nil || pct <= 0 || pct >= 100 {
+				return nil, fmt.Errorf("slo: %q: the percent must be between 0 and 100", spec)
+			}
+			latency, err := time.ParseDuration(kv[1])
+			if err != nil || latency <= 0 {
+				return nil, fmt.Errorf("slo: %q: %q is not a latency", spec, kv[1])
+			}
+			slos = append(slos, &SLO{Target: pct / 100, Latency: latency})
+		}
+	}
+	return slos, nil
+}
+
+func (s *SLO) String() string {
+	return strconv.FormatFloat(s.Target*100, 'f', -1, 64) + "%<" + s.Latency.String()
+}
+
+// count a request answered in latency, err is not nil when it wasn't
+func (s *SLO) count(latency time.Duration, err error) {
+	if err != nil || latency > s.Latency {
+		atomic.AddUint64(&s.bad, 1)
+	} else {
+		atomic.AddUint64(&s.good, 1)
+	}
+}
+
+// burn rate of bad requests out of bad+good
+func (s *SLO) burn(good, bad uint64) float64 {
+	if good+bad <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(good+bad) / (1 - s.Target)
+}
+
+// requests meeting and missing s so far
+type sloSample struct {
+	good uint64
+	bad  uint64
+}
+
+func (s *SLO) sample() sloSample {
+	return sloSample{good: atomic.LoadUint64(&s.good), bad: atomic.LoadUint64(&s.bad)}
+}
+
+// warn when the burn rate of an objective over the last SLOWindow
+// seconds reaches SLOBurn, and again when it's back under: a long run
+// degrading shows before its final report
+func (g *Generator) watchSLOs(ctx context.Context) {
+	cfg := g.Cfg
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	samples := make([][]sloSample, len(g.slos))
+	burning := make([]bool, len(g.slos))
+	for i, s := range g.slos {
+		samples[i] = []sloSample{s.sample()}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		for i, s := range g.slos {
+			now := s.sample()
+			samples[i] = append(samples[i], now)
+			if len(samples[i]) > cfg.SLOWindow+1 {
+				samples[i] = samples[i][1:]
+			}
+			good := now.good - samples[i][0].good
+			bad := now.bad - samples[i][0].bad
+			if good+bad <= 0 {
+				continue
+			}
+			rate := s.burn(good, bad)
+			spent := s.burn(now.good, now.bad) * 100
+			switch {
+			case rate >= cfg.SLOBurn && !burning[i]:
+				burning[i] = true
+				atomic.AddUint64(&s.alerts, 1)
+				g.event("WARNING SLO %s: burn rate %.1f over the last %ds, %d of %d requests slower or not answered, %.0f%% of the error budget spent",
+					s, rate, len(samples[i])-1, bad, good+bad, spent)
+			case rate < cfg.SLOBurn && burning[i]:
+				burning[i] = false
+				g.event("SLO %s burn rate back to %.1f, %.0f%% of the error budget spent", s, rate, spent)
+			}
+		}
+	}
+}
+
+// the --slo objectives over the run, nil without them
+func (g *Generator) SLOs() []control.SLOStats {
+	var stats []control.SLOStats
+	for _, s := range g.slos {
+		now := s.sample()
+		stats = append(stats, control.SLOStats{
+			SLO:       s.String(),
+			Target:    s.Target,
+			LatencyMs: s.Latency.Seconds() * 1000,
+			Good:      now.good,
+			Bad:       now.bad,
+			BurnRate:  s.burn(now.good, now.bad),
+			Alerts:    atomic.LoadUint64(&s.alerts),
+		})
+	}
+	return stats
+}
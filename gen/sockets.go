@@ -0,0 +1,146 @@
+package gen
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/cpupin"
+	"github.com/routecall/go-radius-gen-acct/mux"
+	"github.com/routecall/go-radius-gen-acct/sockbuf"
+	"github.com/routecall/go-radius-gen-acct/target"
+)
+
+// --shared-sockets clients of the targets, and the connections of the tcp
+// and tls ones, created on their first request
+type Sockets struct {
+	cfg     Config
+	mu      sync.Mutex
+	clients map[*target.Target]*mux.Client
+	tls     *tls.Config
+	// --shard of the worker, its share of the Identifiers
+	shard *cdr.Shard
+}
+
+// the UDP targets dial a socket for each request without SharedSockets
+func NewSockets(cfg Config) *Sockets {
+	return &Sockets{cfg: cfg, clients: make(map[*target.Target]*mux.Client)}
+}
+
+// true when the requests to t go through the sockets
+func (s *Sockets) shared(t *target.Target) bool {
+	return s != nil && (s.cfg.SharedSockets > 0 || t.Stream())
+}
+
+// --cpus of the readers of the next client, starting after the ones of
+// the clients already open so the targets spread over them; with s.mu held
+func (s *Sockets) cpus() []int {
+	if len(s.cfg.CPUs) <= 0 {
+		return nil
+	}
+	cpus, err := cpupin.Parse(s.cfg.CPUs)
+	if err != nil {
+		return nil
+	}
+	first := len(s.clients) * s.cfg.SharedSockets % len(cpus)
+	return append(cpus[first:], cpus[:first]...)
+}
+
+func (s *Sockets) client(t *target.Target) (*mux.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[t]; ok {
+		return c, nil
+	}
+	dialer := net.Dialer{Control: sockbuf.Control(s.cfg.RcvBuf, s.cfg.SndBuf)}
+	if t.Stream() {
+		return s.stream(t, dialer)
+	}
+	c, err := mux.New(t.Addr, s.cfg.SharedSockets, time.Second*time.Duration(s.cfg.Retry), s.cfg.IDExhausted, dialer, s.cpus(), &t.ICMP, s.cfg.Clock)
+	if err != nil {
+		return nil, err
+	}
+	c.LateWindow = time.Second * time.Duration(s.cfg.LateWindow)
+	c.ShareIdentifiers(identifiers(s.shard))
+	s.clients[t] = c
+	return c, nil
+}
+
+// connections of a tcp or tls target, with s.mu held
+func (s *Sockets) stream(t *target.Target, dialer net.Dialer) (*mux.Client, error) {
+	var config *tls.Config
+	if t.Transport == target.TLS {
+		if s.tls == nil {
+			var err error
+			if s.tls, err = TLSConfig(s.cfg); err != nil {
+				return nil, err
+			}
+		}
+		config = s.tls
+	}
+	conns := s.cfg.SharedSockets
+	if conns <= 0 {
+		conns = 1
+	}
+	dialer.Timeout = time.Second * time.Duration(s.cfg.Retry*s.cfg.MaxRetry)
+	c, err := mux.NewStream(t.Addr, conns, s.cfg.IDExhausted, dialer, config, s.cfg.Clock)
+	if err != nil {
+		return nil, err
+	}
+	c.LateWindow = time.Second * time.Duration(s.cfg.LateWindow)
+	c.ShareIdentifiers(identifiers(s.shard))
+	s.clients[t] = c
+	return c, nil
+}
+
+// share of the Identifiers of the clients open and the next ones
+func (s *Sockets) setShard(shard *cdr.Shard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shard = shard
+	for _, c := range s.clients {
+		c.ShareIdentifiers(identifiers(shard))
+	}
+}
+
+// times a request found every Identifier of the sockets of its target
+// outstanding, and the sockets open
+func (s *Sockets) Exhausted() (uint64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n uint64
+	open := 0
+	for _, c := range s.clients {
+		n += atomic.LoadUint64(&c.Exhausted)
+		_, sockets := c.Outstanding()
+		open += sockets
+	}
+	return n, open
+}
+
+// responses to t after their request timed out, and the ones of no
+// request (see mux.Client); zero for the requests on a socket of their
+// own, closed at the timeout
+func (s *Sockets) Replies(t *target.Target) (uint64, uint64) {
+	if s == nil {
+		return 0, 0
+	}
+	s.mu.Lock()
+	c, ok := s.clients[t]
+	s.mu.Unlock()
+	if !ok {
+		return 0, 0
+	}
+	return atomic.LoadUint64(&c.Late), atomic.LoadUint64(&c.Stray)
+}
+
+func (s *Sockets) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.clients {
+		c.Close()
+	}
+}
@@ -0,0 +1,53 @@
+package gen
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+)
+
+// the calls source of cfg, --sipp-csv, --source or the random one, with
+// the replay window set and the first SIPpSkip calls skipped
+func NewSource(cfg Config, o *cdr.Options) (cdr.CdrSource, error) {
+	name, arg := cdr.ParseSource(cfg.Source)
+	if len(cfg.SIPpCSV) > 0 {
+		name, arg = cdr.SIPpSource, cfg.SIPpCSV
+	}
+	if len(name) <= 0 {
+		name = cdr.RandomSource
+	}
+	src, err := cdr.NewSource(name, arg, o)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := ReplayWindow(cfg)
+	if err == nil && (!from.IsZero() || !to.IsZero()) {
+		if ts, ok := src.(cdr.TimedSource); ok {
+			ts.SetWindow(from, to)
+		} else {
+			err = fmt.Errorf("source %s has no call times to select from and to", name)
+		}
+	}
+	if ss, ok := src.(cdr.SkipSource); ok && err == nil && cfg.SIPpSkip > 0 {
+		if err = ss.Skip(cfg.SIPpSkip); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		if c, ok := src.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+	return src, nil
+}
+
+// original time of the last call of the source, zero when it has none
+func (g *Generator) callTime() time.Time {
+	if ts, ok := g.source.(cdr.TimedSource); ok {
+		return ts.CallTime()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,86 @@
+package gen
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/pacer"
+)
+
+// retransmissions of a request answered or timed out elapsed after its
+// first transmission, one every Retry seconds
+func retransmissions(elapsed time.Duration, cfg Config) uint64 {
+	if cfg.Retry <= 0 {
+		return 0
+	}
+	n := int(elapsed / (time.Second * time.Duration(cfg.Retry)))
+	if max := transmissions(cfg) - 1; n > max {
+		n = max
+	}
+	return uint64(n)
+}
+
+// requests and retransmissions to the targets so far
+type stormSample struct {
+	sent        uint64
+	retransmits uint64
+}
+
+func (g *Generator) stormSample() stormSample {
+	var s stormSample
+	for _, t := range g.Pool.Targets() {
+		s.sent += atomic.LoadUint64(&t.Sent)
+		s.retransmits += atomic.LoadUint64(&t.Retransmits)
+	}
+	return s
+}
+
+// warn when the retransmissions reach StormRatio of the requests over the
+// last StormWindow seconds: the servers don't keep up and the requests
+// sent are no longer the load they get; with StormBackoff the rate is
+// halved every StormWindow seconds the storm lasts
+func (g *Generator) watchStorm(ctx context.Context) {
+	cfg := g.Cfg
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	samples := []stormSample{g.stormSample()}
+	storming := false
+	var backedOff time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		s := g.stormSample()
+		samples = append(samples, s)
+		if len(samples) > cfg.StormWindow+1 {
+			samples = samples[1:]
+		}
+		sent := s.sent - samples[0].sent
+		retransmits := s.retransmits - samples[0].retransmits
+		if sent <= 0 {
+			continue
+		}
+		ratio := float64(retransmits) / float64(sent)
+		switch {
+		case ratio >= cfg.StormRatio && !storming:
+			storming = true
+			atomic.AddUint64(&g.Counters.Storms, 1)
+			g.event("WARNING retry storm: %d retransmissions for %d accounting-request (%.0f%%) in the last %ds, the measured rate is not the load the servers handle",
+				retransmits, sent, ratio*100, len(samples)-1)
+		case ratio < cfg.StormRatio && storming:
+			storming = false
+			g.event("retry storm over: %d retransmissions for %d accounting-request (%.0f%%)", retransmits, sent, ratio*100)
+		}
+		if storming && cfg.StormBackoff && time.Since(backedOff) >= time.Duration(cfg.StormWindow)*time.Second {
+			backedOff = time.Now()
+			if rate := g.Pacer.Rate(); rate/2 >= pacer.MinRate {
+				if err := g.Pacer.SetRate(rate / 2); err == nil {
+					g.event("retry storm: rate lowered to %g pps", rate/2)
+				}
+			}
+		}
+	}
+}
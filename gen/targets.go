@@ -0,0 +1,105 @@
+package gen
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/target"
+)
+
+// targets from Servers plus the ones discovered by SRV and listed on
+// TargetsFile
+func GetTargets(cfg Config) ([]*target.Target, error) {
+	targets, err := target.ParseList(cfg.Servers, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.TargetsFile) > 0 {
+		file, err := target.LoadFile(cfg.TargetsFile, cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, file...)
+	}
+	if len(cfg.SRV) > 0 {
+		srv, err := target.LookupSRV(cfg.SRV)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, srv...)
+	}
+	return targets, nil
+}
+
+func NewTargetPool(cfg Config) (*target.Pool, error) {
+	targets, err := GetTargets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return target.NewPool(targets, cfg.Policy)
+}
+
+// modification time of the TargetsFile, zero when not set or unreadable
+func targetsFileMtime(cfg Config) time.Time {
+	if len(cfg.TargetsFile) <= 0 {
+		return time.Time{}
+	}
+	fi, err := os.Stat(cfg.TargetsFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// value hashed by the sticky policy
+func StickyKey(c *cdr.CdrValues, cfg Config) string {
+	if cfg.StickyKey == "caller" {
+		return c.CallerId
+	}
+	return c.AcctSessionId
+}
+
+// keep the targets refreshed until ctx is done: SRV records every
+// SRVRefresh seconds, TargetsFile on change or SIGHUP
+func WatchTargets(ctx context.Context, pool *target.Pool, cfg Config) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	mtime := targetsFileMtime(cfg)
+	srvNext := time.Now().Add(time.Second * time.Duration(cfg.SRVRefresh))
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		reload := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload = true
+		case now := <-tick.C:
+			if m := targetsFileMtime(cfg); !m.Equal(mtime) {
+				mtime = m
+				reload = true
+			}
+			if len(cfg.SRV) > 0 && now.After(srvNext) {
+				srvNext = now.Add(time.Second * time.Duration(cfg.SRVRefresh))
+				reload = true
+			}
+		}
+		if !reload {
+			continue
+		}
+		targets, err := GetTargets(cfg)
+		if err == nil {
+			err = pool.Update(targets)
+		}
+		if err != nil {
+			log.Print("targets reload: ", err)
+		}
+	}
+}
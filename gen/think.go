@@ -0,0 +1,43 @@
+package gen
+
+import (
+	"container/heap"
+	"io"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+)
+
+// with --think-time the next record at PPS: a record of a call due by now,
+// else the first one of a new call with its next ones due a think time
+// apart each, so the sessions stay open on the servers meanwhile; once the
+// call source ended, the calls still open at their times. Only called from
+// a single goroutine
+func (g *Generator) nextThinking() (*cdr.CdrValues, call, error) {
+	if len(g.due) > 0 && !g.due[0].due.After(g.Cfg.Clock.Now()) {
+		s := heap.Pop(&g.due).(scheduled)
+		return s.c, s.cl, nil
+	}
+	if !g.sourceDone {
+		records, cl, err := g.nextCall()
+		if err == nil {
+			due := g.Cfg.Clock.Now()
+			for _, r := range records[1:] {
+				due = due.Add(g.think.Sample(g.rnd))
+				g.dueSeq++
+				heap.Push(&g.due, scheduled{due: due, seq: g.dueSeq, c: r, cl: cl})
+			}
+			return records[0], cl, nil
+		} else if err != io.EOF {
+			return nil, call{}, err
+		}
+		g.sourceDone = true
+	}
+	if len(g.due) <= 0 {
+		return nil, call{}, io.EOF
+	}
+	s := heap.Pop(&g.due).(scheduled)
+	if !g.sleepUntil(s.due) {
+		return nil, call{}, io.EOF
+	}
+	return s.c, s.cl, nil
+}
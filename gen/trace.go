@@ -0,0 +1,74 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"github.com/routecall/go-radius-gen-acct/rfc2866"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"layeh.com/radius"
+)
+
+// calls of --trace-session, their requests are logged decoded with the
+// responses
+type TraceSet struct {
+	ids   map[string]bool
+	calls map[uint64]bool
+}
+
+// parse the comma-separated sessions to trace: a number is the index of
+// the call in the run (1 the first one), anything else an Acct-Session-Id
+// or Call-ID
+func ParseTraceSessions(s string) (*TraceSet, error) {
+	ts := &TraceSet{ids: make(map[string]bool), calls: make(map[uint64]bool)}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) <= 0 {
+			continue
+		}
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			if n == 0 {
+				return nil, fmt.Errorf("trace-session: call index starts at 1")
+			}
+			ts.calls[n] = true
+			continue
+		}
+		ts.ids[v] = true
+	}
+	if len(ts.ids) <= 0 && len(ts.calls) <= 0 {
+		return nil, fmt.Errorf("trace-session: no sessions")
+	}
+	return ts, nil
+}
+
+// true when the call n with the record c is traced
+func (ts *TraceSet) Match(n uint64, c *cdr.CdrValues) bool {
+	return ts.calls[n] || ts.ids[c.AcctSessionId] || ts.ids[c.CallId]
+}
+
+// log the request and its response (or the error) decoded, with the
+// attributes of r masked
+func traceExchange(request, response *radius.Packet, t *target.Target, c *cdr.CdrValues, r dump.Redaction, err error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "trace %s to %s:\n", redactSessionId(r, c.AcctSessionId), t.Addr)
+	dump.Fprint(&b, request, r)
+	if err != nil {
+		fmt.Fprintf(&b, "no response: %v\n", err)
+	} else {
+		dump.Fprint(&b, response, r)
+	}
+	log.Print(strings.TrimRight(b.String(), "\n"))
+}
+
+// the session id as logged, Mask when its attribute is redacted
+func redactSessionId(r dump.Redaction, id string) string {
+	if r[rfc2866.SipAcctSessionID_Type] || r[AcctSessionId] {
+		return dump.Mask
+	}
+	return id
+}
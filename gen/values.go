@@ -0,0 +1,199 @@
+package gen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"layeh.com/radius"
+)
+
+// what --value-check does with a generated value its dictionary type or a
+// --bound rejects
+const (
+	ValueCheckOff = "off"
+	// fail the generation, the run stops with the error
+	ValueCheckStrict = "strict"
+	// clamp the value into its bound (strings cut or padded with "0",
+	// integers to the nearest end) or drop the attribute when it can't be
+	// mended, e.g. text on an integer attribute
+	ValueCheckLenient = "lenient"
+)
+
+// longest attribute value of a packet
+const maxValueLen = 253
+
+// bound of an attribute: the length in bytes of the strings and octets, the
+// value of the integers and dates
+type Bound struct {
+	Type     radius.Type
+	Min, Max uint64
+}
+
+// check of the generated values (--value-check, --bound) against their
+// dictionary type and the bounds the servers impose, with the violations
+// found by attribute and reason
+type ValueCheck struct {
+	Lenient bool
+	Bounds  map[radius.Type]Bound
+
+	mu         sync.Mutex
+	violations map[string]uint64
+}
+
+// parse the mode and the bounds "Attr=min-max" (a name or a number, min
+// or max empty for none, e.g. "Calling-Station-Id=-32"); nil when off
+func ParseValueCheck(mode string, bounds []string) (*ValueCheck, error) {
+	switch mode {
+	case "", ValueCheckOff:
+		if len(bounds) > 0 {
+			return nil, fmt.Errorf("bound needs --value-check %s or %s", ValueCheckStrict, ValueCheckLenient)
+		}
+		return nil, nil
+	case ValueCheckStrict, ValueCheckLenient:
+	default:
+		return nil, fmt.Errorf("value-check %q must be %s, %s or %s", mode, ValueCheckOff, ValueCheckStrict, ValueCheckLenient)
+	}
+	v := &ValueCheck{Lenient: mode == ValueCheckLenient, Bounds: make(map[radius.Type]Bound), violations: make(map[string]uint64)}
+	for _, spec := range bounds {
+		kv := strings.SplitN(spec, "=", 2)
+		t, ok := dump.Lookup(strings.TrimSpace(kv[0]))
+		if !ok {
+			return nil, fmt.Errorf("bound %q: unknown attribute %q", spec, kv[0])
+		}
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bound %q must be attribute=min-max", spec)
+		}
+		r := strings.SplitN(strings.TrimSpace(kv[1]), "-", 2)
+		if len(r) != 2 {
+			return nil, fmt.Errorf("bound %q must be attribute=min-max, min or max empty for none", spec)
+		}
+		b := Bound{Type: t, Max: maxValueLen}
+		switch dump.Dictionary[t].Kind {
+		case dump.Integer, dump.Date:
+			b.Max = 1<<32 - 1
+		case dump.IPAddr:
+			return nil, fmt.Errorf("bound %q: no bound on an address", spec)
+		}
+		var err error
+		if len(r[0]) > 0 {
+			if b.Min, err = strconv.ParseUint(r[0], 10, 32); err != nil {
+				return nil, fmt.Errorf("bound %q: bad min %q", spec, r[0])
+			}
+		}
+		if len(r[1]) > 0 {
+			if b.Max, err = strconv.ParseUint(r[1], 10, 32); err != nil {
+				return nil, fmt.Errorf("bound %q: bad max %q", spec, r[1])
+			}
+		}
+		if b.Max <= 0 {
+			// lenient would drop every value
+			return nil, fmt.Errorf("bound %q: max must be greater 0", spec)
+		}
+		if b.Min > b.Max {
+			return nil, fmt.Errorf("bound %q: min greater than max", spec)
+		}
+		if kind := dump.Dictionary[t].Kind; kind != dump.Integer && kind != dump.Date && b.Max > maxValueLen {
+			return nil, fmt.Errorf("bound %q: a value is %d bytes at most", spec, maxValueLen)
+		}
+		v.Bounds[t] = b
+	}
+	return v, nil
+}
+
+// check the attributes of p, an error on the first bad one when strict;
+// lenient mends or drops them
+func (v *ValueCheck) Check(p *radius.Packet) error {
+	for _, t := range dump.Types(p) {
+		values := p.Attributes[t]
+		kept := values[:0]
+		for _, a := range values {
+			a, reason := v.check(t, a)
+			if len(reason) <= 0 {
+				kept = append(kept, a)
+				continue
+			}
+			v.count(t, reason)
+			if !v.Lenient {
+				return fmt.Errorf("value-check: %s %s", dump.Name(t), reason)
+			}
+			if a != nil {
+				kept = append(kept, a)
+			}
+		}
+		if len(kept) > 0 {
+			p.Attributes[t] = kept
+		} else {
+			delete(p.Attributes, t)
+		}
+	}
+	return nil
+}
+
+// a violating value, mended or nil when it can't be, and why; no reason
+// when valid
+func (v *ValueCheck) check(t radius.Type, a radius.Attribute) (radius.Attribute, string) {
+	if len(a) <= 0 {
+		return nil, "empty"
+	}
+	if len(a) > maxValueLen {
+		return nil, fmt.Sprintf("longer than %d bytes", maxValueLen)
+	}
+	kind := dump.Dictionary[t].Kind
+	switch kind {
+	case dump.Integer, dump.Date, dump.IPAddr:
+		if len(a) != 4 {
+			return nil, "not a 4 bytes " + kind
+		}
+	case dump.String:
+		if !utf8.Valid(a) {
+			return nil, "not UTF-8 text"
+		}
+	}
+	b, ok := v.Bounds[t]
+	if !ok {
+		return a, ""
+	}
+	switch kind {
+	case dump.Integer, dump.Date:
+		n := uint64(binary.BigEndian.Uint32(a))
+		if n < b.Min {
+			return radius.NewInteger(uint32(b.Min)), fmt.Sprintf("below %d", b.Min)
+		} else if n > b.Max {
+			return radius.NewInteger(uint32(b.Max)), fmt.Sprintf("above %d", b.Max)
+		}
+	default:
+		n := uint64(len(a))
+		if n < b.Min {
+			padded := append(append(radius.Attribute{}, a...), strings.Repeat("0", int(b.Min-n))...)
+			return padded, fmt.Sprintf("shorter than %d bytes", b.Min)
+		} else if n > b.Max {
+			return a[:b.Max], fmt.Sprintf("longer than %d bytes", b.Max)
+		}
+	}
+	return a, ""
+}
+
+func (v *ValueCheck) count(t radius.Type, reason string) {
+	v.mu.Lock()
+	v.violations[dump.Name(t)+": "+reason]++
+	v.mu.Unlock()
+}
+
+// the values rejected by attribute and reason, nil when none
+func (v *ValueCheck) Violations() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.violations) <= 0 {
+		return nil
+	}
+	counts := make(map[string]uint64, len(v.violations))
+	for k, n := range v.violations {
+		counts[k] = n
+	}
+	return counts
+}
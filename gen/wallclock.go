@@ -0,0 +1,131 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rate of the hours of the day (--schedule), e.g. the business hours peak
+// of a multi-day run; the hours out of every window run at PPS
+type RateWindow struct {
+	// minutes of the day, the window wraps midnight when To is before From
+	From, To int
+	PPS      float64
+}
+
+func (w RateWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+func (w RateWindow) contains(minute int) bool {
+	if w.From <= w.To {
+		return minute >= w.From && minute < w.To
+	}
+	return minute >= w.From || minute < w.To
+}
+
+// minutes of "HH:MM"
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parse the "HH:MM-HH:MM=pps" windows, repeated or comma-separated; the
+// first window holding an hour gives its rate
+func ParseSchedule(specs []string) ([]RateWindow, error) {
+	var windows []RateWindow
+	for _, s := range specs {
+		for _, spec := range strings.Split(s, ",") {
+			spec = strings.TrimSpace(spec)
+			if len(spec) <= 0 {
+				continue
+			}
+			kv := strings.SplitN(spec, "=", 2)
+			hours := strings.SplitN(kv[0], "-", 2)
+			if len(kv) < 2 || len(hours) < 2 {
+				return nil, fmt.Errorf("schedule: %q is not HH:MM-HH:MM=pps, e.g. 09:00-21:00=5000", spec)
+			}
+			var w RateWindow
+			var err error
+			if w.From, err = parseClock(hours[0]); err != nil {
+				return nil, fmt.Errorf("schedule: %s: %v", spec, err)
+			}
+			if w.To, err = parseClock(hours[1]); err != nil {
+				return nil, fmt.Errorf("schedule: %s: %v", spec, err)
+			}
+			if w.From == w.To {
+				return nil, fmt.Errorf("schedule: %s: empty window", spec)
+			}
+			if w.PPS, err = strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err != nil || w.PPS <= 0 {
+				return nil, fmt.Errorf("schedule: %s: the rate must be a number greater 0", spec)
+			}
+			windows = append(windows, w)
+		}
+	}
+	return windows, nil
+}
+
+// highest rate of the run over the --schedule, to size for
+func peakPPS(cfg Config) float64 {
+	pps := cfg.PPS
+	windows, _ := ParseSchedule(cfg.Schedule)
+	for _, w := range windows {
+		if w.PPS > pps {
+			pps = w.PPS
+		}
+	}
+	return pps
+}
+
+// the window of t on the schedule, nil out of them
+func scheduledWindow(windows []RateWindow, t time.Time) *RateWindow {
+	minute := t.Hour()*60 + t.Minute()
+	for i := range windows {
+		if windows[i].contains(minute) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// rate of the --schedule at t in loc, PPS out of the windows
+func (g *Generator) scheduledRate(t time.Time) (float64, string) {
+	if w := scheduledWindow(g.schedule, t.In(g.scheduleLoc)); w != nil {
+		return w.PPS, w.String()
+	}
+	return g.Cfg.PPS, "off-peak"
+}
+
+// set the --schedule rate whenever the clock enters another window,
+// checked every minute; a rate changed by hand holds until then
+func (g *Generator) followSchedule(ctx context.Context) {
+	cfg := g.Cfg
+	rate, current := g.scheduledRate(cfg.Clock.Now())
+	g.event("schedule: %s, starting at %g pps", current, rate)
+	for {
+		now := cfg.Clock.Now()
+		select {
+		case <-ctx.Done():
+			return
+		// on the next minute
+		case <-cfg.Clock.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+		rate, window := g.scheduledRate(cfg.Clock.Now())
+		if window == current {
+			continue
+		}
+		current = window
+		if err := g.Pacer.SetRate(rate); err != nil {
+			log.Print("schedule: ", err)
+			continue
+		}
+		g.event("schedule: %s, rate set to %g pps", window, rate)
+	}
+}
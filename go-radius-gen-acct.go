@@ -1,103 +1,173 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
-	"net"
+	"math"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/routecall/go-radius-gen-acct/batch"
+	"github.com/routecall/go-radius-gen-acct/buildinfo"
 	"github.com/routecall/go-radius-gen-acct/cdr"
-	"github.com/routecall/go-radius-gen-acct/rfc2866"
-	daemon "github.com/sevlyar/go-daemon"
+	"github.com/routecall/go-radius-gen-acct/checkpoint"
+	"github.com/routecall/go-radius-gen-acct/checksum"
+	"github.com/routecall/go-radius-gen-acct/completion"
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/control/controlpb"
+	"github.com/routecall/go-radius-gen-acct/cpupin"
+	"github.com/routecall/go-radius-gen-acct/crash"
+	"github.com/routecall/go-radius-gen-acct/daemonize"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"github.com/routecall/go-radius-gen-acct/export"
+	"github.com/routecall/go-radius-gen-acct/gen"
+	"github.com/routecall/go-radius-gen-acct/instance"
+	"github.com/routecall/go-radius-gen-acct/logfile"
+	"github.com/routecall/go-radius-gen-acct/mockserver"
+	"github.com/routecall/go-radius-gen-acct/mux"
+	"github.com/routecall/go-radius-gen-acct/output"
+	"github.com/routecall/go-radius-gen-acct/pacer"
+	"github.com/routecall/go-radius-gen-acct/pidfile"
+	"github.com/routecall/go-radius-gen-acct/privdrop"
+	"github.com/routecall/go-radius-gen-acct/profile"
+	"github.com/routecall/go-radius-gen-acct/rlimit"
+	"github.com/routecall/go-radius-gen-acct/script"
+	"github.com/routecall/go-radius-gen-acct/secret"
+	"github.com/routecall/go-radius-gen-acct/sockbuf"
+	"github.com/routecall/go-radius-gen-acct/summary"
+	"github.com/routecall/go-radius-gen-acct/systemd"
+	"github.com/routecall/go-radius-gen-acct/target"
+	"github.com/routecall/go-radius-gen-acct/upgrade"
+	"github.com/routecall/go-radius-gen-acct/verify"
 	"github.com/urfave/cli"
-	"go.uber.org/ratelimit"
-	"layeh.com/radius"
-	"layeh.com/radius/rfc2865"
 )
 
-const Version = "0.12.3"
-
-// max int value
-const MaxUint = ^uint(0)
-const MaxInt = int(MaxUint >> 1)
+const Version = buildinfo.Version
 
 // config struct with all user options
 type Config struct {
-	NASPort      int
-	NASIPAddress string
-	Server       string
-	Port         string
-	Key          string
-	PPS          int
-	MaxReq       int
-	ShowCount    bool
-	Daemon       bool
-	LogFileName  string
-	PidFileName  string
-	Retry        int
-	MaxRetry     int
-	CustomFields string
-}
-
-// used for --custom-fields
-type CustomFields struct {
-	ID    radius.Type
-	Value string
-}
-type MapCustomFields map[int]CustomFields
-
-func NewMapCustomFields() MapCustomFields {
-	return make(MapCustomFields)
-}
-
-// parse struct CdrValues to radius packet
-func ParseCdrAttributes(p *radius.Packet, c *cdr.CdrValues, cfg Config) {
-	rfc2866.SipAcctStatusType_Add(p, rfc2866.SipAcctStatusType_Value_Stop)
-	rfc2866.SipServiceType_Add(p, rfc2866.SipServiceType_Value_SipSession)
-	rfc2866.SipResponseCode_AddString(p, c.ResponseCode)
-	rfc2866.SipMethod_Add(p, rfc2866.SipMethod_Value_INVITE)
-	rfc2866.SipEventTimestamp_Add(p, c.EventTimestamp)
-	rfc2866.SipFromTag_AddString(p, c.FromTag)
-	rfc2866.SipToTag_AddString(p, c.ToTag)
-	rfc2866.SipCallerID_AddString(p, c.CallerId)
-	rfc2866.SipCalleeID_AddString(p, c.CalleeId)
-	rfc2866.SipDstNumber_AddString(p, c.DstNumber)
-	rfc2866.SipAcctSessionID_AddString(p, c.AcctSessionId)
-	rfc2866.SipCallMSDuration_Add(p, rfc2866.SipCallMSDuration(c.MsDuration))
-	rfc2866.SipCallSetuptime_Add(p, rfc2866.SipCallSetuptime(c.SetupTime))
-	rfc2865.NASPort_Add(p, rfc2865.NASPort(cfg.NASPort))
-	rfc2865.NASIPAddress_Add(p, net.ParseIP(cfg.NASIPAddress))
-	return
-}
-
-// send the radius Accounting-Request package to server
-func SendAcct(c *cdr.CdrValues, mcf MapCustomFields, cfg Config) {
-	client := radius.Client{
-		Retry:           time.Second * time.Duration(cfg.Retry),
-		MaxPacketErrors: cfg.MaxRetry,
-	}
-	packet := radius.New(radius.CodeAccountingRequest, []byte(cfg.Key))
-	ParseCdrAttributes(packet, c, cfg)
-	if mcf != nil {
-		AddCustomField(packet, mcf)
-	}
+	gen.Config
+	ShowCount   bool
+	Daemon      bool
+	LogFileName string
+	LogMaxSize  int
+	LogMaxAge   int
+	LogBackups  int
+	PidFileName string
+	API         string
+	GRPC        string
+	APILinger   int
+	Workers     []string
+	Interactive bool
+	Command     string
+	ReportLive  bool
+	StopTimeout int
+	Container   bool
+	Health      string
+	KeyFile     string
+	KeyFrom     string
+	NewKeyFile  string
+	Instance    string
+	Checkpoint  string
+	CheckpointS int
+	Resume      bool
+	CrashDir    string
+	InstanceDir string
+	User        string
+	Plugins     []string
+	Script      string
+	Summary     string
+	// snapshots of the running generator (control API, --interactive)
+	SnapshotDir      string
+	SnapshotProfiles bool
+	// --profile and the file of the user defined ones
+	Profile      string
+	ProfilesFile string
+	Mock         MockConfig
+	Verify       VerifyConfig
+	Batch        BatchConfig
+	Bench        BenchConfig
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		time.Sleep(time.Second * time.Duration(cfg.Retry*cfg.MaxRetry))
-		cancel()
-	}()
+// commands run by main
+const (
+	CommandAcct     = "acct"
+	CommandReplay   = "replay"
+	CommandReport   = "report"
+	CommandValidate = "validate"
+	CommandServer   = "server"
+	CommandStop     = "stop"
+	CommandStatus   = "status"
+	CommandReload   = "reload"
+	CommandList     = "instances"
+	CommandVerify   = "verify"
+	CommandProfiles = "profiles"
+	CommandBatch    = "batch"
+	CommandBench    = "bench"
+	// the completion command, run by CliCreate
+	CommandCompletion = "completion"
+)
 
-	_, err := client.Exchange(ctx, packet, cfg.Server+":"+cfg.Port)
-	if err != nil {
-		log.Fatal("error: ", err)
-		os.Exit(1)
-	}
+// options of the server command
+type MockConfig struct {
+	Listen    string
+	Latency   int
+	Loss      float64
+	NAK       float64
+	Delay     float64
+	DelayDist string
+	DelayMean int
+	Duplicate float64
+	Corrupt   float64
+}
+
+// options of the verify command
+type VerifyConfig struct {
+	Driver        string
+	DSN           string
+	Table         string
+	SessionColumn string
+	TimeColumn    string
+	Query         string
+	Late          int
+	Show          int
+	Detail        string
+	DetailAttr    string
+	Unique        bool
+	// attribute of the checksum on the detail file, empty to not check it
+	ChecksumAttr string
+}
+
+// options of the batch command
+type BatchConfig struct {
+	Runs string
+	Dir  string
+}
+
+// options of the bench command
+type BenchConfig struct {
+	SessionTypes string
+	Extra        string
+	Custom       string
+	Duration     int
+	Workers      int
+	Key          string
 }
 
 // create and set the Config struct
@@ -108,6 +178,141 @@ func CliConfig() Config {
 	return cfg
 }
 
+// help of the acct options by topic, with examples
+const acctDescription = `Options by topic (all of them under OPTIONS):
+
+   rate        --pps --cps --erlangs --max-req --schedule --pacer --burst --pacing-jitter
+               --max-in-flight --max-memory --shed
+   targets     --server --port --srv --targets-file --realms-file --policy --key --key-file
+               --key-from --new-key --new-key-file --shared-sockets --radsec-ca --radsec-cert
+               --radsec-key
+   NAS fleet   --nas-ip --nas-port --nas-count --nas-secrets --nas-source-port --nas-clock-skew
+               --nas-rate --nas-port-range
+   scenarios   --scenario --methods --setup-time --ring-time --talk-time --legs --session-type
+               --lifecycle --interim-interval --think-time --failed-ratio --cardinality
+               --custom-fields
+   replay      --sipp-csv --source --map --map-file --from --to --speed --emit-json
+   load search --find-max --adaptive --slo --storm-ratio
+   checks      --expect-within --expect-attr --value-check --bound --functional --export
+               --detail-file --results-db --heatmap --run-id-attr --checksum-attr --shadow
+   faults      --send-loss --send-jitter --bad-auth --outage --simulate --session-collisions
+               --orphan-stops --stop-before-start --interim-after-stop
+   control     --api --grpc --interactive --wait-start --worker --shard --seed --daemon
+               --container --instance-name --checkpoint --resume
+   output      --output --stats --summary --quiet --no-request-errors --log-file --trace-session
+               --redact
+
+Examples:
+
+   # 500 requests per second, 300000 of them, with the stats every second
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret --pps 500 -m 300000 -c
+
+   # the final report as JSON and the stats on /metrics for Prometheus
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret --pps 500 --output json:run.json --output prometheus::9101
+
+   # two servers, each request to the one answering the fastest
+   go-radius-gen-acct acct -s 10.0.0.1,10.0.0.2 -k secret --pps 500 --policy least-latency
+
+   # 50 calls per second of a 70/30 mix of two call shapes
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret --cps 50 --scenario normal.yaml=70 --scenario short.yaml=30
+
+   # replay a SIPp run ten times faster than it happened
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret --sipp-csv calls.csv --speed 10
+
+   # the highest rate the server sustains
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret --pps 100 --find-max`
+
+// help of the replay command
+const replayDescription = `Sends the accounting records of recorded calls instead of generated ones,
+with the options of acct; --sipp-csv or a --source of calls is required.
+
+Examples:
+
+   # a SIPp run ten times faster than it happened
+   go-radius-gen-acct replay -s 10.0.0.1 -k secret --sipp-csv calls.csv --speed 10
+
+   # a CDR export, its columns mapped to the record fields
+   go-radius-gen-acct replay -s 10.0.0.1 -k secret --source csv:cdrs.csv --map-file cdrs.map`
+
+// help of the server command
+const serverDescription = `Answers the Accounting-Requests of the acct command with the impairments
+of a real network: loss, NAKs, delays, duplicates and corruption.
+
+Examples:
+
+   # a server of 5ms, losing 1% of the requests
+   go-radius-gen-acct server -l :1813 -k secret --latency 5 --loss 0.01
+
+   # and a run against it
+   go-radius-gen-acct acct -s 127.0.0.1 -k secret --pps 1000 -m 10000 -c`
+
+// help of the verify command
+const verifyDescription = `Examples:
+
+   # a run exporting its requests, then checked on the database
+   go-radius-gen-acct acct -s 10.0.0.1 -k secret -m 10000 --export run.csv
+   go-radius-gen-acct verify --export run.csv --db-driver mysql --db-dsn "user:pass@tcp(db:3306)/radius"
+
+   # checked on the detail file of the server, with the checksums of the records
+   # (acct --checksum-attr 99999:1)
+   go-radius-gen-acct verify --export run.csv --detail /var/log/radius/radacct/detail --checksum-attr Attr-26.99999.1`
+
+// help of the bench command
+const benchDescription = `Builds and signs Accounting-Requests as acct does, without sending them,
+for each session type, count of extra attributes and of custom fields, and
+prints the packets per second the host reaches: the highest --pps it can
+generate before the network and the server come in.
+
+Examples:
+
+   # the default cases, 2 seconds each on every CPU
+   go-radius-gen-acct bench
+
+   # a single worker, SIP sessions with up to 50 custom fields
+   go-radius-gen-acct bench --workers 1 --session-types sip --custom-fields 0,10,50`
+
+// help of the batch command
+const batchDescription = `Example of a runs file:
+
+   cooldown: 30s
+   options:
+     server: 10.0.0.1:1813
+     key: secret
+   runs:
+     - name: warm-up
+       options:
+         pps: 100
+         max-req: 6000
+     - name: peak
+       options:
+         pps: 2000
+         max-req: 120000
+
+   go-radius-gen-acct batch --runs runs.yaml --report-dir reports/`
+
+// help of the completion command
+const completionDescription = `Examples:
+
+   # bash, in ~/.bashrc
+   source <(go-radius-gen-acct completion bash)
+
+   # zsh, in ~/.zshrc
+   source <(go-radius-gen-acct completion zsh)
+
+   # fish
+   go-radius-gen-acct completion fish > ~/.config/fish/completions/go-radius-gen-acct.fish`
+
+// true on the shell completion requests (see completion.Script), which run
+// no command
+func completing(args []string) bool {
+	for _, arg := range args {
+		if arg == "--generate-bash-completion" {
+			return true
+		}
+	}
+	return false
+}
+
 // cli - command-line
 func (cfg *Config) CliCreate() {
 	parsed := false
@@ -115,209 +320,3302 @@ func (cfg *Config) CliCreate() {
 	app.Usage = "A Go (golang) RADIUS client accounting (RFC 2866) implementation for perfomance testing"
 	app.UsageText = "go-radius-gen-acct - A Go (golang) RADIUS client accounting (RFC 2866) implementation for perfomance testing with generated data according dictionary (./dictionary.routecall.opensips) and RFC2866 (./rfc2866)."
 	app.Version = Version
+	cli.VersionPrinter = func(c *cli.Context) {
+		fmt.Fprintf(c.App.Writer, "%s version %s\n", c.App.Name, buildinfo.Get())
+	}
 	app.Compiled = time.Now()
+	app.EnableBashCompletion = true
+	app.Description = "Without a command, acct runs. Each command has its help with examples, e.g. go-radius-gen-acct acct --help for the acct options by topic, and go-radius-gen-acct completion bash|zsh|fish prints the shell completion."
 
-	app.Flags = []cli.Flag{
-		cli.IntFlag{
+	// without subcommand runs acct, as before the subcommands
+	app.Flags = cfg.AcctFlags()
+	app.Action = cfg.AcctAction(CommandAcct, &parsed)
+	app.Commands = []cli.Command{
+		{
+			Name:        CommandAcct,
+			Category:    "run",
+			Usage:       "send generated accounting-requests (default command)",
+			Description: acctDescription,
+			Flags:       cfg.AcctFlags(),
+			Action:      cfg.AcctAction(CommandAcct, &parsed),
+		},
+		{
+			Name:        CommandReplay,
+			Category:    "run",
+			Usage:       "send the accounting-requests of recorded calls (--sipp-csv, --source)",
+			Description: replayDescription,
+			Flags:       cfg.AcctFlags(),
+			Action: func(c *cli.Context) error {
+				if err := cfg.AcctAction(CommandReplay, &parsed)(c); err != nil {
+					return err
+				}
+				if name, _ := cdr.ParseSource(cfg.Source); len(cfg.SIPpCSV) <= 0 && (len(cfg.Source) <= 0 || name == cdr.RandomSource) {
+					parsed = false
+					return cli.NewExitError("replay needs --sipp-csv or a --source of calls", 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     CommandReport,
+			Category: "manage",
+			Usage:    "fetch the stats or final report from the control API of a running generator",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "api",
+					EnvVar:      "RADGEN_API",
+					Value:       "127.0.0.1:8080",
+					Usage:       "address of the generator control API",
+					Destination: &cfg.API,
+				},
+				cli.BoolFlag{
+					Name:  "live",
+					Usage: "fetch the live stats instead of the final report",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				cfg.Command = CommandReport
+				cfg.ReportLive = c.Bool("live")
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:        CommandServer,
+			Category:    "run",
+			Usage:       "run a mock RADIUS accounting server answering Accounting-Requests",
+			Description: serverDescription,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "listen, l",
+					EnvVar:      "RADGEN_LISTEN",
+					Value:       ":1813",
+					Usage:       "address to listen on",
+					Destination: &cfg.Mock.Listen,
+				},
+				cli.StringFlag{
+					Name:        "key, k",
+					EnvVar:      "RADGEN_KEY",
+					Usage:       "shared secret",
+					Destination: &cfg.Key,
+				},
+				cli.IntFlag{
+					Name:        "latency",
+					EnvVar:      "RADGEN_LATENCY",
+					Value:       0,
+					Usage:       "artificial latency in milliseconds before each Accounting-Response",
+					Destination: &cfg.Mock.Latency,
+				},
+				cli.Float64Flag{
+					Name:        "loss",
+					EnvVar:      "RADGEN_LOSS",
+					Value:       0,
+					Usage:       "probability (0-1) of not answering a request",
+					Destination: &cfg.Mock.Loss,
+				},
+				cli.Float64Flag{
+					Name:        "nak",
+					EnvVar:      "RADGEN_NAK",
+					Value:       0,
+					Usage:       "probability (0-1) of answering with a non-authentic response",
+					Destination: &cfg.Mock.NAK,
+				},
+				cli.Float64Flag{
+					Name:        "delay",
+					EnvVar:      "RADGEN_DELAY",
+					Value:       0,
+					Usage:       "probability (0-1) of an extra delay drawn from --delay-dist",
+					Destination: &cfg.Mock.Delay,
+				},
+				cli.StringFlag{
+					Name:        "delay-dist",
+					EnvVar:      "RADGEN_DELAY_DIST",
+					Value:       mockserver.Fixed,
+					Usage:       "extra delay distribution: fixed, uniform, exponential or normal",
+					Destination: &cfg.Mock.DelayDist,
+				},
+				cli.IntFlag{
+					Name:        "delay-ms",
+					EnvVar:      "RADGEN_DELAY_MS",
+					Value:       100,
+					Usage:       "mean of the extra delay in milliseconds",
+					Destination: &cfg.Mock.DelayMean,
+				},
+				cli.Float64Flag{
+					Name:        "duplicate",
+					EnvVar:      "RADGEN_DUPLICATE",
+					Value:       0,
+					Usage:       "probability (0-1) of sending the response twice",
+					Destination: &cfg.Mock.Duplicate,
+				},
+				cli.Float64Flag{
+					Name:        "corrupt",
+					EnvVar:      "RADGEN_CORRUPT",
+					Value:       0,
+					Usage:       "probability (0-1) of flipping a byte of the response",
+					Destination: &cfg.Mock.Corrupt,
+				},
+				cli.BoolFlag{
+					Name:  "stats, c",
+					Usage: "show count of requests",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if len(cfg.Key) <= 0 {
+					return cli.NewExitError("key not defined", 1)
+				}
+				if cfg.Mock.Latency < 0 || cfg.Mock.DelayMean < 0 {
+					return cli.NewExitError("latency and delay-ms must be greater or equal 0", 1)
+				}
+				for _, p := range []float64{cfg.Mock.Loss, cfg.Mock.NAK, cfg.Mock.Delay, cfg.Mock.Duplicate, cfg.Mock.Corrupt} {
+					if p < 0 || p > 1 {
+						return cli.NewExitError("loss, nak, delay, duplicate and corrupt must be between 0 and 1", 1)
+					}
+				}
+				switch cfg.Mock.DelayDist {
+				case mockserver.Fixed, mockserver.Uniform, mockserver.Exponential, mockserver.Normal:
+				default:
+					return cli.NewExitError("delay-dist must be fixed, uniform, exponential or normal", 1)
+				}
+				cfg.ShowCount = c.Bool("c")
+				cfg.Command = CommandServer
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:     CommandStop,
+			Category: "manage",
+			Usage:    "stop the daemon of the pid file (SIGTERM), waiting it to finish the run",
+			Flags: []cli.Flag{
+				cfg.pidFileFlag(),
+				cfg.instanceNameFlag(),
+				cfg.instanceDirFlag(),
+				cli.IntFlag{
+					Name:        "timeout",
+					EnvVar:      "RADGEN_STOP_TIMEOUT",
+					Value:       10,
+					Usage:       "seconds to wait the daemon to exit",
+					Destination: &cfg.StopTimeout,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if err := cfg.instanceFiles(c); err != nil {
+					return err
+				}
+				cfg.Command = CommandStop
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:     CommandStatus,
+			Category: "manage",
+			Usage:    "show if the daemon of the pid file is running (exit 0), dead with a stale pid file (1) or not running (3)",
+			Flags:    []cli.Flag{cfg.pidFileFlag(), cfg.instanceNameFlag(), cfg.instanceDirFlag()},
+			Action: func(c *cli.Context) error {
+				if err := cfg.instanceFiles(c); err != nil {
+					return err
+				}
+				cfg.Command = CommandStatus
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:     CommandReload,
+			Category: "manage",
+			Usage:    "make the daemon of the pid file reload --targets-file/--srv (SIGHUP)",
+			Flags:    []cli.Flag{cfg.pidFileFlag(), cfg.instanceNameFlag(), cfg.instanceDirFlag()},
+			Action: func(c *cli.Context) error {
+				if err := cfg.instanceFiles(c); err != nil {
+					return err
+				}
+				cfg.Command = CommandReload
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:     CommandList,
+			Category: "manage",
+			Usage:    "list the named generators (--instance-name) of the instance dir and their aggregated stats",
+			Flags:    []cli.Flag{cfg.instanceDirFlag()},
+			Action: func(c *cli.Context) error {
+				cfg.Command = CommandList
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:        CommandVerify,
+			Category:    "check",
+			Usage:       "check that every acknowledged request of an --export landed once and in time in the accounting database or detail file, exits 1 otherwise",
+			Description: verifyDescription,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "export",
+					EnvVar:      "RADGEN_EXPORT",
+					Usage:       "export of the run (acct --export)",
+					Destination: &cfg.Export,
+				},
+				cli.StringFlag{
+					Name:        "db-driver",
+					EnvVar:      "RADGEN_DB_DRIVER",
+					Value:       "mysql",
+					Usage:       "database of the accounting store: mysql or postgres",
+					Destination: &cfg.Verify.Driver,
+				},
+				cli.StringFlag{
+					Name:        "db-dsn",
+					EnvVar:      "RADGEN_DB_DSN",
+					Usage:       "credentials and address of the database, e.g. \"user:pass@tcp(db:3306)/radius\" or \"postgres://user:pass@db/radius\"",
+					Destination: &cfg.Verify.DSN,
+				},
+				cli.StringFlag{
+					Name:        "table",
+					EnvVar:      "RADGEN_DB_TABLE",
+					Value:       "radacct",
+					Usage:       "table of the accounting records",
+					Destination: &cfg.Verify.Table,
+				},
+				cli.StringFlag{
+					Name:        "session-column",
+					EnvVar:      "RADGEN_DB_SESSION_COLUMN",
+					Value:       "acctsessionid",
+					Usage:       "column of the Acct-Session-Id on the table",
+					Destination: &cfg.Verify.SessionColumn,
+				},
+				cli.StringFlag{
+					Name:        "time-column",
+					EnvVar:      "RADGEN_DB_TIME_COLUMN",
+					Value:       "acctstoptime",
+					Usage:       "column of the record time on the table, for the late records",
+					Destination: &cfg.Verify.TimeColumn,
+				},
+				cli.StringFlag{
+					Name:        "query",
+					EnvVar:      "RADGEN_DB_QUERY",
+					Usage:       "query selecting the session id and the record time, instead of --table and the columns (e.g. to limit it to the run window)",
+					Destination: &cfg.Verify.Query,
+				},
+				cli.StringFlag{
+					Name:        "detail",
+					EnvVar:      "RADGEN_DETAIL",
+					Usage:       "FreeRADIUS detail file to check instead of the database: path, http(s) URL or scp://[user@]host[:port]/path",
+					Destination: &cfg.Verify.Detail,
+				},
+				cli.StringFlag{
+					Name:        "detail-attr",
+					EnvVar:      "RADGEN_DETAIL_ATTR",
+					Value:       verify.DetailAttr,
+					Usage:       "attribute of the session id on the detail file",
+					Destination: &cfg.Verify.DetailAttr,
+				},
+				cli.StringFlag{
+					Name:        "checksum-attr",
+					EnvVar:      "RADGEN_VERIFY_CHECKSUM_ATTR",
+					Usage:       "with --detail, check the checksum of the run (acct --checksum-attr) in this attribute of the detail file, as named there (e.g. Attr-26.99999.1), the records stored truncated or changed are mutated",
+					Destination: &cfg.Verify.ChecksumAttr,
+				},
+				cli.StringFlag{
+					Name:        "checksum-key",
+					EnvVar:      "RADGEN_CHECKSUM_KEY",
+					Usage:       "acct --checksum-key of the run",
+					Destination: &cfg.ChecksumKey,
+				},
+				cli.StringFlag{
+					Name:        "checksum-fields",
+					EnvVar:      "RADGEN_CHECKSUM_FIELDS",
+					Usage:       "acct --checksum-fields of the run",
+					Destination: &cfg.ChecksumFields,
+				},
+				cli.BoolFlag{
+					Name:  "unique",
+					Usage: "match the Acct-Unique-Session-Id of the export (acct --acct-unique) instead of the session id, e.g. with --session-column acctuniqueid",
+				},
+				cli.IntFlag{
+					Name:        "late",
+					EnvVar:      "RADGEN_LATE",
+					Value:       60,
+					Usage:       "seconds after the request was sent for a record to be late",
+					Destination: &cfg.Verify.Late,
+				},
+				cli.IntFlag{
+					Name:        "show",
+					Value:       20,
+					Usage:       "session ids listed of each problem",
+					Destination: &cfg.Verify.Show,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if len(cfg.Export) <= 0 {
+					return cli.NewExitError("export not defined", 1)
+				}
+				if len(cfg.Verify.DSN) <= 0 && len(cfg.Verify.Detail) <= 0 {
+					return cli.NewExitError("db-dsn or detail not defined", 1)
+				}
+				switch cfg.Verify.Driver {
+				case "mysql", "postgres":
+				default:
+					return cli.NewExitError("db-driver must be mysql or postgres", 1)
+				}
+				if cfg.Verify.Late < 0 {
+					return cli.NewExitError("late must be greater or equal 0", 1)
+				}
+				if len(cfg.Verify.ChecksumAttr) > 0 && len(cfg.Verify.Detail) <= 0 {
+					return cli.NewExitError("checksum-attr needs --detail", 1)
+				}
+				cfg.Verify.Unique = c.Bool("unique")
+				cfg.Command = CommandVerify
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:        CommandBatch,
+			Category:    "run",
+			Usage:       "execute the acct runs of a batch file one after the other, with cool-downs between them, and compare their reports; exits 1 when a run failed",
+			Description: batchDescription,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "runs",
+					EnvVar:      "RADGEN_BATCH_RUNS",
+					Usage:       "yaml file of the runs: their acct options by flag name, the options of every run and the cool-down between runs",
+					Destination: &cfg.Batch.Runs,
+				},
+				cli.StringFlag{
+					Name:        "report-dir",
+					EnvVar:      "RADGEN_BATCH_REPORT_DIR",
+					Value:       "./",
+					Usage:       "directory of the logs and reports of the runs (named generators, see instances) and of the batch report",
+					Destination: &cfg.Batch.Dir,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if len(cfg.Batch.Runs) <= 0 {
+					return cli.NewExitError("runs not defined", 1)
+				}
+				cfg.Command = CommandBatch
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:        CommandBench,
+			Category:    "setup",
+			Usage:       "measure the packets per second this host builds and signs, by attribute count, to predict the highest rate it can generate",
+			Description: benchDescription,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "session-types",
+					EnvVar:      "RADGEN_BENCH_SESSION_TYPES",
+					Value:       gen.SIPSession + "," + gen.DataSession,
+					Usage:       "attribute sets generated, comma separated --session-type values",
+					Destination: &cfg.Bench.SessionTypes,
+				},
+				cli.StringFlag{
+					Name:        "extra-attributes",
+					EnvVar:      "RADGEN_BENCH_EXTRA_ATTRIBUTES",
+					Value:       "0,20",
+					Usage:       "counts of Class attributes of 16 bytes added to the generated ones, comma separated",
+					Destination: &cfg.Bench.Extra,
+				},
+				cli.StringFlag{
+					Name:        "custom-fields",
+					EnvVar:      "RADGEN_BENCH_CUSTOM_FIELDS",
+					Value:       "0,10,50",
+					Usage:       "counts of custom fields of 16 bytes (as --custom-fields), comma separated",
+					Destination: &cfg.Bench.Custom,
+				},
+				cli.IntFlag{
+					Name:        "duration",
+					EnvVar:      "RADGEN_BENCH_DURATION",
+					Value:       2,
+					Usage:       "seconds each case runs",
+					Destination: &cfg.Bench.Duration,
+				},
+				cli.IntFlag{
+					Name:        "workers",
+					EnvVar:      "RADGEN_BENCH_WORKERS",
+					Usage:       "goroutines building the packets, 0 for one per CPU",
+					Destination: &cfg.Bench.Workers,
+				},
+				cli.StringFlag{
+					Name:        "key, k",
+					EnvVar:      "RADGEN_KEY",
+					Value:       "secret",
+					Usage:       "shared secret signing the packets",
+					Destination: &cfg.Bench.Key,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if _, err := gen.BenchCases(gen.DefaultConfig(), cfg.Bench.SessionTypes, cfg.Bench.Extra, cfg.Bench.Custom); err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				if cfg.Bench.Duration <= 0 {
+					return cli.NewExitError("duration must be greater 0", 1)
+				}
+				if cfg.Bench.Workers < 0 {
+					return cli.NewExitError("workers must be greater or equal 0", 1)
+				}
+				if len(cfg.Bench.Key) <= 0 {
+					return cli.NewExitError("key not defined", 1)
+				}
+				if cfg.Bench.Workers == 0 {
+					cfg.Bench.Workers = runtime.NumCPU()
+				}
+				cfg.Command = CommandBench
+				parsed = true
+				return nil
+			},
+		},
+		{
+			Name:     "config",
+			Category: "setup",
+			Usage:    "configuration tools",
+			Subcommands: []cli.Command{
+				{
+					Name:   "validate",
+					Usage:  "validate the acct options, custom fields, targets, plugins, script and SIPp CSV without sending traffic",
+					Flags:  cfg.AcctFlags(),
+					Action: cfg.AcctAction(CommandValidate, &parsed),
+				},
+				{
+					Name:  CommandProfiles,
+					Usage: "list the traffic profiles of --profile, the built-in ones and the ones of --profiles-file",
+					Flags: []cli.Flag{cfg.profilesFileFlag()},
+					Action: func(c *cli.Context) error {
+						cfg.Command = CommandProfiles
+						parsed = true
+						return nil
+					},
+				},
+			},
+		},
+	}
+
+	app.Commands = append(app.Commands, cli.Command{
+		Name:        CommandCompletion,
+		Category:    "setup",
+		Usage:       "print the shell completion script of bash, zsh or fish",
+		ArgsUsage:   "bash|zsh|fish",
+		Description: completionDescription,
+		Action: func(c *cli.Context) error {
+			shell := c.Args().First()
+			var script string
+			var err error
+			if shell == completion.Fish {
+				script, err = app.ToFishCompletion()
+			} else {
+				script, err = completion.Script(shell, app.Name)
+			}
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			fmt.Print(script)
+			cfg.Command = CommandCompletion
+			parsed = true
+			return nil
+		},
+	})
+
+	err := app.Run(os.Args)
+	if err == nil && completing(os.Args) {
+		os.Exit(0)
+	}
+	if err != nil || parsed == false {
+		os.Exit(1)
+	}
+}
+
+// set the options of the profile name not given on the command line or
+// the environment
+func applyProfile(c *cli.Context, name, file string) error {
+	p, err := profile.Lookup(name, file)
+	if err != nil {
+		return err
+	}
+	for _, flag := range p.Flags() {
+		if c.IsSet(flag) {
+			continue
+		}
+		if err := c.Set(flag, p.Options[flag]); err != nil {
+			return fmt.Errorf("profile %s: %s: %v", name, flag, err)
+		}
+	}
+	return nil
+}
+
+// config profiles command
+func ListProfiles(cfg Config) error {
+	all, err := profile.All(cfg.ProfilesFile)
+	if err != nil {
+		return err
+	}
+	for _, name := range profile.Names(all) {
+		p := all[name]
+		fmt.Printf("%s: %s\n", name, p.Description)
+		for _, flag := range p.Flags() {
+			fmt.Printf("  --%s %s\n", flag, p.Options[flag])
+		}
+	}
+	return nil
+}
+
+// flags of the acct command
+func (cfg *Config) AcctFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:        "profile",
+			EnvVar:      "RADGEN_PROFILE",
+			Usage:       "traffic profile bundling rate, call model, response code mix and attributes (e.g. wholesale-voice-peak, see config profiles), the options given win over the profile ones",
+			Destination: &cfg.Profile,
+		},
+		cfg.profilesFileFlag(),
+		cli.Float64Flag{
 			Name:        "pps, p",
+			EnvVar:      "RADGEN_PPS",
 			Value:       10,
-			Usage:       "packets per second",
+			Usage:       "packets per second, fractional for slow streams (0.2 a packet every 5 seconds, at least 0.001)",
 			Destination: &cfg.PPS,
 		},
+		cli.Float64Flag{
+			Name:        "cps",
+			EnvVar:      "RADGEN_CPS",
+			Usage:       "new calls per second arriving at random (Poisson), each call record sent at its time, the Start at the answer, the Interims and the Stop as the talk time goes; pps defaults to twice the packet rate it makes and only caps the bursts",
+			Destination: &cfg.CPS,
+		},
+		cli.Float64Flag{
+			Name:        "erlangs",
+			EnvVar:      "RADGEN_ERLANGS",
+			Usage:       "with --cps and --lifecycle, concurrent answered calls (Erlangs) to hold, sets the talk time mean (keeping the talk-time distribution) to erlangs / answered calls per second",
+			Destination: &cfg.Erlangs,
+		},
+		cli.StringSliceFlag{
+			Name:   "server, s",
+			EnvVar: "RADGEN_SERVER",
+			Usage:  "server to send accts (host[:port[:secret]][;w=weight][;key=secret][;transport=udp|tcp|tls], IPv6 with a port as [addr]:port, a secret with a colon as ;key=), repeat or use a comma-separated list to distribute across servers",
+		},
+		cli.StringFlag{
+			Name:        "srv",
+			EnvVar:      "RADGEN_SRV",
+			Usage:       "discover servers, ports and priorities from DNS SRV records (e.g. _radius-acct._udp.example.com)",
+			Destination: &cfg.SRV,
+		},
+		cli.IntFlag{
+			Name:        "srv-refresh",
+			EnvVar:      "RADGEN_SRV_REFRESH",
+			Value:       60,
+			Usage:       "interval in seconds to refresh the --srv records",
+			Destination: &cfg.SRVRefresh,
+		},
+		cli.StringFlag{
+			Name:        "targets-file",
+			EnvVar:      "RADGEN_TARGETS_FILE",
+			Usage:       "yaml file listing servers, secrets, weights and transports, re-read on change or SIGHUP",
+			Destination: &cfg.TargetsFile,
+		},
+		cli.StringFlag{
+			Name:        "realms-file",
+			EnvVar:      "RADGEN_REALMS_FILE",
+			Usage:       "yaml file mapping realms to their servers and secret, each call gets a User-Name caller@realm (realms drawn by weight) and goes to the servers of its realm, instead of --server",
+			Destination: &cfg.RealmsFile,
+		},
+		cli.StringFlag{
+			Name:        "policy",
+			EnvVar:      "RADGEN_POLICY",
+			Value:       target.RoundRobin,
+			Usage:       "distribution across servers: round-robin, failover (first server is the primary, the next ones are used on timeout), sticky (hash of --sticky-key), least-latency (lowest recent round-trip time times the outstanding requests) or least-outstanding (fewest requests waiting for a response)",
+			Destination: &cfg.Policy,
+		},
 		cli.StringFlag{
-			Name:        "server, s",
-			Usage:       "server to send accts",
-			Destination: &cfg.Server,
+			Name:        "sticky-key",
+			EnvVar:      "RADGEN_STICKY_KEY",
+			Value:       "session",
+			Usage:       "value hashed by --policy sticky: session (Acct-Session-Id) or caller",
+			Destination: &cfg.StickyKey,
 		},
 		cli.StringFlag{
 			Name:        "port, P",
+			EnvVar:      "RADGEN_PORT",
 			Value:       "1813",
 			Usage:       "port to send accts",
 			Destination: &cfg.Port,
 		},
 		cli.StringFlag{
 			Name:        "nas-ip",
+			EnvVar:      "RADGEN_NAS_IP",
 			Value:       "127.0.0.1",
 			Usage:       "NAS-IP-Address on radius packet",
 			Destination: &cfg.NASIPAddress,
 		},
 		cli.IntFlag{
 			Name:        "nas-port",
+			EnvVar:      "RADGEN_NAS_PORT",
 			Value:       5666,
 			Usage:       "NAS-Port on radius packet",
 			Destination: &cfg.NASPort,
 		},
+		cli.IntFlag{
+			Name:        "nas-count",
+			EnvVar:      "RADGEN_NAS_COUNT",
+			Value:       0,
+			Usage:       "simulate this many NAS, the calls go round them with consecutive NAS-IP-Address from --nas-ip, NAS-Port from --nas-port and NAS-Identifier nas-1 to nas-N (0 no fleet)",
+			Destination: &cfg.NASCount,
+		},
+		cli.StringFlag{
+			Name:        "nas-secrets",
+			EnvVar:      "RADGEN_NAS_SECRETS",
+			Usage:       "comma-separated shared secrets of the simulated NAS, cycled across the fleet instead of the target ones",
+			Destination: &cfg.NASSecrets,
+		},
+		cli.IntFlag{
+			Name:        "nas-source-port",
+			EnvVar:      "RADGEN_NAS_SOURCE_PORT",
+			Value:       0,
+			Usage:       "send the requests of each simulated NAS from its own UDP port, consecutive from this one (one request in flight per NAS), 0 ephemeral ports",
+			Destination: &cfg.NASSourcePort,
+		},
+		cli.StringFlag{
+			Name:        "nas-clock-skew",
+			EnvVar:      "RADGEN_NAS_CLOCK_SKEW",
+			Usage:       "clock offset of the simulated NAS added to their Event-Timestamps: a duration D draws each one from -D to D, a list of signed durations (e.g. \"-5s,0,+2m\") is cycled across the fleet",
+			Destination: &cfg.NASClockSkew,
+		},
+		cli.StringFlag{
+			Name:        "nas-rate",
+			EnvVar:      "RADGEN_NAS_RATE",
+			Usage:       "own rate limit of each simulated NAS in requests per second, the calls shared among them by rate: dist:mean draws each one (fixed, uniform, exponential or normal, e.g. exponential:2 makes a few chatty NAS and many quiet ones), a list of rates (e.g. \"50,5,5,1\") is cycled across the fleet; the run pps still caps the total",
+			Destination: &cfg.NASRate,
+		},
+		cli.IntFlag{
+			Name:        "nas-burst",
+			EnvVar:      "RADGEN_NAS_BURST",
+			Value:       1,
+			Usage:       "with --nas-rate, requests each NAS may send back to back after a quiet period",
+			Destination: &cfg.NASBurst,
+		},
+		cli.StringFlag{
+			Name:        "nas-port-range",
+			EnvVar:      "RADGEN_NAS_PORT_RANGE",
+			Usage:       "NAS-Ports of each NAS, min-max (e.g. 1-2048): a session takes the free port least recently used with its first record and gives it back with its Stop, as on a real NAS, to test servers keying the sessions on (NAS-IP-Address, NAS-Port); with every port taken the oldest open session loses its own (nas_ports_exhausted in the stats)",
+			Destination: &cfg.NASPortRange,
+		},
 		cli.StringFlag{
 			Name:        "key, k",
-			Usage:       "key for acct",
+			EnvVar:      "RADGEN_KEY",
+			Usage:       "key for acct, default for the servers without their own secret",
 			Destination: &cfg.Key,
 		},
+		cli.StringFlag{
+			Name:        "key-file",
+			EnvVar:      "RADGEN_KEY_FILE",
+			Usage:       "read the key from this file instead of --key, keeping it out of argv and /proc",
+			Destination: &cfg.KeyFile,
+		},
+		cli.StringFlag{
+			Name:        "key-from",
+			EnvVar:      "RADGEN_KEY_FROM",
+			Usage:       "fetch the key from a secret store instead of --key: vault://<api path>[#field] (VAULT_ADDR, VAULT_TOKEN) or aws-sm://<secret id>[#field] (aws command)",
+			Destination: &cfg.KeyFrom,
+		},
+		cli.StringFlag{
+			Name:        "new-key",
+			EnvVar:      "RADGEN_NEW_KEY",
+			Usage:       "secret rotation: switch every server to this shared secret at --key-switch, the requests and responses are counted by secret",
+			Destination: &cfg.NewKey,
+		},
+		cli.StringFlag{
+			Name:        "new-key-file",
+			EnvVar:      "RADGEN_NEW_KEY_FILE",
+			Usage:       "read the new key from this file instead of --new-key, keeping it out of argv and /proc",
+			Destination: &cfg.NewKeyFile,
+		},
+		cli.StringFlag{
+			Name:        "key-switch",
+			EnvVar:      "RADGEN_KEY_SWITCH",
+			Usage:       "when the requests switch to --new-key: a time of the run (5m) or a share of the requests using it from the start (25%)",
+			Destination: &cfg.KeySwitch,
+		},
+		cli.StringFlag{
+			Name:        "user",
+			EnvVar:      "RADGEN_USER",
+			Usage:       "start as root and switch to this user once the sockets are open, the key must then come from --key-file or --key-from and the new key from --new-key-file",
+			Destination: &cfg.User,
+		},
 		cli.IntFlag{
 			Name:        "max-req, m",
-			Value:       MaxInt,
+			EnvVar:      "RADGEN_MAX_REQ",
+			Value:       gen.MaxInt,
 			Usage:       "stop the test and exit when max-req are reached",
 			Destination: &cfg.MaxReq,
 		},
 		cli.IntFlag{
 			Name:        "retry-int, r",
+			EnvVar:      "RADGEN_RETRY_INT",
 			Value:       3,
 			Usage:       "interval in second, on which to resend packet (zero or negative value means no retry)",
 			Destination: &cfg.Retry,
 		},
 		cli.IntFlag{
 			Name:        "max-retry",
+			EnvVar:      "RADGEN_MAX_RETRY",
 			Value:       20,
 			Usage:       "max retrys before exit the program",
 			Destination: &cfg.MaxRetry,
 		},
 		cli.BoolFlag{
-			Name:  "stats, c",
-			Usage: "show count of requests",
+			Name:   "stats, c",
+			EnvVar: "RADGEN_STATS",
+			Usage:  "show count of requests",
+		},
+		cli.BoolFlag{
+			Name:   "daemon, d",
+			EnvVar: "RADGEN_DAEMON",
+			Usage:  "daemon (background) proccess",
+		},
+		cli.BoolFlag{
+			Name:   "proxy-state",
+			EnvVar: "RADGEN_PROXY_STATE",
+			Usage:  "proxy-chain test mode, tag each request with a Proxy-State and verify it is echoed back unchanged",
+		},
+		cli.IntFlag{
+			Name:        "proxy-hops",
+			EnvVar:      "RADGEN_PROXY_HOPS",
+			Value:       1,
+			Usage:       "number of proxies between the generator and the end server, used to show the latency per hop on --proxy-state",
+			Destination: &cfg.ProxyHops,
+		},
+		cli.StringFlag{
+			Name:        "api",
+			EnvVar:      "RADGEN_API",
+			Usage:       "listen address of the HTTP control API and web UI (e.g. 127.0.0.1:8080, or systemd for the socket-activated one): POST /start /stop /pause /resume /rate?pps=N /snapshot, GET /stats /report",
+			Destination: &cfg.API,
+		},
+		cli.StringFlag{
+			Name:        "grpc",
+			EnvVar:      "RADGEN_GRPC",
+			Usage:       "listen address of the gRPC control service with streaming stats (e.g. 127.0.0.1:9090, or systemd for the next socket-activated one)",
+			Destination: &cfg.GRPC,
+		},
+		cli.IntFlag{
+			Name:        "api-linger",
+			EnvVar:      "RADGEN_API_LINGER",
+			Value:       0,
+			Usage:       "seconds to keep the control API and gRPC up after the run, to fetch the final report",
+			Destination: &cfg.APILinger,
+		},
+		cli.StringSliceFlag{
+			Name:   "worker",
+			EnvVar: "RADGEN_WORKER",
+			Usage:  "coordinator mode, control API of a worker generator (started with --api --wait-start --api-linger), repeat for each worker; --pps and --max-req are split across the workers, each one given its --shard of the identifiers and the --seed",
+		},
+		cli.StringFlag{
+			Name:        "shard",
+			EnvVar:      "RADGEN_SHARD",
+			Usage:       "worker i (from 0) of n of a distributed run, i/n: its session ids and caller numbers are i modulo n in their last digits, its RADIUS Identifiers i modulo n and its --cardinality callers its share, so the workers never generate the same ones; the coordinator gives each --worker its shard",
+			Destination: &cfg.Shard,
+		},
+		cli.Int64Flag{
+			Name:        "seed",
+			EnvVar:      "RADGEN_SEED",
+			Usage:       "seed of the random draws, mixed with the --shard worker so the workers draw their own values, 0 for a random one (in the report)",
+			Destination: &cfg.Seed,
+		},
+		cli.BoolFlag{
+			Name:   "container",
+			EnvVar: "RADGEN_CONTAINER",
+			Usage:  "container mode: log to stdout, no daemon/pid files, /healthz and /readyz on --health (default :8081), SIGTERM stops gracefully",
+		},
+		cli.StringFlag{
+			Name:        "health",
+			EnvVar:      "RADGEN_HEALTH",
+			Usage:       "listen address of the /healthz and /readyz probes (also served on --api)",
+			Destination: &cfg.Health,
+		},
+		cli.BoolFlag{
+			Name:   "interactive, i",
+			EnvVar: "RADGEN_INTERACTIVE",
+			Usage:  "interactive prompt to start, stop, change pps and custom fields and show stats during the run",
+		},
+		cli.BoolFlag{
+			Name:   "quiet, q",
+			EnvVar: "RADGEN_QUIET",
+			Usage:  "no log lines during the run, for very large runs: no warnings, rate changes or failed requests (they are still counted), only the startup, the errors and the final report as JSON",
+		},
+		cli.BoolFlag{
+			Name:   "no-request-errors",
+			EnvVar: "RADGEN_NO_REQUEST_ERRORS",
+			Usage:  "no log line per failed request (the failed expectations), they are still counted in the stats and the errors of the report",
+		},
+		cli.StringFlag{
+			Name:        "summary",
+			EnvVar:      "RADGEN_SUMMARY",
+			Value:       summary.Auto,
+			Usage:       "end-of-run summary on the console, a table colored by pass and fail (green from 99.9% answered, red below 99%) with a sparkline of the rate: auto (when stderr is a terminal, in color unless NO_COLOR is set), always or never",
+			Destination: &cfg.Summary,
+		},
+		cli.BoolFlag{
+			Name:   "wait-start",
+			EnvVar: "RADGEN_WAIT_START",
+			Usage:  "wait for start on the control API or gRPC before sending",
+		},
+		cli.BoolFlag{
+			Name:   "functional",
+			EnvVar: "RADGEN_FUNCTIONAL",
+			Usage:  "interoperability check instead of load: send a single request, or max-req of them at 1 pps, log each one decoded with its response and exit 1 on any failure (no response, a rejecting answer or a failed expectation)",
 		},
 		cli.BoolFlag{
-			Name:  "daemon, d",
-			Usage: "daemon (background) proccess",
+			Name:   "simulate",
+			EnvVar: "RADGEN_SIMULATE",
+			Usage:  "validate the plan (rates, lifecycles, think times) in virtual time: answer the requests with the built-in mock server without the network, skip over the waits and print the requests by status type and their schedule; needs max-req or a finite call source",
+		},
+		cli.Float64Flag{
+			Name:        "simulate-loss",
+			EnvVar:      "RADGEN_SIMULATE_LOSS",
+			Value:       0,
+			Usage:       "with --simulate, share (0-1) of the transmissions the mock server drops, to see the retransmissions and failovers of the plan",
+			Destination: &cfg.SimulateLoss,
+		},
+		cli.IntFlag{
+			Name:        "dry-run",
+			EnvVar:      "RADGEN_DRY_RUN",
+			Value:       0,
+			Usage:       "build and encode this many packets, printing them decoded and a summary of what would be sent, without touching the network",
+			Destination: &cfg.DryRun,
+		},
+		cli.StringFlag{
+			Name:        "emit-json",
+			EnvVar:      "RADGEN_EMIT_JSON",
+			Usage:       "write the records of max-req requests to this file (- for stdout) as JSON lines keyed by attribute instead of sending them, for another run to send with --source json:- after any transformation in a pipe",
+			Destination: &cfg.EmitJSON,
 		},
 		cli.StringFlag{
 			Name:        "log-file",
+			EnvVar:      "RADGEN_LOG_FILE",
 			Value:       "./go-radius-gen-acct.log",
 			Usage:       "the destination file of the log",
 			Destination: &cfg.LogFileName,
 		},
+		cli.IntFlag{
+			Name:        "log-max-size",
+			EnvVar:      "RADGEN_LOG_MAX_SIZE",
+			Usage:       "rotate the log file when it grows past this many MB (0 no limit), SIGUSR1 reopens it for logrotate",
+			Destination: &cfg.LogMaxSize,
+		},
+		cli.IntFlag{
+			Name:        "log-max-age",
+			EnvVar:      "RADGEN_LOG_MAX_AGE",
+			Usage:       "rotate the log file after this many hours (0 no limit)",
+			Destination: &cfg.LogMaxAge,
+		},
+		cli.IntFlag{
+			Name:        "log-backups",
+			EnvVar:      "RADGEN_LOG_BACKUPS",
+			Value:       5,
+			Usage:       "rotated log files to keep (log-file.1 newest)",
+			Destination: &cfg.LogBackups,
+		},
+		cfg.pidFileFlag(),
+		cfg.instanceNameFlag(),
+		cfg.instanceDirFlag(),
 		cli.StringFlag{
-			Name:        "pid-file",
-			Value:       "./go-radius-gen-acct.pid",
-			Usage:       "file to save the pid of daemon",
-			Destination: &cfg.PidFileName,
+			Name:        "setup-time",
+			EnvVar:      "RADGEN_SETUP_TIME",
+			Usage:       "call model, INVITE to ringing time as dist:mean with dist fixed, uniform, exponential or normal (default " + cdr.DefaultSetup + " when any phase is set)",
+			Destination: &cfg.SetupTime,
 		},
 		cli.StringFlag{
-			Name:        "custom-fields",
-			Value:       "",
-			Usage:       "--custom-fields \"ID=Value,ID=Value\"",
-			Destination: &cfg.CustomFields,
+			Name:        "ring-time",
+			EnvVar:      "RADGEN_RING_TIME",
+			Usage:       "call model, ringing to answer time (default " + cdr.DefaultRing + "); Sip-Call-Setuptime is setup + ring",
+			Destination: &cfg.RingTime,
 		},
-	}
-
-	// options required
-	app.Action = func(c *cli.Context) error {
-		if cfg.PPS <= 0 {
-			return cli.NewExitError("pps must be greater 0", 1)
-		}
-		if len(cfg.Server) <= 0 {
-			return cli.NewExitError("server not defined", 1)
-		}
-		if len(cfg.Key) <= 0 {
-			return cli.NewExitError("key not defined", 1)
-		}
-		if c.Bool("c") {
-			cfg.ShowCount = true
-		}
-		if c.Bool("d") {
-			cfg.Daemon = true
-		}
-		parsed = true
-		return nil
-	}
-
-	err := app.Run(os.Args)
-	if err != nil || parsed == false {
-		os.Exit(1)
-	}
-}
-
-func LogStats(wg *sync.WaitGroup, c Config, t *uint64) {
-	defer wg.Done()
-	for {
-		countTotalS := atomic.LoadUint64(t)
-		if countTotalS >= uint64(c.MaxReq) {
-			break
-		}
-		time.Sleep(1000 * time.Millisecond)
-		// -c count option
-		// I hope the compiler solve this if
-		if c.ShowCount {
-			log.Print("")
-			log.Print("Stats [refresh 1s]:")
-			log.Print("estimated accounting-request per second:  ", atomic.LoadUint64(t)-countTotalS)
-			log.Print("total count accounting-request:           ", atomic.LoadUint64(t))
-		}
-	}
-}
-
-func ParseCustomFields(c string) (MapCustomFields, error) {
-	mapCustomFields := NewMapCustomFields()
-	attrs := strings.Split(c, ",")
-	for k, att := range attrs {
-		s := strings.Split(att, "=")
-		id, err := strconv.Atoi(s[0])
-		if err != nil {
-			return nil, err
-		}
-		mapCustomFields[k] = CustomFields{radius.Type(id), s[1]}
-	}
-	return mapCustomFields, nil
-}
-
-func AddCustomField(p *radius.Packet, mcf MapCustomFields) {
-	for _, c := range mcf {
-		p.Add(c.ID, []byte(c.Value))
-	}
-}
-
-func GetMapCustomFields(c string) (MapCustomFields, error) {
-	if len(c) > 0 {
-		mapCustomFields, err := ParseCustomFields(c)
-		if err != nil {
-			return nil, err
-		}
-		return mapCustomFields, nil
-	}
-	return nil, nil
-}
-
-func main() {
-	cfg := CliConfig()
-	var countTotal uint64
-	var wg sync.WaitGroup
-	// set ratelimit
-	rl := ratelimit.New(cfg.PPS)
-
-	if cfg.Daemon {
-		cntxt := &daemon.Context{
-			PidFileName: cfg.PidFileName,
-			PidFilePerm: 0644,
-			LogFileName: cfg.LogFileName,
-			LogFilePerm: 0640,
-			WorkDir:     "./",
-			Umask:       027,
-		}
-		d, err := cntxt.Reborn()
-		if err != nil {
-			log.Fatal("Unable to run: ", err)
-		}
-		if d != nil {
-			return
-		}
-		defer cntxt.Release()
-		log.Print("daemon started")
-	}
-
-	if cfg.ShowCount {
-		wg.Add(1)
-		go LogStats(&wg, cfg, &countTotal)
-	}
-
-	for i := 0; i < cfg.MaxReq; i++ {
-		_ = rl.Take()
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			atomic.AddUint64(&countTotal, 1)
-			c := cdr.FillCdr()
-			mapCustomFields, _ := GetMapCustomFields(cfg.CustomFields)
-			SendAcct(c, mapCustomFields, cfg)
-		}()
-	}
-
+		cli.StringFlag{
+			Name:        "talk-time",
+			EnvVar:      "RADGEN_TALK_TIME",
+			Usage:       "call model, answered call talk time (default " + cdr.DefaultTalk + "), the Sip-Call-MSDuration",
+			Destination: &cfg.TalkTime,
+		},
+		cli.StringFlag{
+			Name:        "methods",
+			EnvVar:      "RADGEN_METHODS",
+			Usage:       "SIP methods of the records with their weights, e.g. \"INVITE=80,BYE=10,CANCEL=5,UPDATE=3,MESSAGE=2\" (default INVITE only)",
+			Destination: &cfg.Methods,
+		},
+		cli.StringFlag{
+			Name:        "cardinality",
+			EnvVar:      "RADGEN_CARDINALITY",
+			Usage:       "distinct values of the generated attributes over the run, e.g. \"caller=10000,callee=500\" (caller, callee, src-ip and dst-ip), the first calls make them and the next ones reuse them at random (default a new caller and callee per call)",
+			Destination: &cfg.Cardinality,
+		},
+		cli.StringSliceFlag{
+			Name:   "scenario",
+			EnvVar: "RADGEN_SCENARIO",
+			Usage:  "yaml file of a call shape (setup-time, ring-time, talk-time, methods, failed-ratio, failed-codes, legs, interim-interval, reinvite-ratio, the run ones when not given) and its weight, path=weight, repeat to mix them (e.g. normal.yaml=70 short.yaml=20 failures.yaml=10), each one with its own stats",
+		},
+		cli.BoolFlag{
+			Name:   "legs",
+			EnvVar: "RADGEN_LEGS",
+			Usage:  "send an A-leg and a B-leg record per call, sharing the Sip-Call-Id with distinct session-ids (each leg counts as a request)",
+		},
+		cli.StringFlag{
+			Name:        "session-type",
+			EnvVar:      "RADGEN_SESSION_TYPE",
+			Value:       gen.SIPSession,
+			Usage:       "what the records account: sip calls, or data subscriber sessions (Framed-Protocol, Framed-IP-Address, octet and packet counters, NAS-Port-Type Wireless-802.11), the talk time being the session time",
+			Destination: &cfg.SessionType,
+		},
+		cli.BoolFlag{
+			Name:   "lifecycle",
+			EnvVar: "RADGEN_LIFECYCLE",
+			Usage:  "send a Start, the Interims (Sip-Acct-Status-Type Alive) and a Stop with octet counters per answered call, back to back unless --cps (each record counts as a request)",
+		},
+		cli.IntFlag{
+			Name:        "interim-interval",
+			EnvVar:      "RADGEN_INTERIM_INTERVAL",
+			Value:       0,
+			Usage:       "with --lifecycle, an Interim every this many seconds of talk time (0 none)",
+			Destination: &cfg.InterimInterval,
+		},
+		cli.StringFlag{
+			Name:        "think-time",
+			EnvVar:      "RADGEN_THINK_TIME",
+			Usage:       "with --lifecycle, random delay between the Start, Interims and Stop of a call as dist:mean (e.g. exponential:30s), the sessions stay open on the server meanwhile while new calls go on at pps",
+			Destination: &cfg.ThinkTime,
+		},
+		cli.BoolFlag{
+			Name:   "close-sessions",
+			EnvVar: "RADGEN_CLOSE_SESSIONS",
+			Usage:  "with --lifecycle, when the run stops (signal, API, max-req) send the Stops of the sessions left open before exiting, so the server isn't left with dangling sessions",
+		},
+		cli.Float64Flag{
+			Name:        "reinvite-ratio",
+			EnvVar:      "RADGEN_REINVITE_RATIO",
+			Value:       0,
+			Usage:       "with --lifecycle, share (0-1) of the answered calls with a mid-call re-INVITE (codec change, or hold and resume) sending an Interim with the updated octet counters",
+			Destination: &cfg.ReinviteRatio,
+		},
+		cli.Float64Flag{
+			Name:        "orphan-stops",
+			EnvVar:      "RADGEN_ORPHAN_STOPS",
+			Value:       0,
+			Usage:       "with --lifecycle, share (0-1) of the calls sending only their Stop, for a session that never started",
+			Destination: &cfg.OrphanStops,
+		},
+		cli.Float64Flag{
+			Name:        "stop-before-start",
+			EnvVar:      "RADGEN_STOP_BEFORE_START",
+			Value:       0,
+			Usage:       "with --lifecycle, share (0-1) of the calls sending the Stop before the Start and Interims",
+			Destination: &cfg.StopBeforeStart,
+		},
+		cli.Float64Flag{
+			Name:        "interim-after-stop",
+			EnvVar:      "RADGEN_INTERIM_AFTER_STOP",
+			Value:       0,
+			Usage:       "with --lifecycle, share (0-1) of the calls with Interims sending the last one after the Stop",
+			Destination: &cfg.InterimAfterStop,
+		},
+		cli.Float64Flag{
+			Name:        "failed-ratio",
+			EnvVar:      "RADGEN_FAILED_RATIO",
+			Value:       -1,
+			Usage:       "share (0-1) of failed calls, with a --failed-codes code, zero duration and no To-Tag, the others answered 200; negative keeps the 200/480/503 mix",
+			Destination: &cfg.FailedRatio,
+		},
+		cli.StringFlag{
+			Name:        "failed-codes",
+			EnvVar:      "RADGEN_FAILED_CODES",
+			Value:       cdr.DefaultFailedCodes,
+			Usage:       "4xx/5xx/6xx response codes of the failed calls",
+			Destination: &cfg.FailedCodes,
+		},
+		cli.StringFlag{
+			Name:        "from-tag-format",
+			EnvVar:      "RADGEN_FROM_TAG_FORMAT",
+			Usage:       "template of the From-Tag: text with {digits:N}, {hex:N}, {HEX:N}, {alpha:N}, {alnum:N}, {src} and {dst}, e.g. \"as{hex:8}-{hex:8}\" (default 24 digits)",
+			Destination: &cfg.FromTagFormat,
+		},
+		cli.StringFlag{
+			Name:        "to-tag-format",
+			EnvVar:      "RADGEN_TO_TAG_FORMAT",
+			Usage:       "template of the To-Tag, as --from-tag-format (default 16 digits)",
+			Destination: &cfg.ToTagFormat,
+		},
+		cli.StringFlag{
+			Name:        "call-id-format",
+			EnvVar:      "RADGEN_CALL_ID_FORMAT",
+			Usage:       "template of the Call-ID and A-leg session-id, as --from-tag-format, e.g. \"{hex:32}@{src}\" (default 20 digits@source IP)",
+			Destination: &cfg.CallIdFormat,
+		},
+		cli.StringFlag{
+			Name:        "sipp-csv",
+			EnvVar:      "RADGEN_SIPP_CSV",
+			Usage:       "emit the records of the calls in a SIPp run CSV (call id, start, answer and stop times, response code and numbers columns) instead of generated ones, stops after the last call",
+			Destination: &cfg.SIPpCSV,
+		},
+		cli.StringFlag{
+			Name:        "source",
+			EnvVar:      "RADGEN_SOURCE",
+			Usage:       "calls source name[:arg], one of " + strings.Join(cdr.Sources(), ", ") + " (sipp:file is --sipp-csv file, csv:file and json:file a CDR export mapped with --map)",
+			Value:       cdr.RandomSource,
+			Destination: &cfg.Source,
+		},
+		cli.StringSliceFlag{
+			Name:   "map",
+			EnvVar: "RADGEN_MAP",
+			Usage:  "with --source csv:file or json:file, send this column of the export as this attribute, column=Attribute (e.g. duration_ms=Sip-Call-MSDuration, - skips a column), repeat or comma-separate for several; the columns named as an attribute need none",
+		},
+		cli.StringFlag{
+			Name:        "map-file",
+			EnvVar:      "RADGEN_MAP_FILE",
+			Usage:       "yaml file of the --map rules (column: Attribute), read before them and written with them when --map is given",
+			Destination: &cfg.MapFile,
+		},
+		cli.StringFlag{
+			Name:        "from",
+			EnvVar:      "RADGEN_FROM",
+			Usage:       "with --sipp-csv, replay the calls from this time on (unix seconds, \"2006-01-02 15:04:05\" local time or RFC 3339), by their start, answer or stop time",
+			Destination: &cfg.From,
+		},
+		cli.StringFlag{
+			Name:        "to",
+			EnvVar:      "RADGEN_TO",
+			Usage:       "with --sipp-csv, replay the calls before this time, as --from",
+			Destination: &cfg.To,
+		},
+		cli.Float64Flag{
+			Name:        "speed",
+			EnvVar:      "RADGEN_SPEED",
+			Usage:       "with --sipp-csv, send the records at their times in the CSV this many times faster (1 real time, 10 an hour in six minutes) instead of at pps, which doesn't apply",
+			Destination: &cfg.Speed,
+		},
+		cli.StringFlag{
+			Name:        "export",
+			EnvVar:      "RADGEN_EXPORT",
+			Usage:       "write a CSV with the send time, Acct-Session-Id, Call-ID and result of every request, for the verify command",
+			Destination: &cfg.Export,
+		},
+		cli.StringFlag{
+			Name:        "detail-file",
+			EnvVar:      "RADGEN_DETAIL_FILE",
+			Usage:       "append every sent request to this file in FreeRADIUS detail format, for the mediation tools (and verify --detail)",
+			Destination: &cfg.DetailFile,
+		},
+		cli.Float64Flag{
+			Name:        "session-collisions",
+			EnvVar:      "RADGEN_SESSION_COLLISIONS",
+			Value:       0,
+			Usage:       "share (0-1) of the calls reusing the session id of a recent call (with other Call-ID, tags and NAS with --nas-count), to test the server uniqueness logic",
+			Destination: &cfg.SessionCollisions,
+		},
+		cli.BoolFlag{
+			Name:   "acct-unique",
+			EnvVar: "RADGEN_ACCT_UNIQUE",
+			Usage:  "add Acct-Session-Id (the Sip-Acct-Session-Id) and write the Acct-Unique-Session-Id FreeRADIUS derives from it on --export, for verify --unique against acctuniqueid keyed tables",
+		},
+		cli.StringFlag{
+			Name:        "shadow",
+			EnvVar:      "RADGEN_SHADOW",
+			Usage:       "also send every request to this server (host[:port[:secret]]), e.g. the new AAA of a migration, comparing its answers with the ones of --server",
+			Destination: &cfg.Shadow,
+		},
+		cli.StringFlag{
+			Name:        "shadow-report",
+			EnvVar:      "RADGEN_SHADOW_REPORT",
+			Usage:       "write the records the --shadow server answered differently (result, response code or latency) to this CSV file",
+			Destination: &cfg.ShadowReport,
+		},
+		cli.IntFlag{
+			Name:        "shadow-latency-diff",
+			EnvVar:      "RADGEN_SHADOW_LATENCY_DIFF",
+			Value:       0,
+			Usage:       "with --shadow, records whose latencies are further apart than this many milliseconds diverge too, 0 to not compare them",
+			Destination: &cfg.ShadowLatencyDiff,
+		},
+		cli.IntFlag{
+			Name:        "shared-sockets",
+			EnvVar:      "RADGEN_SHARED_SOCKETS",
+			Value:       0,
+			Usage:       "send over this many long-lived UDP sockets per server, allocating the 256 RADIUS Identifiers of each socket to the outstanding requests, instead of a socket per request",
+			Destination: &cfg.SharedSockets,
+		},
+		cli.StringFlag{
+			Name:        "radsec-ca",
+			EnvVar:      "RADGEN_RADSEC_CA",
+			Usage:       "PEM file of the CA verifying the certificates of the tls (RadSec) servers, the system ones when not given",
+			Destination: &cfg.RadSecCA,
+		},
+		cli.StringFlag{
+			Name:        "radsec-cert",
+			EnvVar:      "RADGEN_RADSEC_CERT",
+			Usage:       "PEM file of the client certificate presented to the tls (RadSec) servers, with --radsec-key",
+			Destination: &cfg.RadSecCert,
+		},
+		cli.StringFlag{
+			Name:        "radsec-key",
+			EnvVar:      "RADGEN_RADSEC_KEY",
+			Usage:       "PEM file of the key of --radsec-cert",
+			Destination: &cfg.RadSecKey,
+		},
+		cli.BoolFlag{
+			Name:   "radsec-insecure",
+			EnvVar: "RADGEN_RADSEC_INSECURE",
+			Usage:  "don't verify the certificates of the tls (RadSec) servers",
+		},
+		cli.StringFlag{
+			Name:        "id-exhausted",
+			EnvVar:      "RADGEN_ID_EXHAUSTED",
+			Value:       mux.Block,
+			Usage:       "with --shared-sockets, when every Identifier is outstanding: block (wait for a request to finish) or open (one more socket)",
+			Destination: &cfg.IDExhausted,
+		},
+		cli.IntFlag{
+			Name:        "late-window",
+			EnvVar:      "RADGEN_LATE_WINDOW",
+			Value:       0,
+			Usage:       "with --shared-sockets, hold the Identifier of a timed-out request this many seconds so a response arriving after the timeout is counted as late (server slow) rather than stray; 0 gives it back at once, its late response is still counted until the Identifier is reused",
+			Destination: &cfg.LateWindow,
+		},
+		cli.IntFlag{
+			Name:        "rcvbuf",
+			EnvVar:      "RADGEN_RCVBUF",
+			Value:       0,
+			Usage:       "receive buffer in bytes of the sockets, raise it at high pps so responses aren't dropped by the client (0 is the system default)",
+			Destination: &cfg.RcvBuf,
+		},
+		cli.IntFlag{
+			Name:        "sndbuf",
+			EnvVar:      "RADGEN_SNDBUF",
+			Value:       0,
+			Usage:       "send buffer in bytes of the sockets (0 is the system default)",
+			Destination: &cfg.SndBuf,
+		},
+		cli.StringFlag{
+			Name:        "cpus",
+			EnvVar:      "RADGEN_CPUS",
+			Usage:       "run on these CPUs only (e.g. \"0-3,8\", one NUMA node), GOMAXPROCS set to their number; with --shared-sockets the socket readers are pinned one CPU each and their sockets steered to it (SO_INCOMING_CPU), for steadier tail latencies at very high rates (linux)",
+			Destination: &cfg.CPUs,
+		},
+		cli.BoolFlag{
+			Name:   "acct-on-off",
+			EnvVar: "RADGEN_ACCT_ON_OFF",
+			Usage:  "send an Accounting-On of every NAS (--nas-count, or --nas-ip-address) before the first request and an Accounting-Off after the last one, as devices do on boot and shutdown",
+		},
+		cli.StringFlag{
+			Name:        "mirror",
+			EnvVar:      "RADGEN_MIRROR",
+			Usage:       "copy every request to this destination too (host[:port[:secret]]), e.g. a passive analytics or fraud system; its responses aren't waited for nor counted",
+			Destination: &cfg.Mirror,
+		},
+		cli.IntFlag{
+			Name:        "expect-within",
+			EnvVar:      "RADGEN_EXPECT_WITHIN",
+			Value:       0,
+			Usage:       "expect every accounting-response within this many milliseconds, the requests failing it are counted and the run exits 1 (0 to only expect a response with --expect-attr)",
+			Destination: &cfg.ExpectWithin,
+		},
+		cli.StringSliceFlag{
+			Name:   "expect-attr",
+			EnvVar: "RADGEN_EXPECT_ATTR",
+			Usage:  "expect this attribute (Name[=value], the name as on the dictionary or a number, * on the value matches anything: Class=*) on every accounting-response, repeat for several; the misses are counted by attribute and the run exits 1",
+		},
+		cli.StringFlag{
+			Name:        "value-check",
+			EnvVar:      "RADGEN_VALUE_CHECK",
+			Value:       gen.ValueCheckOff,
+			Usage:       "check every generated value against its dictionary type (4 bytes integers, dates and addresses, UTF-8 strings, 1 to 253 bytes) and the --bound ones: off, strict fails the run on the first bad one, lenient clamps it into its bound or drops it; the violations are counted by attribute in the report, so bad synthetic data doesn't pass for server rejects",
+			Destination: &cfg.ValueCheck,
+		},
+		cli.StringSliceFlag{
+			Name:   "bound",
+			EnvVar: "RADGEN_BOUND",
+			Usage:  "bound the servers impose on an attribute for --value-check, Name=min-max (the name as on the dictionary or a number, min or max empty for none): the length in bytes of strings and octets, the value of integers and dates, e.g. Calling-Station-Id=-32 or Acct-Session-Time=0-86400, repeat for several",
+		},
+		cli.StringSliceFlag{
+			Name:   "label",
+			EnvVar: "RADGEN_LABEL",
+			Usage:  "label the run (name=value) on its reports and results, repeat for several",
+		},
+		cli.StringFlag{
+			Name:        "results-db",
+			EnvVar:      "RADGEN_RESULTS_DB",
+			Usage:       "store the result of every request (session, server, response code, latency) and the run metadata in this SQLite file, keyed by --run-id, for SQL analysis of the run",
+			Destination: &cfg.ResultsDB,
+		},
+		cli.StringFlag{
+			Name:        "heatmap",
+			EnvVar:      "RADGEN_HEATMAP",
+			Usage:       "write a latency heatmap CSV at the end of the run, the responses counted by second of the run and latency bin (second, time, bin_low_ms, bin_high_ms, count), to spot periodic server stalls",
+			Destination: &cfg.Heatmap,
+		},
+		cli.StringSliceFlag{
+			Name:   "output",
+			EnvVar: "RADGEN_OUTPUT",
+			Usage:  "sink of the run, name[:arg], repeat for several: csv:file, detail:file, sqlite:file and heatmap:file as --export, --detail-file, --results-db and --heatmap, json:file (- for stdout) of the final report, console[:color|plain] end-of-run summary, influx:url pushing the stats every second to an InfluxDB write endpoint (e.g. influx:http://influx:8086/write?db=radgen), prometheus:addr serving them on /metrics (e.g. prometheus::9101)",
+		},
+		cli.BoolFlag{
+			Name:   "find-max",
+			EnvVar: "RADGEN_FIND_MAX",
+			Usage:  "search the highest rate the servers sustain, from --pps doubling it until a level fails the thresholds then bisecting, and report it; the failed requests don't stop the run",
+		},
+		cli.IntFlag{
+			Name:        "find-max-settle",
+			EnvVar:      "RADGEN_FIND_MAX_SETTLE",
+			Value:       10,
+			Usage:       "with --find-max, seconds each level runs before it is measured",
+			Destination: &cfg.FindMaxSettle,
+		},
+		cli.IntFlag{
+			Name:        "find-max-hold",
+			EnvVar:      "RADGEN_FIND_MAX_HOLD",
+			Value:       20,
+			Usage:       "with --find-max, seconds each level is measured",
+			Destination: &cfg.FindMaxHold,
+		},
+		cli.Float64Flag{
+			Name:        "find-max-error-rate",
+			EnvVar:      "RADGEN_FIND_MAX_ERROR_RATE",
+			Value:       0.01,
+			Usage:       "with --find-max, share (0-1) of requests without a response failing a level",
+			Destination: &cfg.FindMaxErrorRate,
+		},
+		cli.IntFlag{
+			Name:        "find-max-p99",
+			EnvVar:      "RADGEN_FIND_MAX_P99",
+			Value:       100,
+			Usage:       "with --find-max, p99 latency in ms failing a level (0 none)",
+			Destination: &cfg.FindMaxP99,
+		},
+		cli.BoolFlag{
+			Name:   "adaptive",
+			EnvVar: "RADGEN_ADAPTIVE",
+			Usage:  "throttle on the servers push back (AIMD): raise the rate by --adaptive-step every --adaptive-interval they keep up, multiply it by --adaptive-backoff on rejects (Error-Cause), timeouts or retransmissions over --adaptive-retransmits or a p99 over --adaptive-p99; the rate trajectory and the knee (mean rate pushed back at) go to the report, the failed requests don't stop the run",
+		},
+		cli.IntFlag{
+			Name:        "adaptive-interval",
+			EnvVar:      "RADGEN_ADAPTIVE_INTERVAL",
+			Value:       5,
+			Usage:       "with --adaptive, seconds between two rate changes",
+			Destination: &cfg.AdaptiveInterval,
+		},
+		cli.Float64Flag{
+			Name:        "adaptive-step",
+			EnvVar:      "RADGEN_ADAPTIVE_STEP",
+			Value:       0,
+			Usage:       "with --adaptive, pps added each interval the servers keep up (0 a tenth of --pps)",
+			Destination: &cfg.AdaptiveStep,
+		},
+		cli.Float64Flag{
+			Name:        "adaptive-backoff",
+			EnvVar:      "RADGEN_ADAPTIVE_BACKOFF",
+			Value:       0.5,
+			Usage:       "with --adaptive, factor (0-1) of the rate on a push back",
+			Destination: &cfg.AdaptiveBackoff,
+		},
+		cli.Float64Flag{
+			Name:        "adaptive-retransmits",
+			EnvVar:      "RADGEN_ADAPTIVE_RETRANSMITS",
+			Value:       0.01,
+			Usage:       "with --adaptive, share (0-1) of the requests retransmitted or timed out over an interval that is a push back",
+			Destination: &cfg.AdaptiveRetransmits,
+		},
+		cli.IntFlag{
+			Name:        "adaptive-p99",
+			EnvVar:      "RADGEN_ADAPTIVE_P99",
+			Value:       0,
+			Usage:       "with --adaptive, p99 latency in ms over an interval that is a push back (0 twice the one of the first interval)",
+			Destination: &cfg.AdaptiveP99,
+		},
+		cli.Float64Flag{
+			Name:        "storm-ratio",
+			EnvVar:      "RADGEN_STORM_RATIO",
+			Value:       0.2,
+			Usage:       "warn of a retry storm when the retransmissions reach this share of the requests over --storm-window, the measured rate is then meaningless (0 never)",
+			Destination: &cfg.StormRatio,
+		},
+		cli.IntFlag{
+			Name:        "storm-window",
+			EnvVar:      "RADGEN_STORM_WINDOW",
+			Value:       10,
+			Usage:       "seconds of requests the retry storm ratio is measured on",
+			Destination: &cfg.StormWindow,
+		},
+		cli.BoolFlag{
+			Name:   "storm-backoff",
+			EnvVar: "RADGEN_STORM_BACKOFF",
+			Usage:  "halve the rate every --storm-window seconds a retry storm lasts",
+		},
+		cli.StringSliceFlag{
+			Name:   "schedule",
+			EnvVar: "RADGEN_SCHEDULE",
+			Usage:  "rate of the hours of the day, HH:MM-HH:MM=pps (e.g. 09:00-21:00=5000, a window may wrap midnight), --pps out of the windows; repeat or comma-separate for several, the first window of an hour wins",
+		},
+		cli.StringFlag{
+			Name:        "schedule-tz",
+			EnvVar:      "RADGEN_SCHEDULE_TZ",
+			Usage:       "time zone of the --schedule hours, e.g. America/Sao_Paulo (default the local one)",
+			Destination: &cfg.ScheduleTZ,
+		},
+		cli.StringSliceFlag{
+			Name:   "slo",
+			EnvVar: "RADGEN_SLO",
+			Usage:  "response time objective (percent<latency: 99%<20ms, a request not answered misses it), warned of during the run when its error budget burns at --slo-burn over --slo-window; repeat for several",
+		},
+		cli.IntFlag{
+			Name:        "slo-window",
+			EnvVar:      "RADGEN_SLO_WINDOW",
+			Value:       300,
+			Usage:       "seconds of requests the --slo burn rate is measured on",
+			Destination: &cfg.SLOWindow,
+		},
+		cli.Float64Flag{
+			Name:        "slo-burn",
+			EnvVar:      "RADGEN_SLO_BURN",
+			Value:       10,
+			Usage:       "--slo burn rate warned of: how many times faster than allowed the requests miss the objective (1 spends the error budget exactly)",
+			Destination: &cfg.SLOBurn,
+		},
+		cli.StringFlag{
+			Name:        "run-id",
+			EnvVar:      "RADGEN_RUN_ID",
+			Usage:       "test run id added to every request in --run-id-attr, to filter and clean up the records of the run on the server (default: radgen-<start time>-<random>, kept on --resume)",
+			Destination: &cfg.RunID,
+		},
+		cli.StringFlag{
+			Name:        "run-id-attr",
+			EnvVar:      "RADGEN_RUN_ID_ATTR",
+			Value:       "class",
+			Usage:       "attribute of the run id: class, an attribute number or vendor:type for a Vendor-Specific one (e.g. 9:1), none to not add it",
+			Destination: &cfg.RunIDAttr,
+		},
+		cli.StringFlag{
+			Name:        "checksum-attr",
+			EnvVar:      "RADGEN_CHECKSUM_ATTR",
+			Usage:       "add a checksum of the --checksum-fields of every request in this attribute, vendor:type for a Vendor-Specific one (e.g. 99999:1) or a number, for verify --checksum-attr to tell the records stored truncated or changed",
+			Destination: &cfg.ChecksumAttr,
+		},
+		cli.StringFlag{
+			Name:        "checksum-key",
+			EnvVar:      "RADGEN_CHECKSUM_KEY",
+			Usage:       "with --checksum-attr, key of the checksum HMAC-SHA256 (a plain SHA-256 without)",
+			Destination: &cfg.ChecksumKey,
+		},
+		cli.StringFlag{
+			Name:        "checksum-fields",
+			EnvVar:      "RADGEN_CHECKSUM_FIELDS",
+			Usage:       "with --checksum-attr, comma-separated string or integer attributes summed (default " + strings.Join(checksum.DefaultAttrs, ",") + ")",
+			Destination: &cfg.ChecksumFields,
+		},
+		cli.BoolFlag{
+			Name:   "session-class",
+			EnvVar: "RADGEN_SESSION_CLASS",
+			Usage:  "add a Class to every record of a session as a NAS echoes the one of the Access-Accept (made from the Acct-Session-Id, or the one of an accounting-response of the session), for the billing Class correlation",
+		},
+		cli.StringSliceFlag{
+			Name:   "carry-attr",
+			EnvVar: "RADGEN_CARRY_ATTR",
+			Usage:  "copy this attribute of the accounting-responses (the name as on the dictionary or a number, e.g. State or Class) to the next records of the session, as a NAS echoes what the server hands back; repeat or comma-separate for several, a --scenario sets its own with carry-attr",
+		},
+		cli.StringFlag{
+			Name:        "sample-rate",
+			EnvVar:      "RADGEN_SAMPLE_RATE",
+			Usage:       "keep the detail file records, the results file rows and the --functional traces of one call of every N (1/N, or a share like 0.01) on big runs; the --export CSV keeps every request for verify",
+			Destination: &cfg.SampleRate,
+		},
+		cli.StringFlag{
+			Name:        "trace-session",
+			EnvVar:      "RADGEN_TRACE_SESSION",
+			Usage:       "log the decoded requests and responses of these calls only, comma-separated Acct-Session-Id or Call-ID values or call indexes (1 the first call of the run)",
+			Destination: &cfg.TraceSessions,
+		},
+		cli.StringFlag{
+			Name:        "redact",
+			EnvVar:      "RADGEN_REDACT",
+			Usage:       "mask these attributes, comma-separated dictionary names or numbers (e.g. \"Calling-Station-Id,User-Name\"), on the traces, dumps and detail file so they can be shared without the subscriber data",
+			Destination: &cfg.Redact,
+		},
+		cli.Float64Flag{
+			Name:        "send-loss",
+			EnvVar:      "RADGEN_SEND_LOSS",
+			Value:       0,
+			Usage:       "share (0-1) of the transmissions dropped before the wire, the request goes on the next retry (or times out when all of them are lost), to study the retry and duplicate handling without netem",
+			Destination: &cfg.SendLoss,
+		},
+		cli.IntFlag{
+			Name:        "send-jitter",
+			EnvVar:      "RADGEN_SEND_JITTER",
+			Value:       0,
+			Usage:       "random delay up to this many milliseconds added to the send time of each request",
+			Destination: &cfg.SendJitter,
+		},
+		cli.Float64Flag{
+			Name:        "bad-auth",
+			EnvVar:      "RADGEN_BAD_AUTH",
+			Value:       0,
+			Usage:       "share (0-1) of the requests sent once with a wrong Request Authenticator instead, to measure the cost of the servers checking and dropping them; an answer to one is warned of (UDP targets only)",
+			Destination: &cfg.BadAuth,
+		},
+		cli.StringFlag{
+			Name:        "bad-auth-mode",
+			EnvVar:      "RADGEN_BAD_AUTH_MODE",
+			Value:       gen.BadAuthSecret,
+			Usage:       "how the --bad-auth authenticators are wrong: secret (signed with another secret), random or zero",
+			Destination: &cfg.BadAuthMode,
+		},
+		cli.StringFlag{
+			Name:        "outage",
+			EnvVar:      "RADGEN_OUTAGE",
+			Usage:       "NAS reconnecting after an outage, after:duration of the run (e.g. 2m:30s): the requests due during the blackout are held and all sent at once when it is over with their Acct-Delay-Time, the storm the servers must absorb (see the nas-outage-storm profile)",
+			Destination: &cfg.Outage,
+		},
+		cli.StringFlag{
+			Name:        "crash-dir",
+			EnvVar:      "RADGEN_CRASH_DIR",
+			Value:       "./",
+			Usage:       "on panic or fatal error write a diagnostics bundle (config, log tail, stats, goroutines) to this directory, empty to disable",
+			Destination: &cfg.CrashDir,
+		},
+		cli.StringFlag{
+			Name:        "snapshot-dir",
+			EnvVar:      "RADGEN_SNAPSHOT_DIR",
+			Value:       "./",
+			Usage:       "directory of the snapshots of the stats taken without stopping the run, with POST /snapshot on the control API or snapshot on --interactive",
+			Destination: &cfg.SnapshotDir,
+		},
+		cli.BoolFlag{
+			Name:   "snapshot-profiles",
+			EnvVar: "RADGEN_SNAPSHOT_PROFILES",
+			Usage:  "add a goroutine dump and a heap profile (go tool pprof) to each snapshot",
+		},
+		cli.StringFlag{
+			Name:        "checkpoint",
+			EnvVar:      "RADGEN_CHECKPOINT",
+			Usage:       "save the progress of the run to this file, to continue it with --resume after a crash or reboot",
+			Destination: &cfg.Checkpoint,
+		},
+		cli.IntFlag{
+			Name:        "checkpoint-interval",
+			EnvVar:      "RADGEN_CHECKPOINT_INTERVAL",
+			Value:       10,
+			Usage:       "seconds between checkpoints",
+			Destination: &cfg.CheckpointS,
+		},
+		cli.BoolFlag{
+			Name:   "resume",
+			EnvVar: "RADGEN_RESUME",
+			Usage:  "continue the run saved on --checkpoint, sending only the requests left to --max-req and going on with the random draws of its --seed",
+		},
+		cli.StringFlag{
+			Name:        "custom-fields",
+			EnvVar:      "RADGEN_CUSTOM_FIELDS",
+			Value:       "",
+			Usage:       "--custom-fields \"ID=Value,ID=Value\"",
+			Destination: &cfg.CustomFields,
+		},
+		cli.IntFlag{
+			Name:        "max-memory",
+			EnvVar:      "RADGEN_MAX_MEMORY",
+			Value:       0,
+			Usage:       "max megabytes of in-flight accounting-requests before slow down the generation (zero means no limit)",
+			Destination: &cfg.MaxMemory,
+		},
+		cli.IntFlag{
+			Name:        "max-in-flight",
+			EnvVar:      "RADGEN_MAX_IN_FLIGHT",
+			Value:       0,
+			Usage:       "max accounting-requests in flight, a goroutine each, before slow down the generation (zero means no limit); a warning tells when the generator saturates on it, the stats show the queue depth",
+			Destination: &cfg.MaxInFlight,
+		},
+		cli.BoolFlag{
+			Name:   "shed",
+			EnvVar: "RADGEN_SHED",
+			Usage:  "drop accounting-requests instead of slow down when --max-memory or --max-in-flight is reached",
+		},
+		cli.StringFlag{
+			Name:        "pacer",
+			EnvVar:      "RADGEN_PACER",
+			Value:       pacer.Leaky,
+			Usage:       "pacing implementation: leaky (strict smoothing), token (token-bucket) or hybrid (smooth, bursts to catch up)",
+			Destination: &cfg.Pacer,
+		},
+		cli.IntFlag{
+			Name:        "burst",
+			EnvVar:      "RADGEN_BURST",
+			Value:       10,
+			Usage:       "max packets sent back-to-back by the token and hybrid pacers",
+			Destination: &cfg.Burst,
+		},
+		cli.StringFlag{
+			Name:        "pacing-jitter",
+			EnvVar:      "RADGEN_PACING_JITTER",
+			Usage:       "vary the gaps between packets at random by up to this share of the interval either way (e.g. 10% or 0.1), keeping the mean rate, so the servers don't get machine-gun regular traffic",
+			Destination: &cfg.PacingJitter,
+		},
+		cli.StringSliceFlag{
+			Name:   "plugin",
+			EnvVar: "RADGEN_PLUGIN",
+			Usage:  "Go plugin (.so) exporting BeforeSend and/or AfterResponse hooks func(*radius.Packet, *cdr.CdrValues) error, repeat to chain plugins",
+		},
+		cli.StringFlag{
+			Name:        "script",
+			EnvVar:      "RADGEN_SCRIPT",
+			Usage:       "Lua script with a mutate(attrs, cdr) function called on each packet before sending, after the plugins",
+			Destination: &cfg.Script,
+		},
+	}
+}
+
+// --key-file, --key-from, --new-key-file and --user, no secret on the
+// command line when dropping privileges
+func (cfg *Config) loadKeyFile() error {
+	if len(cfg.User) > 0 {
+		if len(cfg.Key) > 0 {
+			return fmt.Errorf("with user the key must come from key-file or key-from")
+		}
+		if len(cfg.NewKey) > 0 {
+			return fmt.Errorf("with user the new key must come from new-key-file")
+		}
+		targets, _ := target.ParseList(cfg.Servers, cfg.Port)
+		for _, t := range targets {
+			if t.Secret != nil {
+				return fmt.Errorf("with user the secret of %s must come from key-file or targets-file", t.Addr)
+			}
+		}
+	}
+	if len(cfg.NewKeyFile) > 0 {
+		if len(cfg.NewKey) > 0 {
+			return fmt.Errorf("new-key and new-key-file can't be used together")
+		}
+		key, err := privdrop.ReadKey(cfg.NewKeyFile)
+		if err != nil {
+			return err
+		}
+		cfg.NewKey = key
+	}
+	if len(cfg.KeyFrom) > 0 {
+		if len(cfg.Key) > 0 || len(cfg.KeyFile) > 0 {
+			return fmt.Errorf("key-from can't be used with key or key-file")
+		}
+		key, err := secret.Fetch(cfg.KeyFrom)
+		if err != nil {
+			return err
+		}
+		cfg.Key = key
+		return nil
+	}
+	if len(cfg.KeyFile) <= 0 {
+		return nil
+	}
+	if len(cfg.Key) > 0 {
+		return fmt.Errorf("key and key-file can't be used together")
+	}
+	key, err := privdrop.ReadKey(cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	cfg.Key = key
+	return nil
+}
+
+func (cfg *Config) instanceNameFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:        "instance-name",
+		EnvVar:      "RADGEN_INSTANCE_NAME",
+		Usage:       "name of this generator, its pid, log and stats files get the name (go-radius-gen-acct-NAME.*) on the instance dir, see the instances command",
+		Destination: &cfg.Instance,
+	}
+}
+
+// default pid and log files of --instance-name
+func (cfg *Config) instanceFiles(c *cli.Context) error {
+	if len(cfg.Instance) <= 0 {
+		return nil
+	}
+	if !instance.Valid(cfg.Instance) {
+		return cli.NewExitError("instance-name must have only letters, digits, _ . and -", 1)
+	}
+	if !c.IsSet("pid-file") {
+		cfg.PidFileName = instance.PidFile(cfg.InstanceDir, cfg.Instance)
+	}
+	if !c.IsSet("log-file") {
+		cfg.LogFileName = instance.LogFile(cfg.InstanceDir, cfg.Instance)
+	}
+	return nil
+}
+
+func (cfg *Config) instanceDirFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:        "instance-dir",
+		EnvVar:      "RADGEN_INSTANCE_DIR",
+		Value:       "./",
+		Usage:       "directory of the named generators files",
+		Destination: &cfg.InstanceDir,
+	}
+}
+
+func (cfg *Config) profilesFileFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:        "profiles-file",
+		EnvVar:      "RADGEN_PROFILES_FILE",
+		Usage:       "yaml file of user defined --profile traffic profiles, replacing the built-in ones of the same name",
+		Destination: &cfg.ProfilesFile,
+	}
+}
+
+func (cfg *Config) pidFileFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:        "pid-file",
+		EnvVar:      "RADGEN_PID_FILE",
+		Value:       "./go-radius-gen-acct.pid",
+		Usage:       "file to save the pid of daemon",
+		Destination: &cfg.PidFileName,
+	}
+}
+
+// validate the acct options, command is what main runs after parsing
+func (cfg *Config) AcctAction(command string, parsed *bool) cli.ActionFunc {
+	// options required
+	return func(c *cli.Context) error {
+		cfg.Command = command
+		if len(cfg.Profile) > 0 {
+			if err := applyProfile(c, cfg.Profile, cfg.ProfilesFile); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if cfg.PPS < pacer.MinRate {
+			return cli.NewExitError(fmt.Sprintf("pps must be at least %g", pacer.MinRate), 1)
+		}
+		cfg.Maps = c.StringSlice("map")
+		mapping, err := cdr.LoadMapping(cfg.MapFile, cfg.Maps)
+		if err != nil {
+			return cli.NewExitError("map: "+err.Error(), 1)
+		}
+		if name, _ := cdr.ParseSource(cfg.Source); (len(cfg.Maps) > 0 || len(cfg.MapFile) > 0) && name != cdr.CSVSource && name != cdr.JSONSource {
+			return cli.NewExitError("map and map-file need --source csv:file or json:file", 1)
+		}
+		if len(cfg.Maps) > 0 && len(cfg.MapFile) > 0 {
+			if err := mapping.Save(cfg.MapFile); err != nil {
+				return cli.NewExitError("map-file: "+err.Error(), 1)
+			}
+			log.Printf("map: %d columns saved to %s", len(mapping), cfg.MapFile)
+		}
+		// --source sipp:file is --sipp-csv file, random the generated calls
+		if name, arg := cdr.ParseSource(cfg.Source); name == cdr.SIPpSource {
+			if len(cfg.SIPpCSV) > 0 {
+				return cli.NewExitError("source sipp can't be used with sipp-csv", 1)
+			}
+			if len(arg) <= 0 {
+				return cli.NewExitError("source sipp needs the CSV file, sipp:file", 1)
+			}
+			cfg.SIPpCSV, cfg.Source = arg, ""
+		} else if name == cdr.RandomSource {
+			cfg.Source = ""
+		} else if len(cfg.SIPpCSV) > 0 {
+			return cli.NewExitError("source "+name+" can't be used with sipp-csv", 1)
+		} else if arg != cdr.Stdin {
+			// stdin is only read once, by the run
+			src, err := cdr.NewSource(name, arg, &cdr.Options{Mapping: mapping})
+			if err != nil {
+				return cli.NewExitError("source: "+err.Error(), 1)
+			}
+			if cs, ok := src.(cdr.ColumnSource); ok {
+				logColumns(cs.Columns())
+			}
+			if c, ok := src.(io.Closer); ok {
+				c.Close()
+			}
+		}
+		if c.Bool("c") {
+			cfg.ShowCount = true
+		}
+		if c.Bool("quiet") {
+			if cfg.ShowCount || c.Bool("functional") {
+				return cli.NewExitError("quiet can't be used with count or functional", 1)
+			}
+			cfg.Quiet = true
+			cfg.NoRequestErrors = true
+		}
+		if c.Bool("no-request-errors") {
+			cfg.NoRequestErrors = true
+		}
+		if c.Bool("d") {
+			if !daemonize.Supported {
+				return cli.NewExitError(daemonize.ErrNotSupported.Error(), 1)
+			}
+			cfg.Daemon = true
+		}
+		if c.Bool("shed") {
+			cfg.Shed = true
+		}
+		if c.Bool("legs") {
+			cfg.Legs = true
+		}
+		if c.Bool("lifecycle") {
+			cfg.Lifecycle = true
+		}
+		if c.Bool("acct-unique") {
+			cfg.AcctUnique = true
+		}
+		if cfg.SessionCollisions < 0 || cfg.SessionCollisions > 1 {
+			return cli.NewExitError("session-collisions must be between 0 and 1", 1)
+		}
+		if cfg.NASCount < 0 {
+			return cli.NewExitError("nas-count must be greater or equal 0", 1)
+		}
+		if (len(cfg.NASSecrets) > 0 || cfg.NASSourcePort != 0 || len(cfg.NASClockSkew) > 0 || len(cfg.NASRate) > 0) && cfg.NASCount <= 0 {
+			return cli.NewExitError("nas-secrets, nas-source-port, nas-clock-skew and nas-rate need --nas-count", 1)
+		}
+		if cfg.NASBurst <= 0 {
+			return cli.NewExitError("nas-burst must be greater 0", 1)
+		}
+		if _, err := gen.NewFleet(cfg.Config); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if _, err := gen.ParseRunIDAttr(cfg.RunIDAttr); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if _, err := gen.ParseChecksum(cfg.ChecksumAttr, cfg.ChecksumKey, cfg.ChecksumFields); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if len(cfg.ChecksumAttr) > 0 && cfg.ChecksumAttr == cfg.RunIDAttr {
+			return cli.NewExitError("checksum-attr must be another attribute than run-id-attr", 1)
+		}
+		if cfg.ExpectWithin < 0 {
+			return cli.NewExitError("expect-within must be greater or equal 0", 1)
+		}
+		if cfg.RcvBuf < 0 || cfg.SndBuf < 0 {
+			return cli.NewExitError("rcvbuf and sndbuf must be greater or equal 0", 1)
+		}
+		if len(cfg.CPUs) > 0 {
+			if _, err := cpupin.Parse(cfg.CPUs); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if cfg.SharedSockets < 0 {
+			return cli.NewExitError("shared-sockets must be greater or equal 0", 1)
+		}
+		if cfg.IDExhausted != mux.Block && cfg.IDExhausted != mux.Open {
+			return cli.NewExitError("id-exhausted must be block or open", 1)
+		}
+		if cfg.LateWindow < 0 {
+			return cli.NewExitError("late-window must be greater or equal 0", 1)
+		}
+		if cfg.LateWindow > 0 && cfg.SharedSockets <= 0 {
+			return cli.NewExitError("late-window needs shared-sockets, a socket per request is closed at its timeout", 1)
+		}
+		cfg.RadSecInsecure = c.Bool("radsec-insecure")
+		if _, err := gen.TLSConfig(cfg.Config); err != nil {
+			return cli.NewExitError("radsec: "+err.Error(), 1)
+		}
+		if cfg.SharedSockets > 0 && cfg.NASSourcePort != 0 {
+			return cli.NewExitError("shared-sockets and nas-source-port are mutually exclusive", 1)
+		}
+		if cfg.SendLoss < 0 || cfg.SendLoss > 1 {
+			return cli.NewExitError("send-loss must be between 0 and 1", 1)
+		}
+		if cfg.SendJitter < 0 {
+			return cli.NewExitError("send-jitter must be greater or equal 0", 1)
+		}
+		if cfg.BadAuth < 0 || cfg.BadAuth > 1 {
+			return cli.NewExitError("bad-auth must be between 0 and 1", 1)
+		}
+		if _, err := gen.ParseBadAuthMode(cfg.BadAuthMode); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if len(cfg.Outage) > 0 {
+			if _, err := gen.ParseOutage(cfg.Outage); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if len(cfg.TraceSessions) > 0 {
+			if _, err := gen.ParseTraceSessions(cfg.TraceSessions); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if _, err := dump.ParseRedaction(cfg.Redact); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if cfg.InterimInterval < 0 {
+			return cli.NewExitError("interim-interval must be greater or equal 0", 1)
+		}
+		if cfg.ReinviteRatio < 0 || cfg.ReinviteRatio > 1 {
+			return cli.NewExitError("reinvite-ratio must be between 0 and 1", 1)
+		}
+		disorder := []float64{cfg.OrphanStops, cfg.StopBeforeStart, cfg.InterimAfterStop}
+		var sum float64
+		for _, p := range disorder {
+			if p < 0 || p > 1 {
+				return cli.NewExitError("orphan-stops, stop-before-start and interim-after-stop must be between 0 and 1", 1)
+			}
+			sum += p
+		}
+		if sum > 1 {
+			return cli.NewExitError("orphan-stops, stop-before-start and interim-after-stop must add up to 1 at most", 1)
+		}
+		if c.Bool("close-sessions") {
+			if !cfg.Lifecycle {
+				return cli.NewExitError("close-sessions needs --lifecycle", 1)
+			}
+			cfg.CloseSessions = true
+		}
+		if len(cfg.ThinkTime) > 0 {
+			if !cfg.Lifecycle {
+				return cli.NewExitError("think-time needs --lifecycle", 1)
+			}
+			if cfg.CPS > 0 || cfg.Speed > 0 {
+				return cli.NewExitError("think-time can't be used with cps or speed, their records go at their times", 1)
+			}
+			if _, err := cdr.ParsePhase(cfg.ThinkTime); err != nil {
+				return cli.NewExitError("think-time: "+err.Error(), 1)
+			}
+		}
+		if (cfg.InterimInterval > 0 || cfg.ReinviteRatio > 0 || sum > 0) && !cfg.Lifecycle {
+			return cli.NewExitError("interim-interval, reinvite-ratio, orphan-stops, stop-before-start and interim-after-stop need --lifecycle", 1)
+		}
+		switch cfg.SessionType {
+		case gen.SIPSession:
+		case gen.DataSession:
+			if cfg.Legs || cfg.ReinviteRatio > 0 || len(cfg.SIPpCSV) > 0 {
+				return cli.NewExitError("data sessions can't be used with legs, reinvite-ratio or sipp-csv", 1)
+			}
+		default:
+			return cli.NewExitError("session-type must be "+gen.SIPSession+" or "+gen.DataSession, 1)
+		}
+		if c.Bool("proxy-state") {
+			cfg.ProxyState = true
+		}
+		if c.Bool("snapshot-profiles") {
+			cfg.SnapshotProfiles = true
+		}
+		if c.Bool("acct-on-off") {
+			cfg.AcctOnOff = true
+		}
+		if cfg.StormRatio < 0 || cfg.StormWindow <= 0 {
+			return cli.NewExitError("storm-ratio must be greater or equal 0 and storm-window greater 0", 1)
+		}
+		if c.Bool("storm-backoff") {
+			if cfg.StormRatio <= 0 || c.Bool("find-max") {
+				return cli.NewExitError("storm-backoff needs --storm-ratio and can't be used with find-max", 1)
+			}
+			cfg.StormBackoff = true
+		}
+		if c.Bool("adaptive") {
+			if c.Bool("find-max") || cfg.StormBackoff || cfg.CPS > 0 {
+				return cli.NewExitError("adaptive can't be used with find-max, storm-backoff or cps", 1)
+			}
+			if cfg.AdaptiveInterval <= 0 || cfg.AdaptiveStep < 0 {
+				return cli.NewExitError("adaptive-interval must be greater 0 and adaptive-step greater or equal 0", 1)
+			}
+			if cfg.AdaptiveBackoff <= 0 || cfg.AdaptiveBackoff >= 1 || cfg.AdaptiveRetransmits < 0 || cfg.AdaptiveRetransmits > 1 || cfg.AdaptiveP99 < 0 {
+				return cli.NewExitError("adaptive-backoff must be between 0 and 1 excluded, adaptive-retransmits between 0 and 1 and adaptive-p99 greater or equal 0", 1)
+			}
+			cfg.Adaptive = true
+		}
+		cfg.Schedule = c.StringSlice("schedule")
+		if windows, err := gen.ParseSchedule(cfg.Schedule); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		} else if len(windows) > 0 {
+			if c.Bool("find-max") || cfg.Adaptive || cfg.CPS > 0 {
+				return cli.NewExitError("schedule can't be used with find-max, adaptive or cps", 1)
+			}
+			if _, err := time.LoadLocation(cfg.ScheduleTZ); err != nil {
+				return cli.NewExitError("schedule-tz: "+err.Error(), 1)
+			}
+		}
+		cfg.SLOs = c.StringSlice("slo")
+		if _, err := gen.ParseSLOs(cfg.SLOs); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if cfg.SLOWindow <= 0 || cfg.SLOBurn <= 0 {
+			return cli.NewExitError("slo-window and slo-burn must be greater 0", 1)
+		}
+		if c.Bool("functional") {
+			if c.Bool("find-max") || cfg.Daemon {
+				return cli.NewExitError("functional can't be used with find-max or daemon", 1)
+			}
+			cfg.Functional = true
+			if !c.IsSet("max-req") {
+				cfg.MaxReq = 1
+			}
+			if !c.IsSet("pps") {
+				cfg.PPS = 1
+			}
+		}
+		if c.Bool("simulate") {
+			if c.Bool("find-max") || cfg.Daemon || c.Bool("wait-start") || c.Bool("functional") {
+				return cli.NewExitError("simulate can't be used with find-max, daemon, wait-start or functional", 1)
+			}
+			source, _ := cdr.ParseSource(cfg.Source)
+			if cfg.MaxReq == gen.MaxInt && len(cfg.SIPpCSV) <= 0 && (len(source) <= 0 || source == cdr.RandomSource) {
+				return cli.NewExitError("simulate needs max-req, sipp-csv or a finite source to end", 1)
+			}
+			cfg.Simulate = true
+		}
+		if cfg.SimulateLoss < 0 || cfg.SimulateLoss > 1 {
+			return cli.NewExitError("simulate-loss must be between 0 and 1", 1)
+		}
+		if c.Bool("find-max") {
+			if cfg.FindMaxSettle < 0 || cfg.FindMaxHold <= 0 {
+				return cli.NewExitError("find-max-settle must be greater or equal 0 and find-max-hold greater 0", 1)
+			}
+			if cfg.FindMaxErrorRate < 0 || cfg.FindMaxErrorRate > 1 || cfg.FindMaxP99 < 0 {
+				return cli.NewExitError("find-max-error-rate must be between 0 and 1 and find-max-p99 greater or equal 0", 1)
+			}
+			if c.IsSet("max-req") || len(cfg.SIPpCSV) > 0 || cfg.CPS > 0 {
+				return cli.NewExitError("find-max can't be used with max-req, sipp-csv or cps", 1)
+			}
+			cfg.FindMax = true
+		}
+		if err := cfg.instanceFiles(c); err != nil {
+			return err
+		}
+		if c.Bool("resume") {
+			if len(cfg.Checkpoint) <= 0 {
+				return cli.NewExitError("resume needs --checkpoint", 1)
+			}
+			cfg.Resume = true
+		}
+		if _, err := cdr.NewCallModel(cfg.SetupTime, cfg.RingTime, cfg.TalkTime); err != nil {
+			return cli.NewExitError("call model: "+err.Error(), 1)
+		}
+		if cfg.FailedRatio > 1 {
+			return cli.NewExitError("failed-ratio must be between 0 and 1", 1)
+		}
+		if _, err := cdr.ParseFailedCodes(cfg.FailedCodes); err != nil {
+			return cli.NewExitError("failed-codes: "+err.Error(), 1)
+		}
+		for name, spec := range map[string]string{
+			"from-tag-format": cfg.FromTagFormat,
+			"to-tag-format":   cfg.ToTagFormat,
+			"call-id-format":  cfg.CallIdFormat,
+		} {
+			if len(spec) <= 0 {
+				continue
+			}
+			if _, err := cdr.ParseFormat(spec); err != nil {
+				return cli.NewExitError(name+": "+err.Error(), 1)
+			}
+		}
+		if _, err := cdr.ParseMethods(cfg.Methods); err != nil {
+			return cli.NewExitError("methods: "+err.Error(), 1)
+		}
+		if _, err := cdr.ParseCardinality(cfg.Cardinality); err != nil {
+			return cli.NewExitError("cardinality: "+err.Error(), 1)
+		}
+		if len(cfg.SIPpCSV) > 0 {
+			f, err := os.Open(cfg.SIPpCSV)
+			if err != nil {
+				return cli.NewExitError("sipp-csv: "+err.Error(), 1)
+			}
+			_, err = cdr.NewSIPpReader(f, &cdr.Options{})
+			f.Close()
+			if err != nil {
+				return cli.NewExitError("sipp-csv: "+err.Error(), 1)
+			}
+		}
+		if len(cfg.From) > 0 || len(cfg.To) > 0 || cfg.Speed != 0 {
+			if len(cfg.SIPpCSV) <= 0 {
+				return cli.NewExitError("from, to and speed need --sipp-csv", 1)
+			}
+			if _, _, err := gen.ReplayWindow(cfg.Config); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if cfg.Speed < 0 {
+				return cli.NewExitError("speed must be greater 0", 1)
+			}
+			if cfg.Speed > 0 && cfg.CPS > 0 {
+				return cli.NewExitError("speed can't be used with cps, the calls arrive at their times", 1)
+			}
+		}
+		if cfg.CPS < 0 || cfg.Erlangs < 0 {
+			return cli.NewExitError("cps and erlangs must be greater or equal 0", 1)
+		}
+		if cfg.Erlangs > 0 {
+			if cfg.CPS <= 0 || !cfg.Lifecycle {
+				return cli.NewExitError("erlangs needs --cps and --lifecycle", 1)
+			}
+			if len(cfg.SIPpCSV) > 0 {
+				return cli.NewExitError("erlangs can't be used with sipp-csv, the talk times are the CSV ones", 1)
+			}
+		}
+		if cfg.CPS > 0 {
+			plan, err := gen.PlanCalls(cfg.Config)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if !c.IsSet("pps") {
+				cfg.PPS = math.Ceil(2 * plan.PPS)
+			}
+		}
+		if cfg.CheckpointS <= 0 {
+			return cli.NewExitError("checkpoint-interval must be greater 0", 1)
+		}
+		if c.Bool("container") {
+			if cfg.Daemon {
+				return cli.NewExitError("container can't run as daemon", 1)
+			}
+			cfg.Container = true
+			if len(cfg.Health) <= 0 {
+				cfg.Health = ":8081"
+			}
+		}
+		if c.Bool("interactive") {
+			if cfg.Daemon || cfg.Container {
+				return cli.NewExitError("interactive can't run as daemon or container", 1)
+			}
+			cfg.Interactive = true
+			// wait for start on the prompt
+			cfg.WaitStart = true
+		}
+		switch cfg.Summary {
+		case summary.Auto, summary.Always, summary.Never:
+		default:
+			return cli.NewExitError("summary must be auto, always or never", 1)
+		}
+		cfg.Outputs = c.StringSlice("output")
+		for _, spec := range cfg.Outputs {
+			if _, err := output.Lookup(spec); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if c.Bool("wait-start") {
+			if len(cfg.API) <= 0 && len(cfg.GRPC) <= 0 {
+				return cli.NewExitError("wait-start needs --api or --grpc", 1)
+			}
+			cfg.WaitStart = true
+		}
+		if _, err := cdr.ParseShard(cfg.Shard); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		cfg.Workers = c.StringSlice("worker")
+		if len(cfg.Workers) > 0 {
+			if len(cfg.Shard) > 0 {
+				return cli.NewExitError("shard is given to each worker by the coordinator", 1)
+			}
+			// the workers have their own servers
+			*parsed = true
+			return nil
+		}
+		cfg.Servers = c.StringSlice("server")
+		cfg.Plugins = c.StringSlice("plugin")
+		cfg.ExpectAttrs = c.StringSlice("expect-attr")
+		cfg.Bounds = c.StringSlice("bound")
+		if _, err := gen.ParseValueCheck(cfg.ValueCheck, cfg.Bounds); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if _, _, err := gen.ParseNASPortRange(cfg.NASPortRange); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		cfg.SessionClass = c.Bool("session-class")
+		cfg.CarryAttrs = c.StringSlice("carry-attr")
+		if carry, err := gen.ParseCarry(cfg.CarryAttrs); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		} else if class, _ := dump.Lookup("Class"); carry != nil && cfg.SessionClass && carry.Carries(class) {
+			return cli.NewExitError("session-class already carries the Class of the responses", 1)
+		}
+		cfg.Labels = c.StringSlice("label")
+		if _, err := gen.ParseLabels(cfg.Labels); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if _, err := gen.ParseSampleRate(cfg.SampleRate); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		cfg.Scenarios = c.StringSlice("scenario")
+		if len(cfg.Scenarios) > 0 {
+			if len(cfg.SIPpCSV) > 0 || cfg.Erlangs > 0 {
+				return cli.NewExitError("scenario can't be used with sipp-csv or erlangs", 1)
+			}
+			if _, err := gen.LoadScenarios(cfg.Scenarios, cfg.Config); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		if _, err := gen.NewExpect(cfg.Config); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if err := cfg.loadKeyFile(); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if len(cfg.NewKey) > 0 {
+			if _, err := gen.ParseRotation(cfg.NewKey, cfg.KeySwitch); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		} else if len(cfg.KeySwitch) > 0 {
+			return cli.NewExitError("key-switch needs --new-key", 1)
+		}
+		if len(cfg.RealmsFile) > 0 && (len(cfg.Servers) > 0 || len(cfg.SRV) > 0 || len(cfg.TargetsFile) > 0) {
+			return cli.NewExitError("realms-file can't be used with server, srv or targets-file", 1)
+		}
+		if len(cfg.Servers) <= 0 && len(cfg.SRV) <= 0 && len(cfg.TargetsFile) <= 0 && len(cfg.RealmsFile) <= 0 {
+			return cli.NewExitError("server not defined", 1)
+		}
+		if cfg.StickyKey != "session" && cfg.StickyKey != "caller" {
+			return cli.NewExitError("sticky-key must be session or caller", 1)
+		}
+		if cfg.SRVRefresh <= 0 {
+			return cli.NewExitError("srv-refresh must be greater 0", 1)
+		}
+		var targets []*target.Target
+		if len(cfg.RealmsFile) > 0 {
+			realms, err := target.LoadRealms(cfg.RealmsFile, cfg.Port, cfg.Policy)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			targets = target.RealmTargets(realms)
+		} else {
+			pool, err := gen.NewTargetPool(cfg.Config)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			targets = pool.Targets()
+		}
+		if len(cfg.Mirror) > 0 {
+			t, err := target.Parse(cfg.Mirror, cfg.Port)
+			if err != nil {
+				return cli.NewExitError("mirror: "+err.Error(), 1)
+			}
+			targets = append(targets, t)
+		}
+		if len(cfg.Shadow) > 0 {
+			t, err := target.Parse(cfg.Shadow, cfg.Port)
+			if err != nil {
+				return cli.NewExitError("shadow: "+err.Error(), 1)
+			}
+			targets = append(targets, t)
+		} else if len(cfg.ShadowReport) > 0 || cfg.ShadowLatencyDiff != 0 {
+			return cli.NewExitError("shadow-report and shadow-latency-diff need --shadow", 1)
+		}
+		if cfg.ShadowLatencyDiff < 0 {
+			return cli.NewExitError("shadow-latency-diff must be greater or equal 0", 1)
+		}
+		for _, t := range targets {
+			if len(t.Key([]byte(cfg.Key))) <= 0 {
+				return cli.NewExitError("key not defined for "+t.Addr, 1)
+			}
+		}
+		if cfg.MaxMemory < 0 || cfg.MaxInFlight < 0 {
+			return cli.NewExitError("max-memory and max-in-flight must be greater or equal 0", 1)
+		}
+		jitter, err := pacer.ParseJitter(cfg.PacingJitter)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if _, err := pacer.New(cfg.Pacer, cfg.PPS, cfg.Burst, jitter); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if cfg.DryRun < 0 {
+			return cli.NewExitError("dry-run must be greater 0", 1)
+		}
+		if len(cfg.EmitJSON) > 0 && (cfg.DryRun > 0 || c.Bool("simulate") || len(cfg.Workers) > 0) {
+			return cli.NewExitError("emit-json can't be used with dry-run, simulate or workers", 1)
+		}
+		if cfg.EmitJSON == cdr.Stdin && cfg.Container {
+			return cli.NewExitError("emit-json - can't be used with container, its log goes to stdout", 1)
+		}
+		if _, arg := cdr.ParseSource(cfg.Source); arg == cdr.Stdin && cfg.Interactive {
+			return cli.NewExitError("a source on stdin can't be used with interactive", 1)
+		}
+		if cfg.ProxyHops <= 0 {
+			return cli.NewExitError("proxy-hops must be greater 0", 1)
+		}
+		if cfg.LogMaxSize < 0 || cfg.LogMaxAge < 0 || cfg.LogBackups < 0 {
+			return cli.NewExitError("log-max-size, log-max-age and log-backups must be greater or equal 0", 1)
+		}
+		*parsed = true
+		return nil
+	}
+}
+
+func LogStats(wg *sync.WaitGroup, done <-chan struct{}, cfg Config, r *gen.Generator) {
+	defer wg.Done()
+	c := cfg
+	t := &r.Counters
+	for finished := false; !finished; {
+		countTotalS := atomic.LoadUint64(&t.Total)
+		select {
+		case <-done:
+			finished = true
+		case <-time.After(1000 * time.Millisecond):
+		}
+		// -c count option
+		// I hope the compiler solve this if
+		if c.ShowCount {
+			log.Print("")
+			log.Print("Stats [refresh 1s]:")
+			log.Print("estimated accounting-request per second:  ", atomic.LoadUint64(&t.Total)-countTotalS)
+			log.Print("total count accounting-request:           ", atomic.LoadUint64(&t.Total))
+			if c.MaxMemory > 0 {
+				log.Print("in-flight accounting-request bytes:       ", r.InFlight.Bytes())
+			}
+			if c.MaxInFlight > 0 {
+				n, waits, depth := r.InFlight.Requests(r.Pacer.Rate())
+				log.Print("in-flight accounting-request:             ", n, " of ", c.MaxInFlight, " (queue depth ", depth, ", ", waits, " waits, ", runtime.NumGoroutine(), " goroutines)")
+			}
+			if c.MaxMemory > 0 || c.MaxInFlight > 0 {
+				log.Print("shed accounting-request:                  ", atomic.LoadUint64(&t.Shed))
+			}
+			if c.SessionCollisions > 0 {
+				log.Print("calls with a reused session id:           ", atomic.LoadUint64(&t.Collisions))
+			}
+			if len(r.Pool.Targets()) > 1 {
+				for _, tg := range r.Pool.Targets() {
+					log.Print("  ", tg.Addr, " accounting-request: ", atomic.LoadUint64(&tg.Sent),
+						" accounting-response: ", atomic.LoadUint64(&tg.Acked))
+				}
+			}
+			for _, sc := range r.Scenarios {
+				log.Print("  scenario ", sc.Name, " calls: ", atomic.LoadUint64(&sc.Calls),
+					" accounting-request: ", atomic.LoadUint64(&sc.Sent),
+					" accounting-response: ", atomic.LoadUint64(&sc.Acked),
+					" avg latency: ", sc.AvgLatency())
+			}
+			if r.Mirror != nil {
+				log.Print("mirrored accounting-request:              ", atomic.LoadUint64(&r.Mirror.Sent),
+					" (", atomic.LoadUint64(&r.Mirror.Errors), " failed)")
+			}
+			if r.Rotation != nil {
+				log.Print("old secret accounting-request:            ", atomic.LoadUint64(&r.Rotation.OldSent),
+					" accounting-response: ", atomic.LoadUint64(&r.Rotation.OldAcked))
+				log.Print("new secret accounting-request:            ", atomic.LoadUint64(&r.Rotation.NewSent),
+					" accounting-response: ", atomic.LoadUint64(&r.Rotation.NewAcked))
+			}
+			if c.ExpectWithin > 0 || len(c.ExpectAttrs) > 0 {
+				log.Print("failed the expectations:                  ", atomic.LoadUint64(&t.ExpectFailed))
+				misses := r.ExpectMisses()
+				attrs := make([]string, 0, len(misses))
+				for a := range misses {
+					attrs = append(attrs, a)
+				}
+				sort.Strings(attrs)
+				for _, a := range attrs {
+					log.Print("  accounting-response without ", a, ": ", misses[a])
+				}
+			}
+			if c.StormRatio > 0 {
+				var retransmits uint64
+				for _, tg := range r.Pool.Targets() {
+					retransmits += atomic.LoadUint64(&tg.Retransmits)
+				}
+				log.Print("retransmitted accounting-request:         ", retransmits, " (", atomic.LoadUint64(&t.Storms), " retry storms)")
+			}
+			if b := r.Budget.Stats(); b != nil {
+				log.Printf("latency budget (avg of %d answered):     queue %.2fms, wait %.2fms, retry %.2fms", b.Answered, b.QueueMs, b.WaitMs, b.RetryMs)
+			}
+			for _, o := range r.SLOs() {
+				log.Printf("SLO %s: %d of %d requests missed it, burn rate %.2f (%.0f%% of the error budget), %d warnings", o.SLO, o.Bad, o.Good+o.Bad, o.BurnRate, o.BurnRate*100, o.Alerts)
+			}
+			if carried := r.Carried(); carried > 0 {
+				log.Print("requests with response attributes carried: ", carried)
+			}
+			rejects := r.Rejects.Counts()
+			for _, k := range gen.RejectKinds(rejects) {
+				log.Print("rejected by ", k, ": ", rejects[k])
+			}
+			if c.SharedSockets > 0 {
+				exhausted, open := r.Sockets.Exhausted()
+				log.Print("identifier space exhausted:               ", exhausted, " (", open, " sockets open)")
+			}
+			for _, tg := range r.Pool.Targets() {
+				if !tg.Stream() && c.SharedSockets <= 0 {
+					continue
+				}
+				late, stray := r.Sockets.Replies(tg)
+				var unanswered, never uint64
+				if sent, acked := atomic.LoadUint64(&tg.Sent), atomic.LoadUint64(&tg.Acked); sent > acked {
+					unanswered = sent - acked
+				}
+				if unanswered > late {
+					never = unanswered - late
+				}
+				if unanswered > 0 || stray > 0 {
+					log.Print("  ", tg.Addr, " unanswered: ", unanswered, " (answered late: ", late, ", never: ", never, ") stray responses: ", stray)
+				}
+			}
+			if len(c.NASRate) > 0 {
+				// the chattiest and the quietest NAS
+				fleet := r.Fleet()
+				if len(fleet) > 6 {
+					fleet = append(fleet[:3], fleet[len(fleet)-3:]...)
+				}
+				for _, n := range fleet {
+					sent := atomic.LoadUint64(&n.Sent)
+					var waited time.Duration
+					if sent > 0 {
+						waited = time.Duration(atomic.LoadInt64(&n.Waited) / int64(sent))
+					}
+					log.Printf("  %s (%s) rate limit: %.2f/s sent: %d avg wait: %s", n.Identifier, n.IP, n.Rate, sent, waited)
+				}
+			}
+			if c.SendLoss > 0 {
+				var lost uint64
+				for _, tg := range r.Pool.Targets() {
+					lost += atomic.LoadUint64(&tg.Lost)
+				}
+				log.Print("transmissions lost before the wire:       ", lost)
+			}
+			if c.BadAuth > 0 {
+				log.Print("requests with a bad authenticator:        ", atomic.LoadUint64(&t.BadAuth), " (", atomic.LoadUint64(&t.BadAuthAnswered), " answered)")
+			}
+			transports := make(map[string]bool)
+			for _, tg := range r.Pool.Targets() {
+				transports[tg.TransportName()] = true
+			}
+			if len(transports) > 1 {
+				for _, tg := range r.Pool.Targets() {
+					log.Print("  ", tg.Addr, " (", tg.TransportName(), ") sent: ", atomic.LoadUint64(&tg.Sent),
+						" acked: ", atomic.LoadUint64(&tg.Acked), " avg latency: ", tg.AvgLatency())
+				}
+			}
+			for _, tg := range r.Pool.Targets() {
+				port, other := atomic.LoadUint64(&tg.ICMP.PortUnreachable), atomic.LoadUint64(&tg.ICMP.Unreachable)
+				if port > 0 || other > 0 {
+					log.Print("  ", tg.Addr, " icmp port unreachable: ", port, " host unreachable or prohibited: ", other)
+				}
+			}
+			if c.ProxyState {
+				for _, tg := range r.Pool.Targets() {
+					avg := tg.AvgLatency()
+					log.Print("  ", tg.Addr, " proxy-state mismatch: ", atomic.LoadUint64(&tg.ProxyStateMismatch),
+						" avg latency: ", avg, " per hop: ", avg/time.Duration(c.ProxyHops))
+				}
+			}
+		}
+	}
+}
+
+// coordinator mode, split the load plan across the --worker generators
+// and aggregate their stats instead of sending from this host
+func RunCoordinator(cfg Config) error {
+	co := control.NewCoordinator(cfg.Workers)
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Print("seed: ", seed)
+	if err := co.Start(cfg.PPS, cfg.MaxReq, cfg.MaxReq == gen.MaxInt, seed); err != nil {
+		co.Stop()
+		return err
+	}
+	var last uint64
+	for {
+		time.Sleep(1000 * time.Millisecond)
+		s, err := co.Stats()
+		if err != nil {
+			return err
+		}
+		if cfg.ShowCount {
+			log.Print("")
+			log.Print("Stats [refresh 1s, ", len(cfg.Workers), " workers]:")
+			log.Print("estimated accounting-request per second:  ", s.Total-last)
+			log.Print("total count accounting-request:           ", s.Total)
+		}
+		last = s.Total
+		if s.State == control.Stopped {
+			break
+		}
+	}
+	report, err := co.Report()
+	if err != nil {
+		return err
+	}
+	b, _ := json.Marshal(report)
+	log.Print("report: ", string(b))
+	return nil
+}
+
+// server command, answer Accounting-Requests until killed
+func RunMockServer(cfg Config) error {
+	srv := mockserver.New(mockserver.Config{
+		Addr:      cfg.Mock.Listen,
+		Secret:    []byte(cfg.Key),
+		Latency:   time.Duration(cfg.Mock.Latency) * time.Millisecond,
+		Loss:      cfg.Mock.Loss,
+		NAK:       cfg.Mock.NAK,
+		Delay:     cfg.Mock.Delay,
+		DelayDist: cfg.Mock.DelayDist,
+		DelayMean: time.Duration(cfg.Mock.DelayMean) * time.Millisecond,
+		Duplicate: cfg.Mock.Duplicate,
+		Corrupt:   cfg.Mock.Corrupt,
+	})
+	if cfg.ShowCount {
+		go func() {
+			var last uint64
+			for {
+				time.Sleep(1000 * time.Millisecond)
+				received := atomic.LoadUint64(&srv.Received)
+				log.Print("")
+				log.Print("Stats [refresh 1s]:")
+				log.Print("accounting-request per second:  ", received-last)
+				log.Print("received accounting-request:    ", received)
+				log.Print("answered accounting-response:   ", atomic.LoadUint64(&srv.Answered))
+				log.Print("dropped accounting-request:     ", atomic.LoadUint64(&srv.Dropped))
+				log.Print("nak accounting-response:        ", atomic.LoadUint64(&srv.NAKed))
+				log.Print("delayed accounting-response:    ", atomic.LoadUint64(&srv.Delayed))
+				log.Print("duplicated accounting-response: ", atomic.LoadUint64(&srv.Duplicated))
+				log.Print("corrupted accounting-response:  ", atomic.LoadUint64(&srv.Corrupted))
+				log.Print("invalid request:                ", atomic.LoadUint64(&srv.Invalid))
+				last = received
+			}
+		}()
+	}
+	log.Print("mock server listening on ", cfg.Mock.Listen)
+	return srv.ListenAndServe()
+}
+
+// reconcile the --export of a run with the accounting database
+func Verify(cfg Config) (*verify.Report, error) {
+	emitted, err := export.Load(cfg.Export)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Verify.Unique {
+		for i := range emitted {
+			if len(emitted[i].AcctUniqueId) <= 0 {
+				return nil, fmt.Errorf("%s: no Acct-Unique-Session-Id, run acct with --acct-unique", cfg.Export)
+			}
+			emitted[i].AcctSessionId = emitted[i].AcctUniqueId
+		}
+	}
+	late := time.Second * time.Duration(cfg.Verify.Late)
+	if len(cfg.Verify.Detail) > 0 {
+		r, err := verify.OpenDetail(cfg.Verify.Detail)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		var sums *verify.Checksums
+		if len(cfg.Verify.ChecksumAttr) > 0 {
+			fields, err := checksum.ParseAttrs(cfg.ChecksumFields)
+			if err != nil {
+				return nil, err
+			}
+			sums = &verify.Checksums{Attr: cfg.Verify.ChecksumAttr, Key: []byte(cfg.ChecksumKey), Fields: fields}
+		}
+		stored, err := verify.ReadDetail(r, cfg.Verify.DetailAttr, sums.Attrs()...)
+		if err != nil {
+			return nil, err
+		}
+		rep := verify.Reconcile(emitted, stored, late)
+		if sums != nil {
+			rep.CheckSums(stored, *sums)
+		}
+		return rep, nil
+	}
+	db, err := sql.Open(cfg.Verify.Driver, cfg.Verify.DSN)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	query := cfg.Verify.Query
+	if len(query) <= 0 {
+		query = verify.Query(cfg.Verify.Table, cfg.Verify.SessionColumn, cfg.Verify.TimeColumn)
+	}
+	stored, err := verify.Fetch(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return verify.Reconcile(emitted, stored, late), nil
+}
+
+// print the report (or the live stats) of a running generator
+func Report(cfg Config) error {
+	path := "/report"
+	if cfg.ReportLive {
+		path = "/stats"
+	}
+	u := cfg.API
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+	resp, err := http.Get(u + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+// write the stats of a named generator every second, and the final report
+// once done is closed
+func WriteInstanceStats(wg *sync.WaitGroup, done <-chan struct{}, cfg Config, r *gen.Generator) {
+	defer wg.Done()
+	path := instance.StatsFile(cfg.InstanceDir, cfg.Instance)
+	for {
+		select {
+		case <-done:
+			if report := r.Report(); report != nil {
+				if err := instance.WriteStats(path, *report); err != nil {
+					log.Print("instance stats: ", err)
+				}
+			}
+			return
+		case <-time.After(1000 * time.Millisecond):
+		}
+		if err := instance.WriteStats(path, r.Stats()); err != nil {
+			log.Print("instance stats: ", err)
+		}
+	}
+}
+
+// sample the rate every second for the --summary sparkline until done is
+// closed
+func SampleRate(wg *sync.WaitGroup, done <-chan struct{}, r *gen.Generator, rates *summary.Series) {
+	defer wg.Done()
+	last := atomic.LoadUint64(&r.Counters.Total)
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(1000 * time.Millisecond):
+		}
+		total := atomic.LoadUint64(&r.Counters.Total)
+		rates.Add(float64(total - last))
+		last = total
+	}
+}
+
+// save the progress every --checkpoint-interval until done is closed
+func WriteCheckpoint(wg *sync.WaitGroup, done <-chan struct{}, cfg Config, r *gen.Generator, resumed checkpoint.State) {
+	defer wg.Done()
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Second * time.Duration(cfg.CheckpointS)):
+		}
+		state := resumed
+		state.Sent += atomic.LoadUint64(&r.Counters.Total)
+		state.Seed, state.Draws = r.SeedState()
+		if err := checkpoint.Save(cfg.Checkpoint, state); err != nil {
+			log.Print("checkpoint: ", err)
+		}
+	}
+}
+
+// instances command, one line per named generator and their sum
+func ListInstances(cfg Config) error {
+	list, err := instance.List(cfg.InstanceDir)
+	if err != nil {
+		return err
+	}
+	if len(list) <= 0 {
+		fmt.Println("no instances on", cfg.InstanceDir)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tPPS\tTOTAL\tSHED\tELAPSED\tLABELS")
+	var all []control.Stats
+	for _, i := range list {
+		state := i.Stats.State
+		if i.Stale() {
+			state += " (stale)"
+		} else {
+			all = append(all, i.Stats)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%g\t%d\t%d\t%.0fs\t%s\n", i.Name, state, i.Stats.PPS, i.Stats.Total, i.Stats.Shed, i.Stats.Elapsed, gen.FormatLabels(i.Stats.Labels))
+	}
+	agg := control.Aggregate(all)
+	fmt.Fprintf(w, "%s\t%s\t%g\t%d\t%d\t%.0fs\t%s\n", "TOTAL", agg.State, agg.PPS, agg.Total, agg.Shed, agg.Elapsed, gen.FormatLabels(agg.Labels))
+	w.Flush()
+	for _, t := range agg.Targets {
+		fmt.Printf("  %s accounting-request: %d accounting-response: %d avg latency: %.2fms\n", t.Addr, t.Sent, t.Acked, t.AvgLatencyMs)
+	}
+	return nil
+}
+
+// batch command, true when every run succeeded; SIGTERM and SIGINT stop
+// the run in progress, which still writes its report, and the batch
+func RunBatch(cfg Config) (bool, error) {
+	f, err := batch.Load(cfg.Batch.Runs)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(cfg.Batch.Dir, 0755); err != nil {
+		return false, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-stop
+		log.Print("batch: stopping the run in progress and the batch")
+		cancel()
+	}()
+	results := f.Execute(ctx, self, cfg.Batch.Dir, os.Stderr)
+	batch.Fprint(os.Stdout, results)
+	b, err := json.MarshalIndent(batch.Reports(results), "", "  ")
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(cfg.Batch.Dir, "go-radius-gen-acct-batch.json")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return false, err
+	}
+	log.Print("batch: report written to ", path)
+	return batch.OK(results) && len(results) == len(f.Runs), nil
+}
+
+// build and sign the packets of the bench cases, printing their rates
+func RunBench(cfg Config) error {
+	bcfg := gen.DefaultConfig()
+	bcfg.Key = cfg.Bench.Key
+	cases, err := gen.BenchCases(bcfg, cfg.Bench.SessionTypes, cfg.Bench.Extra, cfg.Bench.Custom)
+	if err != nil {
+		return err
+	}
+	d := time.Second * time.Duration(cfg.Bench.Duration)
+	log.Printf("bench: %d cases of %v on %d workers", len(cases), d, cfg.Bench.Workers)
+	results, err := gen.Bench(bcfg, cases, d, cfg.Bench.Workers)
+	if err != nil {
+		return err
+	}
+	gen.FprintBench(os.Stdout, results, cfg.Bench.Workers)
+	return nil
+}
+
+// raise the open files limit to the sockets the run may hold, instead of
+// failing later with "too many open files"
+// tell how the columns of a CDR export are sent, the mapping to check
+// before a replay
+func logColumns(mapped map[string]string, unmapped []string) {
+	columns := make([]string, 0, len(mapped))
+	for column := range mapped {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		log.Printf("map: %s -> %s", column, mapped[column])
+	}
+	if len(unmapped) > 0 {
+		log.Printf("map: not sent: %s", strings.Join(unmapped, ", "))
+	}
+}
+
+func raiseOpenFiles(cfg Config) {
+	// listeners, log and pid files, stdio
+	const reserved = 64
+	need := gen.MaxSockets(cfg.Config) + reserved
+	limit, err := rlimit.Raise(need)
+	if err == rlimit.ErrNotSupported {
+		return
+	}
+	if err != nil {
+		log.Print("warning: unable to raise the open files limit: ", err)
+	}
+	if limit < need {
+		log.Print("warning: open files limit is ", limit, ", a run at ", cfg.PPS, " pps with retry-int ", cfg.Retry,
+			"s * max-retry ", cfg.MaxRetry, " may hold ", need, " sockets; unanswered requests will fail with",
+			" \"too many open files\", raise it with ulimit -n, LimitNOFILE= or run as root")
+	}
+}
+
+// warn when the kernel gives the sockets smaller buffers than asked
+func checkSocketBuffers(cfg Config) {
+	if cfg.RcvBuf <= 0 && cfg.SndBuf <= 0 {
+		return
+	}
+	rcv, snd, err := sockbuf.Check(cfg.RcvBuf, cfg.SndBuf)
+	if err == sockbuf.ErrNotSupported {
+		return
+	}
+	if err != nil {
+		log.Print("warning: unable to check the socket buffers: ", err)
+		return
+	}
+	if rcv < cfg.RcvBuf {
+		log.Print("warning: the kernel clamped --rcvbuf ", cfg.RcvBuf, " to ", rcv, ", raise it with sysctl net.core.rmem_max")
+	}
+	if snd < cfg.SndBuf {
+		log.Print("warning: the kernel clamped --sndbuf ", cfg.SndBuf, " to ", snd, ", raise it with sysctl net.core.wmem_max")
+	}
+}
+
+// options without the secrets, for the crash bundle
+func (cfg Config) Redacted() Config {
+	if len(cfg.Key) > 0 {
+		cfg.Key = "REDACTED"
+	}
+	if len(cfg.Verify.DSN) > 0 {
+		cfg.Verify.DSN = "REDACTED"
+	}
+	if len(cfg.NASSecrets) > 0 {
+		cfg.NASSecrets = "REDACTED"
+	}
+	if len(cfg.NewKey) > 0 {
+		cfg.NewKey = "REDACTED"
+	}
+	if len(cfg.ChecksumKey) > 0 {
+		cfg.ChecksumKey = "REDACTED"
+	}
+	targets, _ := target.ParseList(cfg.Servers, cfg.Port)
+	cfg.Servers = nil
+	for _, t := range targets {
+		if len(t.Transport) > 0 {
+			cfg.Servers = append(cfg.Servers, t.Addr+";transport="+t.Transport)
+			continue
+		}
+		cfg.Servers = append(cfg.Servers, t.Addr)
+	}
+	return cfg
+}
+
+// config validate, load the plugins, script, custom fields, targets and
+// SIPp CSV of the run as it would, printing the problems; false on errors
+func Validate(cfg Config) bool {
+	ok := true
+	var problems []gen.Problem
+	var cb gen.Callbacks
+	for _, p := range cfg.Plugins {
+		if err := gen.LoadPlugin(p, &cb); err != nil {
+			problems = append(problems, gen.Problem{Msg: err.Error()})
+		}
+	}
+	if len(cfg.Script) > 0 {
+		sc, err := script.Load(cfg.Script)
+		if err != nil {
+			problems = append(problems, gen.Problem{Msg: err.Error()})
+		} else {
+			sc.Close()
+		}
+	}
+	if len(cfg.RunID) <= 0 {
+		cfg.RunID = gen.NewRunID()
+	}
+	if run, err := gen.New(cfg.Config, cb); err != nil {
+		problems = append(problems, gen.Problem{Msg: err.Error()})
+	} else {
+		problems = append(problems, run.Lint()...)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// where the log goes, the rotated log file on daemon mode
+var logOut io.Writer = os.Stderr
+
+func main() {
+	cfg := CliConfig()
+
+	switch cfg.Command {
+	case CommandCompletion:
+		return
+	case CommandValidate:
+		if !Validate(cfg) {
+			os.Exit(1)
+		}
+		fmt.Println("configuration ok")
+		return
+	case CommandReport:
+		if err := Report(cfg); err != nil {
+			log.Fatal("report: ", err)
+		}
+		return
+	case CommandServer:
+		log.Fatal("server: ", RunMockServer(cfg))
+	case CommandStop:
+		pid, err := pidfile.Signal(cfg.PidFileName, syscall.SIGTERM)
+		if err == nil {
+			err = pidfile.Wait(cfg.PidFileName, time.Second*time.Duration(cfg.StopTimeout))
+		}
+		if err != nil {
+			log.Fatal("stop: ", err)
+		}
+		fmt.Println("stopped, pid", pid)
+		return
+	case CommandStatus:
+		pid, running, err := pidfile.Check(cfg.PidFileName)
+		switch {
+		case err != nil:
+			log.Fatal("status: ", err)
+		case running:
+			fmt.Println("running, pid", pid)
+		case pid > 0:
+			// LSB status codes
+			fmt.Println("not running, stale pid file with pid", pid)
+			os.Exit(1)
+		default:
+			fmt.Println("not running")
+			os.Exit(3)
+		}
+		return
+	case CommandVerify:
+		report, err := Verify(cfg)
+		if err != nil {
+			log.Fatal("verify: ", err)
+		}
+		report.Fprint(os.Stdout, cfg.Verify.Show)
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return
+	case CommandProfiles:
+		if err := ListProfiles(cfg); err != nil {
+			log.Fatal("profiles: ", err)
+		}
+		return
+	case CommandList:
+		if err := ListInstances(cfg); err != nil {
+			log.Fatal("instances: ", err)
+		}
+		return
+	case CommandBatch:
+		ok, err := RunBatch(cfg)
+		if err != nil {
+			log.Fatal("batch: ", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	case CommandBench:
+		if err := RunBench(cfg); err != nil {
+			log.Fatal("bench: ", err)
+		}
+		return
+	case CommandReload:
+		pid, err := pidfile.Signal(cfg.PidFileName, syscall.SIGHUP)
+		if err != nil {
+			log.Fatal("reload: ", err)
+		}
+		fmt.Println("reload sent, pid", pid)
+		return
+	}
+
+	if cfg.Daemon {
+		cntxt := &daemonize.Context{
+			PidFileName: cfg.PidFileName,
+			LogFileName: cfg.LogFileName,
+		}
+		parent, release, err := cntxt.Reborn()
+		if err == daemonize.ErrRunning {
+			log.Fatal("Unable to run: already running, pid file ", cfg.PidFileName, " is locked")
+		}
+		if err != nil {
+			log.Fatal("Unable to run: ", err)
+		}
+		if parent {
+			return
+		}
+		defer release()
+		// stderr stays on the log file for panics, the log goes through
+		// the rotated one
+		lf, err := logfile.Open(cfg.LogFileName, 0640)
+		if err != nil {
+			log.Fatal("Unable to run: ", err)
+		}
+		defer lf.Close()
+		lf.MaxSize = int64(cfg.LogMaxSize) << 20
+		lf.MaxAge = time.Hour * time.Duration(cfg.LogMaxAge)
+		lf.MaxBackups = cfg.LogBackups
+		logfile.HandleReopenSignal(lf)
+		logOut = lf
+		log.SetOutput(logOut)
+		// reload only re-reads --targets-file/--srv, don't die without them
+		signal.Ignore(syscall.SIGHUP)
+		log.Print("daemon started")
+	}
+
+	if cfg.Container {
+		logOut = os.Stdout
+		log.SetOutput(logOut)
+	}
+
+	if len(cfg.Workers) > 0 {
+		if err := RunCoordinator(cfg); err != nil {
+			log.Fatal("coordinator: ", err)
+		}
+		return
+	}
+
+	var cb gen.Callbacks
+	for _, p := range cfg.Plugins {
+		if err := gen.LoadPlugin(p, &cb); err != nil {
+			log.Fatal("Unable to run: ", err)
+		}
+	}
+	if len(cfg.Script) > 0 {
+		sc, err := script.Load(cfg.Script)
+		if err != nil {
+			log.Fatal("Unable to run: ", err)
+		}
+		defer sc.Close()
+		cb.BeforeSend = append(cb.BeforeSend, sc.Hook)
+	}
+	// last log lines, for the web UI and the crash bundle
+	tail := control.NewLogTail(200)
+	log.SetOutput(io.MultiWriter(logOut, tail))
+
+	// requests sent before the resume, of the whole max-req
+	resumed := checkpoint.State{MaxReq: cfg.MaxReq}
+	// state of the binary this one replaced (SIGUSR2), nil when none
+	var handoff *gen.Handoff
+	if cfg.Resume {
+		state, err := checkpoint.Load(cfg.Checkpoint)
+		if err != nil {
+			log.Fatal("resume: ", err)
+		}
+		switch {
+		case state == nil:
+			log.Print("resume: no checkpoint on ", cfg.Checkpoint, ", starting from the beginning")
+		case state.Done:
+			log.Print("resume: the run of ", cfg.Checkpoint, " is already finished")
+			return
+		default:
+			resumed.Sent = state.Sent
+			if cfg.MaxReq != gen.MaxInt {
+				cfg.MaxReq -= int(state.Sent)
+				if cfg.MaxReq < 0 {
+					cfg.MaxReq = 0
+				}
+			}
+			// calls of the SIPp CSV already sent, two records each with --legs
+			cfg.SIPpSkip = int(state.Sent)
+			if cfg.Legs {
+				cfg.SIPpSkip /= 2
+			}
+			if cfg.Lifecycle && len(cfg.SIPpCSV) > 0 {
+				// the records per call vary, the sent calls are unknown
+				log.Print("resume: --lifecycle replays the SIPp CSV from the first call")
+				cfg.SIPpSkip = 0
+			}
+			if len(state.RunID) > 0 && len(cfg.RunID) <= 0 {
+				cfg.RunID = state.RunID
+			}
+			if state.Seed != 0 && (cfg.Seed == 0 || cfg.Seed == state.Seed) {
+				cfg.Seed = state.Seed
+				cfg.SeedSkip = state.Draws
+				log.Print("resume: seed ", cfg.Seed, ", skipping its first ", state.Draws, " draws")
+			}
+			if handoff, err = gen.LoadHandoff(gen.HandoffPath(cfg.Checkpoint)); err != nil {
+				log.Fatal("resume: ", err)
+			}
+			if handoff != nil {
+				// the handed counters go on, their Total included
+				resumed.Sent -= handoff.Counters.Total
+			}
+			log.Print("resume: ", state.Sent, " requests already sent, ", cfg.MaxReq, " left")
+		}
+	}
+	if len(cfg.RunID) <= 0 {
+		cfg.RunID = gen.NewRunID()
+	}
+	resumed.RunID = cfg.RunID
+	if attr, _ := gen.ParseRunIDAttr(cfg.RunIDAttr); attr != nil {
+		log.Print("run id: ", cfg.RunID)
+	}
+	if cfg.CPS > 0 {
+		plan, _ := gen.PlanCalls(cfg.Config)
+		log.Printf("%g cps: mean talk time %s, %.1f records per call, %.1f pps (capped at %g)", cfg.CPS, plan.HoldTime, plan.Records, plan.PPS, cfg.PPS)
+	}
+	rep := &crash.Reporter{
+		Dir:    cfg.CrashDir,
+		Name:   "go-radius-gen-acct",
+		Config: cfg.Redacted(),
+		Log:    tail.Lines,
+	}
+	if len(cfg.Instance) > 0 {
+		rep.Name += "-" + cfg.Instance
+	}
+	defer rep.Recover()
+	cb.OnPanic = rep.Panic
+	run, err := gen.New(cfg.Config, cb)
+	if err != nil {
+		log.Fatal("Unable to run: ", err)
+	}
+	rep.Stats = func() interface{} { return run.Stats() }
+	run.Control.SnapshotFunc = func() ([]string, error) {
+		files, err := rep.Snapshot(cfg.SnapshotDir, cfg.SnapshotProfiles)
+		if err != nil {
+			log.Print("snapshot: ", err)
+		} else {
+			log.Print("snapshot written to ", strings.Join(files, ", "))
+		}
+		return files, err
+	}
+	if handoff != nil {
+		run.TakeOver(*handoff)
+		log.Print("upgrade: took over the counters and ", len(handoff.Records), " records of the calls begun")
+	}
+	if len(cfg.EmitJSON) > 0 {
+		out := os.Stdout
+		if cfg.EmitJSON != cdr.Stdin {
+			if out, err = os.Create(cfg.EmitJSON); err != nil {
+				log.Fatal("emit-json: ", err)
+			}
+		}
+		if err := run.Emit(out); err != nil {
+			log.Fatal("emit-json: ", err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal("emit-json: ", err)
+		}
+		log.Print("emit-json: ", atomic.LoadUint64(&run.Counters.Total), " records written")
+		return
+	}
+	if cfg.DryRun > 0 {
+		if err := run.DryRun(os.Stdout); err != nil {
+			log.Fatal("dry-run: ", err)
+		}
+		return
+	}
+	if run.Simulation != nil {
+		if err := run.Run(context.Background()); err != nil {
+			log.Fatal("simulate: ", err)
+		}
+		run.Simulation.Fprint(os.Stdout)
+		return
+	}
+	control.HandlePauseSignals(run.Control)
+	if cfg.Interactive {
+		go control.REPL(os.Stdin, os.Stdout, run.Control, run.Stats)
+	}
+
+	if len(cfg.API) > 0 {
+		api := &control.API{
+			Log:     tail,
+			Control: run.Control,
+			Stats:   func() interface{} { return run.Stats() },
+			Report: func() interface{} {
+				if report := run.Report(); report != nil {
+					return report
+				}
+				return nil
+			},
+		}
+		l, err := systemd.Listen(cfg.API)
+		if err != nil {
+			log.Fatal("control api: ", err)
+		}
+		go func() {
+			rep.Fatal("control api: ", api.Serve(l))
+		}()
+	}
+
+	if len(cfg.Health) > 0 {
+		l, err := systemd.Listen(cfg.Health)
+		if err != nil {
+			log.Fatal("health: ", err)
+		}
+		go func() {
+			rep.Fatal("health: ", control.ServeHealth(l, run.Control))
+		}()
+	}
+
+	if len(cfg.GRPC) > 0 {
+		g := &control.GRPC{
+			Control:   run.Control,
+			StatsFunc: func() *controlpb.RunStats { return run.Stats().Proto() },
+			ReportFunc: func() *controlpb.RunStats {
+				if report := run.Report(); report != nil {
+					return report.Proto()
+				}
+				return nil
+			},
+		}
+		l, err := systemd.Listen(cfg.GRPC)
+		if err != nil {
+			log.Fatal("control grpc: ", err)
+		}
+		go func() {
+			rep.Fatal("control grpc: ", g.Serve(l))
+		}()
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	if cfg.ShowCount {
+		wg.Add(1)
+		go LogStats(&wg, done, cfg, run)
+	}
+	if len(cfg.Instance) > 0 {
+		wg.Add(1)
+		go WriteInstanceStats(&wg, done, cfg, run)
+	}
+	if len(cfg.Checkpoint) > 0 {
+		wg.Add(1)
+		go WriteCheckpoint(&wg, done, cfg, run, resumed)
+	}
+	var rates summary.Series
+	if cfg.Summary != summary.Never {
+		wg.Add(1)
+		go SampleRate(&wg, done, run, &rates)
+	}
+	// Type=notify units, the control sockets are listening
+	// while still root on --user
+	raiseOpenFiles(cfg)
+	checkSocketBuffers(cfg)
+	if len(cfg.CPUs) > 0 {
+		cpus, _ := cpupin.Parse(cfg.CPUs)
+		if err := cpupin.Process(cpus); err != nil {
+			log.Fatal("cpus: ", err)
+		}
+		log.Print("running on cpus ", cfg.CPUs)
+	}
+	if len(cfg.User) > 0 {
+		if err := privdrop.Drop(cfg.User); err != nil {
+			log.Fatal("user: ", err)
+		}
+		log.Print("running as ", cfg.User)
+	}
+	systemd.Notify("READY=1")
+	go systemd.Watchdog(done)
+
+	// graceful stop, finishing the in-flight requests and the report
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM)
+	go func() {
+		<-term
+		log.Print("SIGTERM, stopping")
+		cancel()
+	}()
+	upgrading := make(chan os.Signal, 1)
+	upgrade.Notify(upgrading)
+	go func() {
+		for range upgrading {
+			if len(cfg.Checkpoint) <= 0 {
+				log.Print("SIGUSR2: the upgrade needs --checkpoint to hand the run over")
+				continue
+			}
+			log.Print("SIGUSR2, upgrading")
+			run.StopForUpgrade()
+			return
+		}
+	}()
+	var found chan error
+	if cfg.FindMax {
+		found = make(chan error, 1)
+		go func() {
+			pps, err := run.FindMax(func(l gen.Level) {
+				result := "ok"
+				if len(l.Reason) > 0 {
+					result = "failed, " + l.Reason
+				}
+				log.Print("find-max: ", l.PPS, " pps: ", l.Sent, " requests, ",
+					l.Failed, " failed, p99 ", l.P99, ": ", result)
+			})
+			if err != nil {
+				log.Print("find-max: ", err)
+			}
+			if pps > 0 {
+				log.Print("find-max: sustainable rate ", pps, " pps")
+			}
+			found <- err
+			run.Control.Stop()
+		}()
+	}
+	err = run.Run(ctx)
+	systemd.Notify("STOPPING=1")
+	close(done)
 	wg.Wait()
+	if cfg.Adaptive {
+		if steps, knee := run.Adaptive(); knee > 0 {
+			log.Printf("adaptive: knee of the curve %.1f pps (mean rate of the push backs over %d intervals)", knee, len(steps))
+		} else {
+			log.Printf("adaptive: no push back over %d intervals, the knee is above %.1f pps", len(steps), run.Pacer.Rate())
+		}
+	}
+	if run.Shadow != nil {
+		var servers []string
+		for _, t := range run.Pool.Targets() {
+			servers = append(servers, t.Addr)
+		}
+		var b bytes.Buffer
+		run.Shadow.Fprint(&b, strings.Join(servers, ","), run.ShadowTarget.Addr)
+		log.Print(strings.TrimRight(b.String(), "\n"))
+	}
+	if len(cfg.Checkpoint) > 0 {
+		state := resumed
+		total := atomic.LoadUint64(&run.Counters.Total)
+		state.Sent += total
+		state.Seed, state.Draws = run.SeedState()
+		if handoff != nil {
+			total -= handoff.Counters.Total
+		}
+		state.Done = cfg.MaxReq != gen.MaxInt && total >= uint64(cfg.MaxReq)
+		if err := checkpoint.Save(cfg.Checkpoint, state); err != nil {
+			log.Print("checkpoint: ", err)
+		}
+	}
+	if errs := run.Errors.Stats(); len(errs) > 0 {
+		log.Print(len(errs), " distinct errors:")
+		for _, e := range errs {
+			log.Printf("  %d x %s (first %s, last %s, session %q)", e.Count, e.Error,
+				e.First.Format(time.RFC3339), e.Last.Format(time.RFC3339), e.SessionId)
+		}
+	}
+	report := run.Report()
+	if report == nil {
+		s := run.Stats()
+		report = &s
+	}
+	if len(report.ValueViolations) > 0 {
+		// the values lenient mended or dropped, the one strict failed on
+		log.Print("value-check: generated values out of their type or bound:")
+		reasons := make([]string, 0, len(report.ValueViolations))
+		for k := range report.ValueViolations {
+			reasons = append(reasons, k)
+		}
+		sort.Strings(reasons)
+		for _, k := range reasons {
+			log.Printf("  %d x %s", report.ValueViolations[k], k)
+		}
+	}
+	if cfg.Quiet {
+		b, _ := json.Marshal(report)
+		log.Print("report: ", string(b))
+	}
+	if cfg.Summary == summary.Always || cfg.Summary == summary.Auto && summary.IsTerminal(os.Stderr) {
+		summary.Fprint(os.Stderr, *report, rates.Points(), summary.Color(os.Stderr))
+	}
+	if err != nil {
+		rep.Fatal("error: ", err)
+	}
+	if run.Upgrading() {
+		// same pid, the pid file and the deferred cleanups stay
+		if err := gen.SaveHandoff(gen.HandoffPath(cfg.Checkpoint), run.Handoff()); err != nil {
+			log.Fatal("upgrade: ", err)
+		}
+		log.Print("upgrade: exec ", os.Args[0])
+		log.Fatal("upgrade: ", upgrade.Exec(upgrade.ResumeArgs(os.Args, cfg.Command)))
+	}
+
+	if (len(cfg.API) > 0 || len(cfg.GRPC) > 0) && cfg.APILinger > 0 {
+		// keep the api up so the final report can be fetched
+		time.Sleep(time.Second * time.Duration(cfg.APILinger))
+	}
+	if found != nil {
+		if err := <-found; err != nil {
+			os.Exit(1)
+		}
+	}
+	if cfg.Functional {
+		total, failed := atomic.LoadUint64(&run.Counters.Total), atomic.LoadUint64(&run.Counters.Failed)
+		log.Print("functional: ", total-failed, " of ", total, " requests passed")
+		if failed > 0 {
+			os.Exit(1)
+		}
+	}
+	if n := atomic.LoadUint64(&run.Counters.ExpectFailed); n > 0 {
+		log.Print(n, " requests failed the expectations")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,101 @@
+// Package heatmap counts the responses by second of the run and latency
+// bin (--heatmap), a latency-over-time dataset showing the periodic server
+// stalls (GC, vacuum, log rotation) a percentile over the whole run hides.
+package heatmap
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// upper bounds of the latency bins in ms, 1-2-5 steps; the last bin has no
+// upper bound
+var Bins = []float64{0.5, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// one row per second and non-empty bin, long format for the plotting tools
+// (pivot second against bin_low_ms for the heatmap)
+var header = []string{"second", "time", "bin_low_ms", "bin_high_ms", "count"}
+
+// response counts of a run, safe for concurrent use by the sending
+// goroutines; the rows are written on Close
+type Heatmap struct {
+	mu    sync.Mutex
+	f     *os.File
+	start time.Time
+	// counts by second since start, a slice of len(Bins)+1 each
+	counts map[int64][]uint64
+}
+
+// create (truncate) the heatmap on path, of a run started at start
+func Create(path string, start time.Time) (*Heatmap, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Heatmap{f: f, start: start, counts: make(map[int64][]uint64)}, nil
+}
+
+// bin of a latency
+func bin(latency time.Duration) int {
+	ms := latency.Seconds() * 1000
+	return sort.Search(len(Bins), func(i int) bool { return ms <= Bins[i] })
+}
+
+// count a response to a request sent at sent
+func (h *Heatmap) Add(sent time.Time, latency time.Duration) {
+	s := int64(sent.Sub(h.start) / time.Second)
+	if s < 0 {
+		s = 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := h.counts[s]
+	if c == nil {
+		c = make([]uint64, len(Bins)+1)
+		h.counts[s] = c
+	}
+	c[bin(latency)]++
+}
+
+func ms(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// write the rows by second and bin and close the file
+func (h *Heatmap) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seconds := make([]int64, 0, len(h.counts))
+	for s := range h.counts {
+		seconds = append(seconds, s)
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+	w := csv.NewWriter(h.f)
+	w.Write(header)
+	for _, s := range seconds {
+		t := h.start.Add(time.Duration(s) * time.Second).UTC().Format(time.RFC3339)
+		for i, n := range h.counts[s] {
+			if n <= 0 {
+				continue
+			}
+			low, high := "0", ""
+			if i > 0 {
+				low = ms(Bins[i-1])
+			}
+			if i < len(Bins) {
+				high = ms(Bins[i])
+			}
+			w.Write([]string{strconv.FormatInt(s, 10), t, low, high, strconv.FormatUint(n, 10)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.f.Close()
+		return err
+	}
+	return h.f.Close()
+}
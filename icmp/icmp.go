@@ -0,0 +1,53 @@
+// Package icmp tells apart the ICMP errors the kernel reports on connected
+// UDP sockets: a dead port answers port unreachable and a rejecting
+// firewall administratively prohibited, where a dropping one or a dead
+// host only time out.
+package icmp
+
+import (
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+// kinds of the ICMP errors
+const (
+	PortUnreachable = "port unreachable"
+	// host or network unreachable, administratively prohibited included
+	Unreachable = "unreachable"
+)
+
+// ICMP error of a socket error, empty for the other errors
+func Kind(err error) string {
+	if oe, ok := err.(*net.OpError); ok {
+		err = oe.Err
+	}
+	if se, ok := err.(*os.SyscallError); ok {
+		err = se.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return ""
+	}
+	return kind(errno)
+}
+
+// ICMP errors counted by kind, safe for concurrent use
+type Counters struct {
+	PortUnreachable uint64
+	Unreachable     uint64
+}
+
+// count err when it is an ICMP error, true when it is
+func (c *Counters) Add(err error) bool {
+	switch Kind(err) {
+	case PortUnreachable:
+		atomic.AddUint64(&c.PortUnreachable, 1)
+	case Unreachable:
+		atomic.AddUint64(&c.Unreachable, 1)
+	default:
+		return false
+	}
+	return true
+}
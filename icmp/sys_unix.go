@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package icmp
+
+import "syscall"
+
+// Linux (icmp_err_convert) and the BSDs report port unreachable as
+// ECONNREFUSED, the prohibited codes as EHOSTUNREACH
+func kind(errno syscall.Errno) string {
+	switch errno {
+	case syscall.ECONNREFUSED:
+		return PortUnreachable
+	case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+		return Unreachable
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+package icmp
+
+import "syscall"
+
+// winsock errors, missing from the syscall package
+const (
+	wsaENETUNREACH  = 10051
+	wsaECONNRESET   = 10054
+	wsaEHOSTUNREACH = 10065
+)
+
+// windows reports port unreachable on UDP sockets as WSAECONNRESET
+func kind(errno syscall.Errno) string {
+	switch errno {
+	case wsaECONNRESET:
+		return PortUnreachable
+	case wsaEHOSTUNREACH, wsaENETUNREACH:
+		return Unreachable
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+// Package instance namespaces the files of a named generator
+// (--instance-name) so several ones can share a host, and lists them for
+// the instances command.
+package instance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+const prefix = "go-radius-gen-acct-"
+
+// stats not updated for this long on a running instance mean it died
+const StaleAfter = 5 * time.Second
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func Valid(name string) bool {
+	return validName.MatchString(name)
+}
+
+func PidFile(dir, name string) string {
+	return filepath.Join(dir, prefix+name+".pid")
+}
+
+func LogFile(dir, name string) string {
+	return filepath.Join(dir, prefix+name+".log")
+}
+
+// live stats while running, the final report after
+func StatsFile(dir, name string) string {
+	return filepath.Join(dir, prefix+name+".stats.json")
+}
+
+// replace the stats file, readers never see it half written
+func WriteStats(path string, s control.Stats) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// a generator found on the instance dir
+type Instance struct {
+	Name    string
+	Stats   control.Stats
+	Updated time.Time
+}
+
+// true when the instance isn't stopped but stopped updating its stats
+func (i Instance) Stale() bool {
+	return i.Stats.State != control.Stopped && time.Since(i.Updated) > StaleAfter
+}
+
+// instances with a stats file on dir, sorted by name
+func List(dir string) ([]Instance, error) {
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"*.stats.json"))
+	if err != nil {
+		return nil, err
+	}
+	var list []Instance
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		i := Instance{
+			Name:    strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), prefix), ".stats.json"),
+			Updated: fi.ModTime(),
+		}
+		if err := json.Unmarshal(b, &i.Stats); err != nil {
+			continue
+		}
+		list = append(list, i)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list, nil
+}
@@ -0,0 +1,97 @@
+// Package logfile is the daemon log (--log-file), rotated by size and age
+// and reopened on SIGUSR1 for an external logrotate.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type File struct {
+	// rotate when the file grows past MaxSize bytes or is older than
+	// MaxAge, zero disables each one
+	MaxSize int64
+	MaxAge  time.Duration
+	// rotated files kept as name.1 (newest) to name.MaxBackups
+	MaxBackups int
+
+	mu     sync.Mutex
+	name   string
+	perm   os.FileMode
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func Open(name string, perm os.FileMode) (*File, error) {
+	l := &File{name: name, perm: perm}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, l.perm)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size, l.opened = f, fi.Size(), time.Now()
+	return nil
+}
+
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if (l.MaxSize > 0 && l.size+int64(len(p)) > l.MaxSize && l.size > 0) ||
+		(l.MaxAge > 0 && time.Since(l.opened) > l.MaxAge) {
+		if err := l.rotate(); err != nil {
+			// keep logging on the current file
+			fmt.Fprintln(l.f, "log rotate:", err)
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// close and open the file again, after it was moved by logrotate
+func (l *File) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.Close()
+	return l.open()
+}
+
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// move name.N to name.N+1, dropping the oldest, and name to name.1
+func (l *File) rotate() error {
+	if l.MaxBackups <= 0 {
+		if err := l.f.Truncate(0); err != nil {
+			return err
+		}
+		l.size, l.opened = 0, time.Now()
+		return nil
+	}
+	os.Remove(fmt.Sprintf("%s.%d", l.name, l.MaxBackups))
+	for i := l.MaxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.name, i), fmt.Sprintf("%s.%d", l.name, i+1))
+	}
+	if err := os.Rename(l.name, l.name+".1"); err != nil {
+		return err
+	}
+	l.f.Close()
+	return l.open()
+}
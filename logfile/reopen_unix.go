@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reopen the file on SIGUSR1
+func HandleReopenSignal(l *File) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	go func() {
+		for range sigs {
+			if err := l.Reopen(); err != nil {
+				fmt.Fprintln(os.Stderr, "log reopen:", err)
+			}
+		}
+	}()
+}
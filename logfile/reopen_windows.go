@@ -0,0 +1,4 @@
+package logfile
+
+// no SIGUSR1 on windows, the size and age rotation still apply
+func HandleReopenSignal(l *File) {}
@@ -0,0 +1,187 @@
+package mockserver
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"layeh.com/radius"
+)
+
+// delay distributions (--delay-dist)
+const (
+	Fixed       = "fixed"
+	Uniform     = "uniform"
+	Exponential = "exponential"
+	Normal      = "normal"
+)
+
+// behavior of the mock accounting server, probabilities are 0..1 and
+// drawn per packet
+type Config struct {
+	Addr   string
+	Secret []byte
+	// artificial delay before each Accounting-Response
+	Latency time.Duration
+	// probability of not answering a request
+	Loss float64
+	// probability of answering with a non-authentic response, which the
+	// client discards like a NAK
+	NAK float64
+	// probability of an extra delay drawn from DelayDist with mean DelayMean
+	Delay     float64
+	DelayDist string
+	DelayMean time.Duration
+	// probability of sending the response twice
+	Duplicate float64
+	// probability of flipping a byte of the encoded response
+	Corrupt float64
+}
+
+// minimal Accounting-Response responder with network impairments (server
+// subcommand)
+type Server struct {
+	cfg  Config
+	mu   sync.Mutex
+	conn net.PacketConn
+
+	Received   uint64
+	Answered   uint64
+	Dropped    uint64
+	NAKed      uint64
+	Delayed    uint64
+	Duplicated uint64
+	Corrupted  uint64
+	// requests with a bad authenticator or not Accounting-Request
+	Invalid uint64
+}
+
+func New(cfg Config) *Server {
+	return &Server{cfg: cfg}
+}
+
+func (s *Server) ListenAndServe() error {
+	conn, err := net.ListenPacket("udp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// serve on conn until Close
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	buf := make([]byte, radius.MaxPacketLength)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.closed() {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn, addr, append([]byte(nil), buf[:n]...))
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	conn := s.conn
+	s.conn = nil
+	return conn.Close()
+}
+
+func (s *Server) closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn == nil
+}
+
+func chance(p float64) bool {
+	return p > 0 && rand.Float64() < p
+}
+
+// extra delay according the configured distribution
+func (s *Server) sampleDelay() time.Duration {
+	mean := float64(s.cfg.DelayMean)
+	var d float64
+	switch s.cfg.DelayDist {
+	case Uniform:
+		d = rand.Float64() * 2 * mean
+	case Exponential:
+		d = rand.ExpFloat64() * mean
+	case Normal:
+		// standard deviation of a quarter of the mean
+		d = math.Max(0, rand.NormFloat64()*mean/4+mean)
+	default:
+		d = mean
+	}
+	return time.Duration(d)
+}
+
+func (s *Server) handle(conn net.PacketConn, addr net.Addr, b []byte) {
+	wires, delay := s.Respond(b)
+	if len(wires) <= 0 {
+		return
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	for _, wire := range wires {
+		conn.WriteTo(wire, addr)
+	}
+}
+
+// answer the request b with the impairments: the responses to send after
+// delay, none when invalid or dropped and twice the same when duplicated;
+// the network-less half of the server, also used by --simulate
+func (s *Server) Respond(b []byte) ([][]byte, time.Duration) {
+	req, err := radius.Parse(b, s.cfg.Secret)
+	if err != nil || req.Code != radius.CodeAccountingRequest || !radius.IsAuthenticRequest(b, s.cfg.Secret) {
+		atomic.AddUint64(&s.Invalid, 1)
+		return nil, 0
+	}
+	atomic.AddUint64(&s.Received, 1)
+	if chance(s.cfg.Loss) {
+		atomic.AddUint64(&s.Dropped, 1)
+		return nil, 0
+	}
+	delay := s.cfg.Latency
+	if chance(s.cfg.Delay) {
+		delay += s.sampleDelay()
+		atomic.AddUint64(&s.Delayed, 1)
+	}
+
+	resp := req.Response(radius.CodeAccountingResponse)
+	if chance(s.cfg.NAK) {
+		// signed with another secret, fails the client authenticity check
+		resp.Secret = append([]byte("nak-"), s.cfg.Secret...)
+		atomic.AddUint64(&s.NAKed, 1)
+	} else {
+		atomic.AddUint64(&s.Answered, 1)
+	}
+	wire, err := resp.Encode()
+	if err != nil {
+		return nil, 0
+	}
+	if chance(s.cfg.Corrupt) {
+		// anywhere after code and identifier, so the client still matches it
+		wire[2+rand.Intn(len(wire)-2)] ^= 0xff
+		atomic.AddUint64(&s.Corrupted, 1)
+	}
+	wires := [][]byte{wire}
+	if chance(s.cfg.Duplicate) {
+		wires = append(wires, wire)
+		atomic.AddUint64(&s.Duplicated, 1)
+	}
+	return wires, delay
+}
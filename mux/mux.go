@@ -0,0 +1,431 @@
+// Package mux sends the requests to a server over a few long-lived UDP
+// sockets (--shared-sockets), allocating the 8-bit RADIUS Identifier of
+// each request on its socket and matching the responses to them, instead
+// of a socket per request. Two requests outstanding with the same
+// Identifier on a socket would have their responses mixed up, so an
+// Identifier is only reused once its request is done. The TCP (RFC 6613)
+// and TLS (RadSec, RFC 6614) connections are multiplexed the same way.
+package mux
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"github.com/routecall/go-radius-gen-acct/cpupin"
+	"github.com/routecall/go-radius-gen-acct/icmp"
+	"layeh.com/radius"
+)
+
+// Identifiers of a socket
+const idSpace = 256
+
+// request on a closed client
+var ErrClosed = errors.New("mux: client closed")
+
+// what to do when every Identifier of every socket is outstanding
+const (
+	// wait for a request to finish
+	Block = "block"
+	// open one more socket
+	Open = "open"
+)
+
+// client of a server, safe for concurrent use
+type Client struct {
+	Addr string
+	// retransmission interval, zero to send once
+	Retry time.Duration
+	// Block or Open on exhaustion
+	Policy string
+	// dialer of the sockets
+	Dialer net.Dialer
+	// CPUs the readers of the sockets are pinned to round robin (see
+	// cpupin), nil to leave them to the scheduler
+	CPUs []int
+	// times every Identifier was outstanding when a request needed one
+	Exhausted uint64
+	// responses of a request that had timed out, and the ones of no
+	// request: duplicates, late ones once their Identifier is reused and
+	// forged ones
+	Late  uint64
+	Stray uint64
+	// the Identifier of a timed-out request is held this long for its late
+	// response, zero to give it back at once (its late response is still
+	// matched until the Identifier is reused)
+	LateWindow time.Duration
+	// ICMP errors read on the sockets, nil not to count them
+	ICMP *icmp.Counters
+	// TCP connections instead of UDP sockets, over TLS with a TLS config
+	stream bool
+	TLS    *tls.Config
+	// time of the writes, the retransmissions and the LateWindow
+	Clock clock.Clock
+
+	mu sync.Mutex
+	// the Identifiers of a socket are first, first+stride and so on, see
+	// ShareIdentifiers
+	first   int
+	stride  int
+	cond    *sync.Cond
+	sockets []*socket
+	closed  bool
+}
+
+type socket struct {
+	conn net.Conn
+	// requests by Identifier, nil when free
+	pending [idSpace]*request
+	// timed-out requests by the Identifier they gave back, until reused
+	expired     [idSpace]*request
+	outstanding int
+	// next of the Identifiers of the client tried, the least recently
+	// used ones go first
+	next int
+}
+
+type request struct {
+	wire     []byte
+	secret   []byte
+	response chan *radius.Packet
+	// timed out, and its late response was read; with c.mu held
+	timedOut bool
+	late     bool
+}
+
+// client of addr opening sockets sockets upfront with dialer, their
+// readers pinned to cpus and counting the ICMP errors on unreachable; clk
+// nil for the real clock
+func New(addr string, sockets int, retry time.Duration, policy string, dialer net.Dialer, cpus []int, unreachable *icmp.Counters, clk clock.Clock) (*Client, error) {
+	c := &Client{Addr: addr, Retry: retry, Policy: policy, Dialer: dialer, CPUs: cpus, ICMP: unreachable, Clock: clock.Or(clk), stride: 1}
+	c.cond = sync.NewCond(&c.mu)
+	for i := 0; i < sockets; i++ {
+		if _, err := c.open(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// client of addr over conns TCP connections, TLS ones with config (nil
+// for plain TCP); the stream delivers the requests, they are sent once
+func NewStream(addr string, conns int, policy string, dialer net.Dialer, config *tls.Config, clk clock.Clock) (*Client, error) {
+	c := &Client{Addr: addr, Policy: policy, Dialer: dialer, stream: true, TLS: config, Clock: clock.Or(clk), stride: 1}
+	c.cond = sync.NewCond(&c.mu)
+	for i := 0; i < conns; i++ {
+		if _, err := c.open(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// open one more socket, with c.mu held
+func (c *Client) open() (*socket, error) {
+	dialer, cpu := c.Dialer, -1
+	if len(c.CPUs) > 0 {
+		cpu = c.CPUs[len(c.sockets)%len(c.CPUs)]
+		dialer.Control = cpupin.IncomingCPU(cpu, dialer.Control)
+	}
+	var conn net.Conn
+	var err error
+	switch {
+	case c.stream && c.TLS != nil:
+		conn, err = tls.DialWithDialer(&dialer, "tcp", c.Addr, c.TLS)
+	case c.stream:
+		conn, err = dialer.Dial("tcp", c.Addr)
+	default:
+		conn, err = dialer.Dial("udp", c.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &socket{conn: conn}
+	c.sockets = append(c.sockets, s)
+	go func() {
+		if cpu >= 0 {
+			// the reader only, the senders go on any of the CPUs
+			if err := cpupin.Thread(cpu); err != nil {
+				log.Print("mux: pinning the reader to cpu ", cpu, ": ", err)
+			}
+		}
+		c.read(s)
+	}()
+	return s, nil
+}
+
+// use only the Identifiers first+k*stride of the sockets, e.g. i modulo
+// n for the worker i of n of a distributed run so the workers never send
+// the same ones
+func (c *Client) ShareIdentifiers(first, stride int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stride <= 0 || first < 0 || first >= idSpace {
+		first, stride = 0, 1
+	}
+	c.first, c.stride = first, stride
+	for _, s := range c.sockets {
+		s.next = 0
+	}
+}
+
+// Identifiers of a socket, with c.mu held
+func (c *Client) ids() int {
+	return (idSpace - c.first + c.stride - 1) / c.stride
+}
+
+// Identifiers outstanding on the sockets and the sockets open
+func (c *Client) Outstanding() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, s := range c.sockets {
+		n += s.outstanding
+	}
+	return n, len(c.sockets)
+}
+
+// socket and Identifier for r, waiting or opening a socket as the policy
+// says when there is none free
+func (c *Client) acquire(ctx context.Context, r *request) (*socket, byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counted := false
+	for {
+		if c.closed {
+			return nil, 0, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		var free *socket
+		for _, s := range c.sockets {
+			if s.outstanding < c.ids() && (free == nil || s.outstanding < free.outstanding) {
+				free = s
+			}
+		}
+		if free == nil {
+			if !counted {
+				atomic.AddUint64(&c.Exhausted, 1)
+				counted = true
+			}
+			if c.Policy == Open || len(c.sockets) <= 0 {
+				s, err := c.open()
+				if err != nil {
+					return nil, 0, err
+				}
+				free = s
+			} else {
+				// woken by release, which is the timeout at the latest
+				c.cond.Wait()
+				continue
+			}
+		}
+		for {
+			id := c.first + free.next*c.stride
+			free.next = (free.next + 1) % c.ids()
+			if free.pending[id] == nil {
+				free.pending[id] = r
+				free.expired[id] = nil
+				free.outstanding++
+				return free, byte(id), nil
+			}
+		}
+	}
+}
+
+// give back the Identifier of r, done or timed out
+func (c *Client) release(s *socket, id byte, r *request) {
+	c.mu.Lock()
+	s.pending[id] = nil
+	if r.timedOut {
+		s.expired[id] = r
+	}
+	s.outstanding--
+	c.mu.Unlock()
+	c.cond.Signal()
+}
+
+// r timed out, its Identifier is given back after LateWindow
+func (c *Client) timeout(s *socket, id byte, r *request) {
+	c.mu.Lock()
+	r.timedOut = true
+	c.mu.Unlock()
+	if c.LateWindow > 0 {
+		go func() {
+			<-c.Clock.After(c.LateWindow)
+			c.release(s, id, r)
+		}()
+		return
+	}
+	c.release(s, id, r)
+}
+
+// send packet and wait its response, retransmitting it every Retry until
+// ctx is done; the Identifier of packet is replaced. Returns when the
+// packet was first written too, after waiting a free Identifier
+func (c *Client) Exchange(ctx context.Context, packet *radius.Packet) (*radius.Packet, time.Time, error) {
+	r := &request{secret: packet.Secret, response: make(chan *radius.Packet, 1)}
+	s, id, err := c.acquire(ctx, r)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	timedOut := false
+	defer func() {
+		if timedOut {
+			c.timeout(s, id, r)
+		} else {
+			c.release(s, id, r)
+		}
+	}()
+	packet.Identifier = id
+	// the reader reads the wire under c.mu
+	c.mu.Lock()
+	r.wire, err = packet.Encode()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	written := c.Clock.Now()
+	if _, err := s.conn.Write(r.wire); err != nil {
+		return nil, written, err
+	}
+	var retry <-chan time.Time
+	if c.Retry > 0 {
+		retry = c.Clock.After(c.Retry)
+	}
+	for {
+		select {
+		case response := <-r.response:
+			return response, written, nil
+		case <-retry:
+			if _, err := s.conn.Write(r.wire); err != nil {
+				return nil, written, err
+			}
+			retry = c.Clock.After(c.Retry)
+		case <-ctx.Done():
+			timedOut = true
+			return nil, written, ctx.Err()
+		}
+	}
+}
+
+// deliver the responses of s to their requests, counting the late ones
+// and dropping the ones of no outstanding request
+func (c *Client) read(s *socket) {
+	b := make([]byte, 4096)
+	for {
+		n, err := c.readPacket(s, b)
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return
+			}
+			if c.stream {
+				c.drop(s)
+				return
+			}
+			// e.g. ICMP port unreachable, the request retransmits
+			if c.ICMP != nil {
+				c.ICMP.Add(err)
+			}
+			continue
+		}
+		if n < 20 {
+			continue
+		}
+		c.mu.Lock()
+		r := s.pending[b[1]]
+		if r == nil {
+			r = s.expired[b[1]]
+		}
+		var wire []byte
+		if r != nil {
+			wire = r.wire
+		}
+		c.mu.Unlock()
+		if wire == nil || !radius.IsAuthenticResponse(b[:n], wire, r.secret) {
+			atomic.AddUint64(&c.Stray, 1)
+			continue
+		}
+		c.mu.Lock()
+		late := r.timedOut && !r.late
+		stray := r.timedOut && !late
+		r.late = r.late || late
+		c.mu.Unlock()
+		switch {
+		case late:
+			atomic.AddUint64(&c.Late, 1)
+			continue
+		case stray:
+			atomic.AddUint64(&c.Stray, 1)
+			continue
+		}
+		response, err := radius.Parse(b[:n], r.secret)
+		if err != nil {
+			continue
+		}
+		select {
+		case r.response <- response:
+		default:
+			// a duplicate of the response
+			atomic.AddUint64(&c.Stray, 1)
+		}
+	}
+}
+
+// next packet of s into b, its length: a datagram, or the length of the
+// RADIUS header on the streams
+func (c *Client) readPacket(s *socket, b []byte) (int, error) {
+	if !c.stream {
+		return s.conn.Read(b)
+	}
+	if _, err := io.ReadFull(s.conn, b[:4]); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if n < 20 || n > len(b) {
+		return 0, errors.New("mux: invalid packet length on the stream")
+	}
+	_, err := io.ReadFull(s.conn, b[4:n])
+	return n, err
+}
+
+// close a broken connection, the next requests open another one and its
+// pending ones time out
+func (c *Client) drop(s *socket) {
+	s.conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, o := range c.sockets {
+		if o == s {
+			c.sockets = append(c.sockets[:i], c.sockets[i+1:]...)
+			break
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// close the sockets, the pending requests fail
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, s := range c.sockets {
+		s.conn.Close()
+	}
+	c.cond.Broadcast()
+	return nil
+}
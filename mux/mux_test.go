@@ -0,0 +1,165 @@
+package mux
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"layeh.com/radius"
+)
+
+// server on a local UDP socket dropping the first drop requests and
+// answering the others, each request read sent on got
+func lossyServer(t *testing.T, secret []byte, drop int, got chan<- struct{}) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		b := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			got <- struct{}{}
+			if drop > 0 {
+				drop--
+				continue
+			}
+			request, err := radius.Parse(b[:n], secret)
+			if err != nil {
+				continue
+			}
+			wire, err := request.Response(radius.CodeAccountingResponse).Encode()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(wire, addr)
+		}
+	}()
+	return conn
+}
+
+func TestRetransmitsOnClock(t *testing.T) {
+	secret := []byte("secret")
+	got := make(chan struct{}, 4)
+	srv := lossyServer(t, secret, 1, got)
+	defer srv.Close()
+	sim := clock.NewSim(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	c, err := New(srv.LocalAddr().String(), 1, 3*time.Second, Block, net.Dialer{}, nil, nil, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	type result struct {
+		response *radius.Packet
+		written  time.Time
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		r, w, err := c.Exchange(ctx, radius.New(radius.CodeAccountingRequest, secret))
+		done <- result{r, w, err}
+	}()
+	<-got
+	for sim.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the retransmission waits for the clock, not the wall time
+	select {
+	case <-got:
+		t.Fatal("retransmitted before the clock moved")
+	case <-time.After(50 * time.Millisecond):
+	}
+	sim.Advance(3 * time.Second)
+	<-got
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.response.Code != radius.CodeAccountingResponse {
+		t.Errorf("response code %v, want %v", r.response.Code, radius.CodeAccountingResponse)
+	}
+	if !r.written.Equal(sim.Now().Add(-3 * time.Second)) {
+		t.Errorf("written at %v, want the simulated %v", r.written, sim.Now().Add(-3*time.Second))
+	}
+}
+
+func TestLateWindowOnClock(t *testing.T) {
+	secret := []byte("secret")
+	got := make(chan struct{}, 4)
+	// never answers
+	srv := lossyServer(t, secret, 1<<30, got)
+	defer srv.Close()
+	sim := clock.NewSim(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	c, err := New(srv.LocalAddr().String(), 1, 0, Block, net.Dialer{}, nil, nil, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.LateWindow = 5 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := c.Exchange(ctx, radius.New(radius.CodeAccountingRequest, secret))
+		done <- err
+	}()
+	<-got
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("exchange of a canceled request succeeded")
+	}
+	// the Identifier is held until the window elapses on the clock
+	if n, _ := c.Outstanding(); n != 1 {
+		t.Fatalf("%d outstanding in the late window, want 1", n)
+	}
+	for sim.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	sim.Advance(5 * time.Second)
+	deadline := time.Now().Add(time.Second)
+	for n, _ := c.Outstanding(); n != 0; n, _ = c.Outstanding() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d outstanding after the late window, want 0", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShareIdentifiers(t *testing.T) {
+	c, err := New("127.0.0.1:1813", 1, 0, Open, net.Dialer{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// worker 2 of 4
+	c.ShareIdentifiers(2, 4)
+	seen := make(map[byte]bool)
+	for i := 0; i < 64; i++ {
+		_, id, err := c.acquire(context.Background(), &request{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id%4 != 2 || seen[id] {
+			t.Fatalf("Identifier %d, want a new one of 2 modulo 4", id)
+		}
+		seen[id] = true
+	}
+	if n, sockets := c.Outstanding(); n != 64 || sockets != 1 {
+		t.Fatalf("%d outstanding on %d sockets, want 64 on 1", n, sockets)
+	}
+	// the 64 of the share are taken, the next one opens a socket
+	if _, id, err := c.acquire(context.Background(), &request{}); err != nil || id != 2 {
+		t.Errorf("Identifier %d (%v) once the share is taken, want 2 of a new socket", id, err)
+	}
+	if _, sockets := c.Outstanding(); sockets != 2 {
+		t.Errorf("%d sockets, want 2", sockets)
+	}
+}
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"errors"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"github.com/routecall/go-radius-gen-acct/export"
+	"github.com/routecall/go-radius-gen-acct/heatmap"
+	"github.com/routecall/go-radius-gen-acct/results"
+)
+
+// the per request files: the CSV export of verify (--export), the detail
+// file (--detail-file), the SQLite results (--results-db) and the latency
+// heatmap (--heatmap)
+func init() {
+	Register("csv", openCSV)
+	Register("detail", openDetail)
+	Register("sqlite", openSQLite)
+	Register("heatmap", openHeatmap)
+}
+
+var errNoPath = errors.New("needs a file, name:path")
+
+type csvOutput struct {
+	w *export.Writer
+}
+
+func openCSV(path string, run Run) (Writer, error) {
+	if len(path) <= 0 {
+		return nil, errNoPath
+	}
+	w, err := export.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &csvOutput{w: w}, nil
+}
+
+func (o *csvOutput) Request(r *Request) error {
+	return o.w.Write(export.Record{Sent: r.Sent, AcctSessionId: r.AcctSessionId, CallId: r.CallId,
+		Result: r.Result, AcctUniqueId: r.AcctUniqueId})
+}
+
+func (o *csvOutput) Close(final control.Stats) error {
+	return o.w.Close()
+}
+
+// the sampled requests, as sent
+type detailOutput struct {
+	d *dump.DetailWriter
+}
+
+func openDetail(path string, run Run) (Writer, error) {
+	if len(path) <= 0 {
+		return nil, errNoPath
+	}
+	d, err := dump.CreateDetail(path, run.Redact)
+	if err != nil {
+		return nil, err
+	}
+	return &detailOutput{d: d}, nil
+}
+
+func (o *detailOutput) Request(r *Request) error {
+	if !r.Sampled {
+		return nil
+	}
+	return o.d.Write(r.Packet, r.Sent)
+}
+
+func (o *detailOutput) Close(final control.Stats) error {
+	return o.d.Close()
+}
+
+// the sampled requests and the run
+type sqliteOutput struct {
+	db    *results.DB
+	start time.Time
+}
+
+func openSQLite(path string, run Run) (Writer, error) {
+	if len(path) <= 0 {
+		return nil, errNoPath
+	}
+	db, err := results.Open(path, results.Run{RunID: run.RunID, Started: run.Start, PPS: run.PPS,
+		MaxReq: run.MaxReq, Policy: run.Policy, Servers: run.Servers, Labels: run.Labels})
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteOutput{db: db, start: run.Start}, nil
+}
+
+func (o *sqliteOutput) Request(r *Request) error {
+	if !r.Sampled {
+		return nil
+	}
+	row := results.Request{Sent: r.Sent, AcctSessionId: r.AcctSessionId, CallId: r.CallId,
+		StatusType: r.StatusType, Server: r.Server, Result: r.Result}
+	if r.Response != nil {
+		row.Code = int(r.Response.Code)
+		row.Latency = r.Latency
+	}
+	return o.db.Write(row)
+}
+
+func (o *sqliteOutput) Close(final control.Stats) error {
+	finished := o.start.Add(time.Duration(final.Elapsed * float64(time.Second)))
+	return o.db.Close(finished, final.Total, final.Shed)
+}
+
+// the answered requests by second and latency
+type heatmapOutput struct {
+	h *heatmap.Heatmap
+}
+
+func openHeatmap(path string, run Run) (Writer, error) {
+	if len(path) <= 0 {
+		return nil, errNoPath
+	}
+	h, err := heatmap.Create(path, run.Start)
+	if err != nil {
+		return nil, err
+	}
+	return &heatmapOutput{h: h}, nil
+}
+
+func (o *heatmapOutput) Request(r *Request) error {
+	if r.Response != nil {
+		o.h.Add(r.Sent, r.Latency)
+	}
+	return nil
+}
+
+func (o *heatmapOutput) Close(final control.Stats) error {
+	return o.h.Close()
+}
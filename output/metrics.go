@@ -0,0 +1,186 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// the stats every second, pushed to InfluxDB or scraped by Prometheus
+func init() {
+	Register("influx", openInflux)
+	Register("prometheus", openPrometheus)
+}
+
+// requests answered and their mean latency over the targets
+func answered(s control.Stats) (uint64, float64) {
+	var n uint64
+	var latency float64
+	for _, t := range s.Targets {
+		n += t.Acked
+		latency += t.AvgLatencyMs * float64(t.Acked)
+	}
+	if n > 0 {
+		latency /= float64(n)
+	}
+	return n, latency
+}
+
+func errorCount(s control.Stats) uint64 {
+	var n uint64
+	for _, e := range s.Errors {
+		n += e.Count
+	}
+	return n
+}
+
+// the stats as InfluxDB line protocol, POSTed every second to the write
+// endpoint of the argument, e.g. http://influx:8086/write?db=radgen
+type influxOutput struct {
+	url    string
+	tags   string
+	client http.Client
+}
+
+// escape a tag key or value of the line protocol
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+func openInflux(url string, run Run) (Writer, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, errors.New("needs the URL of the write endpoint, e.g. influx:http://influx:8086/write?db=radgen")
+	}
+	tags := ",run_id=" + influxEscaper.Replace(run.RunID)
+	keys := make([]string, 0, len(run.Labels))
+	for k := range run.Labels {
+		keys = append(keys, k)
+	}
+	// the labels in a stable order
+	sort.Strings(keys)
+	for _, k := range keys {
+		tags += "," + influxEscaper.Replace(k) + "=" + influxEscaper.Replace(run.Labels[k])
+	}
+	return &influxOutput{url: url, tags: tags, client: http.Client{Timeout: 5 * time.Second}}, nil
+}
+
+func (o *influxOutput) Request(r *Request) error {
+	return nil
+}
+
+func (o *influxOutput) Stats(s control.Stats) error {
+	var b bytes.Buffer
+	ts := time.Now().UnixNano()
+	n, latency := answered(s)
+	fmt.Fprintf(&b, "radgen%s total=%di,shed=%di,answered=%di,pps=%g,avg_latency_ms=%g,in_flight=%di,errors=%di %d\n",
+		o.tags, s.Total, s.Shed, n, s.PPS, latency, s.InFlight, errorCount(s), ts)
+	for _, t := range s.Targets {
+		fmt.Fprintf(&b, "radgen_target%s,target=%s sent=%di,acked=%di,avg_latency_ms=%g,lost=%di %d\n",
+			o.tags, influxEscaper.Replace(t.Addr), t.Sent, t.Acked, t.AvgLatencyMs, t.Lost, ts)
+	}
+	resp, err := o.client.Post(o.url, "text/plain; charset=utf-8", &b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// the final stats, pushed too; as the others, an error doesn't fail the
+// run
+func (o *influxOutput) Close(final control.Stats) error {
+	if err := o.Stats(final); err != nil {
+		log.Print("output influx: ", err)
+	}
+	return nil
+}
+
+// the last stats on /metrics of the argument address (e.g. :9101), in the
+// Prometheus text format, until the run is finished
+type prometheusOutput struct {
+	l     net.Listener
+	runID string
+	mu    sync.Mutex
+	last  control.Stats
+}
+
+func openPrometheus(addr string, run Run) (Writer, error) {
+	if len(addr) <= 0 {
+		return nil, errors.New("needs the address to serve /metrics on, e.g. prometheus::9101")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	o := &prometheusOutput{l: l, runID: run.RunID}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", o.serveMetrics)
+	go http.Serve(l, mux)
+	return o, nil
+}
+
+func (o *prometheusOutput) Request(r *Request) error {
+	return nil
+}
+
+func (o *prometheusOutput) Stats(s control.Stats) error {
+	o.mu.Lock()
+	o.last = s
+	o.mu.Unlock()
+	return nil
+}
+
+func (o *prometheusOutput) Close(final control.Stats) error {
+	return o.l.Close()
+}
+
+// escape a label value of the text format
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (o *prometheusOutput) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	s := o.last
+	o.mu.Unlock()
+	run := `run_id="` + promEscaper.Replace(o.runID) + `"`
+	var b bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	n, latency := answered(s)
+	metric("radgen_requests_total", "counter", "accounting-requests sent")
+	fmt.Fprintf(&b, "radgen_requests_total{%s} %d\n", run, s.Total)
+	metric("radgen_answered_total", "counter", "accounting-requests answered")
+	fmt.Fprintf(&b, "radgen_answered_total{%s} %d\n", run, n)
+	metric("radgen_shed_total", "counter", "accounting-requests shed")
+	fmt.Fprintf(&b, "radgen_shed_total{%s} %d\n", run, s.Shed)
+	metric("radgen_errors_total", "counter", "errors of the run")
+	fmt.Fprintf(&b, "radgen_errors_total{%s} %d\n", run, errorCount(s))
+	metric("radgen_rate_pps", "gauge", "rate asked")
+	fmt.Fprintf(&b, "radgen_rate_pps{%s} %g\n", run, s.PPS)
+	metric("radgen_in_flight", "gauge", "requests in flight")
+	fmt.Fprintf(&b, "radgen_in_flight{%s} %d\n", run, s.InFlight)
+	metric("radgen_latency_avg_ms", "gauge", "mean latency of the answered requests")
+	fmt.Fprintf(&b, "radgen_latency_avg_ms{%s} %g\n", run, latency)
+	metric("radgen_target_sent_total", "counter", "accounting-requests sent by target")
+	for _, t := range s.Targets {
+		fmt.Fprintf(&b, "radgen_target_sent_total{%s,target=\"%s\"} %d\n", run, promEscaper.Replace(t.Addr), t.Sent)
+	}
+	metric("radgen_target_answered_total", "counter", "accounting-requests answered by target")
+	for _, t := range s.Targets {
+		fmt.Fprintf(&b, "radgen_target_answered_total{%s,target=\"%s\"} %d\n", run, promEscaper.Replace(t.Addr), t.Acked)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(b.Bytes())
+}
@@ -0,0 +1,130 @@
+// Package output is the registry of the sinks of a run (acct --output
+// name[:arg], repeat to enable several): the requests as they are answered
+// and the stats of the run, every second and once finished. A new format
+// registers its Factory instead of being wired into the generator.
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/dump"
+	"layeh.com/radius"
+)
+
+// an accounting-request once answered or failed
+type Request struct {
+	// as sent, and the response, nil when not answered
+	Packet   *radius.Packet
+	Response *radius.Packet
+	Sent     time.Time
+	// of the answer, zero without
+	Latency time.Duration
+	Server  string
+	// export.OK, export.Timeout or export.Error
+	Result        string
+	AcctSessionId string
+	CallId        string
+	StatusType    int
+	// FreeRADIUS Acct-Unique-Session-Id, empty without --acct-unique
+	AcctUniqueId string
+	// kept by --sample-rate, the per request files only keep these
+	Sampled bool
+}
+
+// the run the outputs open for
+type Run struct {
+	RunID   string
+	Start   time.Time
+	PPS     float64
+	MaxReq  int
+	Policy  string
+	Servers []string
+	Labels  map[string]string
+	// attributes masked (--redact)
+	Redact dump.Redaction
+}
+
+// a sink of the run, safe for concurrent use by the senders
+type Writer interface {
+	// a request, an error stops the run
+	Request(r *Request) error
+	// once the run is finished, with its final stats
+	Close(final control.Stats) error
+}
+
+// the sinks of the stats while running
+type StatsWriter interface {
+	Writer
+	// the stats of the run, every second; an error is logged and the run
+	// goes on
+	Stats(s control.Stats) error
+}
+
+// opens an output of the run from its argument (e.g. a file name)
+type Factory func(arg string, run Run) (Writer, error)
+
+var (
+	outputsMu sync.Mutex
+	outputs   = make(map[string]Factory)
+)
+
+// add the output name, enabled with --output name[:arg]
+func Register(name string, f Factory) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	if _, dup := outputs[name]; dup {
+		panic("output: " + name + " registered twice")
+	}
+	outputs[name] = f
+}
+
+// sorted names of the registered outputs
+func Names() []string {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// split "name[:arg]"
+func Parse(spec string) (string, string) {
+	if i := strings.Index(spec, ":"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
+
+// the factory of the output of spec, an error when unknown
+func Lookup(spec string) (Factory, error) {
+	name, _ := Parse(spec)
+	outputsMu.Lock()
+	f, ok := outputs[name]
+	outputsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output %q, one of %s", name, strings.Join(Names(), ", "))
+	}
+	return f, nil
+}
+
+// open the output of spec "name[:arg]" for run
+func Open(spec string, run Run) (Writer, error) {
+	f, err := Lookup(spec)
+	if err != nil {
+		return nil, err
+	}
+	name, arg := Parse(spec)
+	w, err := f(arg, run)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %v", name, err)
+	}
+	return w, nil
+}
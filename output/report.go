@@ -0,0 +1,94 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/summary"
+)
+
+// the final report as JSON, and the console summary
+func init() {
+	Register("json", openJSON)
+	Register("console", openConsole)
+}
+
+// the final report to a file, - for stdout
+type jsonOutput struct {
+	path string
+}
+
+func openJSON(path string, run Run) (Writer, error) {
+	if len(path) <= 0 {
+		return nil, errNoPath
+	}
+	if path != "-" {
+		// fail before the run rather than after it
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+	}
+	return &jsonOutput{path: path}, nil
+}
+
+func (o *jsonOutput) Request(r *Request) error {
+	return nil
+}
+
+func (o *jsonOutput) Close(final control.Stats) error {
+	b, err := json.MarshalIndent(final, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if o.path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(o.path, b, 0644)
+}
+
+// the summary table of summary.Fprint on stderr once finished, in color on
+// a terminal without NO_COLOR, or as the argument says (color, plain)
+type consoleOutput struct {
+	color bool
+	rates summary.Series
+	last  uint64
+}
+
+func openConsole(arg string, run Run) (Writer, error) {
+	o := &consoleOutput{}
+	switch arg {
+	case "":
+		o.color = summary.Color(os.Stderr)
+	case "color":
+		o.color = true
+	case "plain":
+	default:
+		return nil, errConsoleArg
+	}
+	return o, nil
+}
+
+var errConsoleArg = errors.New("the argument is color or plain, none for color on a terminal")
+
+func (o *consoleOutput) Request(r *Request) error {
+	return nil
+}
+
+// the rate of the sparkline, called every second from a single goroutine
+func (o *consoleOutput) Stats(s control.Stats) error {
+	o.rates.Add(float64(s.Total - o.last))
+	o.last = s.Total
+	return nil
+}
+
+func (o *consoleOutput) Close(final control.Stats) error {
+	summary.Fprint(os.Stderr, final, o.rates.Points(), o.color)
+	return nil
+}
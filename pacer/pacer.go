@@ -0,0 +1,199 @@
+package pacer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+	"go.uber.org/ratelimit"
+)
+
+// pacer names accepted by New (--pacer)
+const (
+	Leaky  = "leaky"
+	Token  = "token"
+	Hybrid = "hybrid"
+)
+
+// Pacer blocks the caller until the next packet may be sent
+type Pacer interface {
+	Take() time.Time
+}
+
+// lowest rate, a packet every 1000 seconds
+const MinRate = 0.001
+
+// parse the jitter of the gaps between packets, "10%" or 0.1 of the
+// interval; empty for none
+func ParseJitter(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) <= 0 {
+		return 0, nil
+	}
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s, scale = strings.TrimSpace(strings.TrimSuffix(s, "%")), 100
+	}
+	j, err := strconv.ParseFloat(s, 64)
+	if err != nil || j < 0 || j/scale > 1 {
+		return 0, fmt.Errorf("pacer: jitter must be between 0 and 100%%")
+	}
+	return j / scale, nil
+}
+
+// interval of the next packet: the mean one moved at random by up to
+// jitter of it either way, so the mean rate stays the same
+func gap(interval time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// create the pacer by name, rate is in packets per second (fractional for
+// a packet every few seconds), burst is the number of packets token/hybrid
+// pacers may send back-to-back and jitter the share of the interval the
+// gaps between packets vary by (see ParseJitter)
+func New(name string, rate float64, burst int, jitter float64) (Pacer, error) {
+	return NewClock(clock.Real, name, rate, burst, jitter)
+}
+
+// New, timed by c
+func NewClock(c clock.Clock, name string, rate float64, burst int, jitter float64) (Pacer, error) {
+	if rate < MinRate {
+		return nil, fmt.Errorf("pacer: rate must be at least %g", MinRate)
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	switch name {
+	case Leaky:
+		if rate != math.Trunc(rate) || jitter > 0 || c != clock.Real {
+			// ratelimit takes whole rates, fixed gaps and the system
+			// clock, hybrid without slack is a leaky bucket too
+			return &hybrid{clock: c, interval: interval, jitter: jitter}, nil
+		}
+		return ratelimit.New(int(rate)), nil
+	case Token:
+		return &tokenBucket{clock: c, interval: interval, jitter: jitter, burst: float64(burst), tokens: float64(burst), last: c.Now()}, nil
+	case Hybrid:
+		return &hybrid{clock: c, interval: interval, jitter: jitter, slack: interval * time.Duration(burst)}, nil
+	}
+	return nil, fmt.Errorf("pacer: unknown pacer %q", name)
+}
+
+// token-bucket: starts full, refills at rate and allows bursts up to
+// burst packets after idle periods
+type tokenBucket struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	interval time.Duration
+	jitter   float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func (t *tokenBucket) Take() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	t.tokens += float64(now.Sub(t.last)) / float64(t.interval)
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	if t.tokens >= 1 {
+		t.tokens--
+		return now
+	}
+	wait := time.Duration((1 - t.tokens) * float64(gap(t.interval, t.jitter)))
+	t.clock.Sleep(wait)
+	t.last = now.Add(wait)
+	t.tokens = 0
+	return t.last
+}
+
+// hybrid: leaky-bucket spacing while the sender keeps up, but banks up to
+// burst missed slots and sends them back-to-back to catch up
+type hybrid struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	interval time.Duration
+	jitter   float64
+	slack    time.Duration
+	next     time.Time
+}
+
+func (h *hybrid) Take() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.clock.Now()
+	if h.next.IsZero() {
+		h.next = now
+	}
+	if now.Sub(h.next) > h.slack {
+		h.next = now.Add(-h.slack)
+	}
+	if h.next.After(now) {
+		h.clock.Sleep(h.next.Sub(now))
+		now = h.next
+	}
+	h.next = h.next.Add(gap(h.interval, h.jitter))
+	return now
+}
+
+// pacer which rate can be changed during the run
+type Adjustable struct {
+	mu     sync.RWMutex
+	p      Pacer
+	clock  clock.Clock
+	name   string
+	rate   float64
+	burst  int
+	jitter float64
+}
+
+func NewAdjustable(name string, rate float64, burst int, jitter float64) (*Adjustable, error) {
+	return NewAdjustableClock(clock.Real, name, rate, burst, jitter)
+}
+
+// NewAdjustable, timed by c
+func NewAdjustableClock(c clock.Clock, name string, rate float64, burst int, jitter float64) (*Adjustable, error) {
+	p, err := NewClock(c, name, rate, burst, jitter)
+	if err != nil {
+		return nil, err
+	}
+	return &Adjustable{p: p, clock: c, name: name, rate: rate, burst: burst, jitter: jitter}, nil
+}
+
+func (a *Adjustable) Take() time.Time {
+	a.mu.RLock()
+	p := a.p
+	a.mu.RUnlock()
+	return p.Take()
+}
+
+// replace the pacer by a new one of the same kind with the given rate
+func (a *Adjustable) SetRate(rate float64) error {
+	p, err := NewClock(a.clock, a.name, rate, a.burst, a.jitter)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.p, a.rate = p, rate
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Adjustable) Rate() float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.rate
+}
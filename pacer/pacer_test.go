@@ -0,0 +1,121 @@
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/clock"
+)
+
+var start = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// offsets from start of n takes of p
+func takes(p Pacer, n int) []time.Duration {
+	var got []time.Duration
+	for i := 0; i < n; i++ {
+		got = append(got, p.Take().Sub(start))
+	}
+	return got
+}
+
+func equal(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPacersSpacing(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		want  []time.Duration
+	}{
+		{Leaky, 10, 1, []time.Duration{0, 100 * ms, 200 * ms, 300 * ms}},
+		{Leaky, 0.2, 1, []time.Duration{0, 5 * time.Second, 10 * time.Second, 15 * time.Second}},
+		{Token, 10, 1, []time.Duration{0, 100 * ms, 200 * ms, 300 * ms}},
+		{Token, 10, 3, []time.Duration{0, 0, 0, 100 * ms, 200 * ms}},
+		{Hybrid, 4, 1, []time.Duration{0, 250 * ms, 500 * ms, 750 * ms}},
+	}
+	for _, tt := range tests {
+		sim := clock.NewSim(start)
+		sim.Auto = true
+		p, err := NewClock(sim, tt.name, tt.rate, tt.burst, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := takes(p, len(tt.want)); !equal(got, tt.want) {
+			t.Errorf("%s %g pps burst %d: takes at %v, want %v", tt.name, tt.rate, tt.burst, got, tt.want)
+		}
+	}
+}
+
+func TestHybridCatchesUp(t *testing.T) {
+	sim := clock.NewSim(start)
+	sim.Auto = true
+	p, err := NewClock(sim, Hybrid, 10, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Take()
+	// the sender stalls a second, two missed slots go back-to-back
+	sim.Advance(time.Second)
+	ms := time.Millisecond
+	want := []time.Duration{1000 * ms, 1000 * ms, 1000 * ms, 1100 * ms}
+	if got := takes(p, len(want)); !equal(got, want) {
+		t.Errorf("takes at %v, want %v", got, want)
+	}
+}
+
+func TestAdjustableSetRate(t *testing.T) {
+	sim := clock.NewSim(start)
+	sim.Auto = true
+	a, err := NewAdjustableClock(sim, Hybrid, 10, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	takes(a, 2)
+	if err := a.SetRate(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if a.Rate() != 0.5 {
+		t.Errorf("rate %g, want 0.5", a.Rate())
+	}
+	want := []time.Duration{100 * time.Millisecond, 2100 * time.Millisecond}
+	if got := takes(a, 2); !equal(got, want) {
+		t.Errorf("takes at %v, want %v", got, want)
+	}
+	if err := a.SetRate(MinRate / 2); err == nil {
+		t.Error("rate below MinRate accepted")
+	}
+}
+
+func TestTokenWaitsOnClock(t *testing.T) {
+	sim := clock.NewSim(start)
+	p, err := NewClock(sim, Token, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Take()
+	done := make(chan time.Time)
+	go func() { done <- p.Take() }()
+	for sim.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("take before the clock moved")
+	default:
+	}
+	sim.Advance(time.Second)
+	if got := (<-done).Sub(start); got != time.Second {
+		t.Errorf("take at %v, want 1s", got)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package pidfile
+
+import (
+	"os"
+
+	daemon "github.com/sevlyar/go-daemon"
+)
+
+// pid of the daemon owning the pid file name, running is false when there
+// is no pid file or it isn't locked anymore (stale, pid is the one left on
+// it)
+func Check(name string) (pid int, running bool, err error) {
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	f, err := daemon.OpenLockFile(name, 0)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	pid, _ = f.ReadPid()
+	switch err := f.Lock(); err {
+	case nil:
+		f.Unlock()
+		return pid, false, nil
+	case daemon.ErrWouldBlock:
+		return pid, true, nil
+	default:
+		return pid, false, err
+	}
+}
@@ -0,0 +1,6 @@
+package pidfile
+
+// go-daemon and its pid file locking don't run on windows
+func Check(name string) (pid int, running bool, err error) {
+	return 0, false, ErrNotSupported
+}
@@ -0,0 +1,47 @@
+// Package pidfile finds the daemon of a pid file written by go-daemon,
+// which keeps it locked while running.
+package pidfile
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrNotRunning = errors.New("not running")
+	ErrTimeout    = errors.New("timeout waiting the daemon to exit")
+	// no pid file locking on this platform
+	ErrNotSupported = errors.New("pid file commands are not supported on this platform")
+)
+
+// send sig to the daemon of the pid file
+func Signal(name string, sig os.Signal) (int, error) {
+	pid, running, err := Check(name)
+	if err != nil {
+		return pid, err
+	}
+	if !running {
+		return pid, ErrNotRunning
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return pid, err
+	}
+	return pid, p.Signal(sig)
+}
+
+// wait for the daemon of the pid file to exit
+func Wait(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, running, err := Check(name)
+		if err != nil || !running {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,25 @@
+// Package privdrop switches the process to an unprivileged user (--user)
+// once the root-only setup (limits, low ports, root-owned key file) is
+// done.
+package privdrop
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+var ErrNotSupported = errors.New("--user is not supported on this platform")
+
+// shared secret from file, without the trailing newline
+func ReadKey(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimRight(string(b), "\r\n")
+	if len(key) <= 0 {
+		return "", errors.New("key-file " + name + " is empty")
+	}
+	return key, nil
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package privdrop
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// set the groups, gid and uid of name, in this order as after setuid
+// the process can't change its groups anymore
+func Drop(name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	var groups []int
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.Atoi(id); err == nil {
+				groups = append(groups, g)
+			}
+		}
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups: %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %v", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %v", err)
+	}
+	// can't get root back
+	if syscall.Setuid(0) == nil && uid != 0 {
+		return fmt.Errorf("setuid: still able to regain root")
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+package privdrop
+
+func Drop(name string) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,182 @@
+// Package profile bundles acct options into named traffic shapes
+// (--profile): rate, call model, response code mix and attributes. The
+// options given on the command line or the environment win over the
+// ones of the profile.
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// named set of acct options, by flag name
+type Profile struct {
+	Description string            `yaml:"description"`
+	Options     map[string]string `yaml:"options"`
+}
+
+// the profiles shipped with the generator
+var Builtin = map[string]Profile{
+	"wholesale-voice-peak": {
+		Description: "carrier interconnect at the busy hour: A and B legs, short setups, long tail of talk times, a third of the calls failing",
+		Options: map[string]string{
+			"pps":          "500",
+			"pacer":        "token",
+			"burst":        "50",
+			"legs":         "true",
+			"setup-time":   "normal:1s",
+			"ring-time":    "exponential:5s",
+			"talk-time":    "exponential:150s",
+			"failed-ratio": "0.35",
+			"failed-codes": "404,480,486,487,503,603",
+		},
+	},
+	"residential-voice": {
+		Description: "residential lines: low rate, long rings, busy and no-answer failures, Start/Interim/Stop every 5 minutes",
+		Options: map[string]string{
+			"pps":              "20",
+			"setup-time":       "normal:2s",
+			"ring-time":        "exponential:12s",
+			"talk-time":        "exponential:240s",
+			"failed-ratio":     "0.25",
+			"failed-codes":     "480,486,487",
+			"lifecycle":        "true",
+			"interim-interval": "300",
+		},
+	},
+	"call-center": {
+		Description: "inbound call center: quick answers, long calls put on hold, Interims every minute",
+		Options: map[string]string{
+			"pps":              "100",
+			"ring-time":        "exponential:3s",
+			"talk-time":        "normal:300s",
+			"failed-ratio":     "0.1",
+			"failed-codes":     "486,487,503",
+			"lifecycle":        "true",
+			"interim-interval": "60",
+			"reinvite-ratio":   "0.3",
+		},
+	},
+	"short-call-fraud": {
+		Description: "robocalling or IRSF-like burst: high rate, seconds long calls, most of them rejected",
+		Options: map[string]string{
+			"pps":          "300",
+			"pacer":        "token",
+			"burst":        "100",
+			"ring-time":    "exponential:2s",
+			"talk-time":    "exponential:8s",
+			"failed-ratio": "0.6",
+			"failed-codes": "403,404,484,486,503",
+		},
+	},
+	"wifi-data": {
+		Description: "Wi-Fi subscriber data sessions: Start/Interim/Stop with octet counters every 10 minutes, sessions of about an hour",
+		Options: map[string]string{
+			"pps":              "50",
+			"session-type":     "data",
+			"talk-time":        "exponential:3600s",
+			"lifecycle":        "true",
+			"interim-interval": "600",
+		},
+	},
+	"nas-outage-storm": {
+		Description: "NAS reconnecting after an outage: sessions with Interims every minute, a 2 minute blackout after 3 minutes then the held records all at once with their Acct-Delay-Time",
+		Options: map[string]string{
+			"pps":              "100",
+			"lifecycle":        "true",
+			"interim-interval": "60",
+			"outage":           "3m:2m",
+		},
+	},
+	"soak": {
+		Description: "steady long run: smooth moderate rate, lifecycle records and NAS Accounting-On/Off",
+		Options: map[string]string{
+			"pps":              "50",
+			"pacer":            "leaky",
+			"lifecycle":        "true",
+			"interim-interval": "120",
+			"acct-on-off":      "true",
+		},
+	},
+}
+
+// --profiles-file format, the options by acct flag name
+//
+//	profiles:
+//	  my-peak:
+//	    description: our busy hour
+//	    options:
+//	      pps: 800
+//	      talk-time: exponential:90s
+//	      custom-fields: "25=peak"
+type File struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// the profiles of a --profiles-file
+func Load(name string) (map[string]Profile, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return f.Profiles, nil
+}
+
+// the built-in profiles and the ones of file (empty for none), which
+// replace the built-in ones of the same name
+func All(file string) (map[string]Profile, error) {
+	all := make(map[string]Profile, len(Builtin))
+	for name, p := range Builtin {
+		all[name] = p
+	}
+	if len(file) > 0 {
+		profiles, err := Load(file)
+		if err != nil {
+			return nil, err
+		}
+		for name, p := range profiles {
+			all[name] = p
+		}
+	}
+	return all, nil
+}
+
+// the profile name of file or the built-in ones
+func Lookup(name, file string) (Profile, error) {
+	all, err := All(file)
+	if err != nil {
+		return Profile{}, err
+	}
+	p, ok := all[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %s not found, see config profiles", name)
+	}
+	return p, nil
+}
+
+// sorted names of the profiles
+func Names(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sorted flag names of the options of p
+func (p Profile) Flags() []string {
+	flags := make([]string, 0, len(p.Options))
+	for flag := range p.Options {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,113 @@
+// Package radtest runs the generator from the Go tests of a RADIUS
+// accounting server implementation, or of a client against the mock
+// server standing in for one.
+//
+// Testing a server:
+//
+//	func TestAccounting(t *testing.T) {
+//		addr := startServer(t) // the server under test
+//		stats := radtest.Run(t, radtest.Config(addr, "secret", 1000))
+//		radtest.AllAnswered(t, stats)
+//	}
+//
+// Testing against the mock server:
+//
+//	srv, addr := radtest.Server(t, mockserver.Config{Secret: []byte("secret")})
+//	defer srv.Close()
+package radtest
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+	"github.com/routecall/go-radius-gen-acct/gen"
+	"github.com/routecall/go-radius-gen-acct/mockserver"
+)
+
+// rate of the test runs, high enough to stay short and low enough not to
+// overload a server started by the test
+const PPS = 1000
+
+// generator config of n requests to addr ("host:port") with secret, at
+// PPS and a short retry: a server of the same host answers or it's broken
+func Config(addr, secret string, n int) gen.Config {
+	cfg := gen.DefaultConfig()
+	cfg.Servers = []string{addr}
+	cfg.Key = secret
+	cfg.MaxReq = n
+	cfg.PPS = PPS
+	cfg.Burst = PPS / 10
+	cfg.Retry = 1
+	cfg.MaxRetry = 3
+	return cfg
+}
+
+// run the generator until its MaxReq requests are done, failing tb when
+// it doesn't start or doesn't end within timeout (zero for no limit); the
+// stats of the run
+func Run(tb testing.TB, cfg gen.Config, timeout ...time.Duration) control.Stats {
+	tb.Helper()
+	return RunWith(tb, cfg, gen.Callbacks{}, timeout...)
+}
+
+// Run with the callbacks cb, e.g. to check each answer in OnResponse
+func RunWith(tb testing.TB, cfg gen.Config, cb gen.Callbacks, timeout ...time.Duration) control.Stats {
+	tb.Helper()
+	ctx := context.Background()
+	if len(timeout) > 0 && timeout[0] > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout[0])
+		defer cancel()
+	}
+	stats, err := gen.Run(ctx, cfg, cb)
+	if err != nil {
+		tb.Fatal("radtest: ", err)
+	}
+	if ctx.Err() != nil {
+		tb.Fatalf("radtest: %d requests not done within %s", cfg.MaxReq, timeout[0])
+	}
+	return stats
+}
+
+// requests answered of the run, by any target
+func Answered(stats control.Stats) uint64 {
+	var n uint64
+	for _, t := range stats.Targets {
+		n += t.Acked
+	}
+	return n
+}
+
+// fail tb unless every request of the run was answered
+func AllAnswered(tb testing.TB, stats control.Stats) {
+	tb.Helper()
+	if answered := Answered(stats); answered < stats.Total {
+		tb.Errorf("radtest: %d of %d requests answered", answered, stats.Total)
+	}
+}
+
+// start the mock server on cfg.Addr, a free port of the loopback when
+// empty, failing tb when it can't listen; the server, to Close at the end
+// of the test and read the counters of, and its address
+func Server(tb testing.TB, cfg mockserver.Config) (*mockserver.Server, string) {
+	tb.Helper()
+	if len(cfg.Addr) <= 0 {
+		cfg.Addr = "127.0.0.1:0"
+	}
+	conn, err := net.ListenPacket("udp", cfg.Addr)
+	if err != nil {
+		tb.Fatal("radtest: ", err)
+	}
+	srv := mockserver.New(cfg)
+	go srv.Serve(conn)
+	return srv, conn.LocalAddr().String()
+}
+
+// requests the mock server received
+func Received(srv *mockserver.Server) uint64 {
+	return atomic.LoadUint64(&srv.Received)
+}
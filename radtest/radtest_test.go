@@ -0,0 +1,40 @@
+package radtest_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/mockserver"
+	"github.com/routecall/go-radius-gen-acct/radtest"
+)
+
+func TestRunAgainstMockServer(t *testing.T) {
+	srv, addr := radtest.Server(t, mockserver.Config{Secret: []byte("secret")})
+	defer srv.Close()
+
+	stats := radtest.Run(t, radtest.Config(addr, "secret", 200), 10*time.Second)
+	radtest.AllAnswered(t, stats)
+	if stats.Total != 200 {
+		t.Errorf("%d requests sent, want 200", stats.Total)
+	}
+	if answered := radtest.Answered(stats); answered != 200 {
+		t.Errorf("%d requests answered, want 200", answered)
+	}
+}
+
+func TestMockServerCounts(t *testing.T) {
+	srv, addr := radtest.Server(t, mockserver.Config{Secret: []byte("secret")})
+	defer srv.Close()
+
+	cfg := radtest.Config(addr, "secret", 100)
+	cfg.SharedSockets = 2
+	stats := radtest.Run(t, cfg, 10*time.Second)
+	radtest.AllAnswered(t, stats)
+	if received := radtest.Received(srv); received != stats.Total {
+		t.Errorf("mock server received %d requests, %d sent", received, stats.Total)
+	}
+	if answered := atomic.LoadUint64(&srv.Answered); answered != radtest.Answered(stats) {
+		t.Errorf("mock server answered %d requests, %d acked", answered, radtest.Answered(stats))
+	}
+}
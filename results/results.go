@@ -0,0 +1,214 @@
+// Package results stores the result of every request and the metadata of
+// the run in a SQLite file (--results-db), for ad-hoc SQL on runs too big
+// for the CSV export.
+package results
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	// cgo SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// rows inserted per transaction, and the longest a row waits for it
+const (
+	batchSize  = 1000
+	batchDelay = time.Second
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	run_id   TEXT PRIMARY KEY,
+	started  TIMESTAMP NOT NULL,
+	finished TIMESTAMP,
+	pps      REAL,
+	max_req  INTEGER,
+	policy   TEXT,
+	servers  TEXT,
+	total    INTEGER,
+	shed     INTEGER,
+	labels   TEXT
+);
+CREATE TABLE IF NOT EXISTS requests (
+	run_id          TEXT NOT NULL,
+	sent            TIMESTAMP NOT NULL,
+	acct_session_id TEXT,
+	call_id         TEXT,
+	status_type     INTEGER,
+	server          TEXT,
+	result          TEXT NOT NULL,
+	code            INTEGER,
+	latency_ms      REAL
+);
+CREATE INDEX IF NOT EXISTS requests_session ON requests (run_id, acct_session_id);
+`
+
+// columns added since the first schema, for the files of older runs
+var migrations = []string{
+	"ALTER TABLE runs ADD COLUMN labels TEXT",
+}
+
+// metadata of a run, a run id already on the file is continued (--resume)
+type Run struct {
+	RunID   string
+	Started time.Time
+	PPS     float64
+	MaxReq  int
+	Policy  string
+	Servers []string
+	// --label, a JSON object on the labels column
+	Labels map[string]string
+}
+
+// result of a request, Code zero without a response
+type Request struct {
+	Sent          time.Time
+	AcctSessionId string
+	CallId        string
+	StatusType    int
+	Server        string
+	// export results (ok, timeout, error)
+	Result  string
+	Code    int
+	Latency time.Duration
+}
+
+// results file of a run, safe for concurrent use by the sending goroutines;
+// the requests are inserted in batches from a goroutine of its own
+type DB struct {
+	db    *sql.DB
+	runID string
+	rows  chan Request
+	done  chan struct{}
+	mu    sync.Mutex
+	err   error
+}
+
+// open (create) the results file on path and record the run on it
+func Open(path string, run Run) (*DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single writer, SQLite locks the whole file anyway
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, m := range migrations {
+		if _, err := db.Exec(m); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, err
+		}
+	}
+	var labels interface{}
+	if len(run.Labels) > 0 {
+		b, err := json.Marshal(run.Labels)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		labels = string(b)
+	}
+	_, err = db.Exec("INSERT OR IGNORE INTO runs (run_id, started, pps, max_req, policy, servers, labels) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		run.RunID, run.Started.UTC(), run.PPS, run.MaxReq, run.Policy, strings.Join(run.Servers, ","), labels)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	d := &DB{db: db, runID: run.RunID, rows: make(chan Request, batchSize), done: make(chan struct{})}
+	go d.insert()
+	return d, nil
+}
+
+// queue the result of a request, the error of a previous insert if any
+func (d *DB) Write(r Request) error {
+	d.rows <- r
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *DB) insert() {
+	defer close(d.done)
+	var batch []Request
+	tick := time.NewTicker(batchDelay)
+	defer tick.Stop()
+	for {
+		select {
+		case r, ok := <-d.rows:
+			if !ok {
+				d.flush(batch)
+				return
+			}
+			if batch = append(batch, r); len(batch) >= batchSize {
+				d.flush(batch)
+				batch = batch[:0]
+			}
+		case <-tick.C:
+			d.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (d *DB) flush(batch []Request) {
+	if len(batch) <= 0 {
+		return
+	}
+	err := d.insertBatch(batch)
+	if err != nil {
+		d.mu.Lock()
+		if d.err == nil {
+			d.err = err
+		}
+		d.mu.Unlock()
+	}
+}
+
+func (d *DB) insertBatch(batch []Request) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO requests (run_id, sent, acct_session_id, call_id, status_type, server, result, code, latency_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range batch {
+		var code, latency interface{}
+		if r.Code > 0 {
+			code = r.Code
+			latency = r.Latency.Seconds() * 1000
+		}
+		if _, err := stmt.Exec(d.runID, r.Sent.UTC(), r.AcctSessionId, r.CallId, r.StatusType, r.Server, r.Result, code, latency); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insert the queued requests and record the end of the run, adding its
+// totals to the ones of a resumed run; no Write may be called after it
+func (d *DB) Close(finished time.Time, total, shed uint64) error {
+	close(d.rows)
+	<-d.done
+	_, err := d.db.Exec("UPDATE runs SET finished = ?, total = COALESCE(total, 0) + ?, shed = COALESCE(shed, 0) + ? WHERE run_id = ?", finished.UTC(), total, shed, d.runID)
+	d.mu.Lock()
+	if d.err != nil {
+		err = d.err
+	}
+	d.mu.Unlock()
+	if cerr := d.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
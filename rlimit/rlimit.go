@@ -0,0 +1,33 @@
+// Package rlimit raises the open files limit (RLIMIT_NOFILE) to what the
+// run needs, each in-flight request holds its own UDP socket.
+package rlimit
+
+import "errors"
+
+var ErrNotSupported = errors.New("rlimit: not supported on this platform")
+
+// raise the soft limit to need, up to the hard limit (or over it when
+// running as root), returning the limit in effect
+func Raise(need uint64) (uint64, error) {
+	cur, max, err := get()
+	if err != nil {
+		return 0, err
+	}
+	if cur >= need {
+		return cur, nil
+	}
+	if max < need {
+		// only root can raise the hard limit
+		if err := set(need, need); err == nil {
+			return need, nil
+		}
+		if err := set(max, max); err != nil {
+			return cur, err
+		}
+		return max, nil
+	}
+	if err := set(need, max); err != nil {
+		return cur, err
+	}
+	return need, nil
+}
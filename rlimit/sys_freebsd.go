@@ -0,0 +1,16 @@
+package rlimit
+
+import "syscall"
+
+// the freebsd Rlimit fields are signed
+func get() (cur, max uint64, err error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, 0, err
+	}
+	return uint64(rl.Cur), uint64(rl.Max), nil
+}
+
+func set(cur, max uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: int64(cur), Max: int64(max)})
+}
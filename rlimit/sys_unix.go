@@ -0,0 +1,18 @@
+//go:build !windows && !freebsd
+// +build !windows,!freebsd
+
+package rlimit
+
+import "syscall"
+
+func get() (cur, max uint64, err error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, 0, err
+	}
+	return uint64(rl.Cur), uint64(rl.Max), nil
+}
+
+func set(cur, max uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: cur, Max: max})
+}
@@ -0,0 +1,10 @@
+package rlimit
+
+// no per-process socket limit to raise on windows
+func get() (cur, max uint64, err error) {
+	return 0, 0, ErrNotSupported
+}
+
+func set(cur, max uint64) error {
+	return ErrNotSupported
+}
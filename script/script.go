@@ -0,0 +1,156 @@
+// Package script runs a Lua script on each generated packet (--script).
+//
+// The script must define a mutate function, called before each send with
+// the packet attributes and the generated cdr:
+//
+//	function mutate(attrs, cdr)
+//	  -- attrs is keyed by attribute id, values are the raw attribute
+//	  -- bytes (a table of them when the attribute repeats)
+//	  attrs[113] = "normal"     -- Sip-End-Reason
+//	  attrs[112] = nil          -- remove Sip-Dst-Number
+//	  if cdr.ResponseCode ~= "200" then
+//	    attrs[116] = int(0)     -- 4 bytes integer Sip-Call-Duration
+//	  end
+//	  return true              -- false to skip the packet
+//	end
+package script
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/routecall/go-radius-gen-acct/cdr"
+	"github.com/routecall/go-radius-gen-acct/gen"
+	"github.com/yuin/gopher-lua"
+	"layeh.com/radius"
+)
+
+// name of the function the script must define
+const Func = "mutate"
+
+// a loaded script, a lua state can't be shared so the calls are serialized
+type Script struct {
+	mu sync.Mutex
+	L  *lua.LState
+	fn *lua.LFunction
+}
+
+func Load(path string) (*Script, error) {
+	L := lua.NewState()
+	L.SetGlobal("int", L.NewFunction(luaInt))
+	L.SetGlobal("toint", L.NewFunction(luaToInt))
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("script %s: %v", path, err)
+	}
+	fn, ok := L.GetGlobal(Func).(*lua.LFunction)
+	if !ok {
+		L.Close()
+		return nil, fmt.Errorf("script %s: function %s not defined", path, Func)
+	}
+	return &Script{L: L, fn: fn}, nil
+}
+
+func (s *Script) Close() {
+	s.L.Close()
+}
+
+// gen.Hook calling the script mutate function
+func (s *Script) Hook(packet *radius.Packet, c *cdr.CdrValues) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	L := s.L
+	attrs := attrsTable(L, packet.Attributes)
+	err := L.CallByParam(lua.P{Fn: s.fn, NRet: 1, Protect: true}, attrs, cdrTable(L, c))
+	if err != nil {
+		return fmt.Errorf("script: %v", err)
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+	if ret == lua.LFalse {
+		return gen.ErrSkip
+	}
+	a, err := tableAttrs(attrs)
+	if err != nil {
+		return fmt.Errorf("script: %v", err)
+	}
+	packet.Attributes = a
+	return nil
+}
+
+func attrsTable(L *lua.LState, attrs radius.Attributes) *lua.LTable {
+	t := L.NewTable()
+	for typ, values := range attrs {
+		if len(values) == 1 {
+			t.RawSetInt(int(typ), lua.LString(values[0]))
+			continue
+		}
+		list := L.NewTable()
+		for _, v := range values {
+			list.Append(lua.LString(v))
+		}
+		t.RawSetInt(int(typ), list)
+	}
+	return t
+}
+
+func tableAttrs(t *lua.LTable) (radius.Attributes, error) {
+	attrs := make(radius.Attributes)
+	var err error
+	t.ForEach(func(k, v lua.LValue) {
+		id, ok := k.(lua.LNumber)
+		if !ok || id < 1 || id > 255 || id != lua.LNumber(int(id)) {
+			err = fmt.Errorf("invalid attribute id %v", k)
+			return
+		}
+		typ := radius.Type(id)
+		switch v := v.(type) {
+		case lua.LString:
+			attrs[typ] = append(attrs[typ], radius.Attribute(v))
+		case *lua.LTable:
+			v.ForEach(func(_, item lua.LValue) {
+				attrs[typ] = append(attrs[typ], radius.Attribute(lua.LVAsString(item)))
+			})
+		default:
+			err = fmt.Errorf("attribute %d must be a string or a table of strings", typ)
+		}
+	})
+	return attrs, err
+}
+
+func cdrTable(L *lua.LState, c *cdr.CdrValues) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("AcctStatusType", lua.LNumber(c.AcctStatusType))
+	t.RawSetString("ServiceType", lua.LNumber(c.ServiceType))
+	t.RawSetString("ResponseCode", lua.LString(c.ResponseCode))
+	t.RawSetString("Method", lua.LString(c.Method))
+	t.RawSetString("EventTimestamp", lua.LNumber(c.EventTimestamp.Unix()))
+	t.RawSetString("FromTag", lua.LString(c.FromTag))
+	t.RawSetString("ToTag", lua.LString(c.ToTag))
+	t.RawSetString("AcctSessionId", lua.LString(c.AcctSessionId))
+	t.RawSetString("MsDuration", lua.LNumber(c.MsDuration))
+	t.RawSetString("SetupTime", lua.LNumber(c.SetupTime))
+	t.RawSetString("CallerId", lua.LString(c.CallerId))
+	t.RawSetString("CalleeId", lua.LString(c.CalleeId))
+	t.RawSetString("DstNumber", lua.LString(c.DstNumber))
+	return t
+}
+
+// int(n), 4 bytes big endian value of an integer attribute
+func luaInt(L *lua.LState) int {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(L.CheckInt64(1)))
+	L.Push(lua.LString(b))
+	return 1
+}
+
+// toint(s), integer value of a 4 bytes attribute
+func luaToInt(L *lua.LState) int {
+	s := L.CheckString(1)
+	if len(s) != 4 {
+		L.ArgError(1, "integer attribute must have 4 bytes")
+	}
+	L.Push(lua.LNumber(binary.BigEndian.Uint32([]byte(s))))
+	return 1
+}
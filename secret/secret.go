@@ -0,0 +1,136 @@
+// Package secret fetches the shared secret from an external secret store
+// (--key-from), keeping it out of argv, the environment and the disk.
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// field of the secret when the reference has none
+const DefaultField = "key"
+
+// fetch the secret of ref:
+//
+//	vault://<api path>[#field]      HashiCorp Vault KV (v1 or v2, e.g.
+//	                                vault://secret/data/radius#key), with
+//	                                VAULT_ADDR and VAULT_TOKEN or ~/.vault-token
+//	aws-sm://<secret id>[#field]    AWS Secrets Manager through the aws
+//	                                command, a JSON secret needs the field
+func Fetch(ref string) (string, error) {
+	var v string
+	var err error
+	switch {
+	case strings.HasPrefix(ref, "vault://"):
+		path, field := split(strings.TrimPrefix(ref, "vault://"))
+		if len(field) <= 0 {
+			field = DefaultField
+		}
+		v, err = vault(path, field)
+	case strings.HasPrefix(ref, "aws-sm://"):
+		id, field := split(strings.TrimPrefix(ref, "aws-sm://"))
+		v, err = awsSecretsManager(id, field)
+	default:
+		return "", fmt.Errorf("%s: unknown secret store, use vault:// or aws-sm://", ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(v) <= 0 {
+		return "", fmt.Errorf("%s: empty secret", ref)
+	}
+	return v, nil
+}
+
+func split(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+func vaultToken() (string, error) {
+	if t := os.Getenv("VAULT_TOKEN"); len(t) > 0 {
+		return t, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(os.Getenv("HOME"), ".vault-token"))
+	if err != nil {
+		return "", errors.New("vault: VAULT_TOKEN not set and no ~/.vault-token")
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func vault(path, field string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if len(addr) <= 0 {
+		addr = "http://127.0.0.1:8200"
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s: %s", path, resp.Status)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: %s: %v", path, err)
+	}
+	data := body.Data
+	// KV v2 nests the secret under data.data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, meta := data["metadata"]; meta {
+			data = inner
+		}
+	}
+	v, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault: %s: no string field %s", path, field)
+	}
+	return v, nil
+}
+
+func awsSecretsManager(id, field string) (string, error) {
+	cmd := exec.Command("aws", "secretsmanager", "get-secret-value", "--secret-id", id, "--query", "SecretString", "--output", "text")
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("aws-sm: %s: %s", id, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("aws-sm: %v", err)
+	}
+	v := strings.TrimRight(string(out), "\r\n")
+	if len(field) <= 0 {
+		return v, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(v), &fields); err != nil {
+		return "", fmt.Errorf("aws-sm: %s: not a JSON secret for field %s", id, field)
+	}
+	s, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("aws-sm: %s: no string field %s", id, field)
+	}
+	return s, nil
+}
@@ -0,0 +1,154 @@
+// Package shadow compares the answers of a primary and a shadow server to
+// the same requests (--shadow), e.g. the old and the new AAA during an
+// accounting backend migration.
+package shadow
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// why a record diverged
+const (
+	ResultDiff  = "result"
+	CodeDiff    = "code"
+	LatencyDiff = "latency"
+)
+
+var header = []string{"sent", "acct_session_id", "reason", "primary_result", "primary_code", "primary_ms", "shadow_result", "shadow_code", "shadow_ms"}
+
+// answer of a server to a request
+type Result struct {
+	// export results (ok, timeout, error)
+	Result string
+	// zero without a response
+	Code    int
+	Latency time.Duration
+}
+
+// per server totals of the compared requests
+type Side struct {
+	Acked   uint64
+	Latency time.Duration
+}
+
+func (s Side) AvgLatency() time.Duration {
+	if s.Acked <= 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Acked)
+}
+
+// compares the answers, writing the diverging records to a CSV report;
+// safe for concurrent use by the sending goroutines
+type Comparer struct {
+	// latencies further apart than this diverge, zero to not compare them
+	MaxLatencyDiff time.Duration
+
+	mu       sync.Mutex
+	f        *os.File
+	w        *csv.Writer
+	compared uint64
+	diverged map[string]uint64
+	primary  Side
+	shadow   Side
+}
+
+// comparer writing the divergences to path, none with an empty path
+func Create(path string, maxLatencyDiff time.Duration) (*Comparer, error) {
+	c := &Comparer{MaxLatencyDiff: maxLatencyDiff, diverged: make(map[string]uint64)}
+	if len(path) > 0 {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		c.f, c.w = f, csv.NewWriter(f)
+		c.w.Write(header)
+	}
+	return c, nil
+}
+
+// why p and s diverge, empty when they don't
+func (c *Comparer) reason(p, s Result) string {
+	switch {
+	case p.Result != s.Result:
+		return ResultDiff
+	case p.Code != s.Code:
+		return CodeDiff
+	case c.MaxLatencyDiff > 0 && p.Code > 0:
+		d := p.Latency - s.Latency
+		if d < 0 {
+			d = -d
+		}
+		if d > c.MaxLatencyDiff {
+			return LatencyDiff
+		}
+	}
+	return ""
+}
+
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds()*1000, 'f', 3, 64)
+}
+
+// compare the answers to the request of sessionId sent at sent
+func (c *Comparer) Compare(sent time.Time, sessionId string, p, s Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.compared++
+	for _, side := range []struct {
+		r   Result
+		tot *Side
+	}{{p, &c.primary}, {s, &c.shadow}} {
+		if side.r.Code > 0 {
+			side.tot.Acked++
+			side.tot.Latency += side.r.Latency
+		}
+	}
+	reason := c.reason(p, s)
+	if len(reason) <= 0 {
+		return nil
+	}
+	c.diverged[reason]++
+	if c.w == nil {
+		return nil
+	}
+	return c.w.Write([]string{sent.UTC().Format(time.RFC3339Nano), sessionId, reason,
+		p.Result, strconv.Itoa(p.Code), ms(p.Latency), s.Result, strconv.Itoa(s.Code), ms(s.Latency)})
+}
+
+func (c *Comparer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.w == nil {
+		return nil
+	}
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// print the comparison totals
+func (c *Comparer) Fprint(w io.Writer, primary, shadow string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var diverged uint64
+	for _, n := range c.diverged {
+		diverged += n
+	}
+	fmt.Fprintf(w, "shadow comparison of %d requests, %d diverged", c.compared, diverged)
+	if diverged > 0 {
+		fmt.Fprintf(w, " (result %d, code %d, latency %d)", c.diverged[ResultDiff], c.diverged[CodeDiff], c.diverged[LatencyDiff])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  primary %s: %d answered, avg latency %s\n", primary, c.primary.Acked, c.primary.AvgLatency())
+	fmt.Fprintf(w, "  shadow  %s: %d answered, avg latency %s\n", shadow, c.shadow.Acked, c.shadow.AvgLatency())
+}
@@ -0,0 +1,70 @@
+// Package sockbuf sizes the receive and send buffers of the UDP sockets
+// (--rcvbuf, --sndbuf); at tens of thousands of requests per second the
+// default ones drop responses, which shows up as server loss.
+package sockbuf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+var ErrNotSupported = errors.New("sockbuf: not supported on this platform")
+
+// net.Dialer Control setting the buffers in bytes, zero keeps the system
+// default; nil when both are zero
+func Control(rcv, snd int) func(network, address string, c syscall.RawConn) error {
+	if rcv <= 0 && snd <= 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		cerr := c.Control(func(fd uintptr) {
+			if rcv > 0 {
+				err = set(fd, syscall.SO_RCVBUF, rcv)
+			}
+			if err == nil && snd > 0 {
+				err = set(fd, syscall.SO_SNDBUF, snd)
+			}
+		})
+		if cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
+
+// buffers a socket gets asking for rcv and snd, less than asked when the
+// kernel clamps them (e.g. to net.core.rmem_max and wmem_max on linux);
+// ErrNotSupported where they can't be read back
+func Check(rcv, snd int) (int, int, error) {
+	lc := net.ListenConfig{Control: Control(rcv, snd)}
+	conn, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	rc, err := conn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+	var gotRcv, gotSnd int
+	cerr := rc.Control(func(fd uintptr) {
+		if gotRcv, err = get(fd, syscall.SO_RCVBUF); err == nil {
+			gotSnd, err = get(fd, syscall.SO_SNDBUF)
+		}
+	})
+	if cerr != nil {
+		return 0, 0, cerr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if runtime.GOOS == "linux" {
+		// linux doubles the size asked for its bookkeeping
+		gotRcv, gotSnd = gotRcv/2, gotSnd/2
+	}
+	return gotRcv, gotSnd, nil
+}
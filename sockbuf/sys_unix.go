@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package sockbuf
+
+import "syscall"
+
+func set(fd uintptr, opt, v int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, opt, v)
+}
+
+func get(fd uintptr, opt int) (int, error) {
+	return syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, opt)
+}
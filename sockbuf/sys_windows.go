@@ -0,0 +1,12 @@
+package sockbuf
+
+import "syscall"
+
+func set(fd uintptr, opt, v int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, opt, v)
+}
+
+// no getsockopt on the windows syscall package
+func get(fd uintptr, opt int) (int, error) {
+	return 0, ErrNotSupported
+}
@@ -0,0 +1,249 @@
+// Package summary prints the end-of-run summary of the acct command on the
+// console (--summary): a table of the run, its targets and objectives,
+// colored by the pass and fail thresholds, with a sparkline of the rate
+// over the run; the machine-readable report stays the same.
+package summary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/control"
+)
+
+// when the summary is printed (--summary): on a terminal, always or never
+const (
+	Auto   = "auto"
+	Always = "always"
+	Never  = "never"
+)
+
+// answered share of the requests of a passing run and of one worth a look,
+// a run below fails
+const (
+	PassAnswered = 0.999
+	WarnAnswered = 0.99
+)
+
+// most points of the sparkline, a longer run folds its seconds two by two
+const maxPoints = 60
+
+// ANSI colors of the values
+const (
+	green  = "32"
+	yellow = "33"
+	red    = "31"
+	bold   = "1"
+)
+
+// true when f is a terminal, where the summary goes without --summary
+// always
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// true when the summary on f is colored: a terminal and no NO_COLOR set
+// (no-color.org)
+func Color(f *os.File) bool {
+	return IsTerminal(f) && len(os.Getenv("NO_COLOR")) <= 0
+}
+
+// rate of the run by second, safe for concurrent use; past maxPoints the
+// points fold two by two, so a long run keeps to a line
+type Series struct {
+	mu     sync.Mutex
+	points []float64
+	// seconds per point, and the rates summed toward the next one
+	span int
+	sum  float64
+	n    int
+}
+
+// add the rate of the last second
+func (s *Series) Add(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.span <= 0 {
+		s.span = 1
+	}
+	s.sum += rate
+	s.n++
+	if s.n < s.span {
+		return
+	}
+	s.points = append(s.points, s.sum/float64(s.n))
+	s.sum, s.n = 0, 0
+	if len(s.points) <= maxPoints {
+		return
+	}
+	folded := make([]float64, 0, maxPoints)
+	for i := 0; i+1 < len(s.points); i += 2 {
+		folded = append(folded, (s.points[i]+s.points[i+1])/2)
+	}
+	if len(s.points)%2 != 0 {
+		// half of the next point
+		s.sum, s.n = s.points[len(s.points)-1]*float64(s.span), s.span
+	}
+	s.points = folded
+	s.span *= 2
+}
+
+// the rates, a point per span seconds
+func (s *Series) Points() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]float64(nil), s.points...)
+}
+
+var ticks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline of the points, scaled to the highest
+func Sparkline(points []float64) string {
+	var max float64
+	for _, p := range points {
+		if p > max {
+			max = p
+		}
+	}
+	var b strings.Builder
+	for _, p := range points {
+		i := 0
+		if max > 0 {
+			i = int(p/max*float64(len(ticks)-1) + 0.5)
+		}
+		b.WriteRune(ticks[i])
+	}
+	return b.String()
+}
+
+type printer struct {
+	w     io.Writer
+	color bool
+}
+
+// s in the ANSI color code, as is without colors
+func (p printer) paint(code, s string) string {
+	if !p.color || len(code) <= 0 {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func (p printer) row(label, value, code string) {
+	fmt.Fprintf(p.w, "  %-14s %s\n", label, p.paint(code, value))
+}
+
+// color of an answered share
+func answeredColor(answered, total uint64) string {
+	if total <= 0 {
+		return ""
+	}
+	switch share := float64(answered) / float64(total); {
+	case share >= PassAnswered:
+		return green
+	case share >= WarnAnswered:
+		return yellow
+	}
+	return red
+}
+
+func percent(n, total uint64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// print the summary of the run stats s, rates its rate by second (see
+// Series) in a sparkline
+func Fprint(w io.Writer, s control.Stats, rates []float64, color bool) {
+	p := printer{w: w, color: color}
+	var answered uint64
+	var latency float64
+	for _, t := range s.Targets {
+		answered += t.Acked
+		latency += t.AvgLatencyMs * float64(t.Acked)
+	}
+	if answered > 0 {
+		latency /= float64(answered)
+	}
+	elapsed := time.Duration(s.Elapsed * float64(time.Second)).Round(time.Millisecond)
+	var mean float64
+	if s.Elapsed > 0 {
+		mean = float64(s.Total) / s.Elapsed
+	}
+
+	fmt.Fprintln(w, p.paint(bold, "run summary"))
+	p.row("elapsed", elapsed.String(), "")
+	p.row("requests", fmt.Sprintf("%d (%.1f pps)", s.Total, mean), "")
+	p.row("answered", fmt.Sprintf("%d (%.2f%%)", answered, percent(answered, s.Total)), answeredColor(answered, s.Total))
+	p.row("avg latency", fmt.Sprintf("%.2f ms", latency), "")
+	if len(rates) > 0 {
+		var max float64
+		for _, r := range rates {
+			if r > max {
+				max = r
+			}
+		}
+		p.row("rate", fmt.Sprintf("%s max %.0f pps", Sparkline(rates), max), "")
+	}
+	if s.Shed > 0 {
+		p.row("shed", fmt.Sprint(s.Shed), yellow)
+	}
+	var rejected uint64
+	for _, n := range s.Rejects {
+		rejected += n
+	}
+	if rejected > 0 {
+		p.row("rejected", fmt.Sprint(rejected), yellow)
+	}
+	if s.ExpectFailed > 0 {
+		p.row("expect failed", fmt.Sprint(s.ExpectFailed), red)
+	}
+	if len(s.Errors) > 0 {
+		var n uint64
+		for _, e := range s.Errors {
+			n += e.Count
+		}
+		p.row("errors", fmt.Sprintf("%d (%d distinct, most frequent: %s)", n, len(s.Errors), s.Errors[0].Error), red)
+	}
+
+	if len(s.Targets) > 0 {
+		width := len("target")
+		for _, t := range s.Targets {
+			if len(t.Addr) > width {
+				width = len(t.Addr)
+			}
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  %-*s %10s %10s %8s %10s\n", width, "target", "sent", "answered", "share", "avg ms")
+		for _, t := range s.Targets {
+			line := fmt.Sprintf("%-*s %10d %10d %7.2f%% %10.2f", width, t.Addr, t.Sent, t.Acked, percent(t.Acked, t.Sent), t.AvgLatencyMs)
+			fmt.Fprintln(w, " ", p.paint(answeredColor(t.Acked, t.Sent), line))
+		}
+	}
+
+	if len(s.SLOs) > 0 {
+		width := len("slo")
+		for _, o := range s.SLOs {
+			if len(o.SLO) > width {
+				width = len(o.SLO)
+			}
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  %-*s %10s %10s %8s\n", width, "slo", "good", "bad", "burn")
+		for _, o := range s.SLOs {
+			code := green
+			if o.BurnRate > 1 {
+				code = red
+			}
+			line := fmt.Sprintf("%-*s %10d %10d %8.2f", width, o.SLO, o.Good, o.Bad, o.BurnRate)
+			fmt.Fprintln(w, " ", p.paint(code, line))
+		}
+	}
+}
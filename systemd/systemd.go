@@ -0,0 +1,133 @@
+// Package systemd implements the sd_notify readiness/watchdog protocol and
+// socket activation, without linking libsystemd.
+//
+// Example unit running the generator in the foreground (no --daemon, the
+// go-daemon fork would need NotifyAccess=all):
+//
+//	[Service]
+//	Type=notify
+//	WatchdogSec=30
+//	ExecStart=/usr/local/bin/go-radius-gen-acct acct --api systemd ...
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --api/--grpc value taking the next socket passed by systemd
+const Activated = "systemd"
+
+// first fd passed by systemd, SD_LISTEN_FDS_START
+const listenFdsStart = 3
+
+// send state (e.g. "READY=1") to the service manager, false when not
+// started by systemd with Type=notify
+func Notify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if len(name) <= 0 {
+		return false, nil
+	}
+	if name[0] == '@' {
+		// abstract socket
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// interval the service manager expects watchdog pings within, zero when
+// the watchdog isn't enabled for this process
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); len(pid) > 0 && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// ping the watchdog at half its interval until done is closed
+func Watchdog(done <-chan struct{}) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	tick := time.NewTicker(interval / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			Notify("WATCHDOG=1")
+		}
+	}
+}
+
+var (
+	once      sync.Once
+	listeners []net.Listener
+	listenErr error
+)
+
+// sockets passed by systemd socket activation, in the order of the
+// ListenStream= lines of the .socket unit
+func Listeners() ([]net.Listener, error) {
+	once.Do(func() {
+		if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		// not inherited by children (go-daemon)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+			f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+			l, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				listenErr = fmt.Errorf("systemd: fd %d: %v", fd, err)
+				return
+			}
+			listeners = append(listeners, l)
+		}
+	})
+	return listeners, listenErr
+}
+
+var next int
+
+// listen on addr, or take the next activated socket when addr is
+// Activated
+func Listen(addr string) (net.Listener, error) {
+	if addr != Activated {
+		return net.Listen("tcp", addr)
+	}
+	ls, err := Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if next >= len(ls) {
+		return nil, fmt.Errorf("systemd: no activated socket left for %s", addr)
+	}
+	next++
+	return ls[next-1], nil
+}
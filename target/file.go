@@ -0,0 +1,65 @@
+package target
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// entry of the --targets-file
+type FileTarget struct {
+	Server    string `yaml:"server"`
+	Secret    string `yaml:"secret"`
+	Weight    int    `yaml:"weight"`
+	Priority  int    `yaml:"priority"`
+	Transport string `yaml:"transport"`
+}
+
+// --targets-file format
+//
+//	targets:
+//	  - server: 10.0.0.1:1813
+//	    secret: s3cr3t
+//	    weight: 3
+//	    priority: 0
+//	    transport: udp # tcp, or tls for RadSec
+type File struct {
+	Targets []FileTarget `yaml:"targets"`
+}
+
+// read the targets of a --targets-file, port is used for servers without one
+func LoadFile(name string, port string) ([]*Target, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	targets := make([]*Target, 0, len(f.Targets))
+	for _, ft := range f.Targets {
+		t, err := Parse(ft.Server, port)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(ft.Secret) > 0 {
+			t.Secret = []byte(ft.Secret)
+		}
+		if ft.Weight < 0 {
+			return nil, fmt.Errorf("%s: weight must be greater 0 on %s", name, ft.Server)
+		}
+		if ft.Weight > 0 {
+			t.Weight = ft.Weight
+		}
+		t.Priority = ft.Priority
+		if len(ft.Transport) > 0 {
+			if t.Transport, err = ParseTransport(ft.Transport); err != nil {
+				return nil, fmt.Errorf("%s: %v on %s", name, err, ft.Server)
+			}
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
@@ -0,0 +1,131 @@
+package target
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// a realm of the --realms-file, its users go to its own servers
+type Realm struct {
+	Name string
+	// share of the generated users relative to the other realms
+	Weight int
+	Pool   *Pool
+}
+
+// entry of the --realms-file
+type FileRealm struct {
+	Realm   string   `yaml:"realm"`
+	Weight  int      `yaml:"weight"`
+	Secret  string   `yaml:"secret"`
+	Policy  string   `yaml:"policy"`
+	Servers []string `yaml:"servers"`
+}
+
+// --realms-file format, the servers as --server (host[:port[:secret]]
+// [;w=weight][;key=secret]) with the realm secret as default
+//
+//	realms:
+//	  - realm: example.com
+//	    weight: 3
+//	    secret: s3cr3t
+//	    policy: failover
+//	    servers:
+//	      - 10.0.0.1:1813
+//	      - 10.0.0.2:1813
+type RealmsFile struct {
+	Realms []FileRealm `yaml:"realms"`
+}
+
+// read the realms of a --realms-file, port is used for servers without
+// one and policy for realms without their own; a server shared by realms
+// must have the same secret on all of them
+func LoadRealms(name, port, policy string) ([]*Realm, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f RealmsFile
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(f.Realms) <= 0 {
+		return nil, fmt.Errorf("%s: no realms", name)
+	}
+	shared := make(map[string]*Target)
+	realms := make([]*Realm, 0, len(f.Realms))
+	for _, fr := range f.Realms {
+		if len(fr.Realm) <= 0 {
+			return nil, fmt.Errorf("%s: realm without name", name)
+		}
+		if fr.Weight < 0 {
+			return nil, fmt.Errorf("%s: weight must be greater 0 on realm %s", name, fr.Realm)
+		}
+		targets, err := ParseList(fr.Servers, port)
+		if err != nil {
+			return nil, fmt.Errorf("%s: realm %s: %v", name, fr.Realm, err)
+		}
+		for i, t := range targets {
+			if t.Secret == nil && len(fr.Secret) > 0 {
+				t.Secret = []byte(fr.Secret)
+			}
+			if s, ok := shared[t.Addr]; ok {
+				if !bytes.Equal(s.Secret, t.Secret) {
+					return nil, fmt.Errorf("%s: server %s has different secrets on the realms", name, t.Addr)
+				}
+				// one target, its counters add up the realms
+				targets[i] = s
+				continue
+			}
+			shared[t.Addr] = t
+		}
+		p := policy
+		if len(fr.Policy) > 0 {
+			p = fr.Policy
+		}
+		pool, err := NewPool(targets, p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: realm %s: %v", name, fr.Realm, err)
+		}
+		r := &Realm{Name: fr.Realm, Weight: 1, Pool: pool}
+		if fr.Weight > 0 {
+			r.Weight = fr.Weight
+		}
+		realms = append(realms, r)
+	}
+	return realms, nil
+}
+
+// realm of a new user, drawn according the weights
+func PickRealm(realms []*Realm) *Realm {
+	total := 0
+	for _, r := range realms {
+		total += r.Weight
+	}
+	n := rand.Intn(total)
+	for _, r := range realms {
+		if n -= r.Weight; n < 0 {
+			return r
+		}
+	}
+	return realms[len(realms)-1]
+}
+
+// every target of the realms, once each
+func RealmTargets(realms []*Realm) []*Target {
+	var all []*Target
+	seen := make(map[*Target]bool)
+	for _, r := range realms {
+		for _, t := range r.Pool.Targets() {
+			if !seen[t] {
+				seen[t] = true
+				all = append(all, t)
+			}
+		}
+	}
+	return all
+}
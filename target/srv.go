@@ -0,0 +1,29 @@
+package target
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// discover the targets from the DNS SRV records of name
+// (e.g. _radius-acct._udp.example.com), ordered by priority
+func LookupSRV(name string) ([]*Target, error) {
+	_, addrs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*Target, 0, len(addrs))
+	for _, a := range addrs {
+		w := int(a.Weight)
+		if w <= 0 {
+			w = 1
+		}
+		targets = append(targets, &Target{
+			Addr:     net.JoinHostPort(strings.TrimSuffix(a.Target, "."), strconv.Itoa(int(a.Port))),
+			Weight:   w,
+			Priority: int(a.Priority),
+		})
+	}
+	return targets, nil
+}
@@ -0,0 +1,388 @@
+package target
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/icmp"
+)
+
+// distribution policies accepted by NewPool (--policy)
+const (
+	RoundRobin = "round-robin"
+	Failover   = "failover"
+	Sticky     = "sticky"
+	// the target answering the fastest, its recent round-trip time times
+	// its outstanding requests plus one so a burst spreads to the others
+	LeastLatency = "least-latency"
+	// the target with the fewest requests waiting for a response
+	LeastOutstanding = "least-outstanding"
+)
+
+// weight of a new round-trip time in the recent one of a target, 1/8 as
+// the smoothed RTT of TCP
+const rttGain = 8
+
+// transports of a target, UDP when none is given
+const (
+	UDP = "udp"
+	// RADIUS over TCP, RFC 6613
+	TCP = "tcp"
+	// RADIUS over TLS (RadSec), RFC 6614
+	TLS = "tls"
+)
+
+// shared secret of the TLS targets without their own, RFC 6614 2.3
+const RadSecSecret = "radsec"
+
+// a RADIUS accounting server the requests are sent to
+type Target struct {
+	Addr string
+	// share of the traffic relative to the other targets
+	Weight int
+	// targets with the lowest priority get the traffic, the others are
+	// only used on failover
+	Priority int
+	// shared secret of this target, nil to use the default one (--key)
+	Secret []byte
+	// UDP, TCP or TLS, empty for UDP
+	Transport string
+	// count of accounting-requests sent to this target
+	Sent uint64
+	// count of accounting-responses received from this target
+	Acked uint64
+	// sum of the round-trip time of the acked requests, in nanoseconds
+	Latency uint64
+	// requests waiting for a response
+	Outstanding int64
+	// smoothed round-trip time of the recent requests, in nanoseconds,
+	// zero before the first one is done
+	rtt uint64
+	// responses without the Proxy-State sent on the request (--proxy-state)
+	ProxyStateMismatch uint64
+	// transmissions dropped before the wire (--send-loss)
+	Lost uint64
+	// transmissions after the first of the requests done, one per retry
+	// interval waited
+	Retransmits uint64
+	// ICMP errors the transmissions got back, a dead port or a rejecting
+	// firewall instead of a silent timeout
+	ICMP icmp.Counters
+}
+
+// parse "host", "host:port" or "host:port:secret", using port when none
+// is given, followed by options separated by ";" (w=N weight, key=secret,
+// transport=udp|tcp|tls); an IPv6 host with a port is bracketed, and a
+// secret with a ':' is given with key=
+func Parse(s string, port string) (*Target, error) {
+	opts := strings.Split(strings.TrimSpace(s), ";")
+	s = strings.TrimSpace(opts[0])
+	var secret []byte
+	host, p, err := net.SplitHostPort(s)
+	if err != nil && net.ParseIP(s) == nil {
+		// "host:port:secret", the secret is after the last colon, only
+		// with a numeric port so a bare IPv6 address stays the host
+		if i := strings.LastIndex(s, ":"); i > 0 {
+			h, pp, err := net.SplitHostPort(s[:i])
+			if _, perr := strconv.ParseUint(pp, 10, 16); err == nil && perr == nil && len(h) > 0 && (strings.HasPrefix(s, "[") || !strings.Contains(h, ":")) {
+				host, p, secret = h, pp, []byte(s[i+1:])
+				s = s[:i]
+			}
+		}
+	}
+	if len(host) <= 0 {
+		// no port on the address
+		host, p = strings.Trim(s, "[]"), port
+	}
+	if len(host) <= 0 {
+		return nil, &net.AddrError{Err: "missing host", Addr: s}
+	}
+	t := &Target{Addr: net.JoinHostPort(host, p), Weight: 1, Secret: secret}
+	for _, opt := range opts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("target: invalid option %q on %s", opt, s)
+		}
+		switch kv[0] {
+		case "w", "weight":
+			w, err := strconv.Atoi(kv[1])
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("target: weight must be greater 0 on %s", s)
+			}
+			t.Weight = w
+		case "key":
+			t.Secret = []byte(kv[1])
+		case "transport":
+			tr, err := ParseTransport(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("target: %v on %s", err, s)
+			}
+			t.Transport = tr
+		default:
+			return nil, fmt.Errorf("target: unknown option %q on %s", kv[0], s)
+		}
+	}
+	return t, nil
+}
+
+// transport of its name, radsec for tls
+func ParseTransport(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", UDP:
+		return "", nil
+	case TCP:
+		return TCP, nil
+	case TLS, "radsec":
+		return TLS, nil
+	}
+	return "", fmt.Errorf("unsupported transport %q", s)
+}
+
+// true for the TCP and TLS targets, a connection carries their requests
+// and retransmits them
+func (t *Target) Stream() bool {
+	return t.Transport == TCP || t.Transport == TLS
+}
+
+// transport name of the target, udp when none was given
+func (t *Target) TransportName() string {
+	if len(t.Transport) <= 0 {
+		return UDP
+	}
+	return t.Transport
+}
+
+// parse all --server values, each one may be a comma-separated list
+func ParseList(servers []string, port string) ([]*Target, error) {
+	var targets []*Target
+	for _, server := range servers {
+		for _, s := range strings.Split(server, ",") {
+			if len(strings.TrimSpace(s)) <= 0 {
+				continue
+			}
+			t, err := Parse(s, port)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// shared secret to use with this target, the RadSec one instead of def
+// for the TLS targets
+func (t *Target) Key(def []byte) []byte {
+	if t.Secret != nil {
+		return t.Secret
+	}
+	if t.Transport == TLS {
+		return []byte(RadSecSecret)
+	}
+	return def
+}
+
+// average round-trip time of the acked requests
+func (t *Target) AvgLatency() time.Duration {
+	acked := atomic.LoadUint64(&t.Acked)
+	if acked == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadUint64(&t.Latency) / acked)
+}
+
+// a request to the target starts
+func (t *Target) Begin() {
+	atomic.AddInt64(&t.Outstanding, 1)
+}
+
+// the request started with Begin is done after rtt, the whole timeout
+// when it failed
+func (t *Target) Done(rtt time.Duration) {
+	atomic.AddInt64(&t.Outstanding, -1)
+	for {
+		old := atomic.LoadUint64(&t.rtt)
+		srtt := uint64(rtt)
+		if old > 0 {
+			srtt = old - old/rttGain + srtt/rttGain
+		}
+		if atomic.CompareAndSwapUint64(&t.rtt, old, srtt) {
+			return
+		}
+	}
+}
+
+// smoothed round-trip time of the recent requests, zero before the first
+// one is done
+func (t *Target) RTT() time.Duration {
+	return time.Duration(atomic.LoadUint64(&t.rtt))
+}
+
+// set of targets the generated packets are distributed across
+type Pool struct {
+	mu      sync.Mutex
+	policy  string
+	targets []*Target
+	// every target ever in the pool, kept for the stats after Update
+	all []*Target
+	// smooth weighted round-robin state over the lowest priority targets
+	active  []*Target
+	current []int
+	total   int
+	// first target looked at by the least policies, turning so their ties
+	// go round
+	turn int
+}
+
+func NewPool(targets []*Target, policy string) (*Pool, error) {
+	switch policy {
+	case RoundRobin, Failover, Sticky, LeastLatency, LeastOutstanding:
+	default:
+		return nil, fmt.Errorf("target: unknown policy %q", policy)
+	}
+	p := &Pool{policy: policy}
+	if err := p.Update(targets); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// replace the pool targets (e.g. on SRV refresh), targets with an address
+// and a transport already in the pool keep their counters
+func (p *Pool) Update(targets []*Target) error {
+	if len(targets) <= 0 {
+		return fmt.Errorf("target: no targets")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	merged := make([]*Target, len(targets))
+	for i, t := range targets {
+		merged[i] = t
+		for _, old := range p.all {
+			if old.Addr == t.Addr && old.Transport == t.Transport {
+				old.Weight, old.Priority, old.Secret = t.Weight, t.Priority, t.Secret
+				merged[i] = old
+				break
+			}
+		}
+		if merged[i] == t {
+			p.all = append(p.all, t)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Priority < merged[j].Priority
+	})
+	p.targets = merged
+	p.active, p.total = nil, 0
+	for _, t := range merged {
+		if t.Priority != merged[0].Priority {
+			break
+		}
+		p.active = append(p.active, t)
+		p.total += t.Weight
+	}
+	p.current = make([]int, len(p.active))
+	return nil
+}
+
+// next target according to the pool policy, key is only used by the
+// sticky policy so the same key always goes to the same target
+func (p *Pool) Next(key string) *Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.policy {
+	case Failover:
+		// always start on the primary
+		return p.targets[0]
+	case Sticky:
+		return p.rendezvous(key)
+	case LeastLatency:
+		// the targets not answered yet are tried first, they cost nothing
+		return p.least(func(t *Target) float64 {
+			return float64(t.RTT()) * float64(atomic.LoadInt64(&t.Outstanding)+1)
+		})
+	case LeastOutstanding:
+		return p.least(func(t *Target) float64 {
+			return float64(atomic.LoadInt64(&t.Outstanding))
+		})
+	}
+	// smooth weighted round-robin, spreads the heavier targets instead of
+	// sending their whole share back-to-back
+	best := 0
+	for i, t := range p.active {
+		p.current[i] += t.Weight
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= p.total
+	return p.active[best]
+}
+
+// the target with the lowest cost over its weight, ties broken by the
+// fewest outstanding requests and then in turn
+func (p *Pool) least(cost func(*Target) float64) *Target {
+	n := len(p.active)
+	p.turn = (p.turn + 1) % n
+	var best *Target
+	var bestCost, bestOutstanding float64
+	for i := 0; i < n; i++ {
+		t := p.active[(p.turn+i)%n]
+		w := float64(t.Weight)
+		c, o := cost(t)/w, float64(atomic.LoadInt64(&t.Outstanding))/w
+		if best == nil || c < bestCost || c == bestCost && o < bestOutstanding {
+			best, bestCost, bestOutstanding = t, c, o
+		}
+	}
+	return best
+}
+
+// weighted rendezvous hashing, only the keys of a removed target move
+// when the pool is updated
+func (p *Pool) rendezvous(key string) *Target {
+	var best *Target
+	bestScore := math.Inf(-1)
+	for _, t := range p.active {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte(t.Addr))
+		// uniform in (0, 1)
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		score := -float64(t.Weight) / math.Log(u)
+		if score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+	return best
+}
+
+// targets to try in order for a request that starts on t, on failover
+// policy the ones after t are the secondaries used when t times out
+func (p *Pool) Tries(t *Target) []*Target {
+	if p.policy != Failover {
+		return []*Target{t}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, tg := range p.targets {
+		if tg == t {
+			return append(p.targets[i:len(p.targets):len(p.targets)], p.targets[:i]...)
+		}
+	}
+	return []*Target{t}
+}
+
+// every target used during the run
+func (p *Pool) Targets() []*Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.all
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package upgrade
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// send the upgrade requests (SIGUSR2) on c
+func Notify(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
+
+func exec(path string, args []string, env []string) error {
+	return syscall.Exec(path, args, env)
+}
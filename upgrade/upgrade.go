@@ -0,0 +1,38 @@
+// Package upgrade replaces the running binary by the one on disk (SIGUSR2),
+// keeping the pid so the daemon pid file, systemd and the supervisors
+// still follow it: the run stops sending, hands its state over next to the
+// checkpoint and execs the new build with --resume.
+package upgrade
+
+import (
+	"errors"
+	"os"
+)
+
+var ErrNotSupported = errors.New("upgrade: not supported on this platform")
+
+// args with --resume, after the command when there is one
+func ResumeArgs(args []string, command string) []string {
+	for _, a := range args[1:] {
+		if a == "--resume" || a == "-resume" {
+			return args
+		}
+	}
+	at := 1
+	if len(args) > 1 && args[1] == command {
+		at = 2
+	}
+	out := append([]string{}, args[:at]...)
+	out = append(out, "--resume")
+	return append(out, args[at:]...)
+}
+
+// replace the process by the binary it was started from, with args; only
+// returns on error
+func Exec(args []string) error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return exec(path, args, os.Environ())
+}
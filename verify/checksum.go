@@ -0,0 +1,39 @@
+package verify
+
+import (
+	"github.com/routecall/go-radius-gen-acct/checksum"
+)
+
+// checksums of the records of a run (acct --checksum-attr) on the detail
+// file: Attr is the attribute of the checksum as named there, Key and
+// Fields the acct --checksum-key and --checksum-fields
+type Checksums struct {
+	Attr   string
+	Key    []byte
+	Fields []string
+}
+
+// attributes ReadDetail keeps for the checksums, none on nil
+func (c *Checksums) Attrs() []string {
+	if c == nil {
+		return nil
+	}
+	return append([]string{c.Attr}, c.Fields...)
+}
+
+// check the checksum of the stored records against their values, the
+// records which differ are mutated (truncated or changed by the server),
+// the ones without a checksum unchecked
+func (r *Report) CheckSums(stored []Stored, c Checksums) {
+	r.Checked = true
+	for _, s := range stored {
+		sum, ok := s.Values[c.Attr]
+		if !ok {
+			r.Unchecked++
+			continue
+		}
+		if checksum.Stored(sum) != checksum.Sum(c.Key, c.Fields, s.Values) {
+			r.Mutated = append(r.Mutated, s.AcctSessionId)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package verify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// attribute of the session id on the detail file, Sip-Acct-Session-Id of
+// ./dictionary.routecall.opensips
+const DetailAttr = "Sip-Acct-Session-Id"
+
+// layout of the line opening each detail file record
+const detailLayout = "Mon Jan _2 15:04:05 2006"
+
+// records of a FreeRADIUS detail file, the session id from the attr
+// attribute and the time from Timestamp, or the record header when
+// missing; the first value of the keep attributes goes to Values
+func ReadDetail(r io.Reader, attr string, keep ...string) ([]Stored, error) {
+	var stored []Stored
+	var cur *Stored
+	flush := func() {
+		if cur != nil && len(cur.AcctSessionId) > 0 {
+			stored = append(stored, *cur)
+		}
+		cur = nil
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if len(strings.TrimSpace(text)) == 0 {
+			flush()
+			continue
+		}
+		// attributes are indented, the header isn't
+		if text[0] != ' ' && text[0] != '\t' {
+			flush()
+			cur = &Stored{}
+			if t, err := time.ParseInLocation(detailLayout, strings.TrimSpace(text), time.Local); err == nil {
+				cur.Time = t
+			}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("detail: line %d: attribute outside a record", line)
+		}
+		kv := strings.SplitN(strings.TrimSpace(text), " = ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := kv[1]
+		if v, err := strconv.Unquote(value); err == nil {
+			value = v
+		}
+		for _, k := range keep {
+			if k != kv[0] {
+				continue
+			}
+			if cur.Values == nil {
+				cur.Values = make(map[string]string, len(keep))
+			}
+			if _, ok := cur.Values[k]; !ok {
+				cur.Values[k] = value
+			}
+		}
+		switch kv[0] {
+		case attr:
+			if len(cur.AcctSessionId) == 0 {
+				cur.AcctSessionId = value
+			}
+		case "Timestamp":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Time = time.Unix(n, 0)
+			}
+		}
+	}
+	flush()
+	return stored, sc.Err()
+}
+
+// open the detail file on src: a local path, an http(s) URL or
+// scp://[user@]host[:port]/path copied with the scp command
+func OpenDetail(src string) (io.ReadCloser, error) {
+	u, err := url.Parse(src)
+	if err != nil || len(u.Host) == 0 {
+		return os.Open(src)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		resp, err := http.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", src, resp.Status)
+		}
+		return resp.Body, nil
+	case "scp":
+		return scp(u)
+	}
+	return nil, fmt.Errorf("%s: unsupported scheme %s", src, u.Scheme)
+}
+
+// copy the remote file to a temporary one, removed on close
+func scp(u *url.URL) (io.ReadCloser, error) {
+	tmp, err := ioutil.TempFile("", "radgen-detail-")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	remote := u.Hostname() + ":" + u.Path
+	if u.User != nil {
+		remote = u.User.Username() + "@" + remote
+	}
+	args := []string{"-q", "-B"}
+	if port := u.Port(); len(port) > 0 {
+		args = append(args, "-P", port)
+	}
+	out, err := exec.Command("scp", append(args, remote, tmp.Name())...).CombinedOutput()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("scp %s: %v: %s", remote, err, strings.TrimSpace(string(out)))
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &tempFile{f}, nil
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
@@ -0,0 +1,181 @@
+// Package verify reconciles the records of a run (--export) with the
+// accounting store of the server, its database or detail file (verify
+// command).
+package verify
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/routecall/go-radius-gen-acct/export"
+
+	// accounting stores of OpenSIPS and FreeRADIUS
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// layouts of the time columns read as text (e.g. MySQL without parseTime)
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// FreeRADIUS radacct query of the session ids and their stop time
+func Query(table, sessionColumn, timeColumn string) string {
+	return fmt.Sprintf("SELECT %s, %s FROM %s", sessionColumn, timeColumn, table)
+}
+
+// stored accounting record
+type Stored struct {
+	AcctSessionId string
+	// zero when the store has no time for it
+	Time time.Time
+	// values of the attributes read for the checksum
+	Values map[string]string
+}
+
+// records of the query, it must select the session id and the record time
+// (NULL allowed) in this order
+func Fetch(db *sql.DB, query string) ([]Stored, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stored []Stored
+	for rows.Next() {
+		var id sql.NullString
+		var t interface{}
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, err
+		}
+		s := Stored{AcctSessionId: id.String}
+		if s.Time, err = parseTime(t); err != nil {
+			return nil, fmt.Errorf("session %s: %v", id.String, err)
+		}
+		stored = append(stored, s)
+	}
+	return stored, rows.Err()
+}
+
+func parseTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return t, nil
+	case int64:
+		return time.Unix(t, 0), nil
+	case []byte:
+		return parseTime(string(t))
+	case string:
+		if n, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+		for _, layout := range timeLayouts {
+			if tm, err := time.ParseInLocation(layout, t, time.Local); err == nil {
+				return tm, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time %v", v)
+}
+
+// outcome of the reconciliation, the ids are in the export order
+type Report struct {
+	// acknowledged requests of the export
+	Acked int
+	Found int
+	// acknowledged but not stored
+	Missing []string
+	// stored more than once
+	Duplicate []string
+	// stored more than Late after sent
+	Late []string
+	// requests without answer (timeout or error) stored anyway
+	Unacked int
+	// stored records which weren't emitted by the run
+	Unknown int
+	// with the checksums checked (see CheckSums): stored with another
+	// checksum than their values have, and stored without one
+	Checked   bool
+	Mutated   []string
+	Unchecked int
+}
+
+// reconcile the emitted records with the stored ones, a stored record is
+// late when its time is more than late after the request was sent
+func Reconcile(emitted []export.Record, stored []Stored, late time.Duration) *Report {
+	byId := make(map[string][]Stored, len(stored))
+	for _, s := range stored {
+		byId[s.AcctSessionId] = append(byId[s.AcctSessionId], s)
+	}
+	r := &Report{}
+	seen := make(map[string]bool, len(emitted))
+	for _, e := range emitted {
+		if seen[e.AcctSessionId] {
+			continue
+		}
+		seen[e.AcctSessionId] = true
+		found := byId[e.AcctSessionId]
+		if e.Result != export.OK {
+			if len(found) > 0 {
+				r.Unacked++
+			}
+			continue
+		}
+		r.Acked++
+		if len(found) == 0 {
+			r.Missing = append(r.Missing, e.AcctSessionId)
+			continue
+		}
+		r.Found++
+		if len(found) > 1 {
+			r.Duplicate = append(r.Duplicate, e.AcctSessionId)
+		}
+		if t := found[0].Time; !t.IsZero() && t.Sub(e.Sent) > late {
+			r.Late = append(r.Late, e.AcctSessionId)
+		}
+	}
+	for id := range byId {
+		if !seen[id] {
+			r.Unknown++
+		}
+	}
+	return r
+}
+
+// true when every acknowledged request was stored once and in time
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Duplicate) == 0 && len(r.Late) == 0 && len(r.Mutated) == 0
+}
+
+// print the summary and up to max ids of each problem
+func (r *Report) Fprint(w io.Writer, max int) {
+	fmt.Fprintf(w, "acknowledged requests: %d\n", r.Acked)
+	fmt.Fprintf(w, "found:                 %d\n", r.Found)
+	fmt.Fprintf(w, "missing:               %d\n", len(r.Missing))
+	fmt.Fprintf(w, "duplicate:             %d\n", len(r.Duplicate))
+	fmt.Fprintf(w, "late:                  %d\n", len(r.Late))
+	fmt.Fprintf(w, "unanswered but stored: %d\n", r.Unacked)
+	fmt.Fprintf(w, "not from this run:     %d\n", r.Unknown)
+	if r.Checked {
+		fmt.Fprintf(w, "mutated:               %d\n", len(r.Mutated))
+		fmt.Fprintf(w, "without checksum:      %d\n", r.Unchecked)
+	}
+	for _, l := range []struct {
+		name string
+		ids  []string
+	}{{"missing", r.Missing}, {"duplicate", r.Duplicate}, {"late", r.Late}, {"mutated", r.Mutated}} {
+		for i, id := range l.ids {
+			if i >= max {
+				fmt.Fprintf(w, "%s: ... %d more\n", l.name, len(l.ids)-max)
+				break
+			}
+			fmt.Fprintf(w, "%s: %s\n", l.name, id)
+		}
+	}
+}